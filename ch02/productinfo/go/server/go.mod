@@ -1,12 +1,22 @@
 module productinfo/server
 
-go 1.16
+go 1.21
 
 require (
 	github.com/gofrs/uuid v3.2.0+incompatible
-	github.com/golang/protobuf v1.5.2
-	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
-	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
+	github.com/golang/protobuf v1.5.3
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
 	google.golang.org/genproto v0.0.0-20220722212130-b98a9ff5e252 // indirect
 	google.golang.org/grpc v1.48.0
 )
+
+require github.com/grpc-up-and-running/samples/common/go v0.0.0
+
+require (
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../common/go
@@ -11,16 +11,14 @@ import (
 	"net"
 
 	"github.com/gofrs/uuid"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	pb "productinfo/server/ecommerce"
 )
 
-const (
-	port = ":50051"
-)
-
 // server is used to implement ecommerce/product_info.
 type server struct {
 	productMap map[string]*pb.Product
@@ -53,15 +51,27 @@ func (s *server) GetProduct(ctx context.Context, in *pb.ProductID) (*pb.Product,
 }
 
 func main() {
+	// The port can be changed with -server.addr, e.g. -server.addr :50061
+	// 端口可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051"},
+	})
 	// TCP监听器
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
 	// 创建新的grpc服务器实例
-	s := grpc.NewServer()
+	s := grpc.NewServer(admin.ServerOptions(calls)...)
 	// 将服务注册到grpc服务器上
 	pb.RegisterProductInfoServer(s, &server{})
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 	// 在指定端口开始监听传入的消息
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
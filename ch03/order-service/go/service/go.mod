@@ -1,8 +1,21 @@
 module ordermgt/service
 
-go 1.12
+go 1.21
 
 require (
-	github.com/golang/protobuf v1.3.2
-	google.golang.org/grpc v1.24.0
+	github.com/golang/protobuf v1.5.3
+	google.golang.org/grpc v1.48.0
 )
+
+require github.com/grpc-up-and-running/samples/common/go v0.0.0
+
+require (
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20200806141610-86f49bd18e98 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../common/go
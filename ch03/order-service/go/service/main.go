@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
@@ -20,7 +22,6 @@ import (
 )
 
 const (
-	port           = ":50051"
 	orderBatchSize = 3
 )
 
@@ -163,16 +164,28 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 
 
 func main() {
+	// The port can be changed with -server.addr, e.g. -server.addr :50061
+	// 端口可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051"},
+	})
 	initSampleData()
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	s := grpc.NewServer(admin.ServerOptions(calls)...)
 	// 将服务注册到服务器上
 	pb.RegisterOrderManagementServer(s, &server{})
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
@@ -4,19 +4,66 @@ import (
 	"context"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	// Registers the load balancing policies a service config from the registry may select.
+	_ "github.com/grpc-up-and-running/samples/common/go/loadbalancing"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
 	"google.golang.org/grpc"
 	"io"
 	"log"
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client:  config.Client{Target: "localhost:50051"},
+		Tracing: config.Tracing{SampleRate: 1},
+	})
+	// The spans of the calls are the parents of the server spans.
+	// 客户端 span 是服务端 span 的父 span
+	tracer, err := tracing.Setup(tracing.Options{
+		ServiceName: "order-client",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+	})
+	if err != nil {
+		log.Fatalf("invalid tracing config: %v", err)
+	}
+	defer tracer.Shutdown(context.Background())
+	// Client metrics are exported over OTLP when metrics.endpoint is set.
+	// 设置 metrics.endpoint 后客户端指标通过 OTLP 导出
+	meters, err := metrics.Setup(metrics.Options{
+		ServiceName: "order-client",
+		Endpoint:    cfg.Metrics.Endpoint,
+		Interval:    cfg.Metrics.Interval,
+	})
+	if err != nil {
+		log.Fatalf("invalid metrics config: %v", err)
+	}
+	defer meters.Shutdown(context.Background())
+	// With registry.addr, a client.target of registry:///ecommerce.OrderManagement resolves the servers from the registry.
+	// 设置 registry.addr 后，目标 registry:///ecommerce.OrderManagement 从注册中心获取服务端地址
+	withRegistry, closeRegistry, err := discovery.WithRegistry(cfg.Registry)
+	if err != nil {
+		log.Fatalf("invalid registry config: %v", err)
+	}
+	defer closeRegistry()
+	// With client.tls.ca_file the server is verified over TLS; cert_file and key_file add a client certificate.
+	// 设置 client.tls.ca_file 后通过 TLS 校验服务端证书，设置 cert_file 和 key_file 则同时出示客户端证书
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	// The registry also supplies the service config of ecommerce.OrderManagement: per-method timeouts, retries,
+	// wait-for-ready and the load balancing policy, applied to the calls below without changing this code.
+	// 注册中心同时下发服务配置（按方法的超时、重试、wait-for-ready 和负载均衡策略），客户端无需重新编译即可生效
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds), withRegistry,
+		grpc.WithChainUnaryInterceptor(meters.UnaryClientInterceptor(), tracer.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(meters.StreamClientInterceptor(), tracer.StreamClientInterceptor()))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -85,7 +132,7 @@ func main() {
 	//
 	//
 	//updateRes, _ := updateStream.CloseAndRecv()
-	//log.Printf("Update Orders Res : ", updateRes)
+	//log.Printf("Update Orders Res : %s", updateRes)
 
 	// Process Order
 	streamProcOrder, _ := client.ProcessOrders(ctx)
@@ -5,78 +5,119 @@ import (
 	"github.com/golang/protobuf/ptypes/wrappers"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/healthcheck"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"github.com/grpc-up-and-running/samples/common/go/ratelimit"
+	"github.com/grpc-up-and-running/samples/common/go/streamutil"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"io"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"strings"
+	"time"
 )
 
-const (
-	port           = ":50051"
-	orderBatchSize = 3
-)
+var orders = newOrderStore()
 
-var orderMap = make(map[string]pb.Order)
+// logger logs the order processing. Records logged with the call context
+// carry the request ID, method, peer, principal and trace ID.
+// 使用调用的 context 记录日志时会自动带上请求 ID、方法、对端、主体和追踪 ID
+var logger = slog.Default()
 
 type server struct {
-	orderMap map[string]*pb.Order
+	orders *orderStore
+	// batchSize returns the current batch size of ProcessOrders, which can
+	// change while a stream is open.
+	batchSize func() int
+	// shuttingDown is closed when the server starts shutting down.
+	shuttingDown <-chan struct{}
+	// stats records the business metrics of the orders.
+	stats *orderMetrics
 }
 
 // Simple RPC
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
+	s.orders.Put(*orderReq)
+	s.stats.orderAdded(ctx)
 
-	log.Println("Order : ",  orderReq.Id, " -> Added")
+	logger.InfoContext(ctx, "order added", "order_id", orderReq.Id)
 	return &wrapper.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
 // Simple RPC
 func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord, _ := s.orders.Get(orderId.Value)
 
-	log.Println("Get Order : ", ord.Id)
+	logger.InfoContext(ctx, "order retrieved", "order_id", ord.Id)
 	return &ord, nil
 }
 
 // Server-side Streaming RPC
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
-
-	for key, order := range orderMap {
+	ctx := stream.Context()
+	matches := 0
+	// Range stops as soon as the client cancels the call.
+	// 客户端取消调用后 Range 会立即停止遍历
+	err := s.orders.Range(ctx, func(order pb.Order) (bool, error) {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
+				matches++
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : " + key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
-				break
+				if logging.Sample(ctx, logger, slog.LevelDebug) {
+					logger.DebugContext(ctx, "matching order found, writing it to the stream", "order_id", order.Id)
+				}
+				return true, streamutil.SendContext(ctx, stream, &order)
 			}
 		}
+		return true, nil
+	})
+	s.stats.searched(ctx, matches)
+	if err != nil {
+		logger.InfoContext(ctx, "stopped searching orders", "error", err)
 	}
-
-	return nil
+	return err
 }
 
 // Client-side Streaming RPC
 func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) error {
+	ctx := stream.Context()
 
 	ordersStr := "Updated Order IDs : "
 	for {
-		order, err := stream.Recv()
+		order := new(pb.Order)
+		err := streamutil.RecvContext(ctx, stream, order)
 		if err == io.EOF {
 			// Finished reading the order stream.
 			return stream.SendAndClose(&wrapper.StringValue{Value: "Orders processed " + ordersStr})
 		}
+		if err != nil {
+			logger.InfoContext(ctx, "stopped updating orders", "error", err)
+			return err
+		}
 		// Update order
-		orderMap[order.Id] = *order
+		s.orders.Put(*order)
+		s.stats.orderUpdated(ctx)
 
-		log.Printf("Order ID ", order.Id, ": Updated")
+		logger.InfoContext(ctx, "order updated", "order_id", order.Id)
 		ordersStr += order.Id + ", "
 	}
 }
 
 // Bi-directional Streaming RPC
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
+	ctx := stream.Context()
 
 	batchMarker := 1
 
@@ -84,79 +125,264 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 	//_, cancel := context.WithCancel(stream.Context())
 	//cancel()
 
+	// Receive in a separate goroutine so the handler can also react to the
+	// server shutting down while it waits for the next order ID.
+	// 在单独的 goroutine 中接收消息，这样处理器在等待时也能感知服务器正在关闭
+	received := make(chan receivedOrderId)
+	go func() {
+		for {
+			orderId := new(wrapper.StringValue)
+			// You can determine whether the current RPC is cancelled by the other party.
+			// RecvContext returns as soon as the stream context is done,
+			// whether the client cancelled the call or its deadline expired.
+			// 可以判断当前的 RPC 是否已经被对方取消（包括取消和超出截止时间）
+			err := streamutil.RecvContext(ctx, stream, orderId)
+			select {
+			case received <- receivedOrderId{orderId: orderId, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
 
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	// receivedAt holds when the orders of the current batch were received.
+	var receivedAt []time.Time
 	for {
-		// You can determine whether the current RPC is cancelled by the other party.
-		// 可以判断当前的 RPC 是否已经被对方取消
-		if stream.Context().Err() == context.Canceled {
-			log.Printf(" Context Cacelled for this stream: -> %s", stream.Context().Err())
-			log.Printf("Stopped processing any more order of this stream!")
-			return stream.Context().Err()
+		var r receivedOrderId
+		select {
+		case r = <-received:
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "context cancelled, stopped processing the orders of this stream", "error", ctx.Err())
+			return streamutil.ContextErr(ctx)
+		case <-s.shuttingDown:
+			// Finish the current batch, then ask the client to send the
+			// remaining orders to another server.
+			// 服务器正在关闭：先发送当前批次，再让客户端把剩余订单发往其他服务器
+			logger.InfoContext(ctx, "server is shutting down, shipping the current batch", "destinations", len(combinedShipmentMap))
+			if err := s.shipBatch(ctx, stream, combinedShipmentMap); err != nil {
+				return err
+			}
+			s.stats.batchShipped(ctx, batchShutdown, s.batchSize(), receivedAt)
+			return status.Error(codes.Unavailable, "server is shutting down, send the remaining orders again")
 		}
-		orderId, err := stream.Recv()
-		log.Println("Reading Proc order ... ", orderId)
-
-
-
 
+		orderId, err := r.orderId, r.err
 		if err == io.EOF {
 			// Client has sent all the messages
 			// Send remaining shipments
-			log.Println("EOF for ", orderId)
-
-			for _, comb := range combinedShipmentMap {
-				stream.Send(&comb)
+			logger.DebugContext(ctx, "client sent all orders")
+			if err := s.shipBatch(ctx, stream, combinedShipmentMap); err != nil {
+				return err
 			}
+			s.stats.batchShipped(ctx, batchStreamEnd, s.batchSize(), receivedAt)
 			return nil
 		}
-		destination := orderMap[orderId.GetValue()].Destination
+		if err != nil {
+			logger.InfoContext(ctx, "stopped processing the orders of this stream", "error", err)
+			return err
+		}
+		if logging.Sample(ctx, logger, slog.LevelDebug) {
+			logger.DebugContext(ctx, "processing order", "order_id", orderId.GetValue())
+		}
+
+		ord, known := s.orders.Get(orderId.GetValue())
+		s.stats.orderReceived(ctx, known)
+		receivedAt = append(receivedAt, time.Now())
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!", }
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + destination, Status: "Processed!", }
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
-			log.Print(len(comShip.OrdersList), comShip.GetId())
+			logger.DebugContext(ctx, "new combined shipment", "shipment_id", comShip.GetId(), "orders", len(comShip.OrdersList))
 		}
 
-		if batchMarker == orderBatchSize {
-			for _, comb := range combinedShipmentMap {
-				log.Print("Shipping : " , comb.Id, " -> ", len(comb.OrdersList))
-				stream.Send(&comb)
+		// A new batch size applies from the next order on.
+		// 新的批次大小从下一个订单开始生效
+		if batchSize := s.batchSize(); batchMarker >= batchSize {
+			if err := s.shipBatch(ctx, stream, combinedShipmentMap); err != nil {
+				logger.InfoContext(ctx, "stopped processing the orders of this stream", "error", err)
+				return err
 			}
+			s.stats.batchShipped(ctx, batchFull, batchSize, receivedAt)
 			batchMarker = 0
 			combinedShipmentMap = make(map[string]pb.CombinedShipment)
+			receivedAt = nil
 		} else {
 			batchMarker++
 		}
 	}
 }
 
+type receivedOrderId struct {
+	orderId *wrapper.StringValue
+	err     error
+}
+
+// shipBatch sends the combined shipments of the current batch.
+func (s *server) shipBatch(ctx context.Context, stream pb.OrderManagement_ProcessOrdersServer, combinedShipmentMap map[string]pb.CombinedShipment) error {
+	for destination, comb := range combinedShipmentMap {
+		logger.InfoContext(ctx, "shipping", "shipment_id", comb.Id, "orders", len(comb.OrdersList))
+		if err := streamutil.SendContext(ctx, stream, &comb); err != nil {
+			return err
+		}
+		s.stats.shipmentSent(ctx, destination)
+	}
+	return nil
+}
+
 func main() {
+	holder := config.MustLoadHolder(config.Config{
+		Server:  config.Server{Addr: ":50051", DrainTimeout: 10 * time.Second},
+		Orders:  config.Orders{BatchSize: 3},
+		Log:     config.Log{Level: "info", SampleFirst: 10, SampleEvery: 100},
+		Tracing: config.Tracing{SampleRate: 1},
+		Metrics: config.Metrics{PrometheusAddr: ":9092"},
+	})
+	cfg := holder.Current()
 	initSampleData()
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
+
+	// Log level, rate limit, tokens and batch size follow config reloads.
+	// 日志级别、限流、令牌和批次大小会随配置重新加载而更新
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	logger = logs.Logger("orders")
+	// The standard log package and the shared packages log through slog too.
+	slog.SetDefault(logs.Logger("main"))
+	// Spans are exported when tracing.endpoint is set, e.g. to localhost:4318.
+	// 设置 tracing.endpoint 后才会导出 span，否则只用于日志中的追踪 ID
+	tracer, err := tracing.Setup(tracing.Options{
+		ServiceName: "order-service",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+	})
+	if err != nil {
+		log.Fatalf("invalid tracing config: %v", err)
+	}
+	// RED metrics of every method, scraped from http://localhost:9092/metrics
+	// 每个方法的请求速率、错误和耗时指标，可以通过 Prometheus 抓取
+	meters, err := metrics.Setup(metrics.Options{
+		ServiceName: "order-service",
+		Endpoint:    cfg.Metrics.Endpoint,
+		Interval:    cfg.Metrics.Interval,
+	})
+	if err != nil {
+		log.Fatalf("invalid metrics config: %v", err)
+	}
+	if cfg.Metrics.PrometheusAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", meters.Handler())
+		go func() {
+			if err := http.ListenAndServe(cfg.Metrics.PrometheusAddr, mux); err != nil {
+				logger.Warn("metrics endpoint failed", "error", err)
+			}
+		}()
+	}
+	limiter := ratelimit.New(cfg.RateLimit.RPS, cfg.RateLimit.Burst)
+	tokens, err := auth.NewTokens(cfg.Auth.Tokens)
+	if err != nil {
+		log.Fatalf("invalid auth tokens: %v", err)
+	}
+	holder.Subscribe(func(old, new *config.Config) {
+		if err := logs.SetLevels(new.Log.Level, new.Log.Packages); err != nil {
+			logger.Warn("keeping the log levels", "error", err)
+		}
+		logs.SetSampling(new.Log.SampleFirst, new.Log.SampleEvery)
+		limiter.SetLimit(new.RateLimit.RPS, new.RateLimit.Burst)
+		if err := tokens.Set(new.Auth.Tokens); err != nil {
+			logger.Warn("keeping the auth tokens", "error", err)
+		}
+	})
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	holder.WatchSignal(watchCtx)
+	holder.WatchFile(watchCtx, 5*time.Second)
+
+	// With server.tls.cert_file and key_file the server only accepts TLS connections, with ca_file only mutual TLS ones.
+	// 设置 server.tls 的证书和私钥后只接受 TLS 连接，再设置 ca_file 则要求客户端证书（双向 TLS）
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	s := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(calls.UnaryServerInterceptor(), meters.UnaryServerInterceptor(), tracer.UnaryServerInterceptor(), logs.UnaryServerInterceptor(), tokens.UnaryServerInterceptor(), limiter.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(calls.StreamServerInterceptor(), meters.StreamServerInterceptor(), tracer.StreamServerInterceptor(), logs.StreamServerInterceptor(), tokens.StreamServerInterceptor(), limiter.StreamServerInterceptor()))
+	// The service is SERVING only while the order store is reachable.
+	// 只有订单存储可用时服务才处于 SERVING 状态
+	hs, checker := healthcheck.Register(s)
+	checker.AddService("ecommerce.OrderManagement",
+		healthcheck.NamedCheck{Name: "order-store", Check: orders.Ping})
+	// Channelz, pprof, health, build info, config and calls in flight on admin.addr, protected by the auth tokens.
+	// 管理端口提供 channelz、pprof、健康状态、构建信息、配置和进行中的调用，同样需要 auth token
+	adm, err := admin.Start(cfg.Admin.Addr, admin.Options{Calls: calls, Server: s, Health: hs, Config: holder, Tokens: tokens})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	// With registry.addr the server registers itself as ecommerce.OrderManagement and renews its lease until shutdown.
+	// 设置 registry.addr 后服务启动时向注册中心注册自己，并通过心跳续约，关闭时注销
+	self, err := discovery.RegisterSelf(cfg.Registry, lis.Addr(), "ecommerce.OrderManagement")
+	if err != nil {
+		log.Fatalf("failed to register with the registry: %v", err)
+	}
+	// On SIGTERM the server reports NOT_SERVING for server.shutdown_delay, then in-flight calls get the drain timeout to finish.
+	// 收到 SIGTERM 后先报告 NOT_SERVING 并等待 shutdown_delay，再给正在处理的调用 drain timeout 的时间完成
+	srv := lifecycle.New(s,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("registry", self.Stop),
+		lifecycle.WithShutdownHook("healthcheck", checker.Stop),
+		lifecycle.WithShutdownHook("tracing", tracer.Shutdown),
+		lifecycle.WithShutdownHook("metrics", meters.Shutdown),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown),
+		lifecycle.WithShutdownHook("config", func(ctx context.Context) error {
+			stopWatching()
+			return nil
+		}))
+	batchSize := func() int { return holder.Current().Orders.BatchSize }
+	stats, err := newOrderMetrics(meters.Meter("orders"))
+	if err != nil {
+		log.Fatalf("failed to create the order metrics: %v", err)
+	}
+	pb.RegisterOrderManagementServer(s, &server{orders: orders, batchSize: batchSize, shuttingDown: srv.ShuttingDown(), stats: stats})
+	// Shows the active config: grpcurl -plaintext localhost:50051 grpcsamples.config.ConfigAdmin/GetConfig
+	config.RegisterAdmin(s, holder)
+	checker.Start()
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
-	if err := s.Serve(lis); err != nil {
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	logger.Info("server stopped")
 }
 
 func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+	orders.Put(pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	orders.Put(pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	orders.Put(pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	orders.Put(pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	orders.Put(pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
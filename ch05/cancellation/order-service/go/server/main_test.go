@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	bufSize       = 1024 * 1024
+	testBatchSize = 3
+)
+
+// newTestServer returns a server of store with batches of testBatchSize and
+// order metrics of its own, read by the returned reader.
+func newTestServer(t *testing.T, store *orderStore) (*server, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	stats, err := newOrderMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("orders"))
+	if err != nil {
+		t.Fatalf("newOrderMetrics() failed: %v", err)
+	}
+	return &server{orders: store, batchSize: func() int { return testBatchSize }, stats: stats}, reader
+}
+
+// handlers tracks the handlers of a test server, so the test can wait for
+// them to exit before it returns.
+type handlers struct {
+	wg sync.WaitGroup
+	// done receives the error of every streaming handler when it returns.
+	done chan error
+}
+
+func newHandlers() *handlers {
+	return &handlers{done: make(chan error, 10)}
+}
+
+func (h *handlers) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			h.wg.Add(1)
+			defer h.wg.Done()
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			h.wg.Add(1)
+			defer h.wg.Done()
+			err := handler(srv, ss)
+			h.done <- err
+			return err
+		}),
+	}
+}
+
+// dialBufConn connects to listener; the connection is closed when the test ends.
+func dialBufConn(t *testing.T, listener *bufconn.Listener) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// startBufConnServer starts srv on a bufconn listener. The returned channel
+// receives the error of every streaming handler when it returns. When the
+// test ends, the server stops and its handlers have exited.
+func startBufConnServer(t *testing.T, srv *server) (pb.OrderManagementClient, <-chan error) {
+	t.Helper()
+	listener := bufconn.Listen(bufSize)
+	h := newHandlers()
+	s := grpc.NewServer(h.serverOptions()...)
+	pb.RegisterOrderManagementServer(s, srv)
+	go s.Serve(listener)
+	t.Cleanup(func() {
+		s.Stop()
+		h.wg.Wait()
+	})
+	return pb.NewOrderManagementClient(dialBufConn(t, listener)), h.done
+}
+
+// waitForHandler asserts that the handler goroutine exits soon with one of
+// the given codes.
+func waitForHandler(t *testing.T, handlerDone <-chan error, codes ...codes.Code) {
+	t.Helper()
+	select {
+	case err := <-handlerDone:
+		got := status.Code(err)
+		for _, code := range codes {
+			if got == code {
+				return
+			}
+		}
+		t.Fatalf("handler returned %v, want one of the codes %v", err, codes)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("handler still running 2s after the client went away")
+	}
+}
+
+func TestSearchOrdersStopsWhenClientCancels(t *testing.T) {
+	store := newOrderStore()
+	// Enough matching orders to fill the flow control window, so the handler
+	// blocks in Send once the client stops reading.
+	for i := 0; i < 20000; i++ {
+		id := fmt.Sprintf("%05d", i)
+		store.Put(pb.Order{Id: id, Items: []string{"Google Pixel 3A"}, Description: "order " + id})
+	}
+	srv, _ := newTestServer(t, store)
+	client, handlerDone := startBufConnServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.SearchOrders(ctx, &wrapper.StringValue{Value: "Google"})
+	if err != nil {
+		t.Fatalf("SearchOrders() failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() failed: %v", err)
+	}
+	cancel()
+
+	waitForHandler(t, handlerDone, codes.Canceled)
+}
+
+func TestUpdateOrdersStopsWhenClientCancels(t *testing.T) {
+	srv, _ := newTestServer(t, newOrderStore())
+	client, handlerDone := startBufConnServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.UpdateOrders(ctx)
+	if err != nil {
+		t.Fatalf("UpdateOrders() failed: %v", err)
+	}
+	if err := stream.Send(&pb.Order{Id: "102"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	cancel()
+
+	waitForHandler(t, handlerDone, codes.Canceled)
+}
+
+func TestProcessOrdersStopsWhenClientCancels(t *testing.T) {
+	store := newOrderStore()
+	store.Put(pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	srv, _ := newTestServer(t, store)
+	client, handlerDone := startBufConnServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders() failed: %v", err)
+	}
+	if err := stream.Send(&wrapper.StringValue{Value: "102"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	cancel()
+
+	waitForHandler(t, handlerDone, codes.Canceled)
+}
+
+func TestProcessOrdersStopsWhenDeadlineExpires(t *testing.T) {
+	srv, _ := newTestServer(t, newOrderStore())
+	client, handlerDone := startBufConnServer(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := client.ProcessOrders(ctx); err != nil {
+		t.Fatalf("ProcessOrders() failed: %v", err)
+	}
+
+	// The server sees DEADLINE_EXCEEDED, or CANCELLED when the client resets
+	// the stream on its own deadline first.
+	waitForHandler(t, handlerDone, codes.DeadlineExceeded, codes.Canceled)
+}
+
+func TestRangeStopsWhenContextIsDone(t *testing.T) {
+	store := newOrderStore()
+	for i := 0; i < 10; i++ {
+		store.Put(pb.Order{Id: fmt.Sprint(i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	err := store.Range(ctx, func(order pb.Order) (bool, error) {
+		visited++
+		if visited == 3 {
+			cancel()
+		}
+		return true, nil
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("Range() = %v, want code %v", err, codes.Canceled)
+	}
+	if visited != 3 {
+		t.Fatalf("Range() visited %d orders after cancel, want 3", visited)
+	}
+}
+
+func TestProcessOrdersShipsCurrentBatchOnShutdown(t *testing.T) {
+	store := newOrderStore()
+	store.Put(pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	store.Put(pb.Order{Id: "103", Destination: "San Jose, CA"})
+
+	listener := bufconn.Listen(bufSize)
+	h := newHandlers()
+	s := grpc.NewServer(h.serverOptions()...)
+	srv := lifecycle.New(s, lifecycle.WithDrainTimeout(time.Second))
+	orderServer, _ := newTestServer(t, store)
+	orderServer.shuttingDown = srv.ShuttingDown()
+	pb.RegisterOrderManagementServer(s, orderServer)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+	t.Cleanup(func() {
+		s.Stop()
+		h.wg.Wait()
+	})
+
+	stream, err := pb.NewOrderManagementClient(dialBufConn(t, listener)).ProcessOrders(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessOrders() failed: %v", err)
+	}
+	// Two orders do not fill a batch of testBatchSize.
+	for _, id := range []string{"102", "103"} {
+		if err := stream.Send(&wrapper.StringValue{Value: id}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+	// Make sure both orders were received before shutting down.
+	time.Sleep(100 * time.Millisecond)
+	go srv.Shutdown()
+
+	shipped := 0
+	for {
+		_, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) != codes.Unavailable {
+				t.Fatalf("Recv() = %v, want code %v", err, codes.Unavailable)
+			}
+			break
+		}
+		shipped++
+	}
+	if shipped != 2 {
+		t.Fatalf("received %d shipments before the stream closed, want 2", shipped)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve() = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Serve() did not return after the shutdown")
+	}
+}
+
+func TestProcessOrdersFollowsBatchSizeChanges(t *testing.T) {
+	store := newOrderStore()
+	store.Put(pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	store.Put(pb.Order{Id: "103", Destination: "San Jose, CA"})
+
+	batchSize := int32(100)
+	srv, _ := newTestServer(t, store)
+	srv.batchSize = func() int { return int(atomic.LoadInt32(&batchSize)) }
+	client, handlerDone := startBufConnServer(t, srv)
+
+	stream, err := client.ProcessOrders(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessOrders() failed: %v", err)
+	}
+	if err := stream.Send(&wrapper.StringValue{Value: "102"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	// Shrink the batch while the stream is open, as a config reload does.
+	atomic.StoreInt32(&batchSize, 2)
+	if err := stream.Send(&wrapper.StringValue{Value: "103"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		done := make(chan error, 1)
+		go func() {
+			_, err := stream.Recv()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Recv() failed: %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no shipment %d after the batch size was reduced", i+1)
+		}
+		cancel()
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv() after CloseSend() = %v, want io.EOF", err)
+	}
+	waitForHandler(t, handlerDone, codes.OK)
+}
+
+// sums returns the counter values of rm by metric name and attributes,
+// e.g. "orders.processed{known_id=false}".
+func sums(rm metricdata.ResourceMetrics) map[string]int64 {
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, p := range sum.DataPoints {
+				key := m.Name
+				if p.Attributes.Len() > 0 {
+					key += "{" + p.Attributes.Encoded(attribute.DefaultEncoder()) + "}"
+				}
+				got[key] += p.Value
+			}
+		}
+	}
+	return got
+}
+
+func TestOrderMetrics(t *testing.T) {
+	srv, reader := newTestServer(t, newOrderStore())
+	client, handlerDone := startBufConnServer(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// One destination more than maxDestinations.
+	for i := 0; i <= maxDestinations; i++ {
+		order := &pb.Order{Id: fmt.Sprint(200 + i), Items: []string{"Apple Watch S4"}, Destination: fmt.Sprintf("City %d", i)}
+		if _, err := client.AddOrder(ctx, order); err != nil {
+			t.Fatalf("AddOrder() failed: %v", err)
+		}
+	}
+	search, err := client.SearchOrders(ctx, &wrapper.StringValue{Value: "Apple"})
+	if err != nil {
+		t.Fatalf("SearchOrders() failed: %v", err)
+	}
+	for {
+		if _, err := search.Recv(); err != nil {
+			break
+		}
+	}
+	waitForHandler(t, handlerDone, codes.OK)
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders() failed: %v", err)
+	}
+	// A full batch of testBatchSize orders, then a batch cut short by the
+	// end of the stream. 999 is not a known order.
+	ids := []string{"200", "201", "999"}
+	for i := 3; i <= maxDestinations; i++ {
+		ids = append(ids, fmt.Sprint(200+i))
+	}
+	for _, id := range ids {
+		if err := stream.Send(&wrapper.StringValue{Value: id}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	waitForHandler(t, handlerDone, codes.OK)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Collect() failed: %v", err)
+	}
+	got := sums(rm)
+	for key, want := range map[string]int64{
+		"orders.added":                         maxDestinations + 1,
+		"orders.searches":                      1,
+		"orders.search.matches":                maxDestinations + 1,
+		"orders.processed{known_id=true}":      maxDestinations,
+		"orders.processed{known_id=false}":     1,
+		"orders.shipments{destination=city_0}": 1,
+		// 999 has no destination.
+		"orders.shipments{destination=unknown}": 1,
+	} {
+		if got[key] != want {
+			t.Errorf("%s = %d, want %d", key, got[key], want)
+		}
+	}
+	destinations := 0
+	for key := range got {
+		if strings.HasPrefix(key, "orders.shipments{") {
+			destinations++
+		}
+	}
+	if destinations != maxDestinations+1 {
+		t.Errorf("orders.shipments has %d destinations, want %d plus %q", destinations, maxDestinations, metrics.Other)
+	}
+	if got["orders.shipments{destination="+metrics.Other+"}"] == 0 {
+		t.Errorf("destinations above %d are not counted as %q: %v", maxDestinations, metrics.Other, got)
+	}
+}
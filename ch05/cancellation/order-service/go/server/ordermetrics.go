@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Reasons a ProcessOrders batch is shipped.
+const (
+	batchFull      = "full"
+	batchStreamEnd = "stream_end"
+	batchShutdown  = "shutdown"
+)
+
+// maxDestinations bounds the destination label. Destinations come from the
+// orders, so later ones are counted as metrics.Other.
+// 目的地来自订单数据，只为前 maxDestinations 个目的地单独计数，避免基数爆炸
+const maxDestinations = 20
+
+// orderMetrics records the business metrics of the order pipeline.
+// 订单处理的业务指标
+type orderMetrics struct {
+	added, updated    metric.Int64Counter
+	searches, matches metric.Int64Counter
+	processed         metric.Int64Counter
+	shipments         metric.Int64Counter
+	batchFill         metric.Float64Histogram
+	timeInBatch       metric.Float64Histogram
+	destinations      *metrics.Capped
+}
+
+func newOrderMetrics(meter metric.Meter) (*orderMetrics, error) {
+	m := &orderMetrics{destinations: metrics.NewCapped(maxDestinations)}
+	var err error
+	for _, c := range []struct {
+		counter    *metric.Int64Counter
+		name, desc string
+	}{
+		{&m.added, "orders.added", "Orders added by AddOrder."},
+		{&m.updated, "orders.updated", "Orders updated by UpdateOrders."},
+		{&m.searches, "orders.searches", "SearchOrders calls."},
+		{&m.matches, "orders.search.matches", "Orders returned by SearchOrders."},
+		{&m.processed, "orders.processed", "Order IDs received by ProcessOrders, by whether the ID is known."},
+		{&m.shipments, "orders.shipments", "Combined shipments sent by ProcessOrders, by destination."},
+	} {
+		if *c.counter, err = meter.Int64Counter(c.name, metric.WithDescription(c.desc), metric.WithUnit("{order}")); err != nil {
+			return nil, err
+		}
+	}
+	if m.batchFill, err = meter.Float64Histogram("orders.batch.fill_ratio",
+		metric.WithDescription("Orders of a shipped batch relative to the batch size, by the reason it was shipped."),
+		metric.WithUnit("1"),
+		metric.WithExplicitBucketBoundaries(0.1, 0.25, 0.5, 0.75, 0.9, 1)); err != nil {
+		return nil, err
+	}
+	if m.timeInBatch, err = meter.Float64Histogram("orders.batch.wait",
+		metric.WithDescription("Time from receiving an order until its batch is shipped."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *orderMetrics) orderAdded(ctx context.Context) {
+	m.added.Add(ctx, 1)
+}
+
+func (m *orderMetrics) orderUpdated(ctx context.Context) {
+	m.updated.Add(ctx, 1)
+}
+
+func (m *orderMetrics) searched(ctx context.Context, matches int) {
+	m.searches.Add(ctx, 1)
+	m.matches.Add(ctx, int64(matches))
+}
+
+// orderReceived counts an order ID of ProcessOrders; the unknown ID rate is
+// the share of known_id="false".
+func (m *orderMetrics) orderReceived(ctx context.Context, known bool) {
+	m.processed.Add(ctx, 1, metric.WithAttributes(attribute.Bool("known_id", known)))
+}
+
+func (m *orderMetrics) shipmentSent(ctx context.Context, destination string) {
+	m.shipments.Add(ctx, 1, metric.WithAttributes(attribute.String("destination", m.destinations.Value(destination))))
+}
+
+// batchShipped records a batch of orders received at the given times.
+func (m *orderMetrics) batchShipped(ctx context.Context, reason string, batchSize int, received []time.Time) {
+	if len(received) == 0 {
+		return
+	}
+	if batchSize > 0 {
+		m.batchFill.Record(ctx, float64(len(received))/float64(batchSize),
+			metric.WithAttributes(attribute.String("reason", reason)))
+	}
+	now := time.Now()
+	for _, t := range received {
+		m.timeInBatch.Record(ctx, now.Sub(t).Seconds())
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+
+	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/streamutil"
+)
+
+// orderStore keeps the orders by ID. It is safe for concurrent use by the
+// handlers, and its Range stops as soon as the client of a handler is gone.
+// orderStore 保存订单，可以被多个处理器并发使用；客户端取消后 Range 立即停止遍历
+type orderStore struct {
+	streamutil.Store[pb.Order]
+}
+
+func newOrderStore() *orderStore {
+	return &orderStore{}
+}
+
+// Put stores order under its ID.
+func (s *orderStore) Put(order pb.Order) {
+	s.Store.Put(order.Id, order)
+}
+
+// Ping reports whether the store can serve requests. The in-memory store is
+// always reachable; a store backed by a database would ping it here.
+// Ping 用于健康检查，报告存储是否可用
+func (s *orderStore) Ping(ctx context.Context) error {
+	return streamutil.ContextErr(ctx)
+}
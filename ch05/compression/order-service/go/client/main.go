@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc/encoding/gzip"
 
 	"google.golang.org/grpc"
@@ -10,20 +11,23 @@ import (
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", Timeout: 5 * time.Second},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
 	defer conn.Close()
 	client := pb.NewOrderManagementClient(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second * 5)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
 	defer cancel()
 
 	// RPC: Add Order
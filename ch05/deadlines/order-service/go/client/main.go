@@ -3,19 +3,23 @@ package main
 import (
 	"context"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
 	"log"
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051"},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -42,6 +46,17 @@ func main() {
 		log.Print("AddOrder Response -> ", res.Value)
 	}
 
+	// Add Order with a deadline shorter than the 1s the server requires for addOrder.
+	// The server rejects the call right away instead of starting work it cannot finish.
+	// 截止时间小于服务端要求的最小值（1 秒），服务端会立即拒绝该调用
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer shortCancel()
+	order2 := pb.Order{Id: "107", Items:[]string{"Google Pixel 4"}, Destination:"Mountain View, CA", Price:800.00}
+	_, addErr = client.AddOrder(shortCtx, &order2)
+	if addErr != nil {
+		log.Printf("Error Occured -> addOrder : , %v: %s", status.Code(addErr), status.Convert(addErr).Message())
+	}
+
 
 
 	// Get Order
@@ -77,7 +92,7 @@ func main() {
 	//
 	//
 	//updateRes, _ := updateStream.CloseAndRecv()
-	//log.Printf("Update Orders Res : ", updateRes)
+	//log.Printf("Update Orders Res : %s", updateRes)
 	//
 	//// Process Order
 	//streamProcOrder, _ := client.ProcessOrders(ctx)
@@ -1,9 +1,10 @@
 module client
 
-go 1.17
+go 1.21
 
 require (
 	github.com/grpc-up-and-running/samples v1.0.0
+	github.com/grpc-up-and-running/samples/common/go v0.0.0
 	google.golang.org/genproto v0.0.0-20220725144611-272f38e5d71b
 	google.golang.org/grpc v1.48.0
 )
@@ -14,4 +15,7 @@ require (
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
 	golang.org/x/text v0.3.5 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../../common/go
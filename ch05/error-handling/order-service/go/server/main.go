@@ -6,26 +6,38 @@ import (
 	"github.com/golang/protobuf/ptypes/wrappers"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"github.com/grpc-up-and-running/samples/common/go/streamutil"
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"strings"
+	"time"
 )
 
-const (
-	port           = ":50051"
-	orderBatchSize = 3
-)
+// orderMap keeps the orders by ID; the handlers use it concurrently.
+// orderMap 按 ID 保存订单，可以被多个处理器并发使用
+var orderMap streamutil.Store[pb.Order]
 
-var orderMap = make(map[string]pb.Order)
+// logger logs with the request-scoped fields of the call context.
+// 使用调用的 context 记录日志时会自动带上请求相关的字段
+var logger = slog.Default()
 
 type server struct {
-	orderMap map[string]*pb.Order
+	orderMap  map[string]*pb.Order
+	batchSize int
 }
 
 // Simple RPC
@@ -33,7 +45,7 @@ type server struct {
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
 	// 非法请求，需要生成一个错误并将其返回给客户端
 	if orderReq.Id == "-1" {
-		log.Printf("Order ID is invalid! -> Received Order ID %s", orderReq.Id)
+		logger.InfoContext(ctx, "invalid order ID", "order_id", orderReq.Id)
 		// 创建一个错误码为 InvalidArgument 的新错误状态
 		errorStatus := status.New(codes.InvalidArgument, "Invalid information received")
 		// 包含错误类型 BadRequest_FieldViolation 的所有错误详情
@@ -49,96 +61,106 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.St
 		// 返回生成的错误
 		return nil, ds.Err()
 	} else {
-		orderMap[orderReq.Id] = *orderReq
-		log.Println("Order : ", orderReq.Id, " -> Added")
+		orderMap.Put(orderReq.Id, *orderReq)
+		logger.InfoContext(ctx, "order added", "order_id", orderReq.Id)
 		return &wrapper.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 	}
 }
 
 // Simple RPC
 func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord, _ := orderMap.Get(orderId.Value)
 	return &ord, nil
 }
 
 // Server-side Streaming RPC
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
-
-	for key, order := range orderMap {
+	ctx := stream.Context()
+	// Range and SendContext stop as soon as the client cancels the call or its deadline expires.
+	// 客户端取消调用或超出截止时间后，Range 和 SendContext 会立即停止
+	return orderMap.Range(ctx, func(order pb.Order) (bool, error) {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : "+key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
-				break
+				if logging.Sample(ctx, logger, slog.LevelDebug) {
+					logger.DebugContext(ctx, "matching order found, writing it to the stream", "order_id", order.Id)
+				}
+				return true, streamutil.SendContext(ctx, stream, &order)
 			}
 		}
-	}
-
-	return nil
+		return true, nil
+	})
 }
 
 // Client-side Streaming RPC
 func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) error {
+	ctx := stream.Context()
 
 	ordersStr := "Updated Order IDs : "
 	for {
-		order, err := stream.Recv()
+		order := new(pb.Order)
+		err := streamutil.RecvContext(ctx, stream, order)
 		if err == io.EOF {
 			// Finished reading the order stream.
 			return stream.SendAndClose(&wrapper.StringValue{Value: "Orders processed " + ordersStr})
 		}
+		if err != nil {
+			logger.InfoContext(ctx, "stopped updating orders", "error", err)
+			return err
+		}
 		// Update order
-		orderMap[order.Id] = *order
+		orderMap.Put(order.Id, *order)
 
-		log.Printf("Order ID ", order.Id, ": Updated")
+		logger.InfoContext(ctx, "order updated", "order_id", order.Id)
 		ordersStr += order.Id + ", "
 	}
 }
 
 // Bi-directional Streaming RPC
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
+	ctx := stream.Context()
 
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
 	for {
-		orderId, err := stream.Recv()
-		log.Println("Reading Proc order ... ", orderId)
+		// RecvContext and SendContext stop as soon as the client cancels the call or its deadline expires.
+		// 客户端取消调用或超出截止时间后，RecvContext 和 SendContext 会立即停止
+		orderId := new(wrapper.StringValue)
+		err := streamutil.RecvContext(ctx, stream, orderId)
+		if logging.Sample(ctx, logger, slog.LevelDebug) {
+			logger.DebugContext(ctx, "processing order", "order_id", orderId.GetValue())
+		}
 		if err == io.EOF {
 			// Client has sent all the messages
 			// Send remaining shipments
 
-			log.Println("EOF ", orderId)
+			logger.DebugContext(ctx, "client sent all orders")
 
-			for _, comb := range combinedShipmentMap {
-				stream.Send(&comb)
-			}
-			return nil
+			return shipBatch(ctx, stream, combinedShipmentMap)
 		}
 		if err != nil {
-			log.Println(err)
+			logger.InfoContext(ctx, "stopped processing orders", "error", err)
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord, _ := orderMap.Get(orderId.GetValue())
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!",}
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + destination, Status: "Processed!",}
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
-			log.Print(len(comShip.OrdersList), comShip.GetId())
+			logger.DebugContext(ctx, "new combined shipment", "shipment_id", comShip.GetId(), "orders", len(comShip.OrdersList))
 		}
 
-		if batchMarker == orderBatchSize {
-			for _, comb := range combinedShipmentMap {
-				log.Print("Shipping : ", comb.Id, " -> ", len(comb.OrdersList))
-				stream.Send(&comb)
+		if batchMarker == s.batchSize {
+			if err := shipBatch(ctx, stream, combinedShipmentMap); err != nil {
+				logger.InfoContext(ctx, "stopped processing orders", "error", err)
+				return err
 			}
 			batchMarker = 0
 			combinedShipmentMap = make(map[string]pb.CombinedShipment)
@@ -148,25 +170,83 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 	}
 }
 
+// shipBatch sends the combined shipments of a batch, stopping at the first send that fails.
+// shipBatch 发送当前批次的合并发货，发送失败（例如客户端已取消）时立即返回
+func shipBatch(ctx context.Context, stream pb.OrderManagement_ProcessOrdersServer, combinedShipmentMap map[string]pb.CombinedShipment) error {
+	for _, comb := range combinedShipmentMap {
+		logger.InfoContext(ctx, "shipping", "shipment_id", comb.Id, "orders", len(comb.OrdersList))
+		if err := streamutil.SendContext(ctx, stream, &comb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051", DrainTimeout: 10 * time.Second},
+		Orders: config.Orders{BatchSize: 3},
+		Log:    config.Log{Level: "info", SampleFirst: 10, SampleEvery: 100},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	logger = logs.Logger("orders")
+	slog.SetDefault(logs.Logger("main"))
 	initSampleData()
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	// Takes the x-request-id of the call, or creates one, and returns it in the headers, trailers and error details.
+	// The logging interceptors then attach it, along with the method, peer, principal and trace ID, to the logs of every call.
+	// 读取或生成 x-request-id，并在头信息、trailer 和错误详情中返回；日志拦截器会把它附加到每个调用的日志中
+	s := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), logs.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logs.StreamServerInterceptor()))...)
+	pb.RegisterOrderManagementServer(s, &server{batchSize: cfg.Orders.BatchSize})
+	// Reports NOT_SERVING from the start of the shutdown.
+	// 关闭开始后健康检查返回 NOT_SERVING
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
-	if err := s.Serve(lis); err != nil {
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s, Health: hs})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	// On SIGTERM the server reports NOT_SERVING for server.shutdown_delay, then in-flight calls get the drain timeout to finish.
+	// 收到 SIGTERM 后先报告 NOT_SERVING 并等待 shutdown_delay，再给正在处理的调用 drain timeout 的时间完成
+	srv := lifecycle.New(s,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown))
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
 func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+	orderMap.Put("102", pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	orderMap.Put("103", pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	orderMap.Put("104", pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	orderMap.Put("105", pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	orderMap.Put("106", pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/hedging"
+	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	exampleScheme      = "example"
+	exampleServiceName = "lb.example.grpc.io"
+)
+
+// addrs are the backends returned by the example resolver, taken from client.addrs.
+var addrs []string
+
+func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Addrs: []string{"localhost:50051", "localhost:50052"}},
+	})
+	addrs = cfg.Client.Addrs
+
+	// Hedging is enabled per method. Only idempotent reads such as getOrder
+	// (or getProduct of ProductInfo) should be listed here.
+	// 按方法启用对冲请求，只有幂等的读方法才适合对冲
+	policy := hedging.DefaultPolicy()
+	policy.MaxAttempts = 2
+	policy.Percentile = 0.9
+	policy.MinSamples = 10
+	hedger := hedging.New(map[string]hedging.Policy{
+		"/ecommerce.OrderManagement/getOrder": policy,
+	})
+
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	conn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), // "example:///lb.example.grpc.io"
+		// round_robin makes every hedged copy go to the next backend.
+		// 使用 round_robin，对冲的副本会发往另一个后端
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+		grpc.WithUnaryInterceptor(hedger.UnaryClientInterceptor()),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewOrderManagementClient(conn)
+
+	for i := 0; i < 40; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		ord, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "106"})
+		cancel()
+		if err != nil {
+			log.Fatalf("could not get order: %v", err)
+		}
+		log.Printf("GetOrder Response -> %s, %s in %v (hedging delay %v)", ord.Id, ord.Description,
+			time.Since(start).Round(time.Millisecond), hedger.Delay("/ecommerce.OrderManagement/getOrder"))
+	}
+}
+
+// Name resolver implementation
+
+type exampleResolverBuilder struct{}
+
+func (*exampleResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r := &exampleResolver{
+		target: target,
+		cc:     cc,
+		addrsStore: map[string][]string{
+			exampleServiceName: addrs, // "lb.example.grpc.io": "localhost:50051", "localhost:50052"
+		},
+	}
+	r.start()
+	return r, nil
+}
+func (*exampleResolverBuilder) Scheme() string { return exampleScheme } // "example"
+
+type exampleResolver struct {
+	target     resolver.Target
+	cc         resolver.ClientConn
+	addrsStore map[string][]string
+}
+
+func (r *exampleResolver) start() {
+	addrStrs := r.addrsStore[r.target.Endpoint]
+	addrs := make([]resolver.Address, len(addrStrs))
+	for i, s := range addrStrs {
+		addrs[i] = resolver.Address{Addr: s}
+	}
+	r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+func (*exampleResolver) ResolveNow(o resolver.ResolveNowOptions) {}
+func (*exampleResolver) Close()                                  {}
+
+func init() {
+	resolver.Register(&exampleResolverBuilder{})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The first backend answers slowly now and then to produce a latency tail.
+// 第一个后端偶尔会慢响应，以制造长尾延迟
+const slowDelay = 800 * time.Millisecond
+
+var orderMap = make(map[string]pb.Order)
+
+// logger logs with the request-scoped fields of the call context.
+// 使用调用的 context 记录日志时会自动带上请求相关的字段
+var logger = slog.Default()
+
+type server struct {
+	pb.UnimplementedOrderManagementServer
+	addr      string
+	slowDelay time.Duration
+}
+
+// Simple RPC
+func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
+	attempt := "0"
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("grpc-previous-rpc-attempts")) > 0 {
+		attempt = md.Get("grpc-previous-rpc-attempts")[0]
+	}
+
+	if s.slowDelay > 0 && rand.Intn(4) == 0 {
+		select {
+		case <-time.After(s.slowDelay):
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "GetOrder cancelled", "backend", s.addr, "order_id", orderId.Value, "attempt", attempt, "error", ctx.Err())
+			return nil, ctx.Err()
+		}
+	}
+
+	ord, exists := orderMap[orderId.Value]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Order does not exist. : %s", orderId.Value)
+	}
+	logger.InfoContext(ctx, "GetOrder", "backend", s.addr, "order_id", orderId.Value, "attempt", attempt)
+	ord.Description = fmt.Sprintf("served by %s", s.addr)
+	return &ord, nil
+}
+
+// calls are the calls in flight of all backends, listed by the admin server at /rpcs.
+var calls = admin.NewCalls()
+
+func startServer(addr string, slowDelay time.Duration, creds credentials.TransportCredentials, logs *logging.Logging, opts ...lifecycle.Option) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	// Every backend logs its calls with the x-request-id, so the attempts of one hedged call can be matched up.
+	// 每个后端的日志都带上 x-request-id，可以据此找到同一个对冲调用的所有尝试
+	s := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), logs.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logs.StreamServerInterceptor()))...)
+	pb.RegisterOrderManagementServer(s, &server{addr: addr, slowDelay: slowDelay})
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	slog.Info("serving", "addr", addr)
+	srv := lifecycle.New(s, append([]lifecycle.Option{lifecycle.WithHealth(hs)}, opts...)...)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
+
+func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addrs: []string{":50051", ":50052"}, DrainTimeout: 10 * time.Second},
+		Log:    config.Log{Level: "info"},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	logger = logs.Logger("orders")
+	slog.SetDefault(logs.Logger("main"))
+	initSampleData()
+	// Channelz, pprof, build info, config and the calls in flight of all backends on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供所有后端的 channelz、pprof、构建信息、配置和进行中的调用
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// On SIGTERM every backend reports NOT_SERVING for server.shutdown_delay, then drains its calls.
+	// 收到 SIGTERM 后每个后端先报告 NOT_SERVING 并等待 shutdown_delay，再等待正在处理的调用完成
+	shutdown := []lifecycle.Option{
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+	}
+	var wg sync.WaitGroup
+	for i, addr := range cfg.Server.Addrs {
+		slowDelay := slowDelay
+		if i > 0 {
+			slowDelay = 0
+		}
+		wg.Add(1)
+		go func(addr string, slowDelay time.Duration) {
+			defer wg.Done()
+			startServer(addr, slowDelay, creds, logs, shutdown...)
+		}(addr, slowDelay)
+	}
+	wg.Wait()
+	adm.Shutdown(context.Background())
+}
+
+func initSampleData() {
+	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
+	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
+	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
+	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
+	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+}
@@ -4,19 +4,23 @@ import (
 	"context"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"io"
 	"log"
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", Timeout: 5 * time.Second},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure(),
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds),
 		grpc.WithUnaryInterceptor(orderUnaryClientInterceptor),  // 一元
 		grpc.WithStreamInterceptor(clientStreamInterceptor))	 // 流
 	if err != nil {
@@ -24,7 +28,7 @@ func main() {
 	}
 	defer conn.Close()
 	c := pb.NewOrderManagementClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
 	defer cancel()
 
 	// Add Order
@@ -61,7 +65,7 @@ func main() {
 	_ = updateStream.Send(&updOrder3)
 
 	updateRes, _ := updateStream.CloseAndRecv()
-	log.Printf("Update Orders Res : ", updateRes)
+	log.Printf("Update Orders Res : %s", updateRes)
 
 	// Process Order
 	streamProcOrder, _ := c.ProcessOrders(ctx)
@@ -85,7 +89,7 @@ func asncClientBidirectionalRPC (streamProcOrder pb.OrderManagement_ProcessOrder
 		if errProcOrder == io.EOF {
 			break
 		}
-		log.Printf("Combined shipment : ", combinedShipment.OrdersList)
+		log.Printf("Combined shipment : %v", combinedShipment.OrdersList)
 	}
 	c <- true
 }
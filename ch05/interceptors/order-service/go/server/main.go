@@ -2,119 +2,144 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/accesslog"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"github.com/grpc-up-and-running/samples/common/go/streamutil"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"io"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"strings"
 	"time"
 )
 
-const (
-	port           = ":50051"
-	orderBatchSize = 3
-)
+// orderMap keeps the orders by ID; the handlers use it concurrently.
+// orderMap 按 ID 保存订单，可以被多个处理器并发使用
+var orderMap streamutil.Store[pb.Order]
 
-var orderMap = make(map[string]pb.Order)
+// logger logs with the request-scoped fields of the call context.
+// 使用调用的 context 记录日志时会自动带上请求相关的字段
+var logger = slog.Default()
 
 type server struct {
-	orderMap map[string]*pb.Order
+	orderMap  map[string]*pb.Order
+	batchSize int
 }
 
 // Simple RPC
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
-	log.Println("Order : ",  orderReq.Id, " -> Added")
+	orderMap.Put(orderReq.Id, *orderReq)
+	logger.InfoContext(ctx, "order added", "order_id", orderReq.Id)
 	return &wrapper.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
 // Simple RPC
 func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
+	ord, _ := orderMap.Get(orderId.Value)
 	return &ord, nil
 }
 
 // Server-side Streaming RPC
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
-
-	for key, order := range orderMap {
+	ctx := stream.Context()
+	// Range and SendContext stop as soon as the client cancels the call or its deadline expires.
+	// 客户端取消调用或超出截止时间后，Range 和 SendContext 会立即停止
+	return orderMap.Range(ctx, func(order pb.Order) (bool, error) {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : " + key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
-				break
+				if logging.Sample(ctx, logger, slog.LevelDebug) {
+					logger.DebugContext(ctx, "matching order found, writing it to the stream", "order_id", order.Id)
+				}
+				return true, streamutil.SendContext(ctx, stream, &order)
 			}
 		}
-	}
-	return nil
+		return true, nil
+	})
 }
 
 // Client-side Streaming RPC
 func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) error {
+	ctx := stream.Context()
 
 	ordersStr := "Updated Order IDs : "
 	for {
-		order, err := stream.Recv()
+		order := new(pb.Order)
+		err := streamutil.RecvContext(ctx, stream, order)
 		if err == io.EOF {
 			// Finished reading the order stream.
 			return stream.SendAndClose(&wrapper.StringValue{Value: "Orders processed " + ordersStr})
 		}
+		if err != nil {
+			logger.InfoContext(ctx, "stopped updating orders", "error", err)
+			return err
+		}
 		// Update order
-		orderMap[order.Id] = *order
+		orderMap.Put(order.Id, *order)
 
-		log.Printf("Order ID ", order.Id, ": Updated")
+		logger.InfoContext(ctx, "order updated", "order_id", order.Id)
 		ordersStr += order.Id + ", "
 	}
 }
 
 // Bi-directional Streaming RPC
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
+	ctx := stream.Context()
 
 	batchMarker := 1
 	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
 	for {
-		orderId, err := stream.Recv()
-		log.Println("Reading Proc order ... ", orderId)
+		// RecvContext and SendContext stop as soon as the client cancels the call or its deadline expires.
+		// 客户端取消调用或超出截止时间后，RecvContext 和 SendContext 会立即停止
+		orderId := new(wrapper.StringValue)
+		err := streamutil.RecvContext(ctx, stream, orderId)
+		if logging.Sample(ctx, logger, slog.LevelDebug) {
+			logger.DebugContext(ctx, "processing order", "order_id", orderId.GetValue())
+		}
 		if err == io.EOF {
 			// Client has sent all the messages
 			// Send remaining shipments
 
-			log.Println("EOF ", orderId)
+			logger.DebugContext(ctx, "client sent all orders")
 
-			for _, comb := range combinedShipmentMap {
-				stream.Send(&comb)
-			}
-			return nil
+			return shipBatch(ctx, stream, combinedShipmentMap)
 		}
 		if err != nil {
-			log.Println(err)
+			logger.InfoContext(ctx, "stopped processing orders", "error", err)
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord, _ := orderMap.Get(orderId.GetValue())
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
 			shipment.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = shipment
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!", }
-			ord := orderMap[orderId.GetValue()]
+			comShip := pb.CombinedShipment{Id: "cmb - " + destination, Status: "Processed!", }
 			comShip.OrdersList = append(shipment.OrdersList, &ord)
 			combinedShipmentMap[destination] = comShip
-			log.Print(len(comShip.OrdersList), comShip.GetId())
+			logger.DebugContext(ctx, "new combined shipment", "shipment_id", comShip.GetId(), "orders", len(comShip.OrdersList))
 		}
 
-		if batchMarker == orderBatchSize {
-			for _, comb := range combinedShipmentMap {
-				log.Print("Shipping : " , comb.Id, " -> ", len(comb.OrdersList))
-				stream.Send(&comb)
+		if batchMarker == s.batchSize {
+			if err := shipBatch(ctx, stream, combinedShipmentMap); err != nil {
+				logger.InfoContext(ctx, "stopped processing orders", "error", err)
+				return err
 			}
 			batchMarker = 0
 			combinedShipmentMap = make(map[string]pb.CombinedShipment)
@@ -124,6 +149,18 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 	}
 }
 
+// shipBatch sends the combined shipments of a batch, stopping at the first send that fails.
+// shipBatch 发送当前批次的合并发货，发送失败（例如客户端已取消）时立即返回
+func shipBatch(ctx context.Context, stream pb.OrderManagement_ProcessOrdersServer, combinedShipmentMap map[string]pb.CombinedShipment) error {
+	for _, comb := range combinedShipmentMap {
+		logger.InfoContext(ctx, "shipping", "shipment_id", comb.Id, "orders", len(comb.OrdersList))
+		if err := streamutil.SendContext(ctx, stream, &comb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 
 // Server :: Unary Interceptor
 // 服务器：一元拦截器
@@ -132,8 +169,7 @@ func orderUnaryServerInterceptor(ctx context.Context, req interface{}, info *grp
 	// 前置处理逻辑
 	// Gets info about the current RPC call by examining the args passed in
 	// 通过检查传入的参数，获取关于当前 RPC 的信息
-	log.Println("======= [Server Interceptor] ", info.FullMethod)
-	log.Printf(" Pre Proc Message : %s", req)
+	logger.InfoContext(ctx, "[Server Interceptor] pre-processing", "request", req)
 
 
 	// Invoking the handler to complete the normal execution of a unary RPC.
@@ -142,7 +178,7 @@ func orderUnaryServerInterceptor(ctx context.Context, req interface{}, info *grp
 
 	// Post processing logic
 	// 后置处理逻辑
-	log.Printf(" Post Proc Message : %s", m)
+	logger.InfoContext(ctx, "[Server Interceptor] post-processing", "response", m)
 	return m, err
 }
 
@@ -155,12 +191,12 @@ type wrappedStream struct {
 }
 
 func (w *wrappedStream) RecvMsg(m interface{}) error {
-	log.Printf("====== [Server Stream Interceptor Wrapper] Receive a message (Type: %T) at %s", m, time.Now().Format(time.RFC3339))
+	logger.DebugContext(w.Context(), "[Server Stream Interceptor Wrapper] receive a message", "type", fmt.Sprintf("%T", m))
 	return w.ServerStream.RecvMsg(m)
 }
 
 func (w *wrappedStream) SendMsg(m interface{}) error {
-	log.Printf("====== [Server Stream Interceptor Wrapper] Send a message (Type: %T) at %v", m, time.Now().Format(time.RFC3339))
+	logger.DebugContext(w.Context(), "[Server Stream Interceptor Wrapper] send a message", "type", fmt.Sprintf("%T", m))
 	return w.ServerStream.SendMsg(m)
 }
 
@@ -172,40 +208,106 @@ func newWrappedStream(s grpc.ServerStream) grpc.ServerStream {
 func orderServerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	// Pre-processing
 	// 前置处理
-	log.Println("====== [Server Stream Interceptor] ", info.FullMethod)
+	logger.InfoContext(ss.Context(), "[Server Stream Interceptor] pre-processing")
 
 	// Invoking the StreamHandler to complete the execution of RPC invocation
 	// 调用 StreamHandler 去完成执行 RPC 调用
 	err := handler(srv, newWrappedStream(ss))
 	if err != nil {
-		log.Printf("RPC failed with error %v", err)
+		logger.InfoContext(ss.Context(), "RPC failed", "error", err)
 	}
 	return err
 }
 
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Server:    config.Server{Addr: ":50051", DrainTimeout: 10 * time.Second},
+		Orders:    config.Orders{BatchSize: 3},
+		AccessLog: config.AccessLog{Format: "common", Sinks: []string{"stdout"}, RingSize: 1000},
+		Log:       config.Log{Level: "info", SampleFirst: 10, SampleEvery: 100},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	logger = logs.Logger("orders")
+	slog.SetDefault(logs.Logger("main"))
+	// One access log line per RPC, written when the call ends.
+	// 每个 RPC 结束时写一行访问日志
+	accessLog, err := accesslog.New(accesslog.Options{
+		Format:     cfg.AccessLog.Format,
+		Sinks:      cfg.AccessLog.Sinks,
+		File:       cfg.AccessLog.File,
+		MaxBytes:   cfg.AccessLog.MaxBytes,
+		MaxBackups: cfg.AccessLog.MaxBackups,
+		RingSize:   cfg.AccessLog.RingSize,
+	})
+	if err != nil {
+		log.Fatalf("failed to create access log: %v", err)
+	}
+	defer accessLog.Close()
 	initSampleData()
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// 在服务器端注册拦截器
-	s := grpc.NewServer(
-		grpc.UnaryInterceptor(orderUnaryServerInterceptor),   // 一元
-		grpc.StreamInterceptor(orderServerStreamInterceptor)) // 流
+	// The requestid interceptors come first, so the access log lines and the logs of the call carry the request ID.
+	// The access log interceptors follow, so they see every call, and count the stream messages
+	// with a wrapper like wrappedStream.
+	// requestid 拦截器在最前面，访问日志和调用的日志都带上请求 ID；访问日志拦截器随后，它和 wrappedStream 一样包装流来统计消息
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	s := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), accessLog.UnaryServerInterceptor(), logs.UnaryServerInterceptor(), orderUnaryServerInterceptor),          // 一元
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), accessLog.StreamServerInterceptor(), logs.StreamServerInterceptor(), orderServerStreamInterceptor))...) // 流
 	// 注册服务
-	pb.RegisterOrderManagementServer(s, &server{})
+	pb.RegisterOrderManagementServer(s, &server{batchSize: cfg.Orders.BatchSize})
+	// Reports NOT_SERVING from the start of the shutdown.
+	// 关闭开始后健康检查返回 NOT_SERVING
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
-	if err := s.Serve(lis); err != nil {
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091, and
+	// with the ring sink the last access log lines at /accesslog, all behind the auth.tokens of the config.
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用；使用 ring sink 时
+	// 还在 /accesslog 提供最近的访问日志，均需要 auth token
+	opts := admin.Options{Calls: calls, Server: s, Health: hs}
+	if ring := accessLog.Ring(); ring != nil {
+		opts.Handlers = map[string]http.Handler{"/accesslog": ring}
+	}
+	adm, err := admin.StartFromConfig(cfg, opts)
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	// On SIGTERM the server reports NOT_SERVING for server.shutdown_delay, then in-flight calls get the drain timeout to finish.
+	// 收到 SIGTERM 后先报告 NOT_SERVING 并等待 shutdown_delay，再给正在处理的调用 drain timeout 的时间完成
+	srv := lifecycle.New(s,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown))
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
 func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+	orderMap.Put("102", pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	orderMap.Put("103", pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	orderMap.Put("104", pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	orderMap.Put("105", pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	orderMap.Put("106", pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
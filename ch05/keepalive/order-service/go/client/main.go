@@ -3,26 +3,30 @@ package main
 import (
 	"context"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
 	"log"
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", Timeout: time.Second},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
 	defer conn.Close()
 	client := pb.NewOrderManagementClient(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
 	defer cancel()
 
 	// Add Order
@@ -71,7 +75,7 @@ func main() {
 	//
 	//
 	//updateRes, _ := updateStream.CloseAndRecv()
-	//log.Printf("Update Orders Res : ", updateRes)
+	//log.Printf("Update Orders Res : %s", updateRes)
 	//
 	//// Process Order
 	//streamProcOrder, _ := client.ProcessOrders(ctx)
@@ -2,13 +2,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
+	"github.com/grpc-up-and-running/samples/common/go/circuitbreaker"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/loadbalancing"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"github.com/grpc-up-and-running/samples/common/go/traffic"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	ecpb "google.golang.org/grpc/examples/features/proto/echo"
-	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -16,14 +25,43 @@ const (
 	exampleServiceName = "lb.example.grpc.io"
 )
 
-var addrs = []string{"localhost:50051", "localhost:50052"}
+// zone is the zone of the client for the weighted_locality policy.
+var zone = flag.String("zone", "zone-a", "zone of the client; weighted_locality prefers the backends of this zone")
+
+// newSource returns the endpoints of the example service: from the registry
+// if registry.addr is set, else from client.endpoints_file if it is set, and
+// else from client.addrs.
+// 优先从注册中心订阅端点；其次读取端点文件（文件变化后自动更新）；否则把 client.addrs 放入进程内注册表
+func newSource(cfg *config.Config) discovery.Source {
+	if cfg.Registry.Addr != "" {
+		conn, err := grpc.Dial(cfg.Registry.Addr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("did not connect to the registry: %v", err)
+		}
+		return discovery.NewRemoteSource(conn)
+	}
+	if cfg.Client.EndpointsFile != "" {
+		source, err := discovery.NewFileSource(cfg.Client.EndpointsFile)
+		if err != nil {
+			log.Fatalf("failed to read endpoints: %v", err)
+		}
+		source.WatchFile(5 * time.Second)
+		return source
+	}
+	registry := discovery.NewRegistry()
+	for _, addr := range cfg.Client.Addrs {
+		registry.Register(exampleServiceName, discovery.Endpoint{Addr: addr})
+	}
+	return registry
+}
 
 func callUnaryEcho(c ecpb.EchoClient, message string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	r, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: message})
 	if err != nil {
-		log.Fatalf("could not greet: %v", err)
+		log.Printf("could not greet: %v", err)
+		return
 	}
 	fmt.Println(r.Message)
 }
@@ -36,13 +74,36 @@ func makeRPCs(cc *grpc.ClientConn, n int) {
 }
 
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Addrs: []string{"localhost:50051", "localhost:50052"}},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// The metrics of the circuit breakers are exported over OTLP when metrics.endpoint is set.
+	// 设置 metrics.endpoint 后断路器的指标通过 OTLP 导出
+	meters, err := metrics.Setup(metrics.Options{
+		ServiceName: "echo-client",
+		Endpoint:    cfg.Metrics.Endpoint,
+		Interval:    cfg.Metrics.Interval,
+	})
+	if err != nil {
+		log.Fatalf("invalid metrics config: %v", err)
+	}
+	defer meters.Shutdown(context.Background())
+	source := newSource(cfg)
+	// 解析器把 "example:///lb.example.grpc.io" 解析为端点列表，端点变化时推送给客户端连接
+	resolvers := grpc.WithResolvers(discovery.NewBuilder(exampleScheme, source))
+
 	pickfirstConn, err := grpc.Dial(
 		// 使用模式和服务名创建 gRPC 连接。模式是通过模式解析器解析的，它是客户端应用程序的一部分。
 		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), // "example:///lb.example.grpc.io"
 		// "pick_first" is the default, so this DialOption is not necessary.
 		// "pick_first" 是默认的，所以不是必须的
 		//grpc.WithBalancerName("pick_first"),
-		grpc.WithInsecure(),
+		grpc.WithTransportCredentials(creds),
+		resolvers,
 	)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
@@ -55,9 +116,10 @@ func main() {
 	// Make another ClientConn with round_robin policy.
 	roundrobinConn, err := grpc.Dial(
 		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), // // "example:///lb.example.grpc.io"
-		// 使用轮询调度算法 方法已经废弃了
-		grpc.WithBalancerName("round_robin"), // This sets the initial balancing policy.
-		grpc.WithInsecure(),
+		// 使用轮询调度算法，grpc.WithBalancerName 已经被移除，改用服务配置
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`), // This sets the initial balancing policy.
+		grpc.WithTransportCredentials(creds),
+		resolvers,
 	)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
@@ -66,42 +128,186 @@ func main() {
 
 	log.Println("==== Calling helloworld.Greeter/SayHello with round_robin ====")
 	makeRPCs(roundrobinConn, 10)
-}
 
-// Name resolver implementation
+	// Make another ClientConn with the weighted, locality-aware policy of the shared loadbalancing package.
+	// It prefers the healthy backends of -zone and spreads the calls by the weights of the endpoints.
+	// 使用加权、区域感知的负载均衡策略：优先调用同区域的健康后端，按端点权重分配调用，同区域没有健康后端时才跨区域
+	weightedConn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {"zone": %q}}], "healthCheckConfig": {"serviceName": ""}}`,
+			loadbalancing.WeightedLocalityName, *zone)),
+		grpc.WithTransportCredentials(creds),
+		resolvers,
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer weightedConn.Close()
+
+	log.Printf("==== Calling helloworld.Greeter/SayHello with weighted_locality in %s ====", *zone)
+	makeRPCs(weightedConn, 10)
+
+	// Make another ClientConn with the peak_ewma policy, which compares two backends per call by their observed
+	// latency times their calls in flight. A backend made slow with the server's -slow_addr gets few calls.
+	// 使用 peak_ewma 策略：每次调用随机比较两个后端的延迟（指数加权移动平均）与进行中的调用数，选择负载较低的后端
+	ewmaConn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]}`, loadbalancing.PeakEWMAName)),
+		grpc.WithTransportCredentials(creds),
+		resolvers,
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer ewmaConn.Close()
+
+	log.Println("==== Calling helloworld.Greeter/SayHello with peak_ewma ====")
+	makeRPCs(ewmaConn, 10)
+
+	// Make another ClientConn with the ring_hash policy, which sends all calls with the same hash key to the same
+	// backend, e.g. all calls about an order, so that the caches of the backends stay warm.
+	// 使用 ring_hash 一致性哈希策略：哈希键（如订单 ID）相同的调用总是发往同一个后端，增删后端时只有少量键会迁移
+	hashConn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {"hashHeader": "x-order-id"}}]}`,
+			loadbalancing.RingHashName)),
+		grpc.WithTransportCredentials(creds),
+		resolvers,
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer hashConn.Close()
+
+	log.Println("==== Calling helloworld.Greeter/SayHello with ring_hash ====")
+	makeHashRPCs(hashConn, []string{"101", "102", "103", "104"})
+
+	// Make another ClientConn with the outlier_detection policy over round_robin. A backend failing more than 40%
+	// of its calls in a second, e.g. one started with the server's -failing_addr, is ejected for a while and its
+	// calls go to the others. The SubConns also watch the health service of the backends.
+	// 使用离群检测策略：每秒统计各后端的失败率，失败过多的后端被暂时摘除（连续摘除时摘除时间加倍），并通过健康检查 Watch 流主动探测后端
+	outlierConn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {
+			"interval": "1s", "baseEjectionTime": "5s", "maxEjectionPercent": 50,
+			"failurePercentageEjection": {"threshold": 40, "requestVolume": 5},
+			"childPolicy": [{"round_robin": {}}]}}],
+			"healthCheckConfig": {"serviceName": ""}}`, loadbalancing.OutlierDetectionName)),
+		grpc.WithTransportCredentials(creds),
+		resolvers,
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer outlierConn.Close()
 
-type exampleResolverBuilder struct{}
+	log.Println("==== Calling helloworld.Greeter/SayHello with outlier_detection ====")
+	makeOutlierRPCs(outlierConn, 30)
 
-func (*exampleResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
-	r := &exampleResolver{
-		target: target,
-		cc:     cc,
-		addrsStore: map[string][]string{
-			exampleServiceName: addrs, // "lb.example.grpc.io": "localhost:50051", "localhost:50052"
-		},
+	// With an xDS bootstrap file in GRPC_XDS_BOOTSTRAP, make another ClientConn that gets its listener, routes,
+	// clusters and endpoints over ADS from the control plane of loadbalancing/traffic/go/controlplane, which splits
+	// the calls between a stable and a canary cluster.
+	// 设置 GRPC_XDS_BOOTSTRAP 后，通过 xDS 控制平面获取监听器、路由、集群和端点，按权重在稳定版本和金丝雀版本之间分配流量
+	if os.Getenv(traffic.BootstrapFileEnv) != "" {
+		trafficConn, err := grpc.Dial(
+			fmt.Sprintf("%s:///%s", traffic.Scheme, exampleServiceName), // "xds:///lb.example.grpc.io"
+			grpc.WithTransportCredentials(creds),
+			grpc.WithResolvers(traffic.NewBuilder(traffic.Bootstrap{})),
+		)
+		if err != nil {
+			log.Fatalf("did not connect: %v", err)
+		}
+		defer trafficConn.Close()
+
+		log.Println("==== Calling helloworld.Greeter/SayHello through the xDS control plane ====")
+		makeRPCs(trafficConn, 20)
+		log.Println("==== Calling helloworld.Greeter/SayHello through the traffic control plane with x-canary ====")
+		makeCanaryRPCs(trafficConn, 5)
 	}
-	r.start()
-	return r, nil
+
+	log.Println("==== Calling helloworld.Greeter/SayHello with circuit breakers ====")
+	makeBreakerRPCs(fmt.Sprintf("%s:///%s", exampleScheme, exampleServiceName), creds, resolvers, meters, 30)
 }
-func (*exampleResolverBuilder) Scheme() string { return exampleScheme } // "example"
 
-type exampleResolver struct {
-	target     resolver.Target
-	cc         resolver.ClientConn
-	addrsStore map[string][]string
+// makeHashRPCs makes two calls per order. The calls of an order go to the same backend.
+func makeHashRPCs(cc *grpc.ClientConn, orderIDs []string) {
+	hwc := ecpb.NewEchoClient(cc)
+	for i := 0; i < 2; i++ {
+		for _, id := range orderIDs {
+			// The hash key is sent in the x-order-id metadata; loadbalancing.WithHashKey(ctx, id) sets it without metadata.
+			// 哈希键通过 x-order-id 元数据传递，也可以用 loadbalancing.WithHashKey 放入上下文
+			ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), "x-order-id", id), time.Second)
+			r, err := hwc.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "order " + id})
+			cancel()
+			if err != nil {
+				log.Printf("could not greet: %v", err)
+				continue
+			}
+			fmt.Println(r.Message)
+		}
+	}
 }
 
-func (r *exampleResolver) start() {
-	addrStrs := r.addrsStore[r.target.Endpoint]
-	addrs := make([]resolver.Address, len(addrStrs))
-	for i, s := range addrStrs {
-		addrs[i] = resolver.Address{Addr: s}
+// makeCanaryRPCs makes n calls with the x-canary metadata, which the routes of the control plane send to the canary.
+func makeCanaryRPCs(cc *grpc.ClientConn, n int) {
+	hwc := ecpb.NewEchoClient(cc)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), "x-canary", "true"), time.Second)
+		r, err := hwc.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "this is examples/load_balancing"})
+		cancel()
+		if err != nil {
+			log.Printf("could not greet: %v", err)
+			continue
+		}
+		fmt.Println(r.Message)
 	}
-	r.cc.UpdateState(resolver.State{Addresses: addrs})
 }
-func (*exampleResolver) ResolveNow(o resolver.ResolveNowOption) {}
-func (*exampleResolver) Close()                                 {}
 
-func init() {
-	resolver.Register(&exampleResolverBuilder{})
+// makeOutlierRPCs makes n calls over three seconds, so that the policy sees a few intervals.
+func makeOutlierRPCs(cc *grpc.ClientConn, n int) {
+	hwc := ecpb.NewEchoClient(cc)
+	for i := 0; i < n; i++ {
+		callUnaryEcho(hwc, "this is examples/load_balancing")
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// makeBreakerRPCs makes n calls over one ClientConn whose circuit_breaker policy wraps round_robin, so that every
+// backend gets its own circuit breaker per method. The picker skips the backends whose breaker is open and the calls
+// go to the others; they fail fast with UNAVAILABLE only when the breakers of all backends are open.
+// 断路器按后端地址和方法区分：负载均衡选中断路器打开的后端时跳过它，只有所有后端的断路器都打开时调用才快速失败
+func makeBreakerRPCs(target string, creds credentials.TransportCredentials, resolvers grpc.DialOption, meters *metrics.Metrics, n int) {
+	settings := circuitbreaker.DefaultSettings()
+	settings.MinRequests = 4
+	settings.OpenTimeout = 2 * time.Second
+	settings.OnStateChange = func(key circuitbreaker.Key, from, to circuitbreaker.State) {
+		log.Printf("circuit breaker %s %s : %s -> %s", key.Addr, key.Method, from, to)
+	}
+	breakers := circuitbreaker.New(settings)
+	if err := breakers.RegisterMetrics(meters.Meter("circuitbreaker")); err != nil {
+		log.Fatalf("failed to export the circuit breakers: %v", err)
+	}
+
+	conn, err := grpc.Dial(target,
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {"childPolicy": [{"round_robin": {}}]}}]}`,
+			circuitbreaker.Name)),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(breakers.UnaryClientInterceptor()),
+		resolvers,
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+
+	hwc := ecpb.NewEchoClient(conn)
+	for i := 0; i < n; i++ {
+		callUnaryEcho(hwc, "this is examples/load_balancing")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for _, st := range breakers.Snapshot() {
+		log.Printf("circuit breaker %s %s : state=%s requests=%d failures=%d rejected=%d transitions=%v",
+			st.Key.Addr, st.Key.Method, st.State, st.Requests, st.Failures, st.Rejected, st.Transitions)
+	}
 }
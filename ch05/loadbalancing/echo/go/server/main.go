@@ -2,26 +2,54 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"math/rand"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 )
 
 var (
-	addrs = []string{":50051", ":50052"}
+	failingAddr = flag.String("failing_addr", "", "address of the backend that fails part of its calls, e.g. :50052")
+	failingRate = flag.Float64("failure_rate", 0.5, "fraction of calls failed by the failing backend")
+	slowAddr    = flag.String("slow_addr", "", "address of the backend that answers slowly, e.g. :50052")
+	slowDelay   = flag.Duration("slow_delay", 50*time.Millisecond, "delay of the calls to the slow backend")
 )
 
+// logger logs with the request-scoped fields of the call context.
+// 使用调用的 context 记录日志时会自动带上请求相关的字段
+var logger = slog.Default()
+
 type ecServer struct {
-	addr string
+	ecpb.UnimplementedEchoServer
+	addr        string
+	failureRate float64
+	delay       time.Duration
 }
 
 func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	time.Sleep(s.delay)
+	if rand.Float64() < s.failureRate {
+		logger.DebugContext(ctx, "failing the call", "backend", s.addr)
+		return nil, status.Errorf(codes.Internal, "backend %s is failing", s.addr)
+	}
 	return &ecpb.EchoResponse{Message: fmt.Sprintf("%s (from %s)", req.Message, s.addr)}, nil
 }
 func (s *ecServer) ServerStreamingEcho(*ecpb.EchoRequest, ecpb.Echo_ServerStreamingEchoServer) error {
@@ -34,27 +62,99 @@ func (s *ecServer) BidirectionalStreamingEcho(ecpb.Echo_BidirectionalStreamingEc
 	return status.Errorf(codes.Unimplemented, "not implemented")
 }
 
-func startServer(addr string) {
+// exampleServiceName is the service the backends register with the registry.
+const exampleServiceName = "lb.example.grpc.io"
+
+// calls are the calls in flight of all backends, listed by the admin server at /rpcs.
+var calls = admin.NewCalls()
+
+func startServer(addr string, failureRate float64, delay time.Duration, registry config.Registry, creds credentials.TransportCredentials, logs *logging.Logging, opts ...lifecycle.Option) {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	ecpb.RegisterEchoServer(s, &ecServer{addr: addr})
-	log.Printf("serving on %s\n", addr)
-	if err := s.Serve(lis); err != nil {
+	// Each backend registers itself when registry.addr is set, and stays registered while it runs.
+	// 设置 registry.addr 后每个后端都向注册中心注册自己，运行期间通过心跳续约
+	self, err := discovery.RegisterSelf(registry, lis.Addr(), exampleServiceName)
+	if err != nil {
+		log.Fatalf("failed to register with the registry: %v", err)
+	}
+	// Every backend logs its calls with the x-request-id, e.g. the calls it fails with -log.level debug.
+	// 每个后端的日志都带上 x-request-id，例如使用 -log.level debug 查看它失败的调用
+	s := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), logs.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logs.StreamServerInterceptor()))...)
+	ecpb.RegisterEchoServer(s, &ecServer{addr: addr, failureRate: failureRate, delay: delay})
+	// Clients with a healthCheckConfig only call backends that are SERVING.
+	// 配置了 healthCheckConfig 的客户端只调用状态为 SERVING 的后端
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	slog.Info("serving", "addr", addr)
+	srv := lifecycle.New(s, append([]lifecycle.Option{
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("registry", self.Stop),
+	}, opts...)...)
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addrs: []string{":50051", ":50052"}, DrainTimeout: 10 * time.Second},
+		Log:    config.Log{Level: "info"},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	logger = logs.Logger("echo")
+	slog.SetDefault(logs.Logger("main"))
+	// Channelz, pprof, build info, config and the calls in flight of all backends on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供所有后端的 channelz、pprof、构建信息、配置和进行中的调用
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	registry := cfg.Registry
+	if len(cfg.Server.Addrs) > 1 {
+		// The backends register their own addresses.
+		registry.AdvertiseAddr = ""
+	}
+	// On SIGTERM every backend reports NOT_SERVING for server.shutdown_delay, so clients with a
+	// healthCheckConfig move away from it, then drains its calls and leaves the registry.
+	// 收到 SIGTERM 后每个后端先报告 NOT_SERVING 并等待 shutdown_delay，再等待调用完成并从注册中心注销
+	shutdown := []lifecycle.Option{
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+	}
 	var wg sync.WaitGroup
-	for _, addr := range addrs {
+	for _, addr := range cfg.Server.Addrs {
+		failureRate := 0.0
+		if addr == *failingAddr {
+			failureRate = *failingRate
+		}
+		var delay time.Duration
+		if addr == *slowAddr {
+			delay = *slowDelay
+		}
 		wg.Add(1)
-		go func(addr string) {
+		go func(addr string, failureRate float64, delay time.Duration) {
 			defer wg.Done()
-			startServer(addr)
-		}(addr)
+			startServer(addr, failureRate, delay, registry, creds, logs, shutdown...)
+		}(addr, failureRate, delay)
 	}
 	wg.Wait()
+	adm.Shutdown(context.Background())
 }
\ No newline at end of file
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// serviceConfigs is an endpoints file with the service configs the registry hands out, e.g. service_configs.yaml.
+var serviceConfigs = flag.String("service_configs", "", "endpoints file with the service configs of the services; none when empty")
+
+// loadServiceConfigs sets the service configs of the file in registry, and again whenever the file changes.
+// 从文件加载各服务的服务配置（超时、重试、负载均衡策略等），文件修改后自动更新，客户端随即生效
+func loadServiceConfigs(path string, registry *discovery.Registry) {
+	source, err := discovery.NewFileSource(path)
+	if err != nil {
+		log.Fatalf("failed to read the service configs: %v", err)
+	}
+	source.WatchFile(5 * time.Second)
+	for _, service := range source.Services() {
+		service := service
+		source.WatchServiceConfig(service, func(js string) {
+			if err := registry.SetServiceConfig(service, js); err != nil {
+				slog.Error("invalid service config", "service", service, "error", err)
+			}
+		})
+	}
+}
+
+func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50100"},
+		Log:    config.Log{Level: "info"},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	slog.SetDefault(logs.Logger("main"))
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	calls := admin.NewCalls()
+	s := grpc.NewServer(admin.ServerOptions(calls)...)
+	// Servers register their endpoints with a lease and renew it with heartbeats; clients watch the endpoints
+	// and the service config of the service.
+	// 服务端带租约注册自己的地址并通过心跳续约，客户端通过 Watch 流订阅端点和服务配置的变化
+	registry := discovery.NewRegistry()
+	if *serviceConfigs != "" {
+		loadServiceConfigs(*serviceConfigs, registry)
+	}
+	discovery.RegisterRegistryServer(s, registry)
+	// Register reflection service on gRPC server.
+	reflection.Register(s)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	slog.Info("registry serving", "addr", cfg.Server.Addr)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/logging"
+	"github.com/grpc-up-and-running/samples/common/go/traffic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// resources is the file with the listeners, routes, clusters and endpoints the control plane serves over ADS.
+var resources = flag.String("resources", "traffic.yaml", "file with the resources to serve")
+
+func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":18000"},
+		Log:    config.Log{Level: "info"},
+	})
+	logs, err := logging.New(logging.Options{
+		Level:       cfg.Log.Level,
+		Format:      cfg.Log.Format,
+		Packages:    cfg.Log.Packages,
+		SampleFirst: cfg.Log.SampleFirst,
+		SampleEvery: cfg.Log.SampleEvery,
+	})
+	if err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	slog.SetDefault(logs.Logger("main"))
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	// The resources are read again whenever the file changes, and the clients get them right away.
+	// 资源文件修改后自动重新加载，并立即推送给正在订阅的客户端（例如调整金丝雀流量比例）
+	store, err := traffic.NewFileStore(*resources)
+	if err != nil {
+		log.Fatalf("failed to read the resources: %v", err)
+	}
+	store.WatchFile(2 * time.Second)
+	defer store.Close()
+	// Clusters with a service instead of endpoints take the endpoints of the service from the registry.
+	// 设置 registry.addr 后，没有静态端点的集群从服务注册中心获取端点
+	var source discovery.Source
+	if cfg.Registry.Addr != "" {
+		conn, err := grpc.Dial(cfg.Registry.Addr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("did not connect to the registry: %v", err)
+		}
+		defer conn.Close()
+		source = discovery.NewRemoteSource(conn)
+	}
+	s := grpc.NewServer()
+	traffic.RegisterServer(s, store, source)
+	// Register reflection service on gRPC server.
+	reflection.Register(s)
+	slog.Info("xDS control plane serving", "resources", *resources, "addr", cfg.Server.Addr)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
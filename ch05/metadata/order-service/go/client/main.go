@@ -5,6 +5,8 @@ import (
 	"fmt"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -13,13 +15,20 @@ import (
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051"},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Setting up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	// Every call sends an x-request-id, so it can be found in the server logs.
+	// 每个调用都会发送 x-request-id，以便在服务器日志中找到对应的记录
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(requestid.StreamClientInterceptor()))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -51,9 +60,24 @@ func main() {
 	order1 := pb.Order{Id: "101", Items:[]string{"iPhone XS", "Mac Book Pro"}, Destination:"San Jose, CA", Price:2300.00}
 	// AddOrder 使用带有元数据的新上下文
 	// 传递头信息和 trailer 引用来存储一元 RPC 所返回的值
-	res, _ := client.AddOrder(ctxA, &order1, grpc.Header(&header), grpc.Trailer(&trailer))
+	// The request ID of the context is sent instead of a generated one.
+	// 使用上下文中指定的请求 ID，而不是自动生成
+	idCtx := requestid.NewContext(ctxA, requestid.New())
+	res, err := client.AddOrder(idCtx, &order1, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		// Failed calls carry the request ID in their error details.
+		// 失败的调用会在错误详情中携带请求 ID
+		id, _ := requestid.FromError(err)
+		log.Fatalf("AddOrder failed, request ID %s : %v", id, err)
+	}
 
 	log.Print("AddOrder Response -> ", res.Value)
+	if id, ok := requestid.FromMD(header); ok {
+		log.Printf("request ID from header : %s", id)
+	}
+	if id, ok := requestid.FromMD(trailer); ok {
+		log.Printf("request ID from trailer : %s", id)
+	}
 
 	// Reading the headers
 	// 处理头信息  读取头信息
@@ -105,7 +129,7 @@ func main() {
 
 
 	updateRes, _ := updateStream.CloseAndRecv()
-	log.Printf("Update Orders Res : ", updateRes)
+	log.Printf("Update Orders Res : %s", updateRes)
 }
 
 
@@ -2,23 +2,35 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	pb "github.com/grpc-up-and-running/samples/ch05/interceptors/order-service/go/order-service-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/pool"
 	"google.golang.org/grpc"
 	hwpb "google.golang.org/grpc/examples/helloworld/helloworld"
 	"google.golang.org/grpc/status"
 	"log"
+	"sync"
 	"time"
 )
 
-const (
-	address = "localhost:50051"
-)
+// poolSize is the number of connections the calls are spread over.
+var poolSize = flag.Int("pool_size", pool.DefaultSize, "connections to the server the calls are spread over")
 
 func main() {
-	// Setting up a connection to the server.
-	// 建立到服务器端的连接
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", Timeout: time.Second},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// Setting up a pool of connections to the server. Like a single connection it carries the calls of both
+	// services, but the streams are spread over several HTTP/2 connections, so they are not limited by the
+	// max concurrent streams of one connection.
+	// 建立到服务器端的连接池：两个服务的调用仍然共用这些连接，但流被分散到多个 HTTP/2 连接上，不受单个连接的最大并发流限制
+	conn, err := pool.New(cfg.Client.Target, pool.Options{Size: *poolSize, DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(creds)}})
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -27,14 +39,20 @@ func main() {
 
 	// *********** Calling the Order Management gRPC service **********
 	// 使用创建的 gRPC 连接来建立 OrderManagement 客户端
-	orderManagementClient := pb.NewOrderManagementClient(conn)
+	// The generated OrderManagement client takes a single *grpc.ClientConn: Conn picks the least loaded one.
+	// A client is made per call or batch of calls, so that the calls are still spread over the pool.
+	// 生成的 OrderManagement 客户端需要 *grpc.ClientConn，Conn 返回连接池中负载最低的连接，
+	// 因此每次调用（或每批调用）都新建客户端，使调用仍然分散到连接池中的各个连接
+	orderManagementClient := func() pb.OrderManagementClient {
+		return pb.NewOrderManagementClient(conn.Conn())
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
 	defer cancel()
 
 	// Add Order
 	order1 := pb.Order{Id: "101", Items:[]string{"iPhone XS", "Mac Book Pro"}, Destination:"San Jose, CA", Price:2300.00}
-	res, addErr := orderManagementClient.AddOrder(ctx, &order1)
+	res, addErr := orderManagementClient().AddOrder(ctx, &order1)
 
 	if addErr != nil {
 		got := status.Code(addErr)
@@ -49,7 +67,7 @@ func main() {
 	// 使用小童的 gRPC 连接来建立 Heool 客户端
 	helloClient := hwpb.NewGreeterClient(conn)
 
-	hwcCtx, hwcCancel := context.WithTimeout(context.Background(), time.Second)
+	hwcCtx, hwcCancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
 	defer hwcCancel()
 	helloResponse, err := helloClient.SayHello(hwcCtx, &hwpb.HelloRequest{Name: "gRPC Up and Running!"})
 	if err != nil {
@@ -57,14 +75,34 @@ func main() {
 	}
 	fmt.Println("Greeting: ", helloResponse.Message)
 
+	// Concurrent calls go to the connection with the fewest calls in flight.
+	// 并发调用会发送到进行中调用最少的连接
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Client.Timeout)
+			defer cancel()
+			if _, err := helloClient.SayHello(ctx, &hwpb.HelloRequest{Name: fmt.Sprintf("caller %d", i)}); err != nil {
+				log.Printf("SayHello failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := conn.Stats()
+	for i, c := range stats.Conns {
+		log.Printf("Pool connection %d to %s: %v, %d calls, %d in flight", i, stats.Target, c.State, c.Calls, c.InFlight)
+	}
+
 
 	// Get Order
-	//retrievedOrder , err := orderManagementClient.GetOrder(ctx, &wrapper.StringValue{Value: "106"})
+	//retrievedOrder , err := orderManagementClient().GetOrder(ctx, &wrapper.StringValue{Value: "106"})
 	//log.Print("GetOrder Response -> : ", retrievedOrder)
 
 
 	// Search Order
-	//searchStream, _ := orderManagementClient.SearchOrders(ctx, &wrapper.StringValue{Value: "Google"})
+	//searchStream, _ := orderManagementClient().SearchOrders(ctx, &wrapper.StringValue{Value: "Google"})
 	//for {
 	//	searchOrder, err := searchStream.Recv()
 	//	if err == io.EOF {
@@ -84,17 +122,17 @@ func main() {
 	//updOrder2 := pb.Order{Id: "103", Items:[]string{"Apple Watch S4", "Mac Book Pro", "iPad Pro"}, Destination:"San Jose, CA", Price:2800.00}
 	//updOrder3 := pb.Order{Id: "104", Items:[]string{"Google Home Mini", "Google Nest Hub", "iPad Mini"}, Destination:"Mountain View, CA", Price:2200.00}
 	//
-	//updateStream, _ := orderManagementClient.UpdateOrders(ctx)
+	//updateStream, _ := orderManagementClient().UpdateOrders(ctx)
 	//_ = updateStream.Send(&updOrder1)
 	//_ = updateStream.Send(&updOrder2)
 	//_ = updateStream.Send(&updOrder3)
 	//
 	//
 	//updateRes, _ := updateStream.CloseAndRecv()
-	//log.Printf("Update Orders Res : ", updateRes)
+	//log.Printf("Update Orders Res : %s", updateRes)
 	//
 	//// Process Order
-	//streamProcOrder, _ := orderManagementClient.ProcessOrders(ctx)
+	//streamProcOrder, _ := orderManagementClient().ProcessOrders(ctx)
 	//_ = streamProcOrder.Send(&wrapper.StringValue{Value:"102"})
 	//_ = streamProcOrder.Send(&wrapper.StringValue{Value:"103"})
 	//_ = streamProcOrder.Send(&wrapper.StringValue{Value:"104"})
@@ -15,17 +15,21 @@ import (
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch02/productinfo/go/product_info"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	// The target, the certificate and the expected server name can be changed with -client.target and the -client.tls flags.
+	// 目标地址、证书和服务器名称可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", TLS: config.TLS{
+			CAFile:     filepath.Join("..", "..", "certs", "server.crt"),
+			ServerName: "localhost",
+		}},
+	})
 	// 读取并解析公开证书，创建启用 TLS 的证书
-	creds, err := credentials.NewClientTLSFromFile(filepath.Join("ch06", "secure-channel", "certs", "server.crt"),
-		"localhost")
+	creds, err := credentials.NewClientTLSFromFile(cfg.Client.TLS.CAFile, cfg.Client.TLS.ServerName)
 	if err != nil {
 		log.Fatalf("failed to load credentials: %v", err)
 	}
@@ -47,7 +51,7 @@ func main() {
 
 	// Set up a connection to the server.
 	// 通过传入 dial 选项，建立到服务器的安全连接
-	conn, err := grpc.Dial(address, opts...)
+	conn, err := grpc.Dial(cfg.Client.Target, opts...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
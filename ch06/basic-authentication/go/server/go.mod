@@ -1,6 +1,6 @@
 module server
 
-go 1.17
+go 1.21
 
 require (
 	github.com/golang/protobuf v1.5.2 // indirect
@@ -12,3 +12,7 @@ require (
 	google.golang.org/grpc v1.48.0 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
+
+require github.com/grpc-up-and-running/samples/common/go v0.0.0
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../common/go
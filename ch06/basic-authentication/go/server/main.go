@@ -7,12 +7,13 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch02/productinfo/go/product_info"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -30,7 +31,6 @@ type server struct {
 }
 
 var (
-	port = ":50051"
 	errMissingMetadata = status.Errorf(codes.InvalidArgument, "missing metadata")
 	errInvalidToken    = status.Errorf(codes.Unauthenticated, "invalid credentials")
 )
@@ -59,9 +59,16 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 }
 
 func main() {
+	// The port and the certificates can be changed with -server.addr and the -server.tls flags.
+	// 端口和证书路径可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051", TLS: config.TLS{
+			CertFile: filepath.Join("..", "..", "certs", "server.crt"),
+			KeyFile:  filepath.Join("..", "..", "certs", "server.key"),
+		}},
+	})
 	// 读取和解析公钥 - 私钥对，并创建启用 TLS 的证书
-	cert, err := tls.LoadX509KeyPair(filepath.Join("ch06", "secure-channel", "certs", "server.crt"),
-		filepath.Join("ch06", "secure-channel", "certs", "server.key"))
+	creds, err := credentials.NewServerTLSFromFile(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
 	if err != nil {
 		log.Fatalf("failed to load key pair: %s", err)
 	}
@@ -69,7 +76,7 @@ func main() {
 	opts := []grpc.ServerOption{
 		// Enable TLS for all incoming connections.
 		// 添加证书作为 TLS 服务器凭证，从而为所有传入的连接启用 TLS
-		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.Creds(creds),
 		// 通过 TLS 服务器证书添加新的服务器选项（grpc.ServerOption）。
 		// grpc.UnaryInterceptor 是一个函数，
 		// 我们在其中添加拦截器来拦截所有来自客户端的请求。
@@ -77,14 +84,22 @@ func main() {
 		// 拦截器会将所有的客户端请求传递给该函数。
 		grpc.UnaryInterceptor(ensureValidBasicCredentials),
 	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	opts = append(opts, admin.ServerOptions(calls)...)
 	// 通过传入 TLS 服务器凭证来创建新的 gRPC 服务器实例
 	s := grpc.NewServer(opts...)
 	// 通过调用生成的API，将服务实现注册到新创建的gRPC服务器上
 	pb.RegisterProductInfoServer(s, &server{})
 	// Register reflection service on gRPC server.
 	//reflection.Register(s)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 	// 在端口上创建 TCP 监听器
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
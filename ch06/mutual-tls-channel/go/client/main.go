@@ -17,21 +17,24 @@ import (
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch02/productinfo/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 )
 
-var (
-	address = "localhost:50051"
-	hostname = "localhost"
-	crtFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "client.crt")
-	keyFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "client.key")
-	caFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "ca.crt")
-)
-
 func main() {
+	// The target and the certificates can be changed with -client.target and the -client.tls flags.
+	// 目标地址和证书路径可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", TLS: config.TLS{
+			CertFile:   filepath.Join("..", "..", "certs", "client.crt"),
+			KeyFile:    filepath.Join("..", "..", "certs", "client.key"),
+			CAFile:     filepath.Join("..", "..", "certs", "ca.crt"),
+			ServerName: "localhost",
+		}},
+	})
 	// Load the client certificates from disk
 	// 通过服务器端的证书和密钥直接创建 X.509 密钥对。
-	certificate, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	certificate, err := tls.LoadX509KeyPair(cfg.Client.TLS.CertFile, cfg.Client.TLS.KeyFile)
 	if err != nil {
 		log.Fatalf("could not load client key pair: %s", err)
 	}
@@ -39,7 +42,7 @@ func main() {
 	// Create a certificate pool from the certificate authority
 	// 通过 CA 创建证书池。
 	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(caFile)
+	ca, err := ioutil.ReadFile(cfg.Client.TLS.CAFile)
 	if err != nil {
 		log.Fatalf("could not read ca certificate: %s", err)
 	}
@@ -54,7 +57,7 @@ func main() {
 		// transport credentials.
 		// 添加传输凭证作为连接选项。这里，ServerName 必须与证书中的 Common Name 一致。
 		grpc.WithTransportCredentials( credentials.NewTLS(&tls.Config{
-			ServerName:   hostname, // NOTE: this is required!
+			ServerName:   cfg.Client.TLS.ServerName, // NOTE: this is required!
 			Certificates: []tls.Certificate{certificate},
 			RootCAs:      certPool,
 		})),
@@ -62,7 +65,7 @@ func main() {
 
 	// Set up a connection to the server.
 	// 传入连接选项，搭建到服务器的安全连接。
-	conn, err := grpc.Dial(address, opts...)
+	conn, err := grpc.Dial(cfg.Client.Target, opts...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
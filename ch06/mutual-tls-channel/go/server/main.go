@@ -13,6 +13,8 @@ import (
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch02/productinfo/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"io/ioutil"
@@ -49,16 +51,18 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 	return nil, errors.New("Product does not exist for the ID" + in.Value)
 }
 
-var (
-	port = ":50051"
-    crtFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "server.crt")
-    keyFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "server.key")
-    caFile = filepath.Join("ch06", "mutual-tls-channel", "certs", "ca.crt")
-)
-
 func main() {
+	// The port and the certificates can be changed with -server.addr and the -server.tls flags.
+	// 端口和证书路径可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051", TLS: config.TLS{
+			CertFile: filepath.Join("..", "..", "certs", "server.crt"),
+			KeyFile:  filepath.Join("..", "..", "certs", "server.key"),
+			CAFile:   filepath.Join("..", "..", "certs", "ca.crt"),
+		}},
+	})
 	// 通过服务器端的证书和密钥直接创建 X.509 密钥对。
-	certificate, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	certificate, err := tls.LoadX509KeyPair(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
 	if err != nil {
 		log.Fatalf("failed to load key pair: %s", err)
 	}
@@ -66,7 +70,7 @@ func main() {
 	// Create a certificate pool from the certificate authority
 	// 通过 CA 创建证书池。
 	certPool := x509.NewCertPool()
-	ca, err := ioutil.ReadFile(caFile)
+	ca, err := ioutil.ReadFile(cfg.Server.TLS.CAFile)
 	if err != nil {
 		log.Fatalf("could not read ca certificate: %s", err)
 	}
@@ -89,6 +93,9 @@ func main() {
 			)),
 	}
 
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	opts = append(opts, admin.ServerOptions(calls)...)
 	// 通过传入的 TLS 服务器凭证创建新的 gRPC 服务器实例。
 	s := grpc.NewServer(opts...)
 	// 通过调用生成的 API 将 gRPC 服务注册到新创建的 gRPC 服务器上。
@@ -96,8 +103,14 @@ func main() {
 	// Register reflection service on gRPC server.
 	//reflection.Register(s)
 
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+
 	// 在端口 50051 上创建 TCP 监听器。
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
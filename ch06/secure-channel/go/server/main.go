@@ -7,11 +7,12 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch02/productinfo/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"log"
@@ -19,12 +20,6 @@ import (
 	"path/filepath"
 )
 
-const (
-	port = ":50051"
-	crtFile = filepath.Join("ch06", "secure-channel", "certs", "server.crt")
-	keyFile = filepath.Join("ch06", "secure-channel", "certs", "server.key")
-)
-
 // server is used to implement ecommerce/product_info.
 type server struct {
 	productMap map[string]*pb.Product
@@ -54,24 +49,40 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 }
 
 func main() {
+	// The port and the key pair can be changed with -server.addr, -server.tls.cert_file and -server.tls.key_file.
+	// 端口和证书路径可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051", TLS: config.TLS{
+			CertFile: filepath.Join("..", "..", "certs", "server.crt"),
+			KeyFile:  filepath.Join("..", "..", "certs", "server.key"),
+		}},
+	})
 	//  读取和解析公钥–私钥对，并创建启用 TLS 的证书。
-	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	creds, err := credentials.NewServerTLSFromFile(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
 	if err != nil {
 		log.Fatalf("failed to load key pair: %s", err)
 	}
 	opts := []grpc.ServerOption{
 		// Enable TLS for all incoming connections.
 		// 添加证书作为 TLS 服务器凭证，从而为所有传入的连接启用TLS。
-		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.Creds(creds),
 	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	opts = append(opts, admin.ServerOptions(calls)...)
 	// 通过传入 TLS 服务器凭证来创建新的 gRPC 服务器实例。
 	s := grpc.NewServer(opts...)
 	// 通过调用生成的 API，将服务实现注册到新创建的 gRPC 服务器上。
 	pb.RegisterProductInfoServer(s, &server{})
 	// Register reflection service on gRPC server.
 	//reflection.Register(s)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 	// 在端口 50051 上创建 TCP 监听器。
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
@@ -1,6 +1,6 @@
 module github.com/grpc-up-and-running/samples/ch06/token-based-authentication/go/client
 
-go 1.15
+go 1.21
 
 require (
 	github.com/golang/protobuf v1.4.2
@@ -10,3 +10,7 @@ require (
 )
 
 replace productinfo/client => github.com/grpc-up-and-running/samples/ch02/productinfo/go/client v0.0.0-20200901064603-1f9de1e3efd9
+
+require github.com/grpc-up-and-running/samples/common/go v0.0.0
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../common/go
@@ -16,22 +16,25 @@ import (
 
 	pb "productinfo/client/ecommerce"
 
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
 )
 
-const (
-	address  = "localhost:50051"
-	hostname = "localhost"
-)
-
 func main() {
+	// The target, the certificate and the expected server name can be changed with -client.target and the -client.tls flags.
+	// 目标地址、证书和服务器名称可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051", TLS: config.TLS{
+			CAFile:     filepath.Join("..", "..", "certs", "server.crt"),
+			ServerName: "localhost",
+		}},
+	})
 	// Set up the credentials for the connection.
 	// 设置连接的凭证，需要提供 OAuth 令牌值来创建凭证。这里使用一个硬编码的字符串值作为令牌的值。
 	perRPC := oauth.NewOauthAccess(fetchToken())
 
-	crtFile := filepath.Join("..", "..", "certs", "server.crt")
-	creds, err := credentials.NewClientTLSFromFile(crtFile, hostname)
+	creds, err := credentials.NewClientTLSFromFile(cfg.Client.TLS.CAFile, cfg.Client.TLS.ServerName)
 	if err != nil {
 		log.Fatalf("failed to load credentials: %v", err)
 	}
@@ -44,7 +47,7 @@ func main() {
 	}
 
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, opts...)
+	conn, err := grpc.Dial(cfg.Client.Target, opts...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
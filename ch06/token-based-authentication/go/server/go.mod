@@ -1,12 +1,29 @@
 module github.com/grpc-up-and-running/samples/ch06/token-based-authentication/go/server
 
-go 1.15
+go 1.21
 
 require (
-	github.com/golang/protobuf v1.5.2
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/google/uuid v1.1.2
 	google.golang.org/grpc v1.48.0
 	productinfo/server v0.0.0-20200901064603-1f9de1e3efd9
 )
 
 replace productinfo/server => github.com/grpc-up-and-running/samples/ch02/productinfo/go/server v0.0.0-20200901064603-1f9de1e3efd9
+
+require github.com/grpc-up-and-running/samples/common/go v0.0.0
+
+require (
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/kr/pretty v0.3.1 // indirect
+	github.com/rogpeppe/go-internal v1.10.0 // indirect
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20200825200019-8632dd797987 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/grpc-up-and-running/samples/common/go => ../../../../common/go
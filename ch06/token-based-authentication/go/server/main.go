@@ -7,7 +7,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"log"
 	"net"
@@ -17,6 +16,8 @@ import (
 	pb "productinfo/server/ecommerce"
 
 	"github.com/google/uuid"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -30,9 +31,6 @@ type server struct {
 }
 
 var (
-	port               = ":50051"
-	crtFile            = filepath.Join("..", "..", "certs", "server.crt")
-	keyFile            = filepath.Join("..", "..", "certs", "server.key")
 	errMissingMetadata = status.Errorf(codes.InvalidArgument, "missing metadata")
 	errInvalidToken    = status.Errorf(codes.Unauthenticated, "invalid token")
 )
@@ -61,24 +59,40 @@ func (s *server) GetProduct(ctx context.Context, in *pb.ProductID) (*pb.Product,
 }
 
 func main() {
-	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	// The port and the certificates can be changed with -server.addr and the -server.tls flags.
+	// 端口和证书路径可以通过配置文件、环境变量或命令行参数修改
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051", TLS: config.TLS{
+			CertFile: filepath.Join("..", "..", "certs", "server.crt"),
+			KeyFile:  filepath.Join("..", "..", "certs", "server.key"),
+		}},
+	})
+	creds, err := credentials.NewServerTLSFromFile(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
 	if err != nil {
 		log.Fatalf("failed to load key pair: %s", err)
 	}
 	opts := []grpc.ServerOption{
 		// Enable TLS for all incoming connections.
-		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.Creds(creds),
 		// 添加新的服务器选项（grpc.ServerOption）以及 TLS 服务器证书。
 		// 借助 grpc.UnaryInterceptor 函数，添加拦截器以拦截所有来自客户端的请求。
 		grpc.UnaryInterceptor(ensureValidToken),
 	}
 
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	opts = append(opts, admin.ServerOptions(calls)...)
 	s := grpc.NewServer(opts...)
 	pb.RegisterProductInfoServer(s, &server{})
 	// Register reflection service on gRPC server.
 	//reflection.Register(s)
 
-	lis, err := net.Listen("tcp", port)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
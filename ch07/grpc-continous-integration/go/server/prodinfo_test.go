@@ -13,6 +13,7 @@ import (
 )
 
 const (
+	port    = ":50051"
 	address = "localhost:50051"
 	bufSize = 1024 * 1024
 
@@ -12,16 +12,23 @@ import (
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-docker/go/proto-gen"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/traffic"
 	"google.golang.org/grpc"
 )
 
-const (
-	address = "productinfo:50051"
-)
-
 func main() {
+	// GRPC_SAMPLE_CLIENT_TARGET or -client.target overrides the address, e.g. xds:///ecommerce.ProductInfo to
+	// route through the xDS control plane of the GRPC_XDS_BOOTSTRAP or GRPC_XDS_BOOTSTRAP_CONFIG bootstrap.
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "productinfo:50051"},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(cfg.Client.Target, grpc.WithTransportCredentials(creds), grpc.WithResolvers(traffic.NewBuilder(traffic.Bootstrap{})))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -14,17 +14,25 @@ import (
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-docker/go/proto-gen"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/healthcheck"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	port = ":50051"
+	// livenessService is the health service name of the Kubernetes liveness probe. It has no checks, so it
+	// stays SERVING while the process serves, whatever the state of the downstreams of ecommerce.ProductInfo.
+	livenessService = "liveness"
 )
 
 // server is used to implement ecommerce/product_info.
 type server struct {
-	productMap map[string]*pb.Product
+	products *productStore
 }
 
 // AddProduct implements ecommerce.AddProduct
@@ -34,17 +42,16 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 		log.Fatal(err)
 	}
 	in.Id = out.String()
-	if s.productMap == nil {
-		s.productMap = make(map[string]*pb.Product)
+	if err := s.products.Put(in); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
 	}
-	s.productMap[in.Id] = in
 	log.Printf("New product added - ID : %s, Name : %s", in.Id, in.Name)
 	return &wrapper.StringValue{Value: in.Id}, nil
 }
 
 // GetProduct implements ecommerce.GetProduct
 func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.Product, error) {
-	value, exists := s.productMap[in.Value]
+	value, exists := s.products.Get(in.Value)
 	if exists {
 		log.Printf("New product retrieved - ID : %s", in)
 		return value, nil
@@ -54,15 +61,50 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 }
 
 func main() {
-	lis, err := net.Listen("tcp", port)
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051"},
+	})
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterProductInfoServer(s, &server{})
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	s := grpc.NewServer(append(admin.ServerOptions(calls), grpc.Creds(creds))...)
+	products := newProductStore(maxProducts)
+	pb.RegisterProductInfoServer(s, &server{products: products})
+	// Register the health service used by the Kubernetes gRPC probes. The checker drives the status of
+	// ecommerce.ProductInfo for the readiness probe and of the liveness service for the liveness probe.
+	// ecommerce.ProductInfo is SERVING only while the product store takes new products.
+	// 只有商品存储可以写入时 ecommerce.ProductInfo 才处于 SERVING 状态
+	hs, checker := healthcheck.Register(s)
+	checker.AddService("ecommerce.ProductInfo",
+		healthcheck.NamedCheck{Name: "product-store", Check: products.Ping})
+	checker.AddService(livenessService)
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
-	if err := s.Serve(lis); err != nil {
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, e.g. -admin.addr 127.0.0.1:9091
+	// 设置 admin.addr 后在单独的端口上提供 channelz、pprof、构建信息、配置和进行中的调用
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: s, Health: hs})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	// On SIGTERM (e.g. when Kubernetes stops the pod) the server reports NOT_SERVING for server.shutdown_delay, then
+	// in-flight calls get the drain timeout to finish.
+	// 收到 SIGTERM 后先报告 NOT_SERVING 并等待 shutdown_delay，再给正在处理的调用 drain timeout 的时间完成
+	srv := lifecycle.New(s,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("healthcheck", checker.Stop),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown))
+	checker.Start()
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	log.Println("server stopped")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pb "github.com/grpc-up-and-running/samples/ch07/grpc-docker/go/proto-gen"
+)
+
+// maxProducts is the number of products a server keeps. A full server takes no new products, so it reports
+// NOT_SERVING and Kubernetes sends the calls to the other replicas.
+const maxProducts = 100000
+
+// errStoreFull is returned when the store holds maxProducts products.
+var errStoreFull = errors.New("product store is full")
+
+// productStore keeps the products by ID. It is safe for concurrent use by the handlers.
+// productStore 保存商品，可以被多个处理器并发使用；存满后拒绝新商品，健康检查报告 NOT_SERVING
+type productStore struct {
+	mu       sync.RWMutex
+	products map[string]*pb.Product
+	max      int
+}
+
+func newProductStore(max int) *productStore {
+	return &productStore{products: make(map[string]*pb.Product), max: max}
+}
+
+// Put stores product under its ID, or returns errStoreFull.
+func (s *productStore) Put(product *pb.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.products) >= s.max {
+		return errStoreFull
+	}
+	s.products[product.Id] = product
+	return nil
+}
+
+// Get returns the product with the ID id.
+func (s *productStore) Get(id string) (*pb.Product, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	product, ok := s.products[id]
+	return product, ok
+}
+
+// Ping reports whether the store can take new products; it is the readiness check of ecommerce.ProductInfo.
+// Ping 用于就绪检查，存储已满时返回错误
+func (s *productStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.products) >= s.max {
+		return errStoreFull
+	}
+	return nil
+}
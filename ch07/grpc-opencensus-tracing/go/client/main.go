@@ -11,73 +11,78 @@ import (
 	"time"
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
-	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	pb "github.com/grpc-up-and-running/samples/ch07/grpc-opencensus-tracing/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
+	"go.opentelemetry.io/otel/codes"
 	"google.golang.org/grpc"
-	"go.opencensus.io/plugin/ocgrpc"
-    "go.opencensus.io/trace"
-    "contrib.go.opencensus.io/exporter/jaeger"
-)
-
-const (
-	address = "localhost:50051"
 )
 
 func main() {
-    initTracing()
+	// tracing.endpoint is the OTLP/HTTP endpoint of Jaeger or an OpenTelemetry Collector.
+	cfg := config.MustLoad(config.Config{
+		Client:  config.Client{Target: "localhost:50051"},
+		Tracing: config.Tracing{Endpoint: "localhost:4318", SampleRate: 1},
+	})
+	tp, err := initTracing(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer tp.Shutdown(context.Background())
 
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address,
-	        grpc.WithInsecure(),
-	        )
+	conn, err := grpc.Dial(cfg.Client.Target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(tp.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(tp.StreamClientInterceptor()),
+	)
 	if err != nil {
 		log.Fatalf("Can't connect: %v", err)
 	}
 	defer conn.Close()
 
 	c := pb.NewProductInfoClient(conn)
+	tracer := tp.Tracer("ecommerce.ProductInfoClient")
 	for {
-	    ctx, span := trace.StartSpan(context.Background(), "ecommerce.ProductInfoClient")
-        // Contact the server and print out its response.
-        name := "Sumsung S10"
-        description := "Samsung Galaxy S10 is the latest smart phone, launched in February 2019"
-        price := float32(700.0)
-        r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price})
-        if err != nil {
-            span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: err.Error()})
-            log.Fatalf("Could not add product: %v", err)
-        }
-        log.Printf("Product ID: %s added successfully", r.Value)
-
-        product, err := c.GetProduct(ctx, &wrapper.StringValue{Value: r.Value})
-        if err != nil {
-            span.SetStatus(trace.Status{Code: trace.StatusCodeInternal, Message: err.Error()})
-            log.Fatalf("Could not get product: %v", err)
-        }
-        log.Printf("Product: ", product.String())
-        span.End()
-        time.Sleep(3 * time.Second)
-
-    }
+		// Both calls are children of this span, and so are their server spans.
+		ctx, span := tracer.Start(context.Background(), "ecommerce.ProductInfoClient")
+		// Contact the server and print out its response.
+		name := "Sumsung S10"
+		description := "Samsung Galaxy S10 is the latest smart phone, launched in February 2019"
+		price := float32(700.0)
+		r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			log.Fatalf("Could not add product: %v", err)
+		}
+		log.Printf("Product ID: %s added successfully", r.Value)
 
+		product, err := c.GetProduct(ctx, &wrapper.StringValue{Value: r.Value})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			log.Fatalf("Could not get product: %v", err)
+		}
+		log.Printf("Product: %s", product.String())
+		span.End()
+		time.Sleep(3 * time.Second)
+	}
 }
 
-func initTracing() {
-    // This is a demo app with low QPS. trace.AlwaysSample() is used here
-    // to make sure traces are available for observation and analysis.
-    // In a production environment or high QPS setup please use
-    // trace.ProbabilitySampler set at the desired probability.
-    trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
-    agentEndpointURI := "localhost:6831"
-    collectorEndpointURI := "http://localhost:14268/api/traces"
-     exporter, err := jaeger.NewExporter(jaeger.Options{
-             CollectorEndpoint: collectorEndpointURI,
-             AgentEndpoint: agentEndpointURI,
-             ProjectID:    "product_info",
-
-     })
-     if err != nil {
-        log.Fatal(err)
-     }
-     trace.RegisterExporter(exporter)
-
+func initTracing(cfg config.Tracing) (*tracing.Tracing, error) {
+	// This is a demo app with low QPS. A sample rate of 1 is used by default
+	// to make sure traces are available for observation and analysis.
+	// In a production environment or high QPS setup please use
+	// a lower sample rate, e.g. -tracing.sample_rate 0.1.
+	return tracing.Setup(tracing.Options{
+		ServiceName: "product_info",
+		Exporter:    cfg.Exporter,
+		Endpoint:    cfg.Endpoint,
+		SampleRate:  cfg.SampleRate,
+	})
 }
@@ -7,21 +7,24 @@ package main
 
 import (
 	"context"
-	"errors"
 	"log"
 	"net"
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
-	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	pb "github.com/grpc-up-and-running/samples/ch07/grpc-opencensus-tracing/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
-	"go.opencensus.io/trace"
-	"contrib.go.opencensus.io/exporter/jaeger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-const (
-	port = ":50051"
-)
+// tracer creates the spans of the service's own code. They are children of
+// the span the server interceptor starts for the call.
+var tracer = otel.Tracer("ecommerce.ProductInfo")
 
 // server is used to implement ecommerce/product_info.
 type server struct {
@@ -30,7 +33,7 @@ type server struct {
 
 // AddProduct implements ecommerce.AddProduct
 func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.StringValue, error) {
-	ctx, span := trace.StartSpan(ctx, "ecommerce.AddProduct")
+	_, span := tracer.Start(ctx, "ecommerce.AddProduct")
 	defer span.End()
 	out, err := uuid.NewUUID()
 	if err != nil {
@@ -46,52 +49,66 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 
 // GetProduct implements ecommerce.GetProduct
 func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.Product, error) {
-	ctx, span := trace.StartSpan(ctx, "ecommerce.GetProduct")
+	_, span := tracer.Start(ctx, "ecommerce.GetProduct")
 	defer span.End()
 	value, exists := s.productMap[in.Value]
 	if exists {
-		return value, status.New(codes.OK, "").Err()
+		return value, nil
 	}
-	return nil, status.Errorf(codes.NotFound, "Product does not exist.", in.Value)
+	return nil, status.Errorf(codes.NotFound, "Product does not exist for the ID %s", in.Value)
 }
 
 func main() {
+	// tracing.endpoint is the OTLP/HTTP endpoint of Jaeger or an OpenTelemetry Collector.
+	// admin.addr enables the admin server with channelz, pprof, build info and the calls in flight.
+	cfg := config.MustLoad(config.Config{
+		Server:  config.Server{Addr: ":50051"},
+		Tracing: config.Tracing{Endpoint: "localhost:4318", SampleRate: 1},
+	})
+	// initialize OpenTelemetry tracing
+	tp, err := initTracing(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer tp.Shutdown(context.Background())
 
-	// Create a gRPC Server with stats handler.
-	grpcServer := grpc.NewServer()
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	// Create a gRPC Server with the tracing interceptors.
+	calls := admin.NewCalls()
+	grpcServer := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(tp.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(tp.StreamServerInterceptor()),
+	)...)
 	pb.RegisterProductInfoServer(grpcServer, &server{})
+	// The admin server is protected by the auth.tokens of the config, e.g. -auth.tokens operator=some-secret-token.
+	// 管理端口使用配置中的 auth.tokens 做认证
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: grpcServer}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-    // initialize opencensus jaeger exporter
-	initTracing()
-
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
-
-func initTracing() {
-    // This is a demo app with low QPS. trace.AlwaysSample() is used here
-    // to make sure traces are available for observation and analysis.
-    // In a production environment or high QPS setup please use
-    // trace.ProbabilitySampler set at the desired probability.
-    trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
-    agentEndpointURI := "localhost:6831"
-    collectorEndpointURI := "http://localhost:14268/api/traces"
-     exporter, err := jaeger.NewExporter(jaeger.Options{
-             CollectorEndpoint: collectorEndpointURI,
-             AgentEndpoint: agentEndpointURI,
-             ServiceName: "product_info",
-
-     })
-     if err != nil {
-        log.Fatal(err)
-     }
-     trace.RegisterExporter(exporter)
-
+func initTracing(cfg config.Tracing) (*tracing.Tracing, error) {
+	// This is a demo app with low QPS. A sample rate of 1 is used by default
+	// to make sure traces are available for observation and analysis.
+	// In a production environment or high QPS setup please use
+	// a lower sample rate, e.g. -tracing.sample_rate 0.1.
+	return tracing.Setup(tracing.Options{
+		ServiceName: "product_info",
+		Exporter:    cfg.Exporter,
+		Endpoint:    cfg.Endpoint,
+		SampleRate:  cfg.SampleRate,
+	})
 }
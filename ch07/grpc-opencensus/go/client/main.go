@@ -12,17 +12,17 @@ import (
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/examples/exporter"
 )
 
-const (
-	address = "localhost:50051"
-)
-
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Client: config.Client{Target: "localhost:50051"},
+	})
 	// Register stats and trace exporters to export
 	// the collected data.
 	view.RegisterExporter(&exporter.PrintExporter{})
@@ -32,10 +32,14 @@ func main() {
         log.Fatal(err)
     }
 
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address,
+	conn, err := grpc.Dial(cfg.Client.Target,
 			grpc.WithStatsHandler(&ocgrpc.ClientHandler{}),
-	        grpc.WithInsecure(),
+	        grpc.WithTransportCredentials(creds),
 	        )
 	if err != nil {
 		log.Fatalf("Can't connect: %v", err)
@@ -15,6 +15,8 @@ import (
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 	"google.golang.org/grpc"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/stats/view"
@@ -22,10 +24,6 @@ import (
 	"go.opencensus.io/examples/exporter"
 )
 
-const (
-	port = ":50051"
-)
-
 // server is used to implement ecommerce/product_info.
 type server struct {
 	productMap map[string]*pb.Product
@@ -55,6 +53,9 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 }
 
 func main() {
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":50051"},
+	})
 	// Start z-Pages server.
 	go func() {
 		mux := http.NewServeMux()
@@ -71,11 +72,22 @@ func main() {
 		log.Fatal(err)
 	}
 
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Create a gRPC Server with stats handler.
-	grpcServer := grpc.NewServer(grpc.StatsHandler(&ocgrpc.ServerHandler{}))
+	calls := admin.NewCalls()
+	grpcServer := grpc.NewServer(append(admin.ServerOptions(calls), grpc.Creds(creds), grpc.StatsHandler(&ocgrpc.ServerHandler{}))...)
 	pb.RegisterProductInfoServer(grpcServer, &server{})
+	// zpages show the OpenCensus stats and spans; the admin server adds channelz,
+	// pprof, build info and the calls in flight, e.g. -admin.addr 127.0.0.1:9091.
+	// It is protected by the auth.tokens of the config, e.g. -auth.tokens operator=some-secret-token.
+	if _, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: grpcServer}); err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 
-	lis, err := net.Listen("tcp", port)
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
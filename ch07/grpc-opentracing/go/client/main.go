@@ -7,23 +7,41 @@ package main
 
 import (
 	"context"
-	"github.com/daneshk/samples/ch07/grpc-opentracing/go/tracer"
-	wrapper "github.com/golang/protobuf/ptypes/wrappers"
-	grpcopentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	pb "github.com/grpc-up-and-running/samples/ch07/grpc-opentracing/go/proto"
-	"google.golang.org/grpc"
 	"log"
 	"time"
-)
 
-const (
-	address = "localhost:50051"
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	pb "github.com/grpc-up-and-running/samples/ch07/grpc-opentracing/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
+	// tracing.endpoint is the OTLP/HTTP endpoint of Jaeger or an OpenTelemetry Collector.
+	cfg := config.MustLoad(config.Config{
+		Client:  config.Client{Target: "localhost:50051"},
+		Tracing: config.Tracing{Endpoint: "localhost:4318", SampleRate: 1},
+	})
+	// initialize OpenTelemetry tracing
+	tracer, err := tracing.Setup(tracing.Options{
+		ServiceName: "product_mgt",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	defer tracer.Shutdown(context.Background())
 
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Set up a connection to the server.
-	conn, err := NewClientConn(address)
+	conn, err := NewClientConn(cfg.Client.Target, tracer, creds)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 		return
@@ -54,23 +72,13 @@ func main() {
 	}
 }
 
-func NewClientConn(address string) (*grpc.ClientConn, error) {
-	// initialize jaegertracer
-	jaegertracer, closer, err := tracer.NewTracer("product_mgt")
-	if err != nil {
-		return grpc.Dial(address, grpc.WithInsecure())
-	}
-	defer closer.Close()
-
-	// initialize client with tracing interceptor using grpc client side chaining
+func NewClientConn(address string, tracer *tracing.Tracing, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
+	// initialize client with tracing interceptors, which send the span
+	// context to the server as traceparent metadata
 	return grpc.Dial(
 		address,
-		grpc.WithInsecure(),
-		grpc.WithStreamInterceptor(
-			grpcopentracing.StreamClientInterceptor(grpcopentracing.WithTracer(jaegertracer)),
-		),
-		grpc.WithUnaryInterceptor(
-			grpcopentracing.UnaryClientInterceptor(grpcopentracing.WithTracer(jaegertracer)),
-		),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStreamInterceptor(tracer.StreamClientInterceptor()),
+		grpc.WithUnaryInterceptor(tracer.UnaryClientInterceptor()),
 	)
 }
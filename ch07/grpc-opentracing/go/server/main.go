@@ -8,22 +8,20 @@ package main
 import (
 	"context"
 	"errors"
-	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing/opentracing-go"
 	"log"
 	"net"
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-opentracing/go/proto"
-	"github.com/grpc-up-and-running/samples/ch07/grpc-opentracing/go/tracer"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/healthcheck"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/tracing"
 	"google.golang.org/grpc"
 )
 
-const (
-	port = ":50051"
-)
-
 // server is used to implement ecommerce/product_info.
 type server struct {
 	productMap map[string]*pb.Product
@@ -53,37 +51,69 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 }
 
 func main() {
-	lis, err := net.Listen("tcp", port)
+	// tracing.endpoint is the OTLP/HTTP endpoint of Jaeger or an OpenTelemetry Collector.
+	// admin.addr enables the admin server with channelz, pprof, build info and the calls in flight.
+	cfg := config.MustLoad(config.Config{
+		Server:  config.Server{Addr: ":50051"},
+		Tracing: config.Tracing{Endpoint: "localhost:4318", SampleRate: 1},
+	})
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
+	// initialize OpenTelemetry tracing
+	tracer, err := tracing.Setup(tracing.Options{
+		ServiceName: "product_mgt",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Create a gRPC Server with gRPC interceptor.
-	grpcServer := NewServer()
+	calls := admin.NewCalls()
+	grpcServer := NewServer(tracer, calls, grpc.Creds(creds))
 
 	pb.RegisterProductInfoServer(grpcServer, &server{})
+	hs, checker := healthcheck.Register(grpcServer)
+	checker.AddService("ecommerce.ProductInfo")
+	// The admin server is protected by the auth.tokens of the config, e.g. -auth.tokens operator=some-secret-token.
+	// 管理端口使用配置中的 auth.tokens 做认证
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: grpcServer, Health: hs})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 
-	if err := grpcServer.Serve(lis); err != nil {
+	// On SIGTERM the server reports NOT_SERVING for server.shutdown_delay, lets in-flight calls finish and then
+	// flushes the spans still buffered by the exporter.
+	// 收到 SIGTERM 后先报告 NOT_SERVING，等待正在处理的调用完成，最后导出缓存中的 span
+	srv := lifecycle.New(grpcServer,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("healthcheck", checker.Stop),
+		lifecycle.WithShutdownHook("tracing", tracer.Shutdown),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown))
+	checker.Start()
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	log.Println("server stopped")
 }
 
-func NewServer() *grpc.Server {
-	// initialize jaegertracer
-	jaegertracer, closer, err := tracer.NewTracer("product_mgt")
-	if err != nil {
-		log.Fatalln(err)
-		return grpc.NewServer()
-	}
-	defer closer.Close()
-
-	opentracing.SetGlobalTracer(jaegertracer)
-
-	// initialize grpc server with chained interceptors
-	server := grpc.NewServer(
-		grpc.UnaryInterceptor(
-			// add opentracing unary interceptor to chain
-			grpc_opentracing.UnaryServerInterceptor(grpc_opentracing.WithTracer(jaegertracer)),
-		),
-	)
-	return server
+func NewServer(tracer *tracing.Tracing, calls *admin.Calls, opts ...grpc.ServerOption) *grpc.Server {
+	// initialize grpc server with the tracing interceptors, which continue
+	// the trace of the client from the traceparent metadata, after the
+	// interceptors tracking the calls in flight for the admin server
+	opts = append(admin.ServerOptions(calls), opts...)
+	return grpc.NewServer(append(opts,
+		grpc.ChainUnaryInterceptor(tracer.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(tracer.StreamServerInterceptor()),
+	)...)
 }
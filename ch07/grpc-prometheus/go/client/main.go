@@ -7,71 +7,89 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"time"
 	"net/http"
+	"time"
 
-	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	// Registers the load balancing policies a service config from the registry may select.
+	_ "github.com/grpc-up-and-running/samples/common/go/loadbalancing"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
 	"google.golang.org/grpc"
-	"github.com/prometheus/client_golang/prometheus"
-    "github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-const (
-	address = "localhost:50051"
 )
 
 func main() {
-	// Create a metrics registry.
-	reg := prometheus.NewRegistry()
-	// Create some standard client metrics.
-	grpcMetrics := grpc_prometheus.NewClientMetrics()
-	// Register client metrics to registry.
-	reg.MustRegister(grpcMetrics)
+	cfg := config.MustLoad(config.Config{
+		Client:  config.Client{Target: "localhost:50051"},
+		Metrics: config.Metrics{PrometheusAddr: "0.0.0.0:9094"},
+	})
+	// Create the OpenTelemetry metrics, exported to Prometheus.
+	m, err := metrics.Setup(metrics.Options{ServiceName: "product_mgt_client"})
+	if err != nil {
+		log.Fatalf("failed to set up metrics: %v", err)
+	}
+	defer m.Shutdown(context.Background())
 
+	// With registry.addr, a client.target of registry:///ecommerce.ProductInfo takes the servers and the service
+	// config of ecommerce.ProductInfo from the registry. The service config sets the timeouts, retries,
+	// wait-for-ready and load balancing policy of the calls.
+	// 设置 registry.addr 后，目标 registry:///ecommerce.ProductInfo 从注册中心获取服务端地址和服务配置（超时、重试、wait-for-ready、负载均衡策略）
+	withRegistry, closeRegistry, err := discovery.WithRegistry(cfg.Registry)
+	if err != nil {
+		log.Fatalf("invalid registry config: %v", err)
+	}
+	defer closeRegistry()
+
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address,
-			grpc.WithUnaryInterceptor(grpcMetrics.UnaryClientInterceptor()),
-	        grpc.WithInsecure(),
-	        )
+	conn, err := grpc.Dial(cfg.Client.Target, withRegistry,
+		grpc.WithUnaryInterceptor(m.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(m.StreamClientInterceptor()),
+		grpc.WithTransportCredentials(creds),
+	)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
 	defer conn.Close()
 
-    // Create a HTTP server for prometheus.
-    httpServer := &http.Server{Handler: promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), Addr: fmt.Sprintf("0.0.0.0:%d", 9094)}
+	// Create a HTTP server for prometheus.
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	httpServer := &http.Server{Handler: mux, Addr: cfg.Metrics.PrometheusAddr}
 
-    // Start your http server for prometheus.
-    go func() {
-        if err := httpServer.ListenAndServe(); err != nil {
-            log.Fatal("Unable to start a http server.")
-        }
-    }()
+	// Start your http server for prometheus.
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil {
+			log.Fatal("Unable to start a http server.")
+		}
+	}()
 
 	c := pb.NewProductInfoClient(conn)
 
 	for {
-        // Contact the server and print out its response.
-        name := "Sumsung S10"
-        description := "Samsung Galaxy S10 is the latest smart phone, launched in February 2019"
-        price := float32(700.0)
-        ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-        defer cancel()
-        r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price})
-        if err != nil {
-            log.Fatalf("Could not add product: %v", err)
-        }
-        log.Printf("Product ID: %s added successfully", r.Value)
+		// Contact the server and print out its response.
+		name := "Sumsung S10"
+		description := "Samsung Galaxy S10 is the latest smart phone, launched in February 2019"
+		price := float32(700.0)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price})
+		if err != nil {
+			log.Fatalf("Could not add product: %v", err)
+		}
+		log.Printf("Product ID: %s added successfully", r.Value)
 
-        product, err := c.GetProduct(ctx, &wrapper.StringValue{Value: r.Value})
-        if err != nil {
-            log.Fatalf("Could not get product: %v", err)
-        }
-        log.Printf("Product: ", product.String())
-        time.Sleep(3 * time.Second)
-    }
+		product, err := c.GetProduct(ctx, &wrapper.StringValue{Value: r.Value})
+		if err != nil {
+			log.Fatalf("Could not get product: %v", err)
+		}
+		log.Printf("Product: %s", product.String())
+		cancel()
+		time.Sleep(3 * time.Second)
+	}
 }
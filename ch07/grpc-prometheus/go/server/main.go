@@ -7,8 +7,6 @@ package main
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"log"
 	"net"
 	"net/http"
@@ -16,24 +14,33 @@ import (
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "github.com/grpc-up-and-running/samples/ch07/grpc-prometheus/go/proto"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/healthcheck"
+	"github.com/grpc-up-and-running/samples/common/go/lifecycle"
+	"github.com/grpc-up-and-running/samples/common/go/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"google.golang.org/grpc"
-	"github.com/grpc-ecosystem/go-grpc-prometheus"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-const (
-	port = ":50051"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // server is used to implement ecommerce/product_info.
 type server struct {
 	productMap map[string]*pb.Product
+	// productsAdded counts the added products by name. Names come from the
+	// clients, so only the first ten get their own series.
+	productsAdded metric.Int64Counter
+	names         *metrics.Capped
+	// lookups counts the GetProduct calls by result, found or not_found.
+	lookups metric.Int64Counter
 }
 
 // AddProduct implements ecommerce.AddProduct
 func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.StringValue, error) {
-	customizedCounterMetric.WithLabelValues(in.Name).Inc()
+	s.productsAdded.Add(ctx, 1, metric.WithAttributes(attribute.String("name", s.names.Value(in.Name))))
 	out, err := uuid.NewUUID()
 	if err != nil {
 		log.Fatal(err)
@@ -50,56 +57,106 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.Product, error) {
 	value, exists := s.productMap[in.Value]
 	if exists {
+		s.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "found")))
 		return value, nil
 	}
-	return nil, errors.New("Product does not exist for the ID" + in.Value)
+	s.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "not_found")))
+	return nil, status.Errorf(codes.NotFound, "Product does not exist for the ID %s", in.Value)
 }
 
-var (
-	// Create a metrics registry.
-	reg = prometheus.NewRegistry()
-
-	// Create some standard server metrics.
-	grpcMetrics = grpc_prometheus.NewServerMetrics()
-
-    customizedCounterMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
-        Name: "product_mgt_server_handle_count",
-        Help: "Total number of RPCs handled on the server.",
-    }, []string{"name"})
-)
-
-func init() {
-	// Register standard server metrics and customized metrics to registry.
-	reg.MustRegister(grpcMetrics, customizedCounterMetric)
+func newServer(meter metric.Meter) (*server, error) {
+	productsAdded, err := meter.Int64Counter("product_mgt.products.added",
+		metric.WithDescription("Products added, by name."))
+	if err != nil {
+		return nil, err
+	}
+	lookups, err := meter.Int64Counter("product_mgt.products.lookups",
+		metric.WithDescription("Product lookups, by result."))
+	if err != nil {
+		return nil, err
+	}
+	return &server{productsAdded: productsAdded, names: metrics.NewCapped(10), lookups: lookups}, nil
 }
 
 func main() {
-	lis, err := net.Listen("tcp", port)
+	// admin.addr enables the admin server with channelz, pprof, build info and the calls in flight,
+	// registry.addr registers the server as ecommerce.ProductInfo with the service registry.
+	cfg := config.MustLoad(config.Config{
+		Server:  config.Server{Addr: ":50051"},
+		Metrics: config.Metrics{PrometheusAddr: "0.0.0.0:9092"},
+	})
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
+	// Create the OpenTelemetry metrics, exported to Prometheus.
+	m, err := metrics.Setup(metrics.Options{ServiceName: "product_mgt"})
+	if err != nil {
+		log.Fatalf("failed to set up metrics: %v", err)
+	}
+
 	// Create a HTTP server for prometheus.
-	httpServer := &http.Server{Handler: promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), Addr: fmt.Sprintf("0.0.0.0:%d", 9092)}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	httpServer := &http.Server{Handler: mux, Addr: cfg.Metrics.PrometheusAddr}
 
-	// Create a gRPC Server with gRPC interceptor.
-	grpcServer := grpc.NewServer(
-		grpc.StreamInterceptor(grpcMetrics.StreamServerInterceptor()),
-		grpc.UnaryInterceptor(grpcMetrics.UnaryServerInterceptor()),
-	)
+	// Create a gRPC Server with gRPC interceptor, recording the latency,
+	// sizes, stream messages and calls in flight of every method.
+	creds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	calls := admin.NewCalls()
+	grpcServer := grpc.NewServer(append(admin.ServerOptions(calls),
+		grpc.Creds(creds),
+		grpc.ChainStreamInterceptor(m.StreamServerInterceptor()),
+		grpc.ChainUnaryInterceptor(m.UnaryServerInterceptor()),
+	)...)
+
+	s, err := newServer(m.Meter("product_mgt"))
+	if err != nil {
+		log.Fatalf("failed to create the metrics of the service: %v", err)
+	}
+	pb.RegisterProductInfoServer(grpcServer, s)
+	hs, checker := healthcheck.Register(grpcServer)
+	checker.AddService("ecommerce.ProductInfo")
+	// The admin server is protected by the auth.tokens of the config, e.g. -auth.tokens operator=some-secret-token.
+	// 管理端口使用配置中的 auth.tokens 做认证
+	adm, err := admin.StartFromConfig(cfg, admin.Options{Calls: calls, Server: grpcServer, Health: hs})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
 
-	pb.RegisterProductInfoServer(grpcServer, &server{})
-    // Initialize all metrics.
-    grpcMetrics.InitializeMetrics(grpcServer)
+	// Register with the registry and renew the lease until shutdown.
+	// 向注册中心注册 ecommerce.ProductInfo，通过心跳续约，关闭时注销
+	self, err := discovery.RegisterSelf(cfg.Registry, lis.Addr(), "ecommerce.ProductInfo")
+	if err != nil {
+		log.Fatalf("failed to register with the registry: %v", err)
+	}
 
 	// Start your http server for prometheus.
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal("Unable to start a http server.")
 		}
 	}()
 
-	if err := grpcServer.Serve(lis); err != nil {
+	// On SIGTERM the server reports NOT_SERVING for server.shutdown_delay and lets in-flight calls finish; the
+	// Prometheus endpoint stays up until then, so the last scrape sees the final values.
+	// 收到 SIGTERM 后先报告 NOT_SERVING，等待正在处理的调用完成，再关闭 Prometheus 端点和指标
+	srv := lifecycle.New(grpcServer,
+		lifecycle.WithShutdownDelay(cfg.Server.ShutdownDelay),
+		lifecycle.WithDrainTimeout(cfg.Server.DrainTimeout),
+		lifecycle.WithHealth(hs),
+		lifecycle.WithShutdownHook("registry", self.Stop),
+		lifecycle.WithShutdownHook("healthcheck", checker.Stop),
+		lifecycle.WithShutdownHook("prometheus", httpServer.Shutdown),
+		lifecycle.WithShutdownHook("metrics", m.Shutdown),
+		lifecycle.WithShutdownHook("admin", adm.Shutdown))
+	checker.Start()
+	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	log.Println("server stopped")
 }
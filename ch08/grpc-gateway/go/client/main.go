@@ -9,15 +9,19 @@ import (
 	"google.golang.org/grpc"
 
 	gw "github.com/grpc-up-and-running/samples/ch08/grpc-gateway/go/gw"
-)
-
-var (
-	// command-line options:
-	// gRPC server endpoint
-	grpcServerEndpoint = "localhost:50051"
+	"github.com/grpc-up-and-running/samples/common/go/config"
 )
 
 func main() {
+	// The gateway serves HTTP on server.addr and calls the gRPC server at client.target.
+	cfg := config.MustLoad(config.Config{
+		Server: config.Server{Addr: ":8081"},
+		Client: config.Client{Target: "localhost:50051"},
+	})
+	creds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -25,13 +29,19 @@ func main() {
 	// Register gRPC server endpoint
 	// Note: Make sure the gRPC server is running properly and accessible
 	mux := runtime.NewServeMux()
-	opts := []grpc.DialOption{grpc.WithInsecure()}
-	err := gw.RegisterProductInfoHandlerFromEndpoint(ctx, mux, grpcServerEndpoint, opts)
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	err = gw.RegisterProductInfoHandlerFromEndpoint(ctx, mux, cfg.Client.Target, opts)
 	if err != nil {
 		log.Fatalf("Fail to register gRPC service endpoint: %v", err)
 		return
 	}
-	if err := http.ListenAndServe(":8081", mux); err != nil {
+	// With server.tls the gateway serves HTTPS.
+	if cfg.Server.TLS.Enabled() {
+		err = http.ListenAndServeTLS(cfg.Server.Addr, cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile, mux)
+	} else {
+		err = http.ListenAndServe(cfg.Server.Addr, mux)
+	}
+	if err != nil {
 		log.Fatalf("Could not setup HTTP endpoint: %v", err)
 	}
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/accesslog"
+	"github.com/grpc-up-and-running/samples/common/go/admin"
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/proxy"
+	"github.com/grpc-up-and-running/samples/common/go/ratelimit"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const backendScheme = "backend"
+
+// The backend pools of the two services, used unless registry.addr is set.
+var (
+	ordersAddrs   = flag.String("orders_addrs", "localhost:50051", "comma separated addresses of the OrderManagement backends")
+	productsAddrs = flag.String("products_addrs", "localhost:50052", "comma separated addresses of the ProductInfo backends")
+)
+
+// routes maps the method prefixes the proxy forwards to the services whose backends serve them.
+var routes = map[string]string{
+	"/ecommerce.OrderManagement/": "ecommerce.OrderManagement",
+	"/ecommerce.ProductInfo/":     "ecommerce.ProductInfo",
+}
+
+// newSource returns the backends of the services: from the registry if registry.addr is set, else from the flags.
+// 设置 registry.addr 时从注册中心获取后端，否则使用命令行参数中的地址
+func newSource(cfg *config.Config) discovery.Source {
+	if cfg.Registry.Addr != "" {
+		conn, err := grpc.Dial(cfg.Registry.Addr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("did not connect to the registry: %v", err)
+		}
+		return discovery.NewRemoteSource(conn)
+	}
+	registry := discovery.NewRegistry()
+	for service, addrs := range map[string]string{"ecommerce.OrderManagement": *ordersAddrs, "ecommerce.ProductInfo": *productsAddrs} {
+		for _, addr := range strings.Split(addrs, ",") {
+			registry.Register(service, discovery.Endpoint{Addr: strings.TrimSpace(addr)})
+		}
+	}
+	return registry
+}
+
+func main() {
+	holder := config.MustLoadHolder(config.Config{
+		Server:    config.Server{Addr: ":50050"},
+		AccessLog: config.AccessLog{Format: "common", Sinks: []string{"stdout"}},
+	})
+	cfg := holder.Current()
+	lis, err := net.Listen("tcp", cfg.Server.Addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	// server.tls secures the connections of the clients, client.tls those to the backends.
+	// server.tls 用于客户端到代理的连接，client.tls 用于代理到后端的连接
+	serverCreds, err := cfg.Server.TLS.ServerCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials: %v", err)
+	}
+	backendCreds, err := cfg.Client.TLS.ClientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load the TLS credentials of the backends: %v", err)
+	}
+
+	// One connection per service, balancing the calls over its backends with round_robin.
+	// 每个服务一个连接（后端池），用 round_robin 在它的后端之间分配调用
+	source := newSource(cfg)
+	router := proxy.NewRouter()
+	for prefix, service := range routes {
+		conn, err := grpc.Dial(fmt.Sprintf("%s:///%s", backendScheme, service),
+			grpc.WithTransportCredentials(backendCreds),
+			grpc.WithResolvers(discovery.NewBuilder(backendScheme, source)),
+			grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+			// The backends log the request ID of the proxy.
+			// 后端使用与代理相同的请求 ID
+			grpc.WithChainStreamInterceptor(requestid.StreamClientInterceptor()))
+		if err != nil {
+			log.Fatalf("did not connect to %s: %v", service, err)
+		}
+		defer conn.Close()
+		router.Route(prefix, conn)
+	}
+
+	// Access log, auth and rate limit apply to every call in one place; the backends need none of them.
+	// 访问日志、认证和限流统一在代理上处理，后端服务不需要各自实现
+	accessLog, err := accesslog.New(accesslog.Options{
+		Format:     cfg.AccessLog.Format,
+		Sinks:      cfg.AccessLog.Sinks,
+		File:       cfg.AccessLog.File,
+		MaxBytes:   cfg.AccessLog.MaxBytes,
+		MaxBackups: cfg.AccessLog.MaxBackups,
+		RingSize:   cfg.AccessLog.RingSize,
+	})
+	if err != nil {
+		log.Fatalf("failed to create access log: %v", err)
+	}
+	defer accessLog.Close()
+	limiter := ratelimit.New(cfg.RateLimit.RPS, cfg.RateLimit.Burst)
+	tokens, err := auth.NewTokens(cfg.Auth.Tokens)
+	if err != nil {
+		log.Fatalf("invalid auth tokens: %v", err)
+	}
+	// Rate limit and tokens follow config reloads.
+	// 限流和令牌会随配置重新加载而更新
+	holder.Subscribe(func(old, new *config.Config) {
+		limiter.SetLimit(new.RateLimit.RPS, new.RateLimit.Burst)
+		if err := tokens.Set(new.Auth.Tokens); err != nil {
+			log.Printf("keeping the auth tokens: %v", err)
+		}
+	})
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	holder.WatchSignal(watchCtx)
+	holder.WatchFile(watchCtx, 5*time.Second)
+
+	// Calls of services not registered here go to the router; their messages are passed through undecoded,
+	// so all four kinds of calls work, including the bidirectional processOrders.
+	// 未在代理上注册的服务由路由转发，消息不解码直接透传，所以一元、服务端流、客户端流和双向流调用都可以代理
+	// The calls in flight, listed by the admin server at /rpcs.
+	calls := admin.NewCalls()
+	opts := append(proxy.ServerOptions(router.Director), admin.ServerOptions(calls)...)
+	s := grpc.NewServer(append(opts,
+		grpc.Creds(serverCreds),
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), accessLog.UnaryServerInterceptor(), tokens.UnaryServerInterceptor(), limiter.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), accessLog.StreamServerInterceptor(), tokens.StreamServerInterceptor(), limiter.StreamServerInterceptor()))...)
+	// The health of the proxy itself, for load balancers and probes in front of it.
+	// 代理自身的健康检查服务
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	// Channelz, pprof, build info, config and calls in flight on admin.addr, protected by the auth tokens.
+	// 管理端口提供 channelz、pprof、健康状态、构建信息、配置和进行中的调用，同样需要 auth token
+	adm, err := admin.Start(cfg.Admin.Addr, admin.Options{Calls: calls, Server: s, Health: hs, Config: holder, Tokens: tokens})
+	if err != nil {
+		log.Fatalf("failed to start the admin server: %v", err)
+	}
+	defer adm.Shutdown(context.Background())
+	log.Printf("proxying on %s", cfg.Server.Addr)
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
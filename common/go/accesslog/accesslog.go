@@ -0,0 +1,303 @@
+// Package accesslog writes one line for every call a server handles, with
+// its method, status code, duration, message sizes and counts, peer,
+// principal and compression.
+//
+// Lines are formatted as JSON, in a common-log style or with a text/template
+// of Entry, and written to stdout, a rotating file or an in-memory ring
+// buffer served over HTTP:
+//
+//	127.0.0.1:53412 4bf92f35... order-client [19/Oct/2026:13:25:49.766 +0000] "/ecommerce.OrderManagement/AddOrder" OK 41 24 1.2ms 1/1 gzip
+//
+// Chain the interceptors after the requestid interceptors, so lines carry the
+// request ID, and before the auth and rate limit interceptors, so rejected
+// calls are logged too.
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// CommonFormat is a common-log style template: peer, request ID, principal,
+// time, method, code, request and response bytes, duration, received and
+// sent messages and compression.
+const CommonFormat = `{{.Peer}} {{or .RequestID "-"}} {{or .Principal "-"}} [{{.Time.Format "02/Jan/2006:15:04:05.000 -0700"}}] "{{.Method}}" {{.Code}} {{.RequestBytes}} {{.ResponseBytes}} {{.Duration}} {{.Received}}/{{.Sent}} {{or .Compression "identity"}}`
+
+// Entry describes a finished call.
+type Entry struct {
+	Time     time.Time
+	Method   string
+	Code     codes.Code
+	Duration time.Duration
+	// RequestBytes and ResponseBytes are the uncompressed sizes of all
+	// messages received and sent.
+	RequestBytes  int
+	ResponseBytes int
+	// Received and Sent count the messages of the call.
+	Received    int
+	Sent        int
+	Peer        string
+	Principal   string
+	RequestID   string
+	Compression string
+}
+
+// jsonEntry is the JSON form of Entry.
+type jsonEntry struct {
+	Time          string  `json:"time"`
+	Method        string  `json:"method"`
+	Code          string  `json:"code"`
+	DurationMs    float64 `json:"duration_ms"`
+	RequestBytes  int     `json:"request_bytes"`
+	ResponseBytes int     `json:"response_bytes"`
+	Received      int     `json:"messages_received"`
+	Sent          int     `json:"messages_sent"`
+	Peer          string  `json:"peer,omitempty"`
+	Principal     string  `json:"principal,omitempty"`
+	RequestID     string  `json:"request_id,omitempty"`
+	Compression   string  `json:"compression,omitempty"`
+}
+
+// Formatter formats an entry as a line without the trailing newline.
+type Formatter func(e *Entry) ([]byte, error)
+
+// NewFormatter returns the formatter of format: json, common (the default,
+// CommonFormat) or a text/template of Entry.
+func NewFormatter(format string) (Formatter, error) {
+	switch format {
+	case "json":
+		return func(e *Entry) ([]byte, error) {
+			return json.Marshal(jsonEntry{
+				Time:          e.Time.Format(time.RFC3339Nano),
+				Method:        e.Method,
+				Code:          e.Code.String(),
+				DurationMs:    float64(e.Duration) / float64(time.Millisecond),
+				RequestBytes:  e.RequestBytes,
+				ResponseBytes: e.ResponseBytes,
+				Received:      e.Received,
+				Sent:          e.Sent,
+				Peer:          e.Peer,
+				Principal:     e.Principal,
+				RequestID:     e.RequestID,
+				Compression:   e.Compression,
+			})
+		}, nil
+	case "", "common":
+		format = CommonFormat
+	}
+	tmpl, err := template.New("accesslog").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("access log format: %v", err)
+	}
+	return func(e *Entry) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, e); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}, nil
+}
+
+// Options configures a Logger.
+type Options struct {
+	// Format is json, common or a text/template of Entry.
+	Format string
+	// Sinks are stdout, file and ring. Without sinks nothing is logged.
+	Sinks []string
+	// File, MaxBytes and MaxBackups configure the file sink.
+	File       string
+	MaxBytes   int64
+	MaxBackups int
+	// RingSize is the number of lines kept by the ring sink.
+	RingSize int
+	// Output replaces os.Stdout for the stdout sink.
+	Output io.Writer
+}
+
+// Logger writes the access log lines to its sinks.
+type Logger struct {
+	format Formatter
+	ring   *Ring
+	file   *RotatingFile
+
+	mu    sync.Mutex
+	sinks []io.Writer
+}
+
+// New creates a Logger with the sinks of opts.
+func New(opts Options) (*Logger, error) {
+	format, err := NewFormatter(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{format: format}
+	for _, sink := range opts.Sinks {
+		switch sink {
+		case "stdout":
+			out := opts.Output
+			if out == nil {
+				out = os.Stdout
+			}
+			l.sinks = append(l.sinks, out)
+		case "file":
+			if l.file, err = OpenRotatingFile(opts.File, opts.MaxBytes, opts.MaxBackups); err != nil {
+				return nil, err
+			}
+			l.sinks = append(l.sinks, l.file)
+		case "ring":
+			l.ring = NewRing(opts.RingSize)
+			l.sinks = append(l.sinks, l.ring)
+		default:
+			return nil, fmt.Errorf("unknown access log sink %q, want stdout, file or ring", sink)
+		}
+	}
+	return l, nil
+}
+
+// Ring returns the ring sink, or nil without one.
+func (l *Logger) Ring() *Ring {
+	return l.ring
+}
+
+// Close closes the file sink.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Log writes the line of e to every sink.
+func (l *Logger) Log(e *Entry) {
+	if len(l.sinks) == 0 {
+		return
+	}
+	line, err := l.format(e)
+	if err != nil {
+		log.Printf("accesslog: formatting %s: %v", e.Method, err)
+		return
+	}
+	line = append(line, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sink := range l.sinks {
+		if _, err := sink.Write(line); err != nil {
+			log.Printf("accesslog: %v", err)
+		}
+	}
+}
+
+// newEntry fills in what is known when the call starts.
+func newEntry(ctx context.Context, method string) *Entry {
+	e := &Entry{Time: time.Now(), Method: method}
+	if p, ok := peer.FromContext(ctx); ok {
+		e.Peer = p.Addr.String()
+	}
+	if id, ok := requestid.FromContext(ctx); ok {
+		e.RequestID = id
+	} else if md, ok := metadata.FromIncomingContext(ctx); ok {
+		e.RequestID, _ = requestid.FromMD(md)
+	}
+	// The transport stream knows the grpc-encoding of the request; it is not
+	// part of the incoming metadata.
+	if s, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string }); ok {
+		e.Compression = s.RecvCompress()
+	}
+	return e
+}
+
+func (e *Entry) finish(err error, principal string) {
+	e.Duration = time.Since(e.Time)
+	e.Code = status.Code(err)
+	e.Principal = principal
+}
+
+// size returns the encoded size of a message, or the Size of messages
+// passed through undecoded, e.g. the frames of the proxy package.
+func size(m interface{}) int {
+	switch m := m.(type) {
+	case proto.Message:
+		return proto.Size(m)
+	case interface{ Size() int }:
+		return m.Size()
+	}
+	return 0
+}
+
+// UnaryServerInterceptor logs every unary call.
+func (l *Logger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		e := newEntry(ctx, info.FullMethod)
+		ctx, principal := auth.RecordPrincipal(ctx)
+		resp, err := handler(ctx, req)
+		e.Received, e.RequestBytes = 1, size(req)
+		if err == nil {
+			e.Sent, e.ResponseBytes = 1, size(resp)
+		}
+		e.finish(err, principal())
+		l.Log(e)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs every stream when it ends, with the number
+// and sizes of its messages.
+func (l *Logger) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		e := newEntry(ss.Context(), info.FullMethod)
+		ctx, principal := auth.RecordPrincipal(ss.Context())
+		err := handler(srv, &countingStream{ServerStream: ss, ctx: ctx, entry: e})
+		e.finish(err, principal())
+		l.Log(e)
+		return err
+	}
+}
+
+// countingStream wraps the grpc.ServerStream like the wrappedStream of the
+// ch05 interceptors sample, and counts the messages received and sent.
+// RecvMsg and SendMsg may run concurrently, but each is not called
+// concurrently with itself and updates its own fields only.
+type countingStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	entry *Entry
+}
+
+func (s *countingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.entry.Received++
+		s.entry.RequestBytes += size(m)
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.entry.Sent++
+		s.entry.ResponseBytes += size(m)
+	}
+	return err
+}
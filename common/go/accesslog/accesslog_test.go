@@ -0,0 +1,291 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	return &ecpb.EchoResponse{Message: req.Message}, nil
+}
+
+func (s *ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the server goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) entries(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestEntries(t *testing.T) {
+	out := &syncBuffer{}
+	l, err := New(Options{Format: "json", Sinks: []string{"stdout"}, Output: out})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	tokens, err := auth.NewTokens([]string{"order-client=secret"})
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), l.UnaryServerInterceptor(), tokens.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), l.StreamServerInterceptor(), tokens.StreamServerInterceptor()))
+	ecpb.RegisterEchoServer(s, &ecServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := ecpb.NewEchoClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Rejected by auth, still logged.
+	if _, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hi"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("UnaryEcho() without token = %v, want Unauthenticated", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret", requestid.Key, "req-1")
+	req := &ecpb.EchoRequest{Message: "hello"}
+	if _, err := c.UnaryEcho(ctx, req); err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+
+	stream, err := c.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv() = %v, want EOF", err)
+	}
+	// The entry of a stream is written after its status was sent.
+	s.GracefulStop()
+
+	entries := out.entries(t)
+	if len(entries) != 3 {
+		t.Fatalf("logged %d entries, want 3", len(entries))
+	}
+	if e := entries[0]; e["code"] != "Unauthenticated" || e["principal"] != nil {
+		t.Errorf("rejected call entry = %v", e)
+	}
+
+	size := float64(proto.Size(req))
+	unary := entries[1]
+	for k, want := range map[string]interface{}{
+		"method":            "/grpc.examples.echo.Echo/UnaryEcho",
+		"code":              "OK",
+		"principal":         "order-client",
+		"request_id":        "req-1",
+		"messages_received": 1.0,
+		"messages_sent":     1.0,
+		"request_bytes":     size,
+		"response_bytes":    size,
+	} {
+		if unary[k] != want {
+			t.Errorf("unary entry %s = %v, want %v", k, unary[k], want)
+		}
+	}
+	bidi := entries[2]
+	for k, want := range map[string]interface{}{
+		"method":            "/grpc.examples.echo.Echo/BidirectionalStreamingEcho",
+		"code":              "OK",
+		"principal":         "order-client",
+		"messages_received": 3.0,
+		"messages_sent":     3.0,
+		"request_bytes":     3 * size,
+	} {
+		if bidi[k] != want {
+			t.Errorf("stream entry %s = %v, want %v", k, bidi[k], want)
+		}
+	}
+}
+
+func TestCommonFormat(t *testing.T) {
+	f, err := NewFormatter("")
+	if err != nil {
+		t.Fatalf("NewFormatter() failed: %v", err)
+	}
+	line, err := f(&Entry{
+		Time:     time.Date(2026, 10, 19, 13, 25, 49, 0, time.UTC),
+		Method:   "/ecommerce.OrderManagement/AddOrder",
+		Code:     codes.NotFound,
+		Duration: 1500 * time.Microsecond,
+		Peer:     "127.0.0.1:53412",
+		Received: 1,
+	})
+	if err != nil {
+		t.Fatalf("format failed: %v", err)
+	}
+	want := `127.0.0.1:53412 - - [19/Oct/2026:13:25:49.000 +0000] "/ecommerce.OrderManagement/AddOrder" NotFound 0 0 1.5ms 1/0 identity`
+	if string(line) != want {
+		t.Errorf("line = %q, want %q", line, want)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() failed: %v", err)
+	}
+	defer f.Close()
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), got, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than max backups were kept")
+	}
+}
+
+func TestRotatingFileRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() failed: %v", err)
+	}
+	defer f.Close()
+	// A directory in place of the backup makes the rotation fail.
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "first\nsecond\n" {
+		t.Errorf("access.log after a failed rotation = %q, %v, want both lines appended", got, err)
+	}
+
+	// The rotation is retried once the retry interval has passed.
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "first\nsecond\nthird\n" {
+		t.Errorf("access.log before the retry = %q, want the lines appended", got)
+	}
+	f.mu.Lock()
+	f.retryAt = time.Time{}
+	f.mu.Unlock()
+	if _, err := f.Write([]byte("fourth\n")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "first\nsecond\nthird\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), got, err, want)
+		}
+	}
+
+	f.Close()
+	if _, err := f.Write([]byte("fifth\n")); err != os.ErrClosed {
+		t.Errorf("Write() after Close() = %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(2)
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		r.Write([]byte(line))
+	}
+	if got := strings.Join(r.Lines(), ""); got != "b\nc\n" {
+		t.Errorf("Lines() = %q, want the last two", got)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/accesslog?n=1", nil))
+	if got := rec.Body.String(); got != "c\n" {
+		t.Errorf("GET /accesslog?n=1 = %q, want the last line", got)
+	}
+}
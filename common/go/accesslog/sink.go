@@ -0,0 +1,194 @@
+package accesslog
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rotateRetry is how long a RotatingFile keeps appending to its current file
+// after a failed rotation before it tries again.
+const rotateRetry = 10 * time.Second
+
+// RotatingFile is a file sink that is rotated when it would grow beyond
+// MaxBytes. Rotated files are renamed to path.1, path.2 and so on, keeping
+// maxBackups of them. When a rotation fails, e.g. because a backup cannot be
+// renamed, the entries are appended to the current file and the rotation is
+// tried again later.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	retryAt time.Time
+	closed  bool
+}
+
+// OpenRotatingFile opens or creates the file at path for appending. A
+// maxBytes of 0 never rotates the file.
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("access log file sink needs a path")
+	}
+	r := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := r.open(path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file at path for appending as the current file.
+func (r *RotatingFile) open(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// Write appends p, rotating the file first if p would not fit.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes && !time.Now().Before(r.retryAt) {
+		r.rotate()
+	}
+	if r.f == nil {
+		// Neither the new nor the old file could be opened last time.
+		if err := r.open(r.path); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file to the first backup and opens a new one. If
+// that fails, it reopens the old file, wherever the failure left it, and
+// tries again after rotateRetry.
+func (r *RotatingFile) rotate() {
+	old := r.path
+	err := r.f.Close()
+	r.f = nil
+	if err == nil {
+		if old, err = r.shift(); err == nil {
+			if err = r.open(r.path); err == nil {
+				return
+			}
+		}
+	}
+	log.Printf("accesslog: rotating %s: %v; retrying in %v", r.path, err, rotateRetry)
+	r.retryAt = time.Now().Add(rotateRetry)
+	if old == "" {
+		// The old file was removed; start a new one.
+		old = r.path
+	}
+	if err := r.open(old); err != nil {
+		log.Printf("accesslog: reopening %s: %v", old, err)
+	}
+}
+
+// shift renames the backups and the current file, dropping the oldest
+// backup. It returns the path of the old file: path.1, or empty when it was
+// removed because no backups are kept, or the unchanged path if it was not
+// moved.
+func (r *RotatingFile) shift() (string, error) {
+	backup := func(i int) string { return r.path + "." + strconv.Itoa(i) }
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return r.path, err
+		}
+		return "", nil
+	}
+	os.Remove(backup(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return r.path, err
+		}
+	}
+	// The path is missing when the old file was reopened from path.1.
+	if err := os.Rename(r.path, backup(1)); err != nil && !os.IsNotExist(err) {
+		return r.path, err
+	}
+	return backup(1), nil
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// Ring is a sink keeping the last lines in memory. It serves them over HTTP,
+// oldest first; the n query parameter limits them to the last n.
+type Ring struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewRing returns a ring keeping size lines, 1000 if size is not positive.
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = 1000
+	}
+	return &Ring{lines: make([]string, size)}
+}
+
+// Write stores the line p.
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = string(p)
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	return len(p), nil
+}
+
+// Lines returns the stored lines, oldest first.
+func (r *Ring) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	lines := r.Lines()
+	if n, err := strconv.Atoi(req.URL.Query().Get("n")); err == nil && n >= 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		fmt.Fprint(w, line)
+	}
+}
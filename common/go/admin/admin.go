@@ -0,0 +1,471 @@
+// Package admin serves the introspection endpoints of a sample server on a
+// port of its own, apart from the service:
+//
+//	/                     index of the endpoints
+//	/channelz/            channelz servers and top channels, as JSON
+//	/channelz/channel/ID  one channel, likewise subchannel, socket and server
+//	/debug/pprof/         the net/http/pprof profiles
+//	/healthz              the health status of the server and its services
+//	/buildinfo            the Go version, module versions and VCS revision
+//	/config               the active config, with the secrets masked
+//	/rpcs                 the calls in flight with their durations
+//
+// plus the handlers of the server, e.g. the access log ring at /accesslog.
+//
+// The same port serves gRPC over plaintext HTTP/2: the channelz service, the
+// health service, the ConfigAdmin service and reflection, e.g.
+//
+//	grpcurl -plaintext localhost:9091 grpc.channelz.v1.Channelz/GetServers
+//
+// Both are protected by the bearer tokens of the auth package, except the
+// health checks. Without tokens the admin server is open to anyone who can
+// reach it, so listen on a loopback address, e.g. 127.0.0.1:9091.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	channelzsvc "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// maxResults bounds the channelz lists served over HTTP.
+const maxResults = 100
+
+// Options configures the admin server. Every field is optional.
+type Options struct {
+	// Calls lists the calls in flight at /rpcs.
+	Calls *Calls
+	// Server is the gRPC server of the service. /healthz reports the status
+	// of its services.
+	Server *grpc.Server
+	// Health is the health server of the service. Without one the server is
+	// reported SERVING while it runs.
+	Health *health.Server
+	// Config is shown at /config and by the ConfigAdmin service.
+	Config *config.Holder
+	// Tokens protect the endpoints. They default to the auth.tokens of
+	// Config, following its reloads.
+	Tokens *auth.Tokens
+	// Handlers are more endpoints by path, e.g. the ring of the access log
+	// at /accesslog. The tokens protect them like the others.
+	Handlers map[string]http.Handler
+}
+
+// Server is the admin server.
+type Server struct {
+	opts     Options
+	started  time.Time
+	grpc     *grpc.Server
+	http     *http.Server
+	channelz channelzpb.ChannelzServer
+}
+
+// New returns an admin server with opts.
+func New(opts Options) (*Server, error) {
+	if opts.Tokens == nil {
+		var entries []string
+		if opts.Config != nil {
+			entries = opts.Config.Current().Auth.Tokens
+		}
+		tokens, err := auth.NewTokens(entries)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Config != nil {
+			opts.Config.Subscribe(func(old, new *config.Config) {
+				if err := tokens.Set(new.Auth.Tokens); err != nil {
+					log.Printf("admin: keeping the auth tokens: %v", err)
+				}
+			})
+		}
+		opts.Tokens = tokens
+	}
+	if opts.Health == nil {
+		opts.Health = health.NewServer()
+	}
+	s := &Server{opts: opts, started: time.Now()}
+
+	s.grpc = grpc.NewServer(
+		grpc.UnaryInterceptor(opts.Tokens.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(opts.Tokens.StreamServerInterceptor()))
+	channelzsvc.RegisterChannelzServiceToServer(s.grpc)
+	healthpb.RegisterHealthServer(s.grpc, opts.Health)
+	if opts.Config != nil {
+		config.RegisterAdmin(s.grpc, opts.Config)
+	}
+	reflection.Register(s.grpc)
+
+	// The channelz service does not export its implementation; capture it
+	// to serve the same data over HTTP.
+	// channelz 服务的实现没有导出，通过一个只记录实现的 registrar 拿到它
+	var capture captureRegistrar
+	channelzsvc.RegisterChannelzServiceToServer(&capture)
+	s.channelz = capture.impl.(channelzpb.ChannelzServer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/channelz/", s.serveChannelz)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/buildinfo", s.serveBuildInfo)
+	mux.HandleFunc("/config", s.serveConfig)
+	mux.HandleFunc("/rpcs", s.serveCalls)
+	for path, h := range opts.Handlers {
+		mux.Handle(path, h)
+	}
+	protected := opts.Tokens.HTTPHandler(mux)
+	healthz := http.HandlerFunc(s.serveHealth)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc"):
+			s.grpc.ServeHTTP(w, r)
+		case r.URL.Path == "/healthz":
+			healthz.ServeHTTP(w, r)
+		default:
+			protected.ServeHTTP(w, r)
+		}
+	})
+	// h2c lets gRPC clients talk HTTP/2 without TLS on the same port.
+	s.http = &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+	return s, nil
+}
+
+type captureRegistrar struct {
+	impl interface{}
+}
+
+func (c *captureRegistrar) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	c.impl = impl
+}
+
+// Handler returns the handler of the HTTP and gRPC endpoints.
+func (s *Server) Handler() http.Handler {
+	return s.http.Handler
+}
+
+// Serve serves the admin endpoints on lis until Shutdown is called.
+func (s *Server) Serve(lis net.Listener) error {
+	if !s.opts.Tokens.Enabled() && !isLoopback(lis.Addr()) {
+		log.Printf("admin: serving %v without auth tokens, anyone who can reach it can use it", lis.Addr())
+	}
+	if err := s.http.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ListenAndServe serves the admin endpoints on addr until Shutdown is
+// called.
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Start creates an admin server with opts and serves it on addr in the
+// background. Without an address it returns nil, so samples can call it
+// whether the admin server is enabled or not.
+func Start(addr string, opts Options) (*Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	s, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("admin: serving on %v", lis.Addr())
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Printf("admin: %v", err)
+		}
+	}()
+	return s, nil
+}
+
+// StartFromConfig starts an admin server on the admin.addr of cfg. Unless
+// opts sets them, the server shows cfg at /config and is protected by the
+// auth.tokens of cfg. Like Start it returns nil without an address.
+func StartFromConfig(cfg *config.Config, opts Options) (*Server, error) {
+	if opts.Config == nil {
+		opts.Config = config.NewHolder(*cfg)
+	}
+	return Start(cfg.Admin.Addr, opts)
+}
+
+// ServerOptions returns the options of a gRPC server tracking its calls in
+// calls. Pass them before the other interceptors, so the durations include
+// them.
+func ServerOptions(calls *Calls) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(calls.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(calls.StreamServerInterceptor()),
+	}
+}
+
+// Shutdown stops the admin server. It has the signature of a lifecycle hook
+// and does nothing on a nil Server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.grpc.Stop()
+	return s.http.Shutdown(ctx)
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprint(w, `admin endpoints:
+/channelz/            channelz servers and top channels
+/channelz/channel/ID  one channel, likewise subchannel/ID, socket/ID and server/ID
+/debug/pprof/         profiles
+/healthz              health status
+/buildinfo            build information
+/config               active config
+/rpcs                 calls in flight
+`)
+	paths := make([]string, 0, len(s.opts.Handlers))
+	for path := range s.opts.Handlers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintln(w, path)
+	}
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	check := func(service string) (string, bool) {
+		resp, err := s.opts.Health.Check(r.Context(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return "", false
+		}
+		return resp.Status.String(), true
+	}
+	overall, _ := check("")
+	services := make(map[string]string)
+	if s.opts.Server != nil {
+		for name := range s.opts.Server.GetServiceInfo() {
+			// Services without a status of their own are not reported.
+			if st, ok := check(name); ok {
+				services[name] = st
+			}
+		}
+	}
+	code := http.StatusOK
+	if overall != healthpb.HealthCheckResponse_SERVING.String() {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, struct {
+		Status   string            `json:"status"`
+		Services map[string]string `json:"services,omitempty"`
+	}{overall, services})
+}
+
+func (s *Server) serveBuildInfo(w http.ResponseWriter, r *http.Request) {
+	type module struct {
+		Path    string `json:"path"`
+		Version string `json:"version"`
+		Replace string `json:"replace,omitempty"`
+	}
+	info := struct {
+		GoVersion string            `json:"go_version"`
+		Path      string            `json:"path,omitempty"`
+		Main      module            `json:"main"`
+		Settings  map[string]string `json:"settings,omitempty"`
+		Deps      []module          `json:"deps,omitempty"`
+		Started   time.Time         `json:"started"`
+		Uptime    string            `json:"uptime"`
+	}{Started: s.started, Uptime: time.Since(s.started).Round(time.Second).String()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion, info.Path = bi.GoVersion, bi.Path
+		info.Main = module{Path: bi.Main.Path, Version: bi.Main.Version}
+		// The settings hold the build flags and vcs.revision, vcs.time
+		// and vcs.modified.
+		info.Settings = make(map[string]string)
+		for _, setting := range bi.Settings {
+			info.Settings[setting.Key] = setting.Value
+		}
+		for _, dep := range bi.Deps {
+			m := module{Path: dep.Path, Version: dep.Version}
+			if dep.Replace != nil {
+				m.Replace = dep.Replace.Path
+			}
+			info.Deps = append(info.Deps, m)
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) serveConfig(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Config == nil {
+		http.Error(w, "the server has no config", http.StatusNotFound)
+		return
+	}
+	snap := s.opts.Config.Snapshot()
+	data, err := yaml.Marshal(snap.Config.Redacted())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	fmt.Fprintf(w, "# version %d, loaded at %s", snap.Version, snap.LoadedAt.Format(time.RFC3339))
+	if file := s.opts.Config.File(); file != "" {
+		fmt.Fprintf(w, " from %s", file)
+	}
+	fmt.Fprintf(w, "\n%s", data)
+}
+
+// jsonCall is the JSON form of Call.
+type jsonCall struct {
+	Method     string  `json:"method"`
+	Type       string  `json:"type"`
+	Start      string  `json:"start"`
+	DurationMs float64 `json:"duration_ms"`
+	Peer       string  `json:"peer,omitempty"`
+	RequestID  string  `json:"request_id,omitempty"`
+	Received   int64   `json:"messages_received"`
+	Sent       int64   `json:"messages_sent"`
+}
+
+func (s *Server) serveCalls(w http.ResponseWriter, r *http.Request) {
+	calls := []jsonCall{}
+	if s.opts.Calls != nil {
+		for _, c := range s.opts.Calls.List() {
+			calls = append(calls, jsonCall{
+				Method:     c.Method,
+				Type:       c.Type,
+				Start:      c.Start.Format(time.RFC3339Nano),
+				DurationMs: float64(c.Duration.Microseconds()) / 1000,
+				Peer:       c.Peer,
+				RequestID:  c.RequestID,
+				Received:   c.Received,
+				Sent:       c.Sent,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, calls)
+}
+
+// serveChannelz answers /channelz/ with the servers and top channels, and
+// /channelz/KIND/ID with one entity.
+func (s *Server) serveChannelz(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/channelz/"), "/")
+	if path == "" {
+		servers, err := s.channelz.GetServers(ctx, &channelzpb.GetServersRequest{MaxResults: maxResults})
+		if err != nil {
+			writeStatus(w, err)
+			return
+		}
+		channels, err := s.channelz.GetTopChannels(ctx, &channelzpb.GetTopChannelsRequest{MaxResults: maxResults})
+		if err != nil {
+			writeStatus(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]json.RawMessage{
+			"servers":  protoJSON(servers),
+			"channels": protoJSON(channels),
+		})
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid channelz ID %q", parts[1]), http.StatusBadRequest)
+		return
+	}
+	var resp proto.Message
+	switch parts[0] {
+	case "channel":
+		resp, err = s.channelz.GetChannel(ctx, &channelzpb.GetChannelRequest{ChannelId: id})
+	case "subchannel":
+		resp, err = s.channelz.GetSubchannel(ctx, &channelzpb.GetSubchannelRequest{SubchannelId: id})
+	case "socket":
+		resp, err = s.channelz.GetSocket(ctx, &channelzpb.GetSocketRequest{SocketId: id})
+	case "server":
+		resp, err = s.channelz.GetServerSockets(ctx, &channelzpb.GetServerSocketsRequest{ServerId: id, MaxResults: maxResults})
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, protoJSON(resp))
+}
+
+func protoJSON(m proto.Message) json.RawMessage {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		data, _ = json.Marshal(err.Error())
+	}
+	return data
+}
+
+// writeStatus answers with the HTTP code of a channelz error.
+func writeStatus(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if status.Code(err) == codes.NotFound {
+		code = http.StatusNotFound
+	}
+	http.Error(w, status.Convert(err).Message(), code)
+}
@@ -0,0 +1,277 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const token = "admin-secret"
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	// inStream is signalled while a stream is open.
+	inStream chan struct{}
+}
+
+func (s *ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Message == "wait" {
+			s.inStream <- struct{}{}
+			<-s.inStream
+		}
+		if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// startAdmin starts an echo server tracked by an admin server and returns
+// the echo client and the admin address.
+func startAdmin(t *testing.T, srv *ecServer) (ecpb.EchoClient, string) {
+	t.Helper()
+	calls := NewCalls()
+	s := grpc.NewServer(ServerOptions(calls)...)
+	ecpb.RegisterEchoServer(s, srv)
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	holder := config.NewHolder(config.Config{Auth: config.Auth{Tokens: []string{"operator=" + token}}})
+	hello := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "hello") })
+	adm, err := New(Options{Calls: calls, Server: s, Config: holder, Handlers: map[string]http.Handler{"/hello": hello}})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	adminLis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go adm.Serve(adminLis)
+	t.Cleanup(func() { adm.Shutdown(context.Background()) })
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn), adminLis.Addr().String()
+}
+
+// get fetches path from the admin server, with the token unless it is empty.
+func get(t *testing.T, addr, path, bearer string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", "http://"+addr+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAuth(t *testing.T) {
+	_, addr := startAdmin(t, &ecServer{})
+
+	for _, tc := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/rpcs", "", http.StatusUnauthorized},
+		{"/rpcs", "wrong", http.StatusUnauthorized},
+		{"/rpcs", token, http.StatusOK},
+		{"/debug/pprof/", "", http.StatusUnauthorized},
+		{"/debug/pprof/", token, http.StatusOK},
+		{"/hello", "", http.StatusUnauthorized},
+		{"/hello", token, http.StatusOK},
+		// Probes check the health without a token.
+		{"/healthz", "", http.StatusOK},
+	} {
+		if got, body := get(t, addr, tc.path, tc.token); got != tc.want {
+			t.Errorf("GET %s with token %q = %d %s, want %d", tc.path, tc.token, got, body, tc.want)
+		}
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	channelz := channelzpb.NewChannelzClient(conn)
+	if _, err := channelz.GetServers(ctx, &channelzpb.GetServersRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetServers() without a token = %v, want Unauthenticated", err)
+	}
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	if _, err := channelz.GetServers(authCtx, &channelzpb.GetServersRequest{}); err != nil {
+		t.Errorf("GetServers() with a token failed: %v", err)
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check() = %v, %v, want SERVING", resp, err)
+	}
+}
+
+func TestStartFromConfig(t *testing.T) {
+	if adm, err := StartFromConfig(&config.Config{}, Options{}); adm != nil || err != nil {
+		t.Errorf("StartFromConfig() without admin.addr = %v, %v, want nil, nil", adm, err)
+	}
+
+	cfg := &config.Config{
+		Admin: config.Admin{Addr: "localhost:0"},
+		Auth:  config.Auth{Tokens: []string{"operator=" + token}},
+	}
+	adm, err := StartFromConfig(cfg, Options{})
+	if err != nil {
+		t.Fatalf("StartFromConfig() failed: %v", err)
+	}
+	defer adm.Shutdown(context.Background())
+	srv := httptest.NewServer(adm.Handler())
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	// The auth.tokens of the config protect the endpoints.
+	if got, _ := get(t, addr, "/config", ""); got != http.StatusUnauthorized {
+		t.Errorf("GET /config without a token = %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got, body := get(t, addr, "/config", token); got != http.StatusOK || !strings.Contains(body, "localhost:0") {
+		t.Errorf("GET /config = %d %s, want %d with the config", got, body, http.StatusOK)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	srv := &ecServer{inStream: make(chan struct{})}
+	client, addr := startAdmin(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stream, err := client.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho() failed: %v", err)
+	}
+	if err := stream.Send(&ecpb.EchoRequest{Message: "hello"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() failed: %v", err)
+	}
+	if err := stream.Send(&ecpb.EchoRequest{Message: "wait"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	<-srv.inStream
+	time.Sleep(10 * time.Millisecond)
+
+	_, body := get(t, addr, "/rpcs", token)
+	var calls []jsonCall
+	if err := json.Unmarshal([]byte(body), &calls); err != nil {
+		t.Fatalf("invalid /rpcs response %q: %v", body, err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("/rpcs = %s, want one call", body)
+	}
+	c := calls[0]
+	if c.Method != "/grpc.examples.echo.Echo/BidirectionalStreamingEcho" || c.Type != "bidi_stream" ||
+		c.Received != 2 || c.Sent != 1 || c.DurationMs < 10 || c.Peer == "" {
+		t.Errorf("/rpcs = %+v, want the open stream with 2 messages received and 1 sent", c)
+	}
+
+	srv.inStream <- struct{}{}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	// The interceptor returns after the client saw the end of the stream.
+	for i := 0; ; i++ {
+		if _, body := get(t, addr, "/rpcs", token); strings.TrimSpace(body) == "[]" {
+			break
+		} else if i == 100 {
+			t.Fatalf("/rpcs = %s after the stream ended, want none", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChannelz(t *testing.T) {
+	client, addr := startAdmin(t, &ecServer{})
+	// Any call connects the client, so the echo server has a socket.
+	client.UnaryEcho(context.Background(), &ecpb.EchoRequest{})
+
+	code, body := get(t, addr, "/channelz/", token)
+	if code != http.StatusOK {
+		t.Fatalf("GET /channelz/ = %d %s", code, body)
+	}
+	var overview struct {
+		Servers struct {
+			Server []struct {
+				Ref struct {
+					ServerID string `json:"serverId"`
+				} `json:"ref"`
+			} `json:"server"`
+		} `json:"servers"`
+	}
+	if err := json.Unmarshal([]byte(body), &overview); err != nil {
+		t.Fatalf("invalid /channelz/ response %q: %v", body, err)
+	}
+	// The echo server, listed first, and the admin server.
+	if len(overview.Servers.Server) < 2 {
+		t.Fatalf("/channelz/ lists %d servers, want at least 2: %s", len(overview.Servers.Server), body)
+	}
+	path := fmt.Sprintf("/channelz/server/%s", overview.Servers.Server[0].Ref.ServerID)
+	if code, body := get(t, addr, path, token); code != http.StatusOK || !strings.Contains(body, "socketRef") {
+		t.Errorf("GET %s = %d %s, want the server sockets", path, code, body)
+	}
+	if code, _ := get(t, addr, "/channelz/channel/999999", token); code != http.StatusNotFound {
+		t.Errorf("GET of an unknown channel = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestConfigAndBuildInfo(t *testing.T) {
+	_, addr := startAdmin(t, &ecServer{})
+
+	_, body := get(t, addr, "/config", token)
+	if !strings.Contains(body, "operator=****") || strings.Contains(body, token) {
+		t.Errorf("/config = %q, want the tokens masked", body)
+	}
+	_, body = get(t, addr, "/buildinfo", token)
+	var info struct {
+		GoVersion string `json:"go_version"`
+	}
+	if err := json.Unmarshal([]byte(body), &info); err != nil || !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("/buildinfo = %s, want the Go version", body)
+	}
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Call is a call in flight.
+type Call struct {
+	Method string
+	// Type is unary, client_stream, server_stream or bidi_stream.
+	Type      string
+	Start     time.Time
+	Duration  time.Duration
+	Peer      string
+	RequestID string
+	// Received and Sent count the messages of a stream so far.
+	Received int64
+	Sent     int64
+}
+
+// Calls tracks the calls a server is handling. Chain its interceptors first,
+// so the durations include the other interceptors.
+type Calls struct {
+	mu     sync.Mutex
+	nextID uint64
+	calls  map[uint64]*call
+}
+
+type call struct {
+	Call
+	received, sent int64 // atomic
+}
+
+// NewCalls returns an empty Calls.
+func NewCalls() *Calls {
+	return &Calls{calls: make(map[uint64]*call)}
+}
+
+// List returns the calls in flight, the longest running first.
+func (c *Calls) List() []Call {
+	now := time.Now()
+	c.mu.Lock()
+	list := make([]Call, 0, len(c.calls))
+	for _, cl := range c.calls {
+		entry := cl.Call
+		entry.Duration = now.Sub(entry.Start)
+		entry.Received = atomic.LoadInt64(&cl.received)
+		entry.Sent = atomic.LoadInt64(&cl.sent)
+		list = append(list, entry)
+	}
+	c.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	return list
+}
+
+// start adds a call and returns it with the function removing it.
+func (c *Calls) start(ctx context.Context, method, typ string) (*call, func()) {
+	cl := &call{Call: Call{Method: method, Type: typ, Start: time.Now()}}
+	if p, ok := peer.FromContext(ctx); ok {
+		cl.Peer = p.Addr.String()
+	}
+	// The requestid interceptors may run after ours; the ID is in the
+	// metadata either way.
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		cl.RequestID, _ = requestid.FromMD(md)
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.calls[id] = cl
+	c.mu.Unlock()
+	return cl, func() {
+		c.mu.Lock()
+		delete(c.calls, id)
+		c.mu.Unlock()
+	}
+}
+
+// UnaryServerInterceptor tracks unary calls.
+func (c *Calls) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		_, done := c.start(ctx, info.FullMethod, "unary")
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor tracks streams with their message counts.
+func (c *Calls) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		typ := "bidi_stream"
+		switch {
+		case !info.IsClientStream:
+			typ = "server_stream"
+		case !info.IsServerStream:
+			typ = "client_stream"
+		}
+		cl, done := c.start(ss.Context(), info.FullMethod, typ)
+		defer done()
+		return handler(srv, &countingStream{ServerStream: ss, call: cl})
+	}
+}
+
+// countingStream counts the messages of a stream.
+type countingStream struct {
+	grpc.ServerStream
+	call *call
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.call.received, 1)
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.call.sent, 1)
+	}
+	return err
+}
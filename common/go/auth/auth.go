@@ -0,0 +1,209 @@
+// Package auth checks the bearer tokens of incoming calls.
+//
+// Clients send the token in the authorization metadata, as in the
+// token-based-authentication sample of chapter 6:
+//
+//	authorization: Bearer some-secret-token
+//
+// The accepted tokens can be replaced while the server runs, e.g. to rotate
+// them after a config reload.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultExempt are the method prefixes callable without a token: health
+// checks from probes and server reflection.
+var DefaultExempt = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+type principalKey struct{}
+
+// Principal returns the principal of the token the call was authenticated
+// with.
+func Principal(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	return p, ok
+}
+
+type recorderKey struct{}
+
+// RecordPrincipal returns a context on which the interceptors of Tokens
+// record the principal of the call, and a function returning it. It lets
+// interceptors chained before the auth interceptors, e.g. the access log,
+// see the principal after the call.
+func RecordPrincipal(ctx context.Context) (context.Context, func() string) {
+	var principal atomic.Value // string
+	principal.Store("")
+	ctx = context.WithValue(ctx, recorderKey{}, &principal)
+	return ctx, func() string { return principal.Load().(string) }
+}
+
+// ParseTokens parses principal=token pairs into a map from token to
+// principal.
+func ParseTokens(entries []string) (map[string]string, error) {
+	tokens := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q is not a principal=token pair", parts[0])
+		}
+		tokens[parts[1]] = parts[0]
+	}
+	return tokens, nil
+}
+
+// Tokens authenticates calls by their bearer token. Without tokens every
+// call is accepted.
+type Tokens struct {
+	exempt []string
+
+	mu      sync.RWMutex
+	byToken map[string]string
+}
+
+// NewTokens returns Tokens accepting the principal=token pairs in entries.
+// Calls to methods starting with one of the exempt prefixes are accepted
+// without a token; DefaultExempt is used when none are given.
+func NewTokens(entries []string, exempt ...string) (*Tokens, error) {
+	if len(exempt) == 0 {
+		exempt = DefaultExempt
+	}
+	t := &Tokens{exempt: exempt}
+	if err := t.Set(entries); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Set replaces the accepted tokens. Calls in flight are not affected.
+func (t *Tokens) Set(entries []string) error {
+	byToken, err := ParseTokens(entries)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byToken = byToken
+	return nil
+}
+
+// Enabled reports whether any tokens are set, i.e. whether calls need one.
+func (t *Tokens) Enabled() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.byToken) > 0
+}
+
+// principalOf returns the principal of the bearer token in an authorization
+// value. The tokens are compared in constant time, so the time a check takes
+// does not tell how much of a token was right.
+func principalOf(byToken map[string]string, authorization string) (string, bool) {
+	const scheme = "Bearer "
+	if len(authorization) < len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return "", false
+	}
+	token := []byte(authorization[len(scheme):])
+	principal, found := "", false
+	for t, p := range byToken {
+		if subtle.ConstantTimeCompare([]byte(t), token) == 1 {
+			principal, found = p, true
+		}
+	}
+	return principal, found
+}
+
+// authenticate returns ctx with the principal of the call's token.
+func (t *Tokens) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	for _, prefix := range t.exempt {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return ctx, nil
+		}
+	}
+	t.mu.RLock()
+	byToken := t.byToken
+	t.mu.RUnlock()
+	if len(byToken) == 0 {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization token is not supplied")
+	}
+	principal, ok := principalOf(byToken, values[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if rec, ok := ctx.Value(recorderKey{}).(*atomic.Value); ok {
+		rec.Store(principal)
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// UnaryServerInterceptor rejects unary calls without a valid token with
+// UNAUTHENTICATED.
+func (t *Tokens) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams without a valid token with
+// UNAUTHENTICATED.
+func (t *Tokens) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// HTTPHandler wraps h so requests need a valid bearer token in their
+// Authorization header, as calls do in their metadata. It rejects the others
+// with 401 Unauthorized. Without tokens every request is accepted.
+func (t *Tokens) HTTPHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.RLock()
+		byToken := t.byToken
+		t.mu.RUnlock()
+		if len(byToken) > 0 {
+			if _, ok := principalOf(byToken, r.Header.Get("Authorization")); !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ordersMethod = "/ecommerce.OrderManagement/getOrder"
+	healthMethod = "/grpc.health.v1.Health/Check"
+)
+
+// call runs a unary call to method with the authorization value, if any,
+// through the interceptor of t and returns the principal the handler saw.
+func call(t *Tokens, method, authorization string) (string, error) {
+	ctx := context.Background()
+	if authorization != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+	}
+	var principal string
+	_, err := t.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = Principal(ctx)
+			return nil, nil
+		})
+	return principal, err
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tokens, err := NewTokens([]string{"order-client=secret", "operator=other-secret"})
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		wantCode      codes.Code
+		wantPrincipal string
+	}{
+		{name: "valid token", method: ordersMethod, authorization: "Bearer secret", wantPrincipal: "order-client"},
+		{name: "other valid token", method: ordersMethod, authorization: "Bearer other-secret", wantPrincipal: "operator"},
+		{name: "scheme in lower case", method: ordersMethod, authorization: "bearer secret", wantPrincipal: "order-client"},
+		{name: "missing token", method: ordersMethod, wantCode: codes.Unauthenticated},
+		{name: "invalid token", method: ordersMethod, authorization: "Bearer wrong", wantCode: codes.Unauthenticated},
+		{name: "prefix of a token", method: ordersMethod, authorization: "Bearer secre", wantCode: codes.Unauthenticated},
+		{name: "token without scheme", method: ordersMethod, authorization: "secret", wantCode: codes.Unauthenticated},
+		{name: "other scheme", method: ordersMethod, authorization: "Basic secret", wantCode: codes.Unauthenticated},
+		{name: "empty bearer token", method: ordersMethod, authorization: "Bearer ", wantCode: codes.Unauthenticated},
+		{name: "exempt health check", method: healthMethod},
+		{name: "exempt reflection", method: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := call(tokens, tt.method, tt.authorization)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("call() = %v, want %v", err, tt.wantCode)
+			}
+			if principal != tt.wantPrincipal {
+				t.Errorf("principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestExemptPrefixes(t *testing.T) {
+	tokens, err := NewTokens([]string{"order-client=secret"}, "/ecommerce.OrderManagement/")
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	if _, err := call(tokens, ordersMethod, ""); err != nil {
+		t.Errorf("call() to an exempt method without a token = %v, want nil", err)
+	}
+	// Given prefixes replace DefaultExempt.
+	if _, err := call(tokens, healthMethod, ""); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call() to the health service without a token = %v, want UNAUTHENTICATED", err)
+	}
+}
+
+func TestWithoutTokens(t *testing.T) {
+	tokens, err := NewTokens(nil)
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	if tokens.Enabled() {
+		t.Error("Enabled() = true without tokens")
+	}
+	if _, err := call(tokens, ordersMethod, ""); err != nil {
+		t.Errorf("call() without tokens = %v, want nil", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tokens, err := NewTokens([]string{"order-client=old"})
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	if err := tokens.Set([]string{"order-client=new"}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if _, err := call(tokens, ordersMethod, "Bearer old"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call() with the rotated token = %v, want UNAUTHENTICATED", err)
+	}
+	if principal, err := call(tokens, ordersMethod, "Bearer new"); err != nil || principal != "order-client" {
+		t.Errorf("call() with the new token = %q, %v, want order-client", principal, err)
+	}
+
+	// Invalid entries keep the tokens in place.
+	if err := tokens.Set([]string{"no-token"}); err == nil {
+		t.Error("Set() with an invalid entry succeeded")
+	}
+	if _, err := call(tokens, ordersMethod, "Bearer new"); err != nil {
+		t.Errorf("call() after a failed Set() = %v, want nil", err)
+	}
+
+	// Removing every token opens the server.
+	if err := tokens.Set(nil); err != nil {
+		t.Fatalf("Set(nil) failed: %v", err)
+	}
+	if _, err := call(tokens, ordersMethod, ""); err != nil {
+		t.Errorf("call() after removing the tokens = %v, want nil", err)
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	for _, entries := range [][]string{{"secret"}, {"=secret"}, {"order-client="}} {
+		if _, err := ParseTokens(entries); err == nil {
+			t.Errorf("ParseTokens(%q) succeeded", entries)
+		}
+	}
+	got, err := ParseTokens([]string{"order-client=a=b"})
+	if err != nil || got["a=b"] != "order-client" {
+		t.Errorf("ParseTokens() = %v, %v, want the token a=b of order-client", got, err)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	tokens, err := NewTokens([]string{"operator=secret"})
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	h := tokens.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tc := range []struct {
+		authorization string
+		want          int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/rpcs", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("GET with Authorization %q = %d, want %d", tc.authorization, rec.Code, tc.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("401 without WWW-Authenticate: Bearer")
+		}
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func TestRecordPrincipal(t *testing.T) {
+	tokens, err := NewTokens([]string{"order-client=secret"})
+	if err != nil {
+		t.Fatalf("NewTokens() failed: %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	ctx, recorded := RecordPrincipal(ctx)
+	if got := recorded(); got != "" {
+		t.Errorf("recorded() before the call = %q, want empty", got)
+	}
+
+	var seen string
+	err = tokens.StreamServerInterceptor()(nil, &fakeStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/ecommerce.OrderManagement/processOrders"},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			seen, _ = Principal(ss.Context())
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("stream interceptor failed: %v", err)
+	}
+	if seen != "order-client" {
+		t.Errorf("Principal() in the handler = %q, want order-client", seen)
+	}
+	if got := recorded(); got != "order-client" {
+		t.Errorf("recorded() after the call = %q, want order-client", got)
+	}
+}
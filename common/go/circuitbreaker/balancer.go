@@ -0,0 +1,236 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// Name is the name of the circuit_breaker balancing policy.
+const Name = "circuit_breaker"
+
+// Config is the config of the circuit_breaker policy, which wraps the picker
+// of its child policy,
+//
+//	{"loadBalancingConfig": [{"circuit_breaker": {
+//	  "childPolicy": [{"round_robin": {}}]}}]}
+//
+// Every pick of the child is checked against the breaker of the picked
+// backend and the method of the call. A backend whose breaker rejects the
+// call is skipped and the child picks again, so an open breaker only takes
+// its backend out of rotation. When the breakers of all backends reject the
+// call it fails fast with UNAVAILABLE.
+type Config struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// ChildPolicy is the policy picking the backends, the first registered
+	// one of the list.
+	ChildPolicy []map[string]json.RawMessage `json:"childPolicy"`
+
+	child       balancer.Builder
+	childConfig serviceconfig.LoadBalancingConfig
+}
+
+func init() {
+	balancer.Register(builder{})
+}
+
+type breakersKey struct{}
+
+// withBreakers returns a context carrying b to the picker.
+func withBreakers(ctx context.Context, b *Breakers) context.Context {
+	return context.WithValue(ctx, breakersKey{}, b)
+}
+
+type builder struct{}
+
+func (builder) Name() string { return Name }
+
+func (builder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", Name, js, err)
+	}
+	for _, policy := range cfg.ChildPolicy {
+		for name, raw := range policy {
+			bb := balancer.Get(name)
+			if bb == nil {
+				continue
+			}
+			cfg.child = bb
+			if parser, ok := bb.(balancer.ConfigParser); ok {
+				childConfig, err := parser.ParseConfig(raw)
+				if err != nil {
+					return nil, fmt.Errorf("%s: child policy: %v", Name, err)
+				}
+				cfg.childConfig = childConfig
+			}
+			return cfg, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no registered policy in childPolicy %s", Name, js)
+}
+
+func (builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return &breakerBalancer{cc: cc, opts: opts, addrs: make(map[balancer.SubConn]string)}
+}
+
+// breakerBalancer wraps the child policy and remembers the address of every
+// SubConn of the child, so that its picker knows the backend of a pick.
+type breakerBalancer struct {
+	cc   balancer.ClientConn
+	opts balancer.BuildOptions
+
+	mu        sync.Mutex
+	child     balancer.Balancer
+	childName string
+
+	// addrsMu guards addrs, also used by the child's calls into cc and by
+	// the pickers.
+	addrsMu sync.Mutex
+	addrs   map[balancer.SubConn]string
+}
+
+func (b *breakerBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	cfg, ok := s.BalancerConfig.(*Config)
+	if !ok {
+		return fmt.Errorf("%s: unexpected config %T", Name, s.BalancerConfig)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child == nil || b.childName != cfg.child.Name() {
+		if b.child != nil {
+			b.child.Close()
+		}
+		b.child, b.childName = cfg.child.Build(&breakerClientConn{ClientConn: b.cc, b: b}, b.opts), cfg.child.Name()
+	}
+	return b.child.UpdateClientConnState(balancer.ClientConnState{ResolverState: s.ResolverState, BalancerConfig: cfg.childConfig})
+}
+
+func (b *breakerBalancer) ResolverError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child != nil {
+		b.child.ResolverError(err)
+	}
+}
+
+func (b *breakerBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.SubConnState) {
+	if state.ConnectivityState == connectivity.Shutdown {
+		b.addrsMu.Lock()
+		delete(b.addrs, sc)
+		b.addrsMu.Unlock()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child != nil {
+		b.child.UpdateSubConnState(sc, state)
+	}
+}
+
+func (b *breakerBalancer) ExitIdle() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ei, ok := b.child.(balancer.ExitIdler); ok {
+		ei.ExitIdle()
+	}
+}
+
+func (b *breakerBalancer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child != nil {
+		b.child.Close()
+	}
+}
+
+// addr returns the address of a SubConn of the child and the number of
+// SubConns.
+func (b *breakerBalancer) addr(sc balancer.SubConn) (string, int, bool) {
+	b.addrsMu.Lock()
+	defer b.addrsMu.Unlock()
+	addr, ok := b.addrs[sc]
+	return addr, len(b.addrs), ok
+}
+
+// breakerClientConn is the ClientConn of the child policy.
+type breakerClientConn struct {
+	balancer.ClientConn
+	b *breakerBalancer
+}
+
+func (cc *breakerClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	sc, err := cc.ClientConn.NewSubConn(addrs, opts)
+	if err != nil {
+		return nil, err
+	}
+	cc.b.addrsMu.Lock()
+	cc.b.addrs[sc] = addrs[0].Addr
+	cc.b.addrsMu.Unlock()
+	return sc, nil
+}
+
+func (cc *breakerClientConn) UpdateAddresses(sc balancer.SubConn, addrs []resolver.Address) {
+	cc.b.addrsMu.Lock()
+	if _, ok := cc.b.addrs[sc]; ok && len(addrs) > 0 {
+		cc.b.addrs[sc] = addrs[0].Addr
+	}
+	cc.b.addrsMu.Unlock()
+	cc.ClientConn.UpdateAddresses(sc, addrs)
+}
+
+func (cc *breakerClientConn) UpdateState(s balancer.State) {
+	if s.Picker != nil {
+		s.Picker = &picker{child: s.Picker, b: cc.b}
+	}
+	cc.ClientConn.UpdateState(s)
+}
+
+// picker skips the picks of the child whose breaker rejects the call and
+// records the outcome of the calls in the breaker of their backend.
+type picker struct {
+	child balancer.Picker
+	b     *breakerBalancer
+}
+
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	breakers, _ := info.Ctx.Value(breakersKey{}).(*Breakers)
+	if breakers == nil {
+		// The call did not go through the interceptors of a Breakers.
+		return p.child.Pick(info)
+	}
+	// The child gets one pick per SubConn to find a backend whose breaker
+	// lets the call through.
+	for tries := 1; ; tries++ {
+		res, err := p.child.Pick(info)
+		if err != nil {
+			return res, err
+		}
+		addr, n, ok := p.b.addr(res.SubConn)
+		if !ok {
+			return res, nil
+		}
+		done, err := breakers.get(Key{Addr: addr, Method: info.FullMethodName}).allow()
+		if err == nil {
+			childDone := res.Done
+			res.Done = func(di balancer.DoneInfo) {
+				done(di.Err)
+				if childDone != nil {
+					childDone(di)
+				}
+			}
+			return res, nil
+		}
+		if res.Done != nil {
+			res.Done(balancer.DoneInfo{Err: err})
+		}
+		if tries >= n {
+			return balancer.PickResult{}, err
+		}
+	}
+}
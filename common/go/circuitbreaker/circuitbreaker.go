@@ -0,0 +1,405 @@
+// Package circuitbreaker implements a client side circuit breaker per backend
+// and method.
+//
+// Every (backend address, method) pair has its own breaker. A breaker starts
+// closed and counts requests and failures. When the failure ratio exceeds the
+// configured threshold it opens and the backend gets no more calls of the
+// method. After OpenTimeout it lets a few probe calls through (half-open) and
+// closes again once they all succeed.
+//
+// The breakers need both the interceptors of a Breakers and the
+// circuit_breaker balancing policy of the ClientConn: the interceptors hand
+// the Breakers to the picker of the policy, which checks the breaker of every
+// backend its child policy picks and picks another backend while the breaker
+// rejects the call. So one failing backend behind a balancing ClientConn only
+// takes itself out of rotation, and calls fail fast with UNAVAILABLE only when
+// the breakers of all backends are open.
+//
+// RegisterMetrics exports the state, rejections and transitions of the
+// breakers as OpenTelemetry metrics.
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	// StateClosed lets all calls through.
+	StateClosed State = iota
+	// StateOpen rejects all calls.
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls through.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Key identifies a breaker.
+type Key struct {
+	// Addr is the address of the backend picked for the call, e.g.
+	// localhost:50051.
+	Addr   string
+	Method string
+}
+
+// Settings configures the breakers.
+type Settings struct {
+	// Window is the period after which the counts of a closed breaker are
+	// cleared.
+	Window time.Duration
+	// MinRequests is the number of requests within a window required before
+	// the breaker may trip.
+	MinRequests int
+	// FailureRatio trips the breaker when failures/requests reaches it.
+	FailureRatio float64
+	// OpenTimeout is the time an open breaker waits before going half-open.
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is the number of probe calls allowed while
+	// half-open. The breaker closes when all of them succeed.
+	HalfOpenMaxRequests int
+	// IsFailure reports whether an error counts as a failure. Errors caused
+	// by the caller, such as INVALID_ARGUMENT or NOT_FOUND, should not.
+	IsFailure func(err error) bool
+	// OnStateChange is called after every state transition.
+	OnStateChange func(key Key, from, to State)
+}
+
+// DefaultSettings returns settings tripping at a failure ratio of 50% over
+// at least 10 requests.
+func DefaultSettings() Settings {
+	return Settings{
+		Window:              10 * time.Second,
+		MinRequests:         10,
+		FailureRatio:        0.5,
+		OpenTimeout:         5 * time.Second,
+		HalfOpenMaxRequests: 3,
+		IsFailure:           IsServerFailure,
+	}
+}
+
+// IsServerFailure reports whether err signals an unhealthy backend.
+func IsServerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DeadlineExceeded,
+		codes.ResourceExhausted, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// Stats is a snapshot of a breaker.
+type Stats struct {
+	Key         Key
+	State       State
+	Requests    int
+	Failures    int
+	Rejected    uint64
+	Transitions map[string]uint64
+}
+
+// Breakers holds the breakers of all (backend address, method) pairs.
+type Breakers struct {
+	settings Settings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	breakers map[Key]*breaker
+}
+
+// New creates an empty set of breakers.
+func New(s Settings) *Breakers {
+	d := DefaultSettings()
+	if s.Window <= 0 {
+		s.Window = d.Window
+	}
+	if s.MinRequests <= 0 {
+		s.MinRequests = d.MinRequests
+	}
+	if s.FailureRatio <= 0 {
+		s.FailureRatio = d.FailureRatio
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = d.OpenTimeout
+	}
+	if s.HalfOpenMaxRequests <= 0 {
+		s.HalfOpenMaxRequests = d.HalfOpenMaxRequests
+	}
+	if s.IsFailure == nil {
+		s.IsFailure = d.IsFailure
+	}
+	return &Breakers{settings: s, now: time.Now, breakers: make(map[Key]*breaker)}
+}
+
+func (b *Breakers) get(key Key) *breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br, ok := b.breakers[key]
+	if !ok {
+		br = &breaker{key: key, settings: &b.settings, now: b.now, transitions: make(map[string]uint64)}
+		br.windowStart = b.now()
+		b.breakers[key] = br
+	}
+	return br
+}
+
+// State returns the current state of the breaker of a backend and method.
+func (b *Breakers) State(addr, method string) State {
+	return b.get(Key{Addr: addr, Method: method}).currentState()
+}
+
+// Snapshot returns the stats of all breakers, e.g. to be logged or published
+// with expvar.Func.
+func (b *Breakers) Snapshot() []Stats {
+	b.mu.Lock()
+	all := make([]*breaker, 0, len(b.breakers))
+	for _, br := range b.breakers {
+		all = append(all, br)
+	}
+	b.mu.Unlock()
+
+	stats := make([]Stats, 0, len(all))
+	for _, br := range all {
+		stats = append(stats, br.stats())
+	}
+	return stats
+}
+
+// RegisterMetrics exports the breakers on meter, by backend address and
+// method:
+//
+//	circuit_breaker.state        0 closed, 1 open, 2 half-open
+//	circuit_breaker.rejected     calls failed fast by an open or half-open breaker
+//	circuit_breaker.transitions  state transitions, also by from and to state
+//
+// The values are read from Snapshot on every collection. With the Prometheus
+// exporter of the metrics package they read e.g.
+//
+//	circuit_breaker_transitions_total{addr="localhost:50052",from="closed",method="/grpc.examples.echo.Echo/UnaryEcho",to="open"} 1
+func (b *Breakers) RegisterMetrics(meter metric.Meter) error {
+	state, err := meter.Int64ObservableGauge("circuit_breaker.state",
+		metric.WithDescription("State of the breaker: 0 closed, 1 open, 2 half-open."))
+	if err != nil {
+		return err
+	}
+	rejected, err := meter.Int64ObservableCounter("circuit_breaker.rejected",
+		metric.WithDescription("Calls failed fast by an open or half-open breaker."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		return err
+	}
+	transitions, err := meter.Int64ObservableCounter("circuit_breaker.transitions",
+		metric.WithDescription("State transitions of the breaker."),
+		metric.WithUnit("{transition}"))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, st := range b.Snapshot() {
+			key := []attribute.KeyValue{attribute.String("addr", st.Key.Addr), attribute.String("method", st.Key.Method)}
+			o.ObserveInt64(state, int64(st.State), metric.WithAttributes(key...))
+			o.ObserveInt64(rejected, int64(st.Rejected), metric.WithAttributes(key...))
+			for t, n := range st.Transitions {
+				from, to, _ := strings.Cut(t, "->")
+				o.ObserveInt64(transitions, int64(n), metric.WithAttributes(
+					append(key[:len(key):len(key)], attribute.String("from", from), attribute.String("to", to))...))
+			}
+		}
+		return nil
+	}, state, rejected, transitions)
+	return err
+}
+
+// UnaryClientInterceptor returns a client interceptor guarding unary calls
+// with the breakers of the backends picked by the circuit_breaker policy.
+func (b *Breakers) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withBreakers(ctx, b), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor guarding streaming
+// calls with the breakers of the backends picked by the circuit_breaker
+// policy. A stream is counted with the status it ends with: the error of
+// opening it, its final status, or the error of its context when it is
+// cancelled or its deadline expires.
+func (b *Breakers) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withBreakers(ctx, b), desc, cc, method, opts...)
+	}
+}
+
+type breaker struct {
+	key      Key
+	settings *Settings
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       State
+	generation  uint64 // incremented on every state change
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	inFlight    int // probes in flight while half-open
+	successes   int // successful probes while half-open
+	rejected    uint64
+	transitions map[string]uint64
+	pending     [][2]State // transitions not yet reported to OnStateChange
+}
+
+// allow reports whether a call may proceed. The returned function must be
+// called with the outcome of the call.
+func (br *breaker) allow() (func(error), error) {
+	br.mu.Lock()
+	defer br.unlock()
+
+	br.refresh()
+	switch br.state {
+	case StateOpen:
+		br.rejected++
+		return nil, status.Errorf(codes.Unavailable, "circuit breaker for %s %s is open", br.key.Addr, br.key.Method)
+	case StateHalfOpen:
+		if br.inFlight >= br.settings.HalfOpenMaxRequests {
+			br.rejected++
+			return nil, status.Errorf(codes.Unavailable, "circuit breaker for %s %s is half-open", br.key.Addr, br.key.Method)
+		}
+		br.inFlight++
+	}
+	gen := br.generation
+	return func(err error) { br.record(gen, err) }, nil
+}
+
+func (br *breaker) record(gen uint64, err error) {
+	br.mu.Lock()
+	defer br.unlock()
+
+	if br.generation != gen {
+		// The breaker changed state while the call was in flight.
+		return
+	}
+	failed := err != nil && br.settings.IsFailure(err)
+	if br.state == StateHalfOpen {
+		br.inFlight--
+	}
+	switch br.state {
+	case StateClosed:
+		br.requests++
+		if failed {
+			br.failures++
+		}
+		if br.requests >= br.settings.MinRequests &&
+			float64(br.failures)/float64(br.requests) >= br.settings.FailureRatio {
+			br.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			br.setState(StateOpen)
+			return
+		}
+		br.successes++
+		if br.successes >= br.settings.HalfOpenMaxRequests {
+			br.setState(StateClosed)
+		}
+	}
+}
+
+// refresh applies the time based transitions. br.mu must be held.
+func (br *breaker) refresh() {
+	now := br.now()
+	switch br.state {
+	case StateClosed:
+		if now.Sub(br.windowStart) >= br.settings.Window {
+			br.windowStart = now
+			br.requests, br.failures = 0, 0
+		}
+	case StateOpen:
+		if now.Sub(br.openedAt) >= br.settings.OpenTimeout {
+			br.setState(StateHalfOpen)
+		}
+	}
+}
+
+// setState moves the breaker to a new state. br.mu must be held.
+func (br *breaker) setState(to State) {
+	from := br.state
+	if from == to {
+		return
+	}
+	br.state = to
+	br.generation++
+	now := br.now()
+	switch to {
+	case StateClosed:
+		br.windowStart = now
+		br.requests, br.failures = 0, 0
+	case StateOpen:
+		br.openedAt = now
+	case StateHalfOpen:
+		br.inFlight, br.successes = 0, 0
+	}
+	br.transitions[from.String()+"->"+to.String()]++
+	br.pending = append(br.pending, [2]State{from, to})
+}
+
+// unlock releases br.mu and then reports the pending transitions, so that
+// OnStateChange may query the breakers.
+func (br *breaker) unlock() {
+	pending := br.pending
+	br.pending = nil
+	br.mu.Unlock()
+	if br.settings.OnStateChange == nil {
+		return
+	}
+	for _, t := range pending {
+		br.settings.OnStateChange(br.key, t[0], t[1])
+	}
+}
+
+func (br *breaker) currentState() State {
+	br.mu.Lock()
+	defer br.unlock()
+	br.refresh()
+	return br.state
+}
+
+func (br *breaker) stats() Stats {
+	br.mu.Lock()
+	defer br.unlock()
+	br.refresh()
+	transitions := make(map[string]uint64, len(br.transitions))
+	for k, v := range br.transitions {
+		transitions[k] = v
+	}
+	return Stats{
+		Key:         br.key,
+		State:       br.state,
+		Requests:    br.requests,
+		Failures:    br.failures,
+		Rejected:    br.rejected,
+		Transitions: transitions,
+	}
+}
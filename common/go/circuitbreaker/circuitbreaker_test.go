@@ -0,0 +1,435 @@
+package circuitbreaker
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+)
+
+const unaryEchoMethod = "/grpc.examples.echo.Echo/UnaryEcho"
+
+type transition struct{ from, to State }
+
+func newTestBreakers(now *time.Time) (*Breakers, *[]transition) {
+	var mu sync.Mutex
+	var transitions []transition
+	b := New(Settings{
+		Window:              time.Minute,
+		MinRequests:         4,
+		FailureRatio:        0.5,
+		OpenTimeout:         time.Second,
+		HalfOpenMaxRequests: 2,
+		OnStateChange: func(key Key, from, to State) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, transition{from, to})
+		},
+	})
+	b.now = func() time.Time { return *now }
+	return b, &transitions
+}
+
+func call(t *testing.T, br *breaker, err error) error {
+	t.Helper()
+	done, allowErr := br.allow()
+	if allowErr != nil {
+		return allowErr
+	}
+	done(err)
+	return nil
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	now := time.Unix(0, 0)
+	b, transitions := newTestBreakers(&now)
+	br := b.get(Key{Addr: "localhost:50051", Method: unaryEchoMethod})
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	call(t, br, nil)
+	call(t, br, nil)
+	call(t, br, unavailable)
+	if got := br.currentState(); got != StateClosed {
+		t.Fatalf("state after 1/3 failures = %v, want closed", got)
+	}
+	call(t, br, unavailable)
+	if got := br.currentState(); got != StateOpen {
+		t.Fatalf("state after 2/4 failures = %v, want open", got)
+	}
+
+	if err := call(t, br, nil); status.Code(err) != codes.Unavailable {
+		t.Fatalf("call while open returned %v, want UNAVAILABLE", err)
+	}
+
+	now = now.Add(time.Second)
+	if got := br.currentState(); got != StateHalfOpen {
+		t.Fatalf("state after open timeout = %v, want half-open", got)
+	}
+	// A failing probe opens the breaker again.
+	call(t, br, unavailable)
+	if got := br.currentState(); got != StateOpen {
+		t.Fatalf("state after failed probe = %v, want open", got)
+	}
+
+	now = now.Add(time.Second)
+	call(t, br, nil)
+	call(t, br, nil)
+	if got := br.currentState(); got != StateClosed {
+		t.Fatalf("state after successful probes = %v, want closed", got)
+	}
+
+	want := []transition{
+		{StateClosed, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateClosed},
+	}
+	if len(*transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", *transitions, want)
+	}
+	for i := range want {
+		if (*transitions)[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", *transitions, want)
+		}
+	}
+}
+
+func TestClientErrorsDoNotTrip(t *testing.T) {
+	now := time.Unix(0, 0)
+	b, _ := newTestBreakers(&now)
+	br := b.get(Key{Addr: "localhost:50051", Method: "/ecommerce.OrderManagement/getOrder"})
+
+	for i := 0; i < 10; i++ {
+		call(t, br, status.Error(codes.NotFound, "no such order"))
+	}
+	if got := br.currentState(); got != StateClosed {
+		t.Fatalf("state after NOT_FOUND errors = %v, want closed", got)
+	}
+}
+
+// echoServer fails the calls with the message "fail", or all calls when it
+// is down, with UNAVAILABLE, after sending one response on streams, and
+// blocks the calls with the message "block" until they are done.
+type echoServer struct {
+	ecpb.UnimplementedEchoServer
+	down  int32
+	calls int32
+}
+
+func (s *echoServer) reply(ctx context.Context, msg string) error {
+	atomic.AddInt32(&s.calls, 1)
+	if atomic.LoadInt32(&s.down) != 0 {
+		msg = "fail"
+	}
+	switch msg {
+	case "fail":
+		return status.Error(codes.Unavailable, "backend down")
+	case "block":
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return nil
+}
+
+func (s *echoServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	if err := s.reply(ctx, req.Message); err != nil {
+		return nil, err
+	}
+	return &ecpb.EchoResponse{Message: req.Message}, nil
+}
+
+func (s *echoServer) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+		return err
+	}
+	return s.reply(stream.Context(), req.Message)
+}
+
+func (s *echoServer) ClientStreamingEcho(stream ecpb.Echo_ClientStreamingEchoServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if err := s.reply(stream.Context(), req.Message); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&ecpb.EchoResponse{Message: req.Message})
+}
+
+// serve serves srv on a local port and returns its address.
+func serve(t *testing.T, srv *echoServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// dialAll connects to the echo service of registry through the interceptors
+// of b and the circuit_breaker policy over round_robin.
+func dialAll(t *testing.T, b *Breakers, registry *discovery.Registry) ecpb.EchoClient {
+	t.Helper()
+	conn, err := grpc.Dial("test:///echo", grpc.WithInsecure(),
+		grpc.WithResolvers(discovery.NewBuilder("test", registry)),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"circuit_breaker": {"childPolicy": [{"round_robin": {}}]}}]}`),
+		grpc.WithUnaryInterceptor(b.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(b.StreamClientInterceptor()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn)
+}
+
+// dial serves an echoServer and dials it through the breakers of b.
+func dial(t *testing.T, b *Breakers) (ecpb.EchoClient, *echoServer, string) {
+	t.Helper()
+	srv := &echoServer{}
+	addr := serve(t, srv)
+	registry := discovery.NewRegistry()
+	registry.Register("echo", discovery.Endpoint{Addr: addr})
+	return dialAll(t, b, registry), srv, addr
+}
+
+func interceptorBreakers() *Breakers {
+	return New(Settings{MinRequests: 4, FailureRatio: 0.5, OpenTimeout: time.Minute})
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	b := interceptorBreakers()
+	client, srv, target := dial(t, b)
+	ctx := context.Background()
+	for _, msg := range []string{"ok", "ok", "fail", "fail"} {
+		client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: msg})
+	}
+	if got := b.State(target, unaryEchoMethod); got != StateOpen {
+		t.Fatalf("state after 2/4 failures = %v, want open", got)
+	}
+
+	_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"})
+	if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "circuit breaker") {
+		t.Errorf("call while open = %v, want UNAVAILABLE from the circuit breaker", err)
+	}
+	if got := atomic.LoadInt32(&srv.calls); got != 4 {
+		t.Errorf("server got %d calls, want 4: the call while open reached it", got)
+	}
+	// The breakers are per method.
+	if got := b.State(target, "/grpc.examples.echo.Echo/ServerStreamingEcho"); got != StateClosed {
+		t.Errorf("state of another method = %v, want closed", got)
+	}
+}
+
+// recvAll reads a stream until it ends and returns its final status.
+func recvAll(stream ecpb.Echo_ServerStreamingEchoClient) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	b := interceptorBreakers()
+	client, srv, target := dial(t, b)
+	method := "/grpc.examples.echo.Echo/ServerStreamingEcho"
+	ctx := context.Background()
+
+	for _, msg := range []string{"ok", "fail"} {
+		stream, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: msg})
+		if err != nil {
+			t.Fatalf("ServerStreamingEcho() failed: %v", err)
+		}
+		recvAll(stream)
+	}
+	// A stream whose deadline expires counts as a failure.
+	dctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ServerStreamingEcho(dctx, &ecpb.EchoRequest{Message: "block"}); err != nil {
+		t.Fatalf("ServerStreamingEcho() failed: %v", err)
+	}
+	<-dctx.Done()
+	// The breaker counts the expired stream asynchronously.
+	var st Stats
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if st = stats(b, target, method); st.Requests == 3 {
+			break
+		}
+	}
+	if st.Requests != 3 || st.Failures != 2 {
+		t.Fatalf("stats after 3 streams = %d requests, %d failures, want 3 and 2", st.Requests, st.Failures)
+	}
+
+	stream, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: "fail"})
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho() failed: %v", err)
+	}
+	recvAll(stream)
+	if got := b.State(target, method); got != StateOpen {
+		t.Fatalf("state after 3/4 failed streams = %v, want open", got)
+	}
+	calls := atomic.LoadInt32(&srv.calls)
+	if _, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: "ok"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("stream while open = %v, want UNAVAILABLE", err)
+	}
+	if got := atomic.LoadInt32(&srv.calls); got != calls {
+		t.Error("the stream opened while open reached the server")
+	}
+}
+
+func TestClientStreamingStatus(t *testing.T) {
+	b := interceptorBreakers()
+	client, _, target := dial(t, b)
+	for _, msg := range []string{"ok", "fail"} {
+		stream, err := client.ClientStreamingEcho(context.Background())
+		if err != nil {
+			t.Fatalf("ClientStreamingEcho() failed: %v", err)
+		}
+		if err := stream.Send(&ecpb.EchoRequest{Message: msg}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		stream.CloseAndRecv()
+	}
+	st := stats(b, target, "/grpc.examples.echo.Echo/ClientStreamingEcho")
+	if st.Requests != 2 || st.Failures != 1 {
+		t.Errorf("stats after 2 client streams = %d requests, %d failures, want 2 and 1", st.Requests, st.Failures)
+	}
+}
+
+func stats(b *Breakers, addr, method string) Stats {
+	for _, st := range b.Snapshot() {
+		if st.Key == (Key{Addr: addr, Method: method}) {
+			return st
+		}
+	}
+	return Stats{}
+}
+
+func TestOpenBreakerSkipsBackend(t *testing.T) {
+	b := interceptorBreakers()
+	up, down := &echoServer{}, &echoServer{down: 1}
+	upAddr, downAddr := serve(t, up), serve(t, down)
+	registry := discovery.NewRegistry()
+	registry.Register("echo", discovery.Endpoint{Addr: upAddr})
+	registry.Register("echo", discovery.Endpoint{Addr: downAddr})
+	client := dialAll(t, b, registry)
+	ctx := context.Background()
+
+	// Wait for round_robin to use both backends.
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&down.calls) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("no call reached the second backend")
+		}
+		client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"})
+	}
+	for i := 0; i < 10; i++ {
+		client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"})
+	}
+	if got := b.State(downAddr, unaryEchoMethod); got != StateOpen {
+		t.Fatalf("state of the failing backend = %v, want open", got)
+	}
+	if got := b.State(upAddr, unaryEchoMethod); got != StateClosed {
+		t.Fatalf("state of the healthy backend = %v, want closed", got)
+	}
+
+	// The calls go to the healthy backend only.
+	calls := atomic.LoadInt32(&down.calls)
+	for i := 0; i < 10; i++ {
+		if _, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"}); err != nil {
+			t.Fatalf("call %d with one breaker open failed: %v", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(&down.calls); got != calls {
+		t.Errorf("the failing backend got %d calls while its breaker was open", got-calls)
+	}
+
+	// With all breakers open the calls fail fast.
+	atomic.StoreInt32(&up.down, 1)
+	for i := 0; i < 100 && b.State(upAddr, unaryEchoMethod) != StateOpen; i++ {
+		client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"})
+	}
+	_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "ok"})
+	if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "circuit breaker") {
+		t.Errorf("call with all breakers open = %v, want UNAVAILABLE from the circuit breaker", err)
+	}
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	b := interceptorBreakers()
+	client, _, target := dial(t, b)
+	for _, msg := range []string{"fail", "fail", "fail", "fail", "ok", "ok"} {
+		client.UnaryEcho(context.Background(), &ecpb.EchoRequest{Message: msg})
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+	if err := b.RegisterMetrics(provider.Meter("circuitbreaker")); err != nil {
+		t.Fatalf("RegisterMetrics() failed: %v", err)
+	}
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() failed: %v", err)
+	}
+	key := attribute.NewSet(attribute.String("addr", target), attribute.String("method", unaryEchoMethod))
+	transition := attribute.NewSet(attribute.String("addr", target), attribute.String("from", "closed"),
+		attribute.String("method", unaryEchoMethod), attribute.String("to", "open"))
+	want := map[string]struct {
+		attrs attribute.Set
+		value int64
+	}{
+		"circuit_breaker.state":       {key, int64(StateOpen)},
+		"circuit_breaker.rejected":    {key, 2},
+		"circuit_breaker.transitions": {transition, 1},
+	}
+	got := make(map[string]bool)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			w, ok := want[m.Name]
+			if !ok {
+				continue
+			}
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			case metricdata.Sum[int64]:
+				points = data.DataPoints
+			}
+			for _, p := range points {
+				if p.Attributes.Equals(&w.attrs) {
+					got[m.Name] = true
+					if p.Value != w.value {
+						t.Errorf("%s = %d, want %d", m.Name, p.Value, w.value)
+					}
+				}
+			}
+		}
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("%s was not exported for %s", name, target)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+	"errors"
+
+	"github.com/grpc-up-and-running/samples/common/go/config/configpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+// RegisterAdmin registers the ConfigAdmin service of h on s. It shows the
+// active config and reloads it on request, e.g.
+//
+//	grpcurl -plaintext localhost:50051 grpcsamples.config.ConfigAdmin/GetConfig
+func RegisterAdmin(s grpc.ServiceRegistrar, h *Holder) {
+	configpb.RegisterConfigAdminServer(s, &adminServer{holder: h})
+}
+
+type adminServer struct {
+	configpb.UnimplementedConfigAdminServer
+	holder *Holder
+}
+
+func (a *adminServer) GetConfig(ctx context.Context, req *configpb.GetConfigRequest) (*configpb.ConfigSnapshot, error) {
+	return a.snapshot()
+}
+
+func (a *adminServer) ReloadConfig(ctx context.Context, req *configpb.ReloadConfigRequest) (*configpb.ConfigSnapshot, error) {
+	if err := a.holder.Reload(); err != nil && !errors.Is(err, ErrUnchanged) {
+		return nil, status.Errorf(codes.InvalidArgument, "config rejected, keeping version %d: %v", a.holder.Snapshot().Version, err)
+	}
+	return a.snapshot()
+}
+
+func (a *adminServer) snapshot() (*configpb.ConfigSnapshot, error) {
+	snap := a.holder.Snapshot()
+	data, err := yaml.Marshal(snap.Config.Redacted())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding config: %v", err)
+	}
+	return &configpb.ConfigSnapshot{
+		Yaml:     string(data),
+		Version:  snap.Version,
+		LoadedAt: timestamppb.New(snap.LoadedAt),
+		File:     a.holder.File(),
+	}, nil
+}
@@ -0,0 +1,468 @@
+// Package config loads the typed configuration shared by the sample servers
+// and clients.
+//
+// Values are taken from, in increasing order of precedence,
+//
+//  1. the defaults passed by the sample,
+//  2. the YAML file named by the -config flag or the GRPC_SAMPLE_CONFIG
+//     environment variable,
+//  3. environment variables, e.g. GRPC_SAMPLE_SERVER_ADDR,
+//  4. command line flags, e.g. -server.addr.
+//
+// The log level, rate limit, auth tokens and order batching can change at run
+// time. A Holder reloads them on SIGHUP or when the config file changes, see
+// LoadHolder.
+//
+// The flag and environment variable names are derived from the YAML keys: the
+// key server.tls.cert_file is set by the flag -server.tls.cert_file and the
+// environment variable GRPC_SAMPLE_SERVER_TLS_CERT_FILE. The loaded config is
+// validated before it is returned.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix prefixes the environment variables read by Load.
+	EnvPrefix = "GRPC_SAMPLE_"
+	// FileFlag names the flag, and without EnvPrefix the environment
+	// variable, holding the path of the YAML file.
+	FileFlag = "config"
+)
+
+// Config is the configuration of a sample server or client. Samples only use
+// the sections they need.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Client    Client    `yaml:"client"`
+	Orders    Orders    `yaml:"orders"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Log       Log       `yaml:"log"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Auth      Auth      `yaml:"auth"`
+	AccessLog AccessLog `yaml:"access_log"`
+	Admin     Admin     `yaml:"admin"`
+	Registry  Registry  `yaml:"registry"`
+}
+
+// Server configures a sample server.
+type Server struct {
+	Addr string `yaml:"addr" usage:"address the server listens on, e.g. :50051"`
+	// Addrs is used by samples running several backends in one process.
+	Addrs         []string      `yaml:"addrs" usage:"comma separated addresses of the backends run by the sample"`
+	TLS           TLS           `yaml:"tls"`
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" usage:"time the server keeps serving after reporting NOT_SERVING on shutdown"`
+	DrainTimeout  time.Duration `yaml:"drain_timeout" usage:"time in-flight calls get to finish on shutdown"`
+}
+
+// Client configures a sample client.
+type Client struct {
+	Target string `yaml:"target" usage:"address of the server, e.g. localhost:50051"`
+	// Addrs is used by samples resolving several backends themselves.
+	Addrs []string `yaml:"addrs" usage:"comma separated addresses of the backends the client balances over"`
+	// EndpointsFile is read by samples using the discovery package instead
+	// of Addrs.
+	EndpointsFile string        `yaml:"endpoints_file" usage:"JSON or YAML file with the endpoints of each service, re-read when it changes"`
+	TLS           TLS           `yaml:"tls"`
+	Timeout       time.Duration `yaml:"timeout" usage:"deadline of the calls made by the client"`
+	// ProductInfoAddr is used by the OrderManagement servers calling the
+	// ProductInfo service.
+	ProductInfoAddr string `yaml:"productinfo_addr" usage:"address of the ProductInfo service the items of new orders are registered with, e.g. localhost:50052"`
+}
+
+// TLS holds the certificate paths of a server or client.
+type TLS struct {
+	CertFile   string `yaml:"cert_file" usage:"PEM certificate file"`
+	KeyFile    string `yaml:"key_file" usage:"PEM private key file"`
+	CAFile     string `yaml:"ca_file" usage:"PEM file of the CA verifying the peer"`
+	ServerName string `yaml:"server_name" usage:"server name expected in the server certificate"`
+}
+
+// Enabled reports whether any certificate is configured.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.CAFile != ""
+}
+
+// Orders configures the order processing of the OrderManagement servers.
+type Orders struct {
+	BatchSize int `yaml:"batch_size" usage:"number of orders ProcessOrders combines into shipments"`
+}
+
+// Tracing configures the trace exporter.
+type Tracing struct {
+	Exporter   string  `yaml:"exporter" usage:"trace exporter: otlp, stdout or none; otlp when an endpoint is set"`
+	Endpoint   string  `yaml:"endpoint" usage:"OTLP/HTTP trace collector, e.g. localhost:4318 or http://collector:4318/v1/traces"`
+	SampleRate float64 `yaml:"sample_rate" usage:"fraction of the traces sampled, from 0 to 1"`
+}
+
+// Metrics configures the metric exporters. It is read on startup only.
+type Metrics struct {
+	PrometheusAddr string        `yaml:"prometheus_addr" usage:"HTTP address serving the Prometheus metrics at /metrics, e.g. :9092"`
+	Endpoint       string        `yaml:"endpoint" usage:"OTLP/HTTP metrics collector, e.g. localhost:4318 or http://collector:4318/v1/metrics"`
+	Interval       time.Duration `yaml:"interval" usage:"interval of the OTLP metric exports"`
+}
+
+// Log configures the logging of a sample.
+type Log struct {
+	Level string `yaml:"level" usage:"minimum level logged: debug, info, warn or error"`
+	// Format is read on startup only.
+	Format   string   `yaml:"format" usage:"log format: text or json"`
+	Packages []string `yaml:"packages" usage:"comma separated package=level pairs overriding the level"`
+	// SampleFirst and SampleEvery sample the per-message logs of streams.
+	SampleFirst int `yaml:"sample_first" usage:"per-message logs written at the start of every stream"`
+	SampleEvery int `yaml:"sample_every" usage:"after the first ones, write every n-th per-message log of a stream, 0 for none"`
+}
+
+// RateLimit limits the calls a server accepts. A zero RPS disables the limit.
+type RateLimit struct {
+	RPS   float64 `yaml:"rps" usage:"calls per second accepted by the server, 0 for no limit"`
+	Burst int     `yaml:"burst" usage:"calls accepted at once above the rate"`
+}
+
+// Auth holds the bearer tokens a server accepts. Without tokens every call is
+// accepted.
+type Auth struct {
+	Tokens []string `yaml:"tokens" usage:"comma separated principal=token pairs of the accepted bearer tokens"`
+}
+
+// AccessLog configures the access log of a server. It is read on startup
+// only. Without sinks no access log is written.
+type AccessLog struct {
+	Format     string   `yaml:"format" usage:"access log line format: common, json or a text/template of accesslog.Entry"`
+	Sinks      []string `yaml:"sinks" usage:"comma separated access log sinks: stdout, file or ring"`
+	File       string   `yaml:"file" usage:"path of the access log file sink"`
+	MaxBytes   int64    `yaml:"max_bytes" usage:"size at which the access log file is rotated, 0 for never"`
+	MaxBackups int      `yaml:"max_backups" usage:"rotated access log files kept"`
+	RingSize   int      `yaml:"ring_size" usage:"access log lines kept by the ring sink"`
+}
+
+// Admin configures the admin server of a sample server, see the admin
+// package. It is read on startup only. Without an address no admin server is
+// started.
+type Admin struct {
+	Addr string `yaml:"addr" usage:"address of the admin server with channelz, pprof, health, build info, config and in-flight calls, e.g. 127.0.0.1:9091"`
+}
+
+// Registry configures the service registry of the discovery package. With an
+// address, sample servers register themselves with the registry and sample
+// clients resolve registry:/// targets from it. It is read on startup only.
+type Registry struct {
+	Addr          string        `yaml:"addr" usage:"address of the service registry, e.g. localhost:50100"`
+	AdvertiseAddr string        `yaml:"advertise_addr" usage:"address the server registers, by default the port of server.addr on localhost"`
+	Zone          string        `yaml:"zone" usage:"zone the server registers, e.g. zone-a"`
+	Weight        int           `yaml:"weight" usage:"relative share of calls for the server, 1 when 0"`
+	TTL           time.Duration `yaml:"ttl" usage:"lease of the registration, renewed every third of it, 10s when 0"`
+}
+
+// Redacted returns a copy of the config with the secrets masked, for logs and
+// the admin service.
+func (c Config) Redacted() Config {
+	if len(c.Auth.Tokens) > 0 {
+		tokens := make([]string, len(c.Auth.Tokens))
+		for i, entry := range c.Auth.Tokens {
+			tokens[i] = strings.SplitN(entry, "=", 2)[0] + "=****"
+		}
+		c.Auth.Tokens = tokens
+	}
+	return c
+}
+
+// Load loads the config of the sample from the flags of flag.CommandLine,
+// the environment and the config file. The sample's own flags must be
+// defined before Load is called, Load parses the command line.
+func Load(defaults Config) (*Config, error) {
+	return LoadFrom(flag.CommandLine, os.Args[1:], os.LookupEnv, defaults)
+}
+
+// MustLoad is like Load but exits the process when the config is invalid.
+func MustLoad(defaults Config) *Config {
+	cfg, err := Load(defaults)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(2)
+	}
+	return cfg
+}
+
+// LoadFrom is like Load with the flag set, arguments and environment lookup
+// given explicitly. It registers the config flags on fs.
+func LoadFrom(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool), defaults Config) (*Config, error) {
+	l, err := newLoader(fs, args, lookupEnv, defaults)
+	if err != nil {
+		return nil, err
+	}
+	return l.load()
+}
+
+// loader keeps the sources of a config, so it can be loaded again when the
+// file or the environment changed.
+type loader struct {
+	defaults  Config
+	file      string
+	lookupEnv func(string) (string, bool)
+	// flags are the config flags given on the command line, in order.
+	flags []flagSetting
+}
+
+type flagSetting struct {
+	path, raw string
+}
+
+func newLoader(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool), defaults Config) (*loader, error) {
+	path := fs.String(FileFlag, "", "YAML config file, also set by "+EnvPrefix+strings.ToUpper(FileFlag))
+	flagValues := make(map[string]*flagValue)
+	for _, f := range collectFields(&defaults) {
+		fv := &flagValue{field: f}
+		flagValues[f.path] = fv
+		fs.Var(fv, f.path, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	l := &loader{defaults: defaults, file: *path, lookupEnv: lookupEnv}
+	if l.file == "" {
+		l.file, _ = lookupEnv(EnvPrefix + strings.ToUpper(FileFlag))
+	}
+	// Only the flags given on the command line override the other sources.
+	fs.Visit(func(fl *flag.Flag) {
+		if fv, ok := flagValues[fl.Name]; ok {
+			l.flags = append(l.flags, flagSetting{path: fl.Name, raw: fv.raw})
+		}
+	})
+	return l, nil
+}
+
+func (l *loader) load() (*Config, error) {
+	cfg := l.defaults
+	fields := collectFields(&cfg)
+
+	if l.file != "" {
+		if err := decodeFile(l.file, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	byPath := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byPath[f.path] = f
+		if s, ok := l.lookupEnv(f.env); ok {
+			if err := f.set(s); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %v", f.env, err)
+			}
+		}
+	}
+
+	for _, fl := range l.flags {
+		if err := byPath[fl.path].set(fl.raw); err != nil {
+			return nil, fmt.Errorf("flag -%s: %v", fl.path, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func decodeFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %v", err)
+	}
+	if err := Decode(data, cfg); err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Decode decodes the YAML document data into cfg. Keys that are not part of
+// Config are rejected, so typos do not go unnoticed.
+func Decode(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// ValidationError lists every invalid value of a config.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// Validate checks the values that are set. Samples supply defaults for the
+// values they need, so empty values are not an error.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	if c.Server.Addr != "" {
+		check(validAddr(c.Server.Addr), "server.addr %q is not a host:port address", c.Server.Addr)
+	}
+	for _, addr := range c.Server.Addrs {
+		check(validAddr(addr), "server.addrs: %q is not a host:port address", addr)
+	}
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	check(c.Server.DrainTimeout >= 0, "server.drain_timeout must not be negative")
+	errs = append(errs, c.Server.TLS.validate("server.tls", true)...)
+
+	if c.Client.Target != "" {
+		// Targets may use a resolver scheme, e.g. dns:///productinfo:80.
+		check(strings.Contains(c.Client.Target, ":///") || validAddr(c.Client.Target),
+			"client.target %q is neither a host:port address nor a scheme:/// target", c.Client.Target)
+	}
+	for _, addr := range c.Client.Addrs {
+		check(validAddr(addr), "client.addrs: %q is not a host:port address", addr)
+	}
+	if c.Client.EndpointsFile != "" {
+		_, err := os.Stat(c.Client.EndpointsFile)
+		check(err == nil, "client.endpoints_file: %v", err)
+	}
+	if c.Client.ProductInfoAddr != "" {
+		check(strings.Contains(c.Client.ProductInfoAddr, ":///") || validAddr(c.Client.ProductInfoAddr),
+			"client.productinfo_addr %q is neither a host:port address nor a scheme:/// target", c.Client.ProductInfoAddr)
+	}
+	check(c.Client.Timeout >= 0, "client.timeout must not be negative")
+	errs = append(errs, c.Client.TLS.validate("client.tls", false)...)
+
+	check(c.Orders.BatchSize >= 0, "orders.batch_size must not be negative")
+	check(c.Tracing.SampleRate >= 0 && c.Tracing.SampleRate <= 1, "tracing.sample_rate must be between 0 and 1")
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout":
+	case "otlp":
+		check(c.Tracing.Endpoint != "", "tracing.endpoint must be set for the otlp exporter")
+	default:
+		check(false, "tracing.exporter %q is not one of otlp, stdout or none", c.Tracing.Exporter)
+	}
+	if c.Tracing.Endpoint != "" {
+		check(validAddr(c.Tracing.Endpoint) || strings.Contains(c.Tracing.Endpoint, "://"),
+			"tracing.endpoint %q is neither a host:port address nor a URL", c.Tracing.Endpoint)
+	}
+
+	if c.Metrics.PrometheusAddr != "" {
+		check(validAddr(c.Metrics.PrometheusAddr), "metrics.prometheus_addr %q is not a host:port address", c.Metrics.PrometheusAddr)
+	}
+	if c.Metrics.Endpoint != "" {
+		check(validAddr(c.Metrics.Endpoint) || strings.Contains(c.Metrics.Endpoint, "://"),
+			"metrics.endpoint %q is neither a host:port address nor a URL", c.Metrics.Endpoint)
+	}
+	check(c.Metrics.Interval >= 0, "metrics.interval must not be negative")
+
+	check(validLevel(c.Log.Level), "log.level %q is not one of debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "" || c.Log.Format == "text" || c.Log.Format == "json",
+		"log.format %q is neither text nor json", c.Log.Format)
+	for _, entry := range c.Log.Packages {
+		parts := strings.SplitN(entry, "=", 2)
+		check(len(parts) == 2 && parts[0] != "" && validLevel(parts[1]),
+			"log.packages: %q is not a package=level pair", entry)
+	}
+	check(c.Log.SampleFirst >= 0 && c.Log.SampleEvery >= 0, "log.sample_first and log.sample_every must not be negative")
+	check(c.RateLimit.RPS >= 0, "rate_limit.rps must not be negative")
+	check(c.RateLimit.Burst >= 0, "rate_limit.burst must not be negative")
+	check(c.RateLimit.RPS == 0 || c.RateLimit.Burst > 0, "rate_limit.burst must be positive when rate_limit.rps is set")
+	for _, entry := range c.Auth.Tokens {
+		parts := strings.SplitN(entry, "=", 2)
+		check(len(parts) == 2 && parts[0] != "" && parts[1] != "",
+			"auth.tokens: %q is not a principal=token pair", strings.SplitN(entry, "=", 2)[0])
+	}
+
+	errs = append(errs, c.AccessLog.validate()...)
+	if c.Admin.Addr != "" {
+		check(validAddr(c.Admin.Addr), "admin.addr %q is not a host:port address", c.Admin.Addr)
+	}
+	if c.Registry.Addr != "" {
+		check(validAddr(c.Registry.Addr), "registry.addr %q is not a host:port address", c.Registry.Addr)
+	}
+	if c.Registry.AdvertiseAddr != "" {
+		check(validAddr(c.Registry.AdvertiseAddr), "registry.advertise_addr %q is not a host:port address", c.Registry.AdvertiseAddr)
+	}
+	check(c.Registry.Weight >= 0, "registry.weight must not be negative")
+	check(c.Registry.TTL >= 0, "registry.ttl must not be negative")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (a AccessLog) validate() []string {
+	var errs []string
+	switch a.Format {
+	case "", "common", "json":
+	default:
+		if _, err := template.New("access_log").Parse(a.Format); err != nil {
+			errs = append(errs, fmt.Sprintf("access_log.format is neither common, json nor a valid template: %v", err))
+		}
+	}
+	sinks := make(map[string]bool)
+	for _, sink := range a.Sinks {
+		switch sink {
+		case "stdout", "file", "ring":
+			sinks[sink] = true
+		default:
+			errs = append(errs, fmt.Sprintf("access_log.sinks: %q is not one of stdout, file or ring", sink))
+		}
+	}
+	if sinks["file"] && a.File == "" {
+		errs = append(errs, "access_log.file must be set for the file sink")
+	}
+	if a.MaxBytes < 0 || a.MaxBackups < 0 || a.RingSize < 0 {
+		errs = append(errs, "access_log.max_bytes, access_log.max_backups and access_log.ring_size must not be negative")
+	}
+	return errs
+}
+
+func (t TLS) validate(prefix string, server bool) []string {
+	var errs []string
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, prefix+": cert_file and key_file must be set together")
+	}
+	if server && t.Enabled() && t.CertFile == "" {
+		errs = append(errs, prefix+": a server needs cert_file and key_file")
+	}
+	for _, f := range []struct{ name, path string }{
+		{"cert_file", t.CertFile}, {"key_file", t.KeyFile}, {"ca_file", t.CAFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s: %v", prefix, f.name, err))
+		}
+	}
+	return errs
+}
+
+func validLevel(level string) bool {
+	switch level {
+	case "", "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args []string, env map[string]string, defaults Config) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	return LoadFrom(fs, args, lookupEnv, defaults)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	defaults := Config{
+		Server: Server{Addr: ":50051", DrainTimeout: 10 * time.Second},
+		Orders: Orders{BatchSize: 3},
+	}
+	path := writeFile(t, `
+server:
+  addr: ":50061"
+  drain_timeout: 20s
+orders:
+  batch_size: 5
+client:
+  addrs: ["localhost:50051", "localhost:50052"]
+`)
+	env := map[string]string{
+		"GRPC_SAMPLE_CONFIG":              path,
+		"GRPC_SAMPLE_SERVER_ADDR":         ":50071",
+		"GRPC_SAMPLE_ORDERS_BATCH_SIZE":   "7",
+		"GRPC_SAMPLE_CLIENT_TARGET":       "dns:///productinfo:80",
+		"GRPC_SAMPLE_TRACING_SAMPLE_RATE": "0.5",
+	}
+	cfg, err := load(t, []string{"-server.addr", ":50081"}, env, defaults)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := Config{
+		Server:  Server{Addr: ":50081", DrainTimeout: 20 * time.Second},
+		Client:  Client{Target: "dns:///productinfo:80", Addrs: []string{"localhost:50051", "localhost:50052"}},
+		Orders:  Orders{BatchSize: 7},
+		Tracing: Tracing{SampleRate: 0.5},
+	}
+	if !reflect.DeepEqual(*cfg, want) {
+		t.Fatalf("Load() = %+v, want %+v", *cfg, want)
+	}
+}
+
+func TestFlagsOverrideConfigFileFlag(t *testing.T) {
+	path := writeFile(t, "client:\n  target: localhost:50052\n")
+	cfg, err := load(t, []string{"-client.addrs=localhost:1, localhost:2", "-config", path}, nil,
+		Config{Client: Client{Target: "localhost:50051"}})
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Client.Target != "localhost:50052" {
+		t.Errorf("client.target = %q, want the value of the file", cfg.Client.Target)
+	}
+	if want := []string{"localhost:1", "localhost:2"}; !reflect.DeepEqual(cfg.Client.Addrs, want) {
+		t.Errorf("client.addrs = %q, want %q", cfg.Client.Addrs, want)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{name: "bad address", args: []string{"-server.addr", "50051"}, wantErr: "server.addr"},
+		{name: "bad duration flag", args: []string{"-client.timeout", "5"}, wantErr: "client.timeout"},
+		{name: "bad env", env: map[string]string{"GRPC_SAMPLE_ORDERS_BATCH_SIZE": "three"}, wantErr: "GRPC_SAMPLE_ORDERS_BATCH_SIZE"},
+		{name: "negative batch size", args: []string{"-orders.batch_size", "-1"}, wantErr: "orders.batch_size"},
+		{name: "unknown key", file: "server:\n  adr: :50051\n", wantErr: "adr"},
+		{name: "cert without key", args: []string{"-server.tls.cert_file", "server.crt"}, wantErr: "key_file"},
+		{name: "missing cert file", args: []string{"-client.tls.ca_file", "does-not-exist.crt"}, wantErr: "client.tls.ca_file"},
+		{name: "sample rate", args: []string{"-tracing.sample_rate", "2"}, wantErr: "tracing.sample_rate"},
+		{name: "trace exporter", args: []string{"-tracing.exporter", "jaeger"}, wantErr: "tracing.exporter"},
+		{name: "otlp without endpoint", args: []string{"-tracing.exporter", "otlp"}, wantErr: "tracing.endpoint"},
+		{name: "prometheus address", args: []string{"-metrics.prometheus_addr", "9092"}, wantErr: "metrics.prometheus_addr"},
+		{name: "productinfo address", args: []string{"-client.productinfo_addr", "50052"}, wantErr: "client.productinfo_addr"},
+		{name: "endpoints file", args: []string{"-client.endpoints_file", "missing.yaml"}, wantErr: "client.endpoints_file"},
+		{name: "registry address", args: []string{"-registry.addr", "50100"}, wantErr: "registry.addr"},
+		{name: "admin address", args: []string{"-admin.addr", "localhost"}, wantErr: "admin.addr"},
+		{name: "access log sink", args: []string{"-access_log.sinks", "stdout,syslog"}, wantErr: "access_log.sinks"},
+		{name: "access log file", args: []string{"-access_log.sinks", "file"}, wantErr: "access_log.file"},
+		{name: "access log format", args: []string{"-access_log.format", "{{.Method"}, wantErr: "access_log.format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if tc.file != "" {
+				args = append(args, "-config", writeFile(t, tc.file))
+			}
+			_, err := load(t, args, tc.env, Config{})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load() = %v, want an error mentioning %q", err, tc.wantErr)
+			}
+		})
+	}
+}
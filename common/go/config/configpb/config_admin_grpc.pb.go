@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: config_admin.proto
+
+package configpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ConfigAdminClient is the client API for ConfigAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ConfigAdminClient interface {
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*ConfigSnapshot, error)
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ConfigSnapshot, error)
+}
+
+type configAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConfigAdminClient(cc grpc.ClientConnInterface) ConfigAdminClient {
+	return &configAdminClient{cc}
+}
+
+func (c *configAdminClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*ConfigSnapshot, error) {
+	out := new(ConfigSnapshot)
+	err := c.cc.Invoke(ctx, "/grpcsamples.config.ConfigAdmin/GetConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configAdminClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ConfigSnapshot, error) {
+	out := new(ConfigSnapshot)
+	err := c.cc.Invoke(ctx, "/grpcsamples.config.ConfigAdmin/ReloadConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConfigAdminServer is the server API for ConfigAdmin service.
+// All implementations must embed UnimplementedConfigAdminServer
+// for forward compatibility
+type ConfigAdminServer interface {
+	GetConfig(context.Context, *GetConfigRequest) (*ConfigSnapshot, error)
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ConfigSnapshot, error)
+	mustEmbedUnimplementedConfigAdminServer()
+}
+
+// UnimplementedConfigAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedConfigAdminServer struct {
+}
+
+func (UnimplementedConfigAdminServer) GetConfig(context.Context, *GetConfigRequest) (*ConfigSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedConfigAdminServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ConfigSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedConfigAdminServer) mustEmbedUnimplementedConfigAdminServer() {}
+
+// UnsafeConfigAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConfigAdminServer will
+// result in compilation errors.
+type UnsafeConfigAdminServer interface {
+	mustEmbedUnimplementedConfigAdminServer()
+}
+
+func RegisterConfigAdminServer(s grpc.ServiceRegistrar, srv ConfigAdminServer) {
+	s.RegisterService(&ConfigAdmin_ServiceDesc, srv)
+}
+
+func _ConfigAdmin_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigAdminServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcsamples.config.ConfigAdmin/GetConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigAdminServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigAdmin_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigAdminServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcsamples.config.ConfigAdmin/ReloadConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigAdminServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ConfigAdmin_ServiceDesc is the grpc.ServiceDesc for ConfigAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConfigAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpcsamples.config.ConfigAdmin",
+	HandlerType: (*ConfigAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _ConfigAdmin_GetConfig_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _ConfigAdmin_ReloadConfig_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "config_admin.proto",
+}
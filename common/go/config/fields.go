@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a settable leaf of Config, e.g. server.tls.cert_file.
+type field struct {
+	path  string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// collectFields walks the struct cfg points to and returns its leaves.
+func collectFields(cfg interface{}) []field {
+	var fields []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			path := prefix + name
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+				walk(fv, path+".")
+				continue
+			}
+			fields = append(fields, field{
+				path:  path,
+				env:   EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+				usage: sf.Tag.Get("usage"),
+				value: fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return fields
+}
+
+// set parses s into the field.
+func (f field) set(s string) error {
+	v := f.value
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// String formats the current value of the field like set parses it.
+func (f field) String() string {
+	v := f.value
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// flagValue records the raw flag value. It is applied after the file and the
+// environment, so flags take precedence whatever their position.
+type flagValue struct {
+	field field
+	raw   string
+}
+
+func (fv *flagValue) String() string {
+	if fv == nil || !fv.field.value.IsValid() {
+		return ""
+	}
+	return fv.field.String()
+}
+
+func (fv *flagValue) Set(s string) error {
+	// Validate early, so flag.Parse reports the offending flag with usage.
+	tmp := field{value: reflect.New(fv.field.value.Type()).Elem()}
+	if err := tmp.set(s); err != nil {
+		return err
+	}
+	fv.raw = s
+	return nil
+}
+
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.field.value.IsValid() && fv.field.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Subscriber is called with the previous and the new config after a
+// successful reload.
+type Subscriber func(old, new *Config)
+
+// Snapshot is a loaded config with its version. The version starts at 1 and
+// increases with every applied reload.
+type Snapshot struct {
+	Config   *Config
+	Version  uint64
+	LoadedAt time.Time
+}
+
+// Holder holds the active config of a long running server and reloads it
+// from the same sources it was loaded from.
+//
+// Only the log level, the rate limit, the auth tokens and the order batching
+// are applied at run time. Changes to the server, client, tracing, metrics,
+// access log, admin and registry sections need a restart; a reload keeps
+// their active values and logs a warning.
+type Holder struct {
+	loader  *loader
+	current atomic.Value // Snapshot
+
+	// mu serializes reloads and the subscriber list.
+	mu   sync.Mutex
+	subs []Subscriber
+}
+
+// LoadHolder is like Load but returns a Holder that can reload the config.
+func LoadHolder(defaults Config) (*Holder, error) {
+	return LoadHolderFrom(flag.CommandLine, os.Args[1:], os.LookupEnv, defaults)
+}
+
+// MustLoadHolder is like LoadHolder but exits the process when the config is
+// invalid.
+func MustLoadHolder(defaults Config) *Holder {
+	h, err := LoadHolder(defaults)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(2)
+	}
+	return h
+}
+
+// LoadHolderFrom is like LoadHolder with the flag set, arguments and
+// environment lookup given explicitly.
+func LoadHolderFrom(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool), defaults Config) (*Holder, error) {
+	l, err := newLoader(fs, args, lookupEnv, defaults)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	h := &Holder{loader: l}
+	h.current.Store(Snapshot{Config: cfg, Version: 1, LoadedAt: time.Now()})
+	return h, nil
+}
+
+// NewHolder returns a Holder of cfg without sources, e.g. for tests. Reload
+// returns ErrUnchanged.
+func NewHolder(cfg Config) *Holder {
+	noEnv := func(string) (string, bool) { return "", false }
+	h := &Holder{loader: &loader{defaults: cfg, lookupEnv: noEnv}}
+	h.current.Store(Snapshot{Config: &cfg, Version: 1, LoadedAt: time.Now()})
+	return h
+}
+
+// Current returns the active config. It must not be modified.
+func (h *Holder) Current() *Config {
+	return h.Snapshot().Config
+}
+
+// Snapshot returns the active config with its version.
+func (h *Holder) Snapshot() Snapshot {
+	return h.current.Load().(Snapshot)
+}
+
+// File returns the path of the config file, if any.
+func (h *Holder) File() string {
+	return h.loader.file
+}
+
+// Subscribe adds fn to the subscribers called after every applied reload.
+// Subscribers run in the order they were added, one reload at a time.
+func (h *Holder) Subscribe(fn Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs = append(h.subs, fn)
+}
+
+// ErrUnchanged is returned by Reload when the sources yield the active config.
+var ErrUnchanged = errors.New("config unchanged")
+
+// Reload loads the config again. An invalid config is rejected with an error
+// and the active config stays in place.
+func (h *Holder) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	next, err := h.loader.load()
+	if err != nil {
+		return err
+	}
+	prev := h.Snapshot()
+	keepRestartOnly(prev.Config, next)
+	if reflect.DeepEqual(prev.Config, next) {
+		return ErrUnchanged
+	}
+	h.current.Store(Snapshot{Config: next, Version: prev.Version + 1, LoadedAt: time.Now()})
+	for _, fn := range h.subs {
+		fn(prev.Config, next)
+	}
+	return nil
+}
+
+// keepRestartOnly copies the sections that need a restart from active to next.
+func keepRestartOnly(active, next *Config) {
+	if !reflect.DeepEqual(active.Server, next.Server) {
+		log.Printf("config: server settings changed, restart to apply them")
+		next.Server = active.Server
+	}
+	if !reflect.DeepEqual(active.Client, next.Client) {
+		log.Printf("config: client settings changed, restart to apply them")
+		next.Client = active.Client
+	}
+	if !reflect.DeepEqual(active.Tracing, next.Tracing) {
+		log.Printf("config: tracing settings changed, restart to apply them")
+		next.Tracing = active.Tracing
+	}
+	if !reflect.DeepEqual(active.Metrics, next.Metrics) {
+		log.Printf("config: metrics settings changed, restart to apply them")
+		next.Metrics = active.Metrics
+	}
+	if !reflect.DeepEqual(active.AccessLog, next.AccessLog) {
+		log.Printf("config: access log settings changed, restart to apply them")
+		next.AccessLog = active.AccessLog
+	}
+	if !reflect.DeepEqual(active.Admin, next.Admin) {
+		log.Printf("config: admin settings changed, restart to apply them")
+		next.Admin = active.Admin
+	}
+	if !reflect.DeepEqual(active.Registry, next.Registry) {
+		log.Printf("config: registry settings changed, restart to apply them")
+		next.Registry = active.Registry
+	}
+}
+
+// reload reloads and logs the outcome.
+func (h *Holder) reload(reason string) {
+	switch err := h.Reload(); {
+	case err == nil:
+		log.Printf("config: reloaded after %s, version %d", reason, h.Snapshot().Version)
+	case errors.Is(err, ErrUnchanged):
+		log.Printf("config: %s, config unchanged", reason)
+	default:
+		log.Printf("config: rejected reload after %s, keeping version %d: %v", reason, h.Snapshot().Version, err)
+	}
+}
+
+// WatchSignal reloads the config whenever the process receives SIGHUP, or one
+// of the given signals, until ctx is done.
+func (h *Holder) WatchSignal(ctx context.Context, sig ...os.Signal) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sig...)
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case s := <-sigCh:
+				h.reload(fmt.Sprintf("%v", s))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// WatchFile polls the config file every interval and reloads the config when
+// the file was modified, until ctx is done. It does nothing without a file.
+func (h *Holder) WatchFile(ctx context.Context, interval time.Duration) {
+	if h.File() == "" {
+		return
+	}
+	modTime := func() time.Time {
+		fi, err := os.Stat(h.File())
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	go func() {
+		last := modTime()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if mt := modTime(); !mt.Equal(last) {
+					last = mt
+					h.reload("change of " + h.File())
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
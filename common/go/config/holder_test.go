@@ -0,0 +1,150 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/grpc-up-and-running/samples/common/go/config/configpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func loadHolder(t *testing.T, file string) *Holder {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	noEnv := func(string) (string, bool) { return "", false }
+	h, err := LoadHolderFrom(fs, []string{"-config", file}, noEnv,
+		Config{Server: Server{Addr: ":50051"}, Orders: Orders{BatchSize: 3}})
+	if err != nil {
+		t.Fatalf("LoadHolderFrom() failed: %v", err)
+	}
+	return h
+}
+
+func rewrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadNotifiesSubscribers(t *testing.T) {
+	path := writeFile(t, "orders:\n  batch_size: 5\n")
+	h := loadHolder(t, path)
+
+	var got []int
+	h.Subscribe(func(old, new *Config) {
+		got = append(got, old.Orders.BatchSize, new.Orders.BatchSize)
+	})
+
+	rewrite(t, path, "orders:\n  batch_size: 10\nlog:\n  level: debug\n")
+	if err := h.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	if want := []int{5, 10}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("subscriber saw batch sizes %v, want %v", got, want)
+	}
+	snap := h.Snapshot()
+	if snap.Version != 2 || snap.Config.Log.Level != "debug" {
+		t.Fatalf("Snapshot() = version %d, log level %q, want version 2, level debug", snap.Version, snap.Config.Log.Level)
+	}
+
+	if err := h.Reload(); !errors.Is(err, ErrUnchanged) {
+		t.Fatalf("Reload() of the same file = %v, want ErrUnchanged", err)
+	}
+}
+
+func TestInvalidReloadKeepsActiveConfig(t *testing.T) {
+	path := writeFile(t, "orders:\n  batch_size: 5\n")
+	h := loadHolder(t, path)
+	h.Subscribe(func(old, new *Config) {
+		t.Errorf("subscriber called for a rejected reload")
+	})
+
+	for _, content := range []string{
+		"orders:\n  batch_size: -1\n",
+		"log:\n  level: verbose\n",
+		"orders: [\n",
+	} {
+		rewrite(t, path, content)
+		if err := h.Reload(); err == nil || errors.Is(err, ErrUnchanged) {
+			t.Fatalf("Reload() of %q = %v, want an error", content, err)
+		}
+		if snap := h.Snapshot(); snap.Version != 1 || snap.Config.Orders.BatchSize != 5 {
+			t.Fatalf("after a rejected reload: version %d, batch size %d, want version 1, batch size 5",
+				snap.Version, snap.Config.Orders.BatchSize)
+		}
+	}
+}
+
+func TestReloadKeepsRestartOnlySettings(t *testing.T) {
+	path := writeFile(t, "server:\n  addr: :50051\n")
+	h := loadHolder(t, path)
+
+	rewrite(t, path, "server:\n  addr: :50061\nrate_limit:\n  rps: 10\n  burst: 5\n")
+	if err := h.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+	cfg := h.Current()
+	if cfg.Server.Addr != ":50051" {
+		t.Errorf("server.addr = %q after reload, want the active :50051", cfg.Server.Addr)
+	}
+	if cfg.RateLimit.RPS != 10 {
+		t.Errorf("rate_limit.rps = %v after reload, want 10", cfg.RateLimit.RPS)
+	}
+}
+
+func TestAdminService(t *testing.T) {
+	path := writeFile(t, "auth:\n  tokens: [\"alice=s3cret\"]\n")
+	h := loadHolder(t, path)
+
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	RegisterAdmin(s, h)
+	go s.Serve(listener)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	client := configpb.NewConfigAdminClient(conn)
+
+	snap, err := client.GetConfig(context.Background(), &configpb.GetConfigRequest{})
+	if err != nil {
+		t.Fatalf("GetConfig() failed: %v", err)
+	}
+	if strings.Contains(snap.Yaml, "s3cret") || !strings.Contains(snap.Yaml, "alice=****") {
+		t.Fatalf("GetConfig() did not mask the token:\n%s", snap.Yaml)
+	}
+	if snap.Version != 1 || snap.File != path {
+		t.Fatalf("GetConfig() = version %d, file %q, want version 1, file %q", snap.Version, snap.File, path)
+	}
+
+	rewrite(t, path, "orders:\n  batch_size: 0\nlog:\n  level: loud\n")
+	_, err = client.ReloadConfig(context.Background(), &configpb.ReloadConfigRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ReloadConfig() of an invalid file = %v, want code %v", err, codes.InvalidArgument)
+	}
+
+	rewrite(t, path, "orders:\n  batch_size: 7\n")
+	snap, err = client.ReloadConfig(context.Background(), &configpb.ReloadConfigRequest{})
+	if err != nil {
+		t.Fatalf("ReloadConfig() failed: %v", err)
+	}
+	if snap.Version != 2 || !strings.Contains(snap.Yaml, "batch_size: 7") {
+		t.Fatalf("ReloadConfig() = version %d:\n%s", snap.Version, snap.Yaml)
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ServerCredentials returns the transport credentials of a server using the
+// certificate of t. With a CA file, clients must present a certificate signed
+// by that CA (mutual TLS). Without certificates the server accepts plaintext
+// connections.
+func (t TLS) ServerCredentials() (credentials.TransportCredentials, error) {
+	if !t.Enabled() {
+		return insecure.NewCredentials(), nil
+	}
+	if t.CAFile == "" {
+		return credentials.NewServerTLSFromFile(t.CertFile, t.KeyFile)
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading key pair: %v", err)
+	}
+	pool, err := loadCertPool(t.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}), nil
+}
+
+// ClientCredentials returns the transport credentials of a client verifying
+// the server with the CA file of t and, when set, expecting ServerName in
+// the server certificate. With a certificate the client presents it to the
+// server (mutual TLS). Without certificates the client dials in plaintext.
+func (t TLS) ClientCredentials() (credentials.TransportCredentials, error) {
+	if !t.Enabled() {
+		return insecure.NewCredentials(), nil
+	}
+	if t.CertFile == "" {
+		return credentials.NewClientTLSFromFile(t.CAFile, t.ServerName)
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading key pair: %v", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ServerName:   t.ServerName,
+	}
+	// Without a CA file the server is verified with the system roots.
+	if t.CAFile != "" {
+		if cfg.RootCAs, err = loadCertPool(t.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in CA file %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// certs writes a CA and a server and client certificate signed by it to dir.
+type certs struct {
+	dir string
+	ca  *x509.Certificate
+	key *ecdsa.PrivateKey
+}
+
+func newCerts(t *testing.T) *certs {
+	t.Helper()
+	c := &certs{dir: t.TempDir()}
+	c.ca, c.key = c.issue(t, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+	c.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	c.issue(t, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return c
+}
+
+// issue writes name.crt and name.key, signed by the CA once there is one.
+func (c *certs) issue(t *testing.T, name string, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	parent, parentKey := tmpl, key
+	if c.ca != nil {
+		parent, parentKey = c.ca, c.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(file, typ string, b []byte) {
+		if err := os.WriteFile(filepath.Join(c.dir, file), pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(name+".crt", "CERTIFICATE", der)
+	write(name+".key", "EC PRIVATE KEY", keyDER)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (c *certs) path(file string) string {
+	return filepath.Join(c.dir, file)
+}
+
+// check calls the health service of a server using server over a client
+// using client and returns the error of the call.
+func check(t *testing.T, server, client TLS) error {
+	t.Helper()
+	serverCreds, err := server.ServerCredentials()
+	if err != nil {
+		t.Fatalf("ServerCredentials() failed: %v", err)
+	}
+	clientCreds, err := client.ClientCredentials()
+	if err != nil {
+		t.Fatalf("ClientCredentials() failed: %v", err)
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.Creds(serverCreds))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(clientCreds))
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestTLSCredentials(t *testing.T) {
+	c := newCerts(t)
+	server := TLS{CertFile: c.path("server.crt"), KeyFile: c.path("server.key")}
+	mutual := TLS{CertFile: c.path("server.crt"), KeyFile: c.path("server.key"), CAFile: c.path("ca.crt")}
+	client := TLS{CertFile: c.path("client.crt"), KeyFile: c.path("client.key"), CAFile: c.path("ca.crt"), ServerName: "localhost"}
+
+	tests := []struct {
+		name           string
+		server, client TLS
+		wantErr        bool
+	}{
+		{name: "plaintext", server: TLS{}, client: TLS{}},
+		{name: "tls", server: server, client: TLS{CAFile: c.path("ca.crt"), ServerName: "localhost"}},
+		{name: "mutual tls", server: mutual, client: client},
+		{name: "plaintext client", server: server, client: TLS{}, wantErr: true},
+		{name: "wrong server name", server: server, client: TLS{CAFile: c.path("ca.crt"), ServerName: "example.com"}, wantErr: true},
+		{name: "client without certificate", server: mutual, client: TLS{CAFile: c.path("ca.crt"), ServerName: "localhost"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check(t, tt.server, tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSCredentialsErrors(t *testing.T) {
+	c := newCerts(t)
+	if _, err := (TLS{CertFile: c.path("server.crt"), KeyFile: c.path("client.key"), CAFile: c.path("ca.crt")}).ServerCredentials(); err == nil {
+		t.Error("ServerCredentials() with a mismatched key succeeded")
+	}
+	if _, err := (TLS{CertFile: c.path("server.crt"), KeyFile: c.path("server.key"), CAFile: c.path("server.key")}).ServerCredentials(); err == nil {
+		t.Error("ServerCredentials() with a CA file without certificates succeeded")
+	}
+	if _, err := (TLS{CAFile: c.path("missing.crt")}).ClientCredentials(); err == nil {
+		t.Error("ClientCredentials() with a missing CA file succeeded")
+	}
+}
@@ -0,0 +1,161 @@
+// Package deadline enforces and propagates deadline budgets.
+//
+// The server interceptors reject calls that arrive with less time left than
+// the minimum a method needs, and warn when a handler uses more than a given
+// fraction of the budget it was called with. The client helpers forward the
+// remaining deadline of an incoming call, minus a safety margin, to the calls
+// a service makes to other services.
+package deadline
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOptions configures the server interceptors.
+type ServerOptions struct {
+	// MinRemaining is the minimum time a method needs, keyed by the full
+	// method name the server reports in grpc.UnaryServerInfo and
+	// grpc.StreamServerInfo, e.g. "/ecommerce.OrderManagement/AddOrder".
+	MinRemaining map[string]time.Duration
+	// DefaultMinRemaining applies to the methods missing in MinRemaining.
+	DefaultMinRemaining time.Duration
+	// WarnFraction logs a warning when a handler takes longer than this
+	// fraction of its budget, e.g. 0.8. Zero disables the warning.
+	WarnFraction float64
+	// Logf logs the warnings. It defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+}
+
+func (o ServerOptions) minRemaining(method string) time.Duration {
+	if d, ok := o.MinRemaining[method]; ok {
+		return d
+	}
+	return o.DefaultMinRemaining
+}
+
+func (o ServerOptions) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// check rejects a call whose remaining deadline is below the minimum of the
+// method. It returns the budget of the call, or zero without a deadline.
+func (o ServerOptions) check(ctx context.Context, method string) (time.Duration, error) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+	remaining := time.Until(d)
+	if min := o.minRemaining(method); remaining < min {
+		return remaining, status.Errorf(codes.DeadlineExceeded,
+			"remaining deadline %v is below the minimum %v of %s", remaining.Round(time.Millisecond), min, method)
+	}
+	return remaining, nil
+}
+
+func (o ServerOptions) observe(method string, budget, elapsed time.Duration) {
+	if o.WarnFraction <= 0 || budget <= 0 {
+		return
+	}
+	if float64(elapsed) > o.WarnFraction*float64(budget) {
+		o.logf("deadline: %s took %v, %.0f%% of its %v budget",
+			method, elapsed.Round(time.Millisecond), 100*float64(elapsed)/float64(budget), budget.Round(time.Millisecond))
+	}
+}
+
+// UnaryServerInterceptor returns a server interceptor enforcing the deadline
+// budget of unary calls.
+func UnaryServerInterceptor(o ServerOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		budget, err := o.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		o.observe(info.FullMethod, budget, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor enforcing the deadline
+// budget of streaming calls.
+func StreamServerInterceptor(o ServerOptions) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		budget, err := o.check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = handler(srv, ss)
+		o.observe(info.FullMethod, budget, time.Since(start))
+		return err
+	}
+}
+
+// Forward derives a context for an outgoing call from ctx, usually the
+// context of the incoming call being handled. The deadline of the returned
+// context is the remaining deadline of ctx minus margin, which leaves the
+// caller time to handle the outcome of the outgoing call. If ctx has no
+// deadline, the returned context has none either.
+//
+// Forward fails with DEADLINE_EXCEEDED when the margin uses up the remaining
+// time, so no call is started that cannot finish.
+func Forward(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc, error) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	forwarded := d.Add(-margin)
+	if remaining := time.Until(forwarded); remaining <= 0 {
+		return ctx, func() {}, status.Errorf(codes.DeadlineExceeded,
+			"remaining deadline %v does not cover the safety margin %v", time.Until(d).Round(time.Millisecond), margin)
+	}
+	ctx, cancel := context.WithDeadline(ctx, forwarded)
+	return ctx, cancel, nil
+}
+
+// UnaryClientInterceptor returns a client interceptor applying Forward with
+// the given margin to every unary call.
+func UnaryClientInterceptor(margin time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel, err := Forward(ctx, margin)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor applying Forward with
+// the given margin to every streaming call. The derived context is released
+// when the stream ends.
+func StreamClientInterceptor(margin time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel, err := Forward(ctx, margin)
+		if err != nil {
+			return nil, err
+		}
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		go func() {
+			<-s.Context().Done()
+			cancel()
+		}()
+		return s, nil
+	}
+}
@@ -0,0 +1,235 @@
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	addOrderMethod = "/ecommerce.OrderManagement/addOrder"
+	getOrderMethod = "/ecommerce.OrderManagement/getOrder"
+)
+
+func withTimeout(t *testing.T, d time.Duration) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCheck(t *testing.T) {
+	o := ServerOptions{
+		MinRemaining:        map[string]time.Duration{addOrderMethod: time.Second},
+		DefaultMinRemaining: 100 * time.Millisecond,
+	}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode codes.Code
+	}{
+		{name: "no deadline", ctx: context.Background(), method: addOrderMethod},
+		{name: "above the method minimum", ctx: withTimeout(t, 5*time.Second), method: addOrderMethod},
+		{name: "below the method minimum", ctx: withTimeout(t, 500*time.Millisecond), method: addOrderMethod, wantCode: codes.DeadlineExceeded},
+		{name: "above the default minimum", ctx: withTimeout(t, 500*time.Millisecond), method: getOrderMethod},
+		{name: "below the default minimum", ctx: withTimeout(t, 10*time.Millisecond), method: getOrderMethod, wantCode: codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget, err := o.check(tt.ctx, tt.method)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("check() = %v, want %v", err, tt.wantCode)
+			}
+			if _, ok := tt.ctx.Deadline(); !ok && budget != 0 {
+				t.Errorf("budget without a deadline = %v, want 0", budget)
+			}
+			if _, ok := tt.ctx.Deadline(); ok && budget <= 0 {
+				t.Errorf("budget with a deadline = %v, want > 0", budget)
+			}
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	var logged []string
+	o := ServerOptions{
+		WarnFraction: 0.8,
+		Logf:         func(format string, args ...interface{}) { logged = append(logged, fmt.Sprintf(format, args...)) },
+	}
+	o.observe(getOrderMethod, time.Second, 500*time.Millisecond)
+	o.observe(getOrderMethod, 0, time.Second)
+	if len(logged) != 0 {
+		t.Errorf("observe() within the budget or without one logged %q", logged)
+	}
+	o.observe(getOrderMethod, time.Second, 900*time.Millisecond)
+	if len(logged) != 1 || !strings.Contains(logged[0], "90% of its 1s budget") {
+		t.Errorf("observe() above the fraction logged %q, want one warning with 90%% of its 1s budget", logged)
+	}
+
+	o.WarnFraction = 0
+	o.observe(getOrderMethod, time.Second, 2*time.Second)
+	if len(logged) != 1 {
+		t.Errorf("observe() without WarnFraction logged %q", logged[1:])
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	o := ServerOptions{DefaultMinRemaining: time.Second}
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: getOrderMethod}
+	if _, err := UnaryServerInterceptor(o)(withTimeout(t, 10*time.Millisecond), nil, info, handler); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("call below the minimum = %v, want DEADLINE_EXCEEDED", err)
+	}
+	if called {
+		t.Error("handler ran for a call below the minimum")
+	}
+	if _, err := UnaryServerInterceptor(o)(withTimeout(t, 5*time.Second), nil, info, handler); err != nil || !called {
+		t.Errorf("call above the minimum = %v, handler ran: %v", err, called)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var logged int
+	o := ServerOptions{
+		DefaultMinRemaining: 10 * time.Millisecond,
+		WarnFraction:        0.1,
+		Logf:                func(string, ...interface{}) { logged++ },
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/ecommerce.OrderManagement/processOrders"}
+	slow := func(srv interface{}, ss grpc.ServerStream) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	if err := StreamServerInterceptor(o)(nil, &fakeServerStream{ctx: withTimeout(t, time.Millisecond)}, info, slow); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("stream below the minimum = %v, want DEADLINE_EXCEEDED", err)
+	}
+	if err := StreamServerInterceptor(o)(nil, &fakeServerStream{ctx: withTimeout(t, 200*time.Millisecond)}, info, slow); err != nil {
+		t.Errorf("stream above the minimum = %v, want nil", err)
+	}
+	if logged != 1 {
+		t.Errorf("stream using a quarter of its budget logged %d warnings, want 1", logged)
+	}
+}
+
+func TestForward(t *testing.T) {
+	ctx, cancel, err := Forward(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("Forward() without a deadline failed: %v", err)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Forward() without a deadline set one")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Error("cancel() did not cancel the forwarded context")
+	}
+
+	parent, parentCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer parentCancel()
+	ctx, cancel, err = Forward(parent, time.Second)
+	if err != nil {
+		t.Fatalf("Forward() failed: %v", err)
+	}
+	defer cancel()
+	want, _ := parent.Deadline()
+	if got, _ := ctx.Deadline(); !got.Equal(want.Add(-time.Second)) {
+		t.Errorf("forwarded deadline = %v, want %v", got, want.Add(-time.Second))
+	}
+
+	if _, _, err := Forward(withTimeout(t, 100*time.Millisecond), time.Second); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Forward() with a margin above the remaining time = %v, want DEADLINE_EXCEEDED", err)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var forwarded context.Context
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		forwarded = ctx
+		return nil
+	}
+	ctx := withTimeout(t, 5*time.Second)
+	if err := UnaryClientInterceptor(time.Second)(ctx, getOrderMethod, nil, nil, nil, invoker); err != nil {
+		t.Fatalf("call = %v, want nil", err)
+	}
+	want, _ := ctx.Deadline()
+	if got, _ := forwarded.Deadline(); !got.Equal(want.Add(-time.Second)) {
+		t.Errorf("deadline of the outgoing call = %v, want %v", got, want.Add(-time.Second))
+	}
+	if forwarded.Err() == nil {
+		t.Error("the context of the outgoing call is not released after the call")
+	}
+
+	forwarded = nil
+	if err := UnaryClientInterceptor(time.Second)(withTimeout(t, 100*time.Millisecond), getOrderMethod, nil, nil, nil, invoker); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("call with a margin above the remaining time = %v, want DEADLINE_EXCEEDED", err)
+	}
+	if forwarded != nil {
+		t.Error("invoker ran for a call that cannot finish")
+	}
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	ctx context.Context
+}
+
+func (s *fakeClientStream) Context() context.Context { return s.ctx }
+
+func TestStreamClientInterceptor(t *testing.T) {
+	streamCtx, endStream := context.WithCancel(context.Background())
+	defer endStream()
+	var forwarded context.Context
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		forwarded = ctx
+		return &fakeClientStream{ctx: streamCtx}, nil
+	}
+	ctx := withTimeout(t, 5*time.Second)
+	if _, err := StreamClientInterceptor(time.Second)(ctx, &grpc.StreamDesc{}, nil, "/ecommerce.OrderManagement/processOrders", streamer); err != nil {
+		t.Fatalf("stream = %v, want nil", err)
+	}
+	want, _ := ctx.Deadline()
+	if got, _ := forwarded.Deadline(); !got.Equal(want.Add(-time.Second)) {
+		t.Errorf("deadline of the outgoing stream = %v, want %v", got, want.Add(-time.Second))
+	}
+	if forwarded.Err() != nil {
+		t.Fatal("the context of the outgoing stream is released while the stream runs")
+	}
+
+	// Ending the stream releases the forwarded context.
+	endStream()
+	select {
+	case <-forwarded.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context of the outgoing stream is not released after the stream ended")
+	}
+
+	// A failing streamer releases the forwarded context at once.
+	failing := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		forwarded = ctx
+		return nil, status.Error(codes.Unavailable, "no backend")
+	}
+	if _, err := StreamClientInterceptor(time.Second)(ctx, &grpc.StreamDesc{}, nil, "/ecommerce.OrderManagement/processOrders", failing); status.Code(err) != codes.Unavailable {
+		t.Errorf("stream with a failing streamer = %v, want UNAVAILABLE", err)
+	}
+	if forwarded.Err() == nil {
+		t.Error("the context of a failed stream is not released")
+	}
+}
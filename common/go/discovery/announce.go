@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/grpc-up-and-running/samples/common/go/discovery/registrypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Announcement keeps an endpoint registered with a registry server.
+type Announcement struct {
+	client   pb.RegistryClient
+	service  string
+	endpoint Endpoint
+	ttl      time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	leaseID string
+}
+
+// Announce registers the endpoint of service with the registry server behind
+// conn and renews its lease with a heartbeat every third of ttl, DefaultTTL
+// when zero. When the registry cannot be reached or lost the lease, e.g.
+// after a restart, the endpoint is registered again. Stop removes it.
+func Announce(conn grpc.ClientConnInterface, service string, endpoint Endpoint, ttl time.Duration) *Announcement {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Announcement{
+		client:   pb.NewRegistryClient(conn),
+		service:  service,
+		endpoint: endpoint,
+		ttl:      ttl,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go a.run(ctx)
+	return a
+}
+
+func (a *Announcement) run(ctx context.Context) {
+	defer close(a.done)
+	interval := a.ttl / 3
+	for {
+		if lease, err := a.renew(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("discovery: announcing %s for %s: %v", a.endpoint.Addr, a.service, err)
+		} else {
+			interval = lease.Ttl.AsDuration() / 3
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// renew sends a heartbeat for the lease, or registers the endpoint if there
+// is no lease.
+func (a *Announcement) renew(ctx context.Context) (*pb.Lease, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.ttl/3)
+	defer cancel()
+	a.mu.Lock()
+	id := a.leaseID
+	a.mu.Unlock()
+	if id != "" {
+		lease, err := a.client.Heartbeat(ctx, &pb.HeartbeatRequest{LeaseId: id})
+		if status.Code(err) != codes.NotFound {
+			return lease, err
+		}
+		log.Printf("discovery: lease of %s for %s expired, registering again", a.endpoint.Addr, a.service)
+	}
+	lease, err := a.client.Register(ctx, &pb.RegisterRequest{
+		Service:  a.service,
+		Endpoint: &pb.Endpoint{Addr: a.endpoint.Addr, Zone: a.endpoint.Zone, Weight: a.endpoint.Weight},
+		Ttl:      durationpb.New(a.ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.leaseID = lease.Id
+	a.mu.Unlock()
+	return lease, nil
+}
+
+// Stop stops the heartbeats and deregisters the endpoint, so clients stop
+// sending calls to it before the server shuts down.
+func (a *Announcement) Stop(ctx context.Context) error {
+	a.cancel()
+	<-a.done
+	a.mu.Lock()
+	id := a.leaseID
+	a.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	_, err := a.client.Deregister(ctx, &pb.DeregisterRequest{LeaseId: id})
+	return err
+}
+
+// AdvertiseAddr returns the address clients dial for a server listening on
+// addr. An unspecified host, as in :50051, becomes localhost.
+func AdvertiseAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
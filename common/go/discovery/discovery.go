@@ -0,0 +1,310 @@
+// Package discovery resolves service names to backend addresses from a
+// source that can change at run time.
+//
+// A Builder turns a target such as file:///lb.example.grpc.io into a
+// resolver that watches the endpoints of lb.example.grpc.io in its Source
+// and pushes them to the ClientConn whenever they change. Sources are an
+// endpoints file (FileSource) or an in-process Registry. Each address
+// carries the zone and weight of its endpoint as balancer attributes, read
+// back with EndpointOf.
+//
+// Sources that are also a ConfigSource supply the gRPC service config of the
+// service with its endpoints: per-method timeouts, retry policies,
+// wait-for-ready and the load balancing policy. The ClientConn uses it
+// instead of the one of grpc.WithDefaultServiceConfig.
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// Endpoint is a backend of a service.
+type Endpoint struct {
+	Addr string `json:"addr" yaml:"addr"`
+	// Zone is the locality of the backend, e.g. us-east-1a.
+	Zone string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	// Weight is the relative share of calls for the backend. Zero means 1.
+	Weight uint32 `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// endpointKey is the balancer attribute key of the zone and weight.
+type endpointKey struct{}
+
+// endpointInfo is comparable, so addresses can be compared with Equal.
+type endpointInfo struct {
+	zone   string
+	weight uint32
+}
+
+// Address returns the resolver address of the endpoint with its zone and
+// weight.
+func (e Endpoint) Address() resolver.Address {
+	return resolver.Address{
+		Addr:               e.Addr,
+		BalancerAttributes: attributes.New(endpointKey{}, endpointInfo{zone: e.Zone, weight: e.Weight}),
+	}
+}
+
+// EndpointOf returns the endpoint of an address built by Address. The weight
+// is at least 1.
+func EndpointOf(addr resolver.Address) Endpoint {
+	e := Endpoint{Addr: addr.Addr, Weight: 1}
+	if info, ok := addr.BalancerAttributes.Value(endpointKey{}).(endpointInfo); ok {
+		e.Zone = info.zone
+		if info.weight > 0 {
+			e.Weight = info.weight
+		}
+	}
+	return e
+}
+
+// Source provides the endpoints of services.
+type Source interface {
+	// Watch calls update with the endpoints of service, once right away and
+	// again whenever they may have changed, until stop is called.
+	Watch(service string, update func([]Endpoint)) (stop func())
+	// Refresh re-reads the endpoints and calls the watchers of service.
+	Refresh(service string)
+}
+
+// ConfigSource is a Source that also provides the service config of
+// services, in JSON.
+type ConfigSource interface {
+	Source
+	// WatchServiceConfig calls update with the service config of service, ""
+	// if it has none, once right away and again whenever it may have
+	// changed, until stop is called.
+	WatchServiceConfig(service string, update func(string)) (stop func())
+}
+
+// checkServiceConfig checks that a service config is empty or a JSON
+// object. The ClientConn checks the rest and keeps the last valid one.
+func checkServiceConfig(js string) error {
+	if js == "" {
+		return nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(js), &obj); err != nil {
+		return fmt.Errorf("service config is not a JSON object: %v", err)
+	}
+	return nil
+}
+
+// Builder builds resolvers for targets scheme:///service from a Source.
+type Builder struct {
+	scheme string
+	source Source
+}
+
+// NewBuilder returns a Builder for scheme. Pass it to grpc.WithResolvers, or
+// to resolver.Register to use it for every ClientConn.
+func NewBuilder(scheme string, source Source) *Builder {
+	return &Builder{scheme: scheme, source: source}
+}
+
+// Scheme returns the scheme of the targets the builder resolves.
+func (b *Builder) Scheme() string { return b.scheme }
+
+// Build starts watching the service named by the target.
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	service := strings.TrimPrefix(target.URL.Path, "/")
+	if service == "" {
+		service = target.URL.Opaque
+	}
+	if service == "" {
+		return nil, fmt.Errorf("discovery: target %q names no service", target.URL.String())
+	}
+	r := &discoveryResolver{service: service, cc: cc, source: b.source}
+	stopConfig := func() {}
+	if cs, ok := b.source.(ConfigSource); ok {
+		// The config comes first, so the first state has it.
+		stopConfig = cs.WatchServiceConfig(service, r.updateConfig)
+	}
+	stop := b.source.Watch(service, r.update)
+	r.stop = func() {
+		stop()
+		stopConfig()
+	}
+	return r, nil
+}
+
+type discoveryResolver struct {
+	service string
+	cc      resolver.ClientConn
+	source  Source
+	stop    func()
+
+	mu     sync.Mutex
+	closed bool
+	// addrs is nil until the first endpoints arrive.
+	addrs []resolver.Address
+	// config is the service config last parsed without errors, nil for
+	// none.
+	config     *serviceconfig.ParseResult
+	configJSON string
+}
+
+// update pushes the endpoints to the ClientConn. An empty list is pushed
+// too, so calls fail instead of going to removed backends.
+func (r *discoveryResolver) update(endpoints []Endpoint) {
+	addrs := make([]resolver.Address, len(endpoints))
+	for i, e := range endpoints {
+		addrs[i] = e.Address()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs = addrs
+	r.push()
+}
+
+// updateConfig pushes the service config to the ClientConn. An invalid
+// config is logged and the last valid one stays in place.
+func (r *discoveryResolver) updateConfig(js string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if js == r.configJSON {
+		return
+	}
+	var config *serviceconfig.ParseResult
+	switch {
+	case js != "":
+		config = r.cc.ParseServiceConfig(js)
+		if config.Err != nil {
+			log.Printf("discovery: invalid service config of %s, keeping the last one: %v", r.service, config.Err)
+			return
+		}
+	case r.config != nil:
+		// The ClientConn keeps its config when it gets none, so a removed
+		// config is replaced by an empty one.
+		config = r.cc.ParseServiceConfig("{}")
+	}
+	r.config, r.configJSON = config, js
+	if r.addrs != nil {
+		r.push()
+	}
+}
+
+// push sends the endpoints and service config to the ClientConn.
+func (r *discoveryResolver) push() {
+	if r.closed {
+		return
+	}
+	err := r.cc.UpdateState(resolver.State{Addresses: r.addrs, ServiceConfig: r.config})
+	if err != nil && len(r.addrs) > 0 {
+		log.Printf("discovery: updating %s: %v", r.service, err)
+	}
+}
+
+// ResolveNow re-reads the endpoints, e.g. after a connection failed.
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	// Refresh may call update, which must not run under the ClientConn's
+	// lock held while ResolveNow is called.
+	go r.source.Refresh(r.service)
+}
+
+func (r *discoveryResolver) Close() {
+	r.stop()
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+}
+
+// watchers keeps the update functions of a Source by service.
+type watchers struct {
+	mu     sync.Mutex
+	nextID int
+	byID   map[int]watcher
+}
+
+// watcher is an endpoints watcher with update, or a service config watcher
+// with updateConfig.
+type watcher struct {
+	service      string
+	update       func([]Endpoint)
+	updateConfig func(string)
+}
+
+// add adds a watcher and calls it with the current endpoints.
+func (w *watchers) add(service string, update func([]Endpoint), endpoints func(string) []Endpoint) (stop func()) {
+	stop = w.insert(watcher{service: service, update: update})
+	update(endpoints(service))
+	return stop
+}
+
+// addConfig adds a service config watcher and calls it with the current
+// service config.
+func (w *watchers) addConfig(service string, update func(string), config func(string) string) (stop func()) {
+	stop = w.insert(watcher{service: service, updateConfig: update})
+	update(config(service))
+	return stop
+}
+
+func (w *watchers) insert(wt watcher) (stop func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.byID == nil {
+		w.byID = make(map[int]watcher)
+	}
+	w.nextID++
+	id := w.nextID
+	w.byID[id] = wt
+	return func() {
+		w.mu.Lock()
+		delete(w.byID, id)
+		w.mu.Unlock()
+	}
+}
+
+// notify calls the watchers of service with its endpoints.
+func (w *watchers) notify(service string, endpoints []Endpoint) {
+	for _, wt := range w.list() {
+		if wt.service == service && wt.update != nil {
+			wt.update(append([]Endpoint(nil), endpoints...))
+		}
+	}
+}
+
+// notifyConfig calls the service config watchers of service.
+func (w *watchers) notifyConfig(service, config string) {
+	for _, wt := range w.list() {
+		if wt.service == service && wt.updateConfig != nil {
+			wt.updateConfig(config)
+		}
+	}
+}
+
+// notifyAll calls every watcher with the service config or endpoints of its
+// service. The service configs go first, so they apply to the new
+// endpoints.
+func (w *watchers) notifyAll(endpoints func(string) []Endpoint, config func(string) string) {
+	list := w.list()
+	for _, wt := range list {
+		if wt.updateConfig != nil {
+			wt.updateConfig(config(wt.service))
+		}
+	}
+	for _, wt := range list {
+		if wt.update != nil {
+			wt.update(endpoints(wt.service))
+		}
+	}
+}
+
+// list returns the watchers, so they are called without holding the lock.
+func (w *watchers) list() []watcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := make([]watcher, 0, len(w.byID))
+	for _, wt := range w.byID {
+		list = append(list, wt)
+	}
+	return list
+}
@@ -0,0 +1,397 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// fakeClientConn records the states pushed by a resolver.
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	cc.states <- s
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(error) {}
+
+// fakeConfig is a service config parsed by a fakeClientConn.
+type fakeConfig struct {
+	serviceconfig.Config
+	js string
+}
+
+func (cc *fakeClientConn) ParseServiceConfig(js string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{Config: fakeConfig{js: js}}
+}
+
+// configOf returns the JSON of the service config of a state, "" for none.
+func configOf(s resolver.State) string {
+	if s.ServiceConfig == nil {
+		return ""
+	}
+	return s.ServiceConfig.Config.(fakeConfig).js
+}
+
+// build builds a resolver for service and returns it with its ClientConn.
+func build(t *testing.T, source Source, service string) (resolver.Resolver, *fakeClientConn) {
+	t.Helper()
+	cc := &fakeClientConn{states: make(chan resolver.State, 10)}
+	u, _ := url.Parse("test:///" + service)
+	r, err := NewBuilder("test", source).Build(resolver.Target{URL: *u}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r, cc
+}
+
+// next returns the next state pushed to cc as endpoints.
+func next(t *testing.T, cc *fakeClientConn) []Endpoint {
+	t.Helper()
+	select {
+	case s := <-cc.states:
+		endpoints := make([]Endpoint, len(s.Addresses))
+		for i, a := range s.Addresses {
+			endpoints[i] = EndpointOf(a)
+		}
+		return endpoints
+	case <-time.After(2 * time.Second):
+		t.Fatal("no state pushed")
+		return nil
+	}
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make the change visible to WatchFile on file systems with coarse
+	// modification times.
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const twoEndpoints = `
+services:
+  echo:
+    endpoints:
+      - addr: localhost:50051
+        zone: zone-a
+        weight: 3
+      - addr: localhost:50052
+        zone: zone-b
+`
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	writeFile(t, path, twoEndpoints, time.Now())
+	source, err := NewFileSource(path)
+	if err != nil {
+		t.Fatalf("NewFileSource() failed: %v", err)
+	}
+	r, cc := build(t, source, "echo")
+
+	want := []Endpoint{
+		{Addr: "localhost:50051", Zone: "zone-a", Weight: 3},
+		{Addr: "localhost:50052", Zone: "zone-b", Weight: 1},
+	}
+	if got := next(t, cc); !reflect.DeepEqual(got, want) {
+		t.Errorf("first state = %+v, want %+v", got, want)
+	}
+
+	// ResolveNow re-reads the file.
+	writeFile(t, path, strings.Replace(twoEndpoints, "weight: 3", "weight: 5", 1), time.Now())
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	want[0].Weight = 5
+	if got := next(t, cc); !reflect.DeepEqual(got, want) {
+		t.Errorf("state after ResolveNow = %+v, want %+v", got, want)
+	}
+
+	// An invalid file keeps the endpoints.
+	writeFile(t, path, "services: [", time.Now())
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	time.Sleep(50 * time.Millisecond)
+	if got := source.Endpoints("echo"); len(got) != 2 || got[0].Weight != 5 {
+		t.Errorf("endpoints after an invalid file = %+v, want the previous ones", got)
+	}
+	select {
+	case s := <-cc.states:
+		t.Errorf("state pushed after an invalid file: %+v", s)
+	default:
+	}
+
+	// A service missing from the file has no endpoints.
+	_, other := build(t, source, "other")
+	if got := next(t, other); len(got) != 0 {
+		t.Errorf("state of an unknown service = %+v, want none", got)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	writeFile(t, path, twoEndpoints, time.Now().Add(-time.Minute))
+	source, err := NewFileSource(path)
+	if err != nil {
+		t.Fatalf("NewFileSource() failed: %v", err)
+	}
+	source.WatchFile(10 * time.Millisecond)
+	defer source.Close()
+	_, cc := build(t, source, "echo")
+	next(t, cc)
+
+	writeFile(t, path, "services: {echo: {endpoints: [{addr: 'localhost:50053'}]}}", time.Now())
+	want := []Endpoint{{Addr: "localhost:50053", Weight: 1}}
+	if got := next(t, cc); !reflect.DeepEqual(got, want) {
+		t.Errorf("state after a change = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	f, err := ParseFile("endpoints.json", []byte(`{"services": {"echo": {"endpoints": [{"addr": "localhost:50051", "zone": "zone-a"}]}}}`))
+	if err != nil {
+		t.Fatalf("ParseFile() failed: %v", err)
+	}
+	if got := f.Services["echo"].Endpoints; len(got) != 1 || got[0].Zone != "zone-a" {
+		t.Errorf("ParseFile() = %+v", f)
+	}
+	for name, data := range map[string]string{
+		"endpoints.json": `{"services": {"echo": {"endpoints": [{"address": "localhost:50051"}]}}}`,
+		"endpoints.yaml": "services: {echo: {endpoints: [{addr: localhost}]}}",
+		"endpoints.yml":  "services: {echo: {endpoints: [{addr: 'localhost:1', weight: -1}]}}",
+		"config.yaml":    "services: {echo: {service_config: '[1, 2]'}}",
+		"config.json":    `{"services": {"echo": {"service_config": "{"}}}`,
+	} {
+		if _, err := ParseFile(name, []byte(data)); err == nil {
+			t.Errorf("ParseFile(%s, %q) succeeded, want an error", name, data)
+		}
+	}
+}
+
+func TestParseServiceConfig(t *testing.T) {
+	const want = `{"methodConfig":[{"name":[{"service":"echo"}],"timeout":"2s"}]}`
+	for name, data := range map[string]string{
+		"mapping.yaml": `
+services:
+  echo:
+    service_config:
+      methodConfig:
+        - name: [{service: echo}]
+          timeout: 2s
+`,
+		"string.yaml": "services: {echo: {service_config: '" + want + "'}}",
+		"object.json": `{"services": {"echo": {"service_config": {"methodConfig": [{"name": [{"service": "echo"}], "timeout": "2s"}]}}}}`,
+		"string.json": `{"services": {"echo": {"service_config": ` + strconv.Quote(want) + `}}}`,
+	} {
+		f, err := ParseFile(name, []byte(data))
+		if err != nil {
+			t.Errorf("ParseFile(%s) failed: %v", name, err)
+			continue
+		}
+		if got := string(f.Services["echo"].ServiceConfig); got != want {
+			t.Errorf("ParseFile(%s) service config = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestFileServiceConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	writeFile(t, path, "services: {echo: {endpoints: [{addr: 'localhost:50051'}], service_config: {loadBalancingConfig: [{round_robin: {}}]}}}", time.Now())
+	source, err := NewFileSource(path)
+	if err != nil {
+		t.Fatalf("NewFileSource() failed: %v", err)
+	}
+	r, cc := build(t, source, "echo")
+	// The first state has the endpoints and the service config.
+	if s := <-cc.states; len(s.Addresses) != 1 || configOf(s) != `{"loadBalancingConfig":[{"round_robin":{}}]}` {
+		t.Errorf("first state = %+v with config %s, want the endpoint with round_robin", s.Addresses, configOf(s))
+	}
+
+	// A file without the service config replaces it with an empty one.
+	writeFile(t, path, "services: {echo: {endpoints: [{addr: 'localhost:50051'}]}}", time.Now())
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	if s := <-cc.states; len(s.Addresses) != 1 || configOf(s) != "{}" {
+		t.Errorf("state without a config = %+v with config %s, want the endpoint with {}", s.Addresses, configOf(s))
+	}
+}
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	addr string
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	return &ecpb.EchoResponse{Message: s.addr}, nil
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, &ecServer{addr: lis.Addr().String()})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// backends makes n calls and returns the backends that answered.
+func backends(t *testing.T, client ecpb.EchoClient, n int) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("UnaryEcho() failed: %v", err)
+		}
+		seen[resp.Message]++
+	}
+	return seen
+}
+
+func TestRegistry(t *testing.T) {
+	addr1, addr2 := startServer(t), startServer(t)
+	registry := NewRegistry()
+	registry.Register("echo", Endpoint{Addr: addr1, Zone: "zone-a"})
+
+	conn, err := grpc.Dial("registry:///echo", grpc.WithInsecure(),
+		grpc.WithResolvers(NewBuilder("registry", registry)),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	client := ecpb.NewEchoClient(conn)
+
+	if got := backends(t, client, 4); got[addr1] != 4 {
+		t.Errorf("calls went to %v, want all to %s", got, addr1)
+	}
+
+	// A new backend gets calls once round_robin connected to it.
+	registry.Register("echo", Endpoint{Addr: addr2, Zone: "zone-b"})
+	for i := 0; ; i++ {
+		if got := backends(t, client, 4); got[addr2] > 0 {
+			break
+		} else if i == 100 {
+			t.Fatalf("calls went to %v after registering %s", got, addr2)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	registry.Deregister("echo", addr1)
+	if got := registry.Endpoints("echo"); len(got) != 1 || got[0].Addr != addr2 {
+		t.Errorf("Endpoints() = %+v, want only %s", got, addr2)
+	}
+	for i := 0; ; i++ {
+		if got := backends(t, client, 4); got[addr1] == 0 {
+			break
+		} else if i == 100 {
+			t.Fatalf("calls went to %v after deregistering %s", got, addr1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegistryUpdates(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("echo", Endpoint{Addr: "localhost:50051", Weight: 2})
+	_, cc := build(t, registry, "echo")
+	if got := next(t, cc); len(got) != 1 || got[0].Weight != 2 {
+		t.Errorf("first state = %+v", got)
+	}
+
+	// Registering an address again replaces its endpoint.
+	registry.Register("echo", Endpoint{Addr: "localhost:50051", Zone: "zone-a"})
+	want := []Endpoint{{Addr: "localhost:50051", Zone: "zone-a", Weight: 1}}
+	if got := next(t, cc); !reflect.DeepEqual(got, want) {
+		t.Errorf("state after registering again = %+v, want %+v", got, want)
+	}
+
+	// Removing the last endpoint pushes an empty list.
+	registry.Deregister("echo", "localhost:50051")
+	if got := next(t, cc); len(got) != 0 {
+		t.Errorf("state after deregistering = %+v, want none", got)
+	}
+	if got := registry.Services(); len(got) != 0 {
+		t.Errorf("Services() = %v, want none", got)
+	}
+}
+
+func TestRegistryServiceConfig(t *testing.T) {
+	addr1, addr2 := startServer(t), startServer(t)
+	registry := NewRegistry()
+	registry.Set("echo", []Endpoint{{Addr: addr1}, {Addr: addr2}})
+	if err := registry.SetServiceConfig("echo", `{"loadBalancingConfig": [{"round_robin": {}}]}`); err != nil {
+		t.Fatalf("SetServiceConfig() failed: %v", err)
+	}
+	if err := registry.SetServiceConfig("echo", "round_robin"); err == nil {
+		t.Errorf("SetServiceConfig() of a string succeeded, want an error")
+	}
+
+	// The ClientConn uses pick_first unless the registry says otherwise.
+	conn, err := grpc.Dial("registry:///echo", grpc.WithInsecure(), grpc.WithResolvers(NewBuilder("registry", registry)))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	client := ecpb.NewEchoClient(conn)
+	call := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{})
+		return err
+	}
+	for i := 0; ; i++ {
+		if got := backends(t, client, 10); got[addr1] > 0 && got[addr2] > 0 {
+			break
+		} else if i == 100 {
+			t.Fatalf("calls went to %v, want round_robin over both backends", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A per-method timeout applies to the next calls.
+	if err := registry.SetServiceConfig("echo", `{"methodConfig": [{"name": [{"service": "grpc.examples.echo.Echo", "method": "UnaryEcho"}], "timeout": "0.000000001s"}]}`); err != nil {
+		t.Fatalf("SetServiceConfig() failed: %v", err)
+	}
+	waitFor(t, "the timeout of the service config", func() bool { return status.Code(call()) == codes.DeadlineExceeded })
+
+	// A config the ClientConn rejects keeps the last one.
+	if err := registry.SetServiceConfig("echo", `{"loadBalancingConfig": [{"no_such_policy": {}}]}`); err != nil {
+		t.Fatalf("SetServiceConfig() failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := call(); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("call after an invalid config = %v, want DeadlineExceeded from the last valid one", err)
+	}
+
+	// Without a config the calls succeed again.
+	if err := registry.SetServiceConfig("echo", ""); err != nil {
+		t.Fatalf("SetServiceConfig() failed: %v", err)
+	}
+	waitFor(t, "the calls without a timeout", func() bool { return call() == nil })
+}
@@ -0,0 +1,264 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the content of an endpoints file,
+//
+//	services:
+//	  lb.example.grpc.io:
+//	    endpoints:
+//	      - addr: localhost:50051
+//	        zone: zone-a
+//	        weight: 2
+//	      - addr: localhost:50052
+//	        zone: zone-b
+//	    service_config:
+//	      loadBalancingConfig: [{round_robin: {}}]
+//	      methodConfig:
+//	        - name: [{service: lb.example.grpc.io}]
+//	          timeout: 2s
+//
+// Files ending in .json are read as JSON with the same keys.
+type File struct {
+	Services map[string]Service `json:"services" yaml:"services"`
+}
+
+// Service is a service of an endpoints file.
+type Service struct {
+	Endpoints []Endpoint `json:"endpoints" yaml:"endpoints"`
+	// ServiceConfig is the gRPC service config of the service.
+	ServiceConfig ServiceConfigJSON `json:"service_config,omitempty" yaml:"service_config,omitempty"`
+}
+
+// ServiceConfigJSON is a service config in JSON. In a file it is either a
+// mapping or a string holding the JSON.
+type ServiceConfigJSON string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *ServiceConfigJSON) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" {
+		*c = ServiceConfigJSON(value.Value)
+		return nil
+	}
+	var v interface{}
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("service config: %v", err)
+	}
+	*c = ServiceConfigJSON(js)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *ServiceConfigJSON) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var js string
+		if err := json.Unmarshal(data, &js); err != nil {
+			return err
+		}
+		*c = ServiceConfigJSON(js)
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return err
+	}
+	*c = ServiceConfigJSON(buf.String())
+	return nil
+}
+
+// ParseFile parses and validates an endpoints file. name is only used to
+// choose between JSON and YAML.
+func ParseFile(name string, data []byte) (*File, error) {
+	f := &File{}
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(f); err != nil {
+			return nil, fmt.Errorf("discovery: parsing %s: %v", name, err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("discovery: parsing %s: %v", name, err)
+		}
+	}
+	for name, svc := range f.Services {
+		for _, e := range svc.Endpoints {
+			if _, _, err := net.SplitHostPort(e.Addr); err != nil {
+				return nil, fmt.Errorf("discovery: service %s: %q is not a host:port address", name, e.Addr)
+			}
+		}
+		if err := checkServiceConfig(string(svc.ServiceConfig)); err != nil {
+			return nil, fmt.Errorf("discovery: service %s: %v", name, err)
+		}
+	}
+	return f, nil
+}
+
+// FileSource is a Source reading an endpoints file. The file is read again
+// on Refresh and, after WatchFile, whenever it is modified. A file that
+// cannot be read or parsed is logged and the endpoints read before stay in
+// place.
+type FileSource struct {
+	path     string
+	watchers watchers
+	// reloadMu orders the reloads and the first update of new watchers, so
+	// watchers never see older endpoints after newer ones.
+	reloadMu sync.Mutex
+
+	mu   sync.Mutex
+	file *File
+	done chan struct{}
+}
+
+// NewFileSource reads the endpoints file at path. It fails if the file
+// cannot be read or parsed.
+func NewFileSource(path string) (*FileSource, error) {
+	s := &FileSource{path: path, done: make(chan struct{})}
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// read reads and parses the file.
+func (s *FileSource) read() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	f, err := ParseFile(s.path, data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.file = f
+	s.mu.Unlock()
+	return nil
+}
+
+// Endpoints returns the endpoints of service.
+func (s *FileSource) Endpoints(service string) []Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Endpoint(nil), s.file.Services[service].Endpoints...)
+}
+
+// Services returns the names of the services in the file, sorted.
+func (s *FileSource) Services() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.file.Services))
+	for name := range s.file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServiceConfig returns the service config of service, "" if it has none.
+func (s *FileSource) ServiceConfig(service string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.file.Services[service].ServiceConfig)
+}
+
+// Watch implements Source.
+func (s *FileSource) Watch(service string, update func([]Endpoint)) (stop func()) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.watchers.add(service, update, s.Endpoints)
+}
+
+// WatchServiceConfig implements ConfigSource.
+func (s *FileSource) WatchServiceConfig(service string, update func(string)) (stop func()) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.watchers.addConfig(service, update, s.ServiceConfig)
+}
+
+// Refresh implements Source. It re-reads the file and calls the watchers of
+// every service, because other services may have changed as well.
+func (s *FileSource) Refresh(string) {
+	s.reload("refresh")
+}
+
+func (s *FileSource) reload(reason string) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.mu.Lock()
+	prev := s.file
+	s.mu.Unlock()
+	if err := s.read(); err != nil {
+		log.Printf("discovery: %s of %s failed, keeping the endpoints and service configs: %v", reason, s.path, err)
+		return
+	}
+	s.mu.Lock()
+	changed := !reflect.DeepEqual(prev, s.file)
+	s.mu.Unlock()
+	if changed {
+		log.Printf("discovery: endpoints of %s changed after %s", s.path, reason)
+	}
+	s.watchers.notifyAll(s.Endpoints, s.ServiceConfig)
+}
+
+// WatchFile polls the file every interval and re-reads it when it was
+// modified, until Close is called.
+func (s *FileSource) WatchFile(interval time.Duration) {
+	modTime := func() time.Time {
+		fi, err := os.Stat(s.path)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	last := modTime()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if mt := modTime(); !mt.Equal(last) {
+					last = mt
+					s.reload("change")
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops watching the file.
+func (s *FileSource) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
@@ -0,0 +1,146 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry is an in-process ConfigSource. Endpoints are added with Register
+// and removed with Deregister, and the resolvers watching the service are
+// updated right away. The same goes for service configs set with
+// SetServiceConfig.
+type Registry struct {
+	watchers watchers
+
+	// mu also orders the updates of the watchers.
+	mu       sync.Mutex
+	services map[string][]Endpoint
+	configs  map[string]string
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{services: make(map[string][]Endpoint), configs: make(map[string]string)}
+}
+
+// Register adds an endpoint to service, or replaces the endpoint with the
+// same address.
+func (r *Registry) Register(service string, e Endpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endpoints := r.services[service]
+	for i := range endpoints {
+		if endpoints[i].Addr == e.Addr {
+			endpoints[i] = e
+			r.watchers.notify(service, endpoints)
+			return
+		}
+	}
+	r.services[service] = append(endpoints, e)
+	r.watchers.notify(service, r.services[service])
+}
+
+// Deregister removes the endpoint with address addr from service.
+func (r *Registry) Deregister(service, addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endpoints := r.services[service]
+	for i := range endpoints {
+		if endpoints[i].Addr == addr {
+			endpoints = append(endpoints[:i:i], endpoints[i+1:]...)
+			if len(endpoints) == 0 {
+				delete(r.services, service)
+			} else {
+				r.services[service] = endpoints
+			}
+			r.watchers.notify(service, endpoints)
+			return
+		}
+	}
+}
+
+// Set replaces the endpoints of service.
+func (r *Registry) Set(service string, endpoints []Endpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(endpoints) == 0 {
+		delete(r.services, service)
+	} else {
+		r.services[service] = append([]Endpoint(nil), endpoints...)
+	}
+	r.watchers.notify(service, endpoints)
+}
+
+// SetServiceConfig replaces the service config of service; "" removes it. It
+// fails if js is not a JSON object.
+func (r *Registry) SetServiceConfig(service, js string) error {
+	if err := checkServiceConfig(js); err != nil {
+		return fmt.Errorf("discovery: service %s: %v", service, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if js == "" {
+		delete(r.configs, service)
+	} else {
+		r.configs[service] = js
+	}
+	r.watchers.notifyConfig(service, js)
+	return nil
+}
+
+// ServiceConfig returns the service config of service, "" if it has none.
+func (r *Registry) ServiceConfig(service string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.configs[service]
+}
+
+func (r *Registry) serviceConfig(service string) string {
+	return r.configs[service]
+}
+
+// Endpoints returns the endpoints of service.
+func (r *Registry) Endpoints(service string) []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.endpoints(service)
+}
+
+func (r *Registry) endpoints(service string) []Endpoint {
+	return append([]Endpoint(nil), r.services[service]...)
+}
+
+// Services returns the names of the services with endpoints, sorted.
+func (r *Registry) Services() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Watch implements Source.
+func (r *Registry) Watch(service string, update func([]Endpoint)) (stop func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watchers.add(service, update, r.endpoints)
+}
+
+// WatchServiceConfig implements ConfigSource.
+func (r *Registry) WatchServiceConfig(service string, update func(string)) (stop func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watchers.addConfig(service, update, r.serviceConfig)
+}
+
+// Refresh implements Source. The endpoints are always up to date, so it
+// pushes them again.
+func (r *Registry) Refresh(service string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers.notify(service, r.services[service])
+}
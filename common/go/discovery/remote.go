@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/grpc-up-and-running/samples/common/go/discovery/registrypb"
+	"google.golang.org/grpc"
+)
+
+// RegistryScheme is the scheme of targets resolved from a registry server,
+// e.g. registry:///ecommerce.OrderManagement.
+const RegistryScheme = "registry"
+
+// Delays between attempts to watch a service again after the registry could
+// not be reached.
+const (
+	watchRetryMin = 500 * time.Millisecond
+	watchRetryMax = 30 * time.Second
+)
+
+// RemoteSource is a ConfigSource watching the endpoints and service configs
+// in a registry server. There is one Watch stream per service, shared by its
+// watchers. While the registry cannot be reached the watchers keep the
+// endpoints and service config received last.
+type RemoteSource struct {
+	client pb.RegistryClient
+
+	mu       sync.Mutex
+	services map[string]*remoteService
+}
+
+type remoteService struct {
+	nextID         int
+	watchers       map[int]func([]Endpoint)
+	configWatchers map[int]func(string)
+	// known is set once the registry sent the endpoints.
+	known     bool
+	endpoints []Endpoint
+	config    string
+	cancel    context.CancelFunc
+	retry     chan struct{}
+}
+
+// NewRemoteSource returns a Source for the registry server behind conn.
+func NewRemoteSource(conn grpc.ClientConnInterface) *RemoteSource {
+	return &RemoteSource{client: pb.NewRegistryClient(conn), services: make(map[string]*remoteService)}
+}
+
+// Watch implements Source. The first update comes once the registry sent the
+// endpoints of service.
+func (s *RemoteSource) Watch(service string, update func([]Endpoint)) (stop func()) {
+	return s.add(service, func(svc *remoteService, id int) {
+		svc.watchers[id] = update
+		if svc.known {
+			update(append([]Endpoint(nil), svc.endpoints...))
+		}
+	})
+}
+
+// WatchServiceConfig implements ConfigSource. The first update comes once the
+// registry sent the endpoints of service.
+func (s *RemoteSource) WatchServiceConfig(service string, update func(string)) (stop func()) {
+	return s.add(service, func(svc *remoteService, id int) {
+		svc.configWatchers[id] = update
+		if svc.known {
+			update(svc.config)
+		}
+	})
+}
+
+// add adds a watcher of service with insert, starting the stream of service
+// if it is the first one.
+func (s *RemoteSource) add(service string, insert func(svc *remoteService, id int)) (stop func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[service]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		svc = &remoteService{
+			watchers:       make(map[int]func([]Endpoint)),
+			configWatchers: make(map[int]func(string)),
+			cancel:         cancel,
+			retry:          make(chan struct{}, 1),
+		}
+		s.services[service] = svc
+		go s.watch(ctx, service, svc)
+	}
+	svc.nextID++
+	id := svc.nextID
+	insert(svc, id)
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(svc.watchers, id)
+		delete(svc.configWatchers, id)
+		if len(svc.watchers) == 0 && len(svc.configWatchers) == 0 && s.services[service] == svc {
+			svc.cancel()
+			delete(s.services, service)
+		}
+	}
+}
+
+// Refresh implements Source. The stream keeps the endpoints up to date, so
+// it only retries at once if the stream is broken.
+func (s *RemoteSource) Refresh(service string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if svc, ok := s.services[service]; ok {
+		select {
+		case svc.retry <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch keeps a Watch stream for service open until ctx is done.
+func (s *RemoteSource) watch(ctx context.Context, service string, svc *remoteService) {
+	delay := watchRetryMin
+	for {
+		stream, err := s.client.Watch(ctx, &pb.WatchRequest{Service: service})
+		for err == nil {
+			var msg *pb.Endpoints
+			if msg, err = stream.Recv(); err == nil {
+				delay = watchRetryMin
+				s.update(svc, fromProto(msg), msg.ServiceConfig)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("discovery: watching %s: %v, retrying in %v", service, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-svc.retry:
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > watchRetryMax {
+			delay = watchRetryMax
+		}
+	}
+}
+
+func (s *RemoteSource) update(svc *remoteService, endpoints []Endpoint, config string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc.known, svc.endpoints, svc.config = true, endpoints, config
+	// The service config goes first, so it applies to the new endpoints.
+	for _, update := range svc.configWatchers {
+		update(config)
+	}
+	for _, update := range svc.watchers {
+		update(append([]Endpoint(nil), endpoints...))
+	}
+}
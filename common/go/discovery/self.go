@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	"google.golang.org/grpc"
+)
+
+// Self is the registration of a server with the registry of its config.
+type Self struct {
+	conn          *grpc.ClientConn
+	announcements []*Announcement
+}
+
+// RegisterSelf announces the server listening on lis for each service to the
+// registry at cfg.Addr, with the zone, weight and lease of cfg. The address
+// is cfg.AdvertiseAddr, or AdvertiseAddr of lis. It returns nil when cfg has
+// no registry address.
+func RegisterSelf(cfg config.Registry, lis net.Addr, services ...string) (*Self, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	conn, err := grpc.Dial(cfg.Addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("discovery: dialing the registry %s: %v", cfg.Addr, err)
+	}
+	e := Endpoint{Addr: cfg.AdvertiseAddr, Zone: cfg.Zone, Weight: uint32(cfg.Weight)}
+	if e.Addr == "" {
+		e.Addr = AdvertiseAddr(lis)
+	}
+	s := &Self{conn: conn}
+	for _, service := range services {
+		s.announcements = append(s.announcements, Announce(conn, service, e, cfg.TTL))
+	}
+	return s, nil
+}
+
+// Stop deregisters the server. It does nothing on a nil Self, so it can be
+// registered with lifecycle.WithShutdownHook either way.
+func (s *Self) Stop(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	var errs []error
+	for _, a := range s.announcements {
+		errs = append(errs, a.Stop(ctx))
+	}
+	errs = append(errs, s.conn.Close())
+	return errors.Join(errs...)
+}
+
+// WithRegistry returns a DialOption resolving registry:/// targets from the
+// registry at cfg.Addr, and a function closing the connection to the
+// registry. Without a registry address the option does nothing.
+func WithRegistry(cfg config.Registry) (grpc.DialOption, func() error, error) {
+	if cfg.Addr == "" {
+		return grpc.EmptyDialOption{}, func() error { return nil }, nil
+	}
+	conn, err := grpc.Dial(cfg.Addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, fmt.Errorf("discovery: dialing the registry %s: %v", cfg.Addr, err)
+	}
+	return grpc.WithResolvers(NewBuilder(RegistryScheme, NewRemoteSource(conn))), conn.Close, nil
+}
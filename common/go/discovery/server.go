@@ -0,0 +1,218 @@
+package discovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/grpc-up-and-running/samples/common/go/discovery/registrypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Bounds of the leases handed out by a RegistryServer.
+const (
+	DefaultTTL = 10 * time.Second
+	MinTTL     = time.Second
+	MaxTTL     = 5 * time.Minute
+)
+
+// RegistryServer serves a Registry as the grpcsamples.discovery.Registry
+// service. Endpoints registered through it are removed when their lease
+// expires; service configs stay until they are replaced.
+type RegistryServer struct {
+	pb.UnimplementedRegistryServer
+	registry *Registry
+
+	mu     sync.Mutex
+	leases map[string]*lease
+	// byEndpoint maps service and address to the lease of the endpoint.
+	byEndpoint map[[2]string]string
+}
+
+type lease struct {
+	id, service, addr string
+	ttl               time.Duration
+	expires           time.Time
+	timer             *time.Timer
+}
+
+// NewRegistryServer returns a RegistryServer keeping the endpoints in
+// registry.
+func NewRegistryServer(registry *Registry) *RegistryServer {
+	return &RegistryServer{
+		registry:   registry,
+		leases:     make(map[string]*lease),
+		byEndpoint: make(map[[2]string]string),
+	}
+}
+
+// RegisterRegistryServer registers a RegistryServer for registry with s and
+// returns it.
+func RegisterRegistryServer(s grpc.ServiceRegistrar, registry *Registry) *RegistryServer {
+	srv := NewRegistryServer(registry)
+	pb.RegisterRegistryServer(s, srv)
+	return srv
+}
+
+func (s *RegistryServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Lease, error) {
+	e := req.GetEndpoint()
+	if req.Service == "" {
+		return nil, status.Error(codes.InvalidArgument, "service is required")
+	}
+	if _, _, err := net.SplitHostPort(e.GetAddr()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%q is not a host:port address", e.GetAddr())
+	}
+	ttl := DefaultTTL
+	if req.Ttl != nil {
+		ttl = req.Ttl.AsDuration()
+	}
+	if ttl < MinTTL {
+		ttl = MinTTL
+	} else if ttl > MaxTTL {
+		ttl = MaxTTL
+	}
+
+	l := &lease{id: newLeaseID(), service: req.Service, addr: e.Addr, ttl: ttl, expires: time.Now().Add(ttl)}
+	s.mu.Lock()
+	key := [2]string{req.Service, e.Addr}
+	// A server registering again, e.g. after a restart, replaces its lease.
+	if old, ok := s.leases[s.byEndpoint[key]]; ok {
+		old.timer.Stop()
+		delete(s.leases, old.id)
+	}
+	s.leases[l.id] = l
+	s.byEndpoint[key] = l.id
+	l.timer = time.AfterFunc(ttl, func() { s.expire(l.id) })
+	s.registry.Register(req.Service, Endpoint{Addr: e.Addr, Zone: e.Zone, Weight: e.Weight})
+	s.mu.Unlock()
+	log.Printf("registry: registered %s for %s with a lease of %v", e.Addr, req.Service, ttl)
+	return &pb.Lease{Id: l.id, Ttl: durationpb.New(ttl)}, nil
+}
+
+func (s *RegistryServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.leases[req.LeaseId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "lease %q expired or unknown", req.LeaseId)
+	}
+	l.expires = time.Now().Add(l.ttl)
+	l.timer.Reset(l.ttl)
+	return &pb.Lease{Id: l.id, Ttl: durationpb.New(l.ttl)}, nil
+}
+
+func (s *RegistryServer) Deregister(ctx context.Context, req *pb.DeregisterRequest) (*pb.DeregisterResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[req.LeaseId]; ok {
+		l.timer.Stop()
+		s.remove(l)
+		log.Printf("registry: deregistered %s of %s", l.addr, l.service)
+	}
+	return &pb.DeregisterResponse{}, nil
+}
+
+// expire removes the endpoint of a lease that was not renewed in time.
+func (s *RegistryServer) expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.leases[id]
+	// A heartbeat may have renewed the lease while the timer fired.
+	if !ok || time.Now().Before(l.expires) {
+		return
+	}
+	s.remove(l)
+	log.Printf("registry: lease of %s for %s expired", l.addr, l.service)
+}
+
+func (s *RegistryServer) remove(l *lease) {
+	delete(s.leases, l.id)
+	delete(s.byEndpoint, [2]string{l.service, l.addr})
+	s.registry.Deregister(l.service, l.addr)
+}
+
+func (s *RegistryServer) Watch(req *pb.WatchRequest, stream pb.Registry_WatchServer) error {
+	if req.Service == "" {
+		return status.Error(codes.InvalidArgument, "service is required")
+	}
+	// Only the latest state matters, so a slow watcher skips states.
+	var (
+		mu        sync.Mutex
+		endpoints []Endpoint
+		config    string
+	)
+	changed := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	stopConfig := s.registry.WatchServiceConfig(req.Service, func(js string) {
+		mu.Lock()
+		config = js
+		mu.Unlock()
+		signal()
+	})
+	defer stopConfig()
+	stop := s.registry.Watch(req.Service, func(e []Endpoint) {
+		mu.Lock()
+		endpoints = e
+		mu.Unlock()
+		signal()
+	})
+	defer stop()
+	for {
+		select {
+		case <-changed:
+			mu.Lock()
+			msg := toProto(endpoints, config)
+			mu.Unlock()
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+func (s *RegistryServer) SetServiceConfig(ctx context.Context, req *pb.SetServiceConfigRequest) (*pb.SetServiceConfigResponse, error) {
+	if req.Service == "" {
+		return nil, status.Error(codes.InvalidArgument, "service is required")
+	}
+	if err := s.registry.SetServiceConfig(req.Service, req.ServiceConfig); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Printf("registry: set the service config of %s", req.Service)
+	return &pb.SetServiceConfigResponse{}, nil
+}
+
+func toProto(endpoints []Endpoint, config string) *pb.Endpoints {
+	msg := &pb.Endpoints{Endpoints: make([]*pb.Endpoint, len(endpoints)), ServiceConfig: config}
+	for i, e := range endpoints {
+		msg.Endpoints[i] = &pb.Endpoint{Addr: e.Addr, Zone: e.Zone, Weight: e.Weight}
+	}
+	return msg
+}
+
+func fromProto(msg *pb.Endpoints) []Endpoint {
+	endpoints := make([]Endpoint, len(msg.Endpoints))
+	for i, e := range msg.Endpoints {
+		endpoints[i] = Endpoint{Addr: e.Addr, Zone: e.Zone, Weight: e.Weight}
+	}
+	return endpoints
+}
+
+func newLeaseID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,221 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/config"
+	pb "github.com/grpc-up-and-running/samples/common/go/discovery/registrypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// startRegistry starts a registry server and returns a connection to it.
+func startRegistry(t *testing.T) (*Registry, *grpc.ClientConn) {
+	t.Helper()
+	registry := NewRegistry()
+	s := grpc.NewServer()
+	RegisterRegistryServer(s, registry)
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return registry, conn
+}
+
+// waitFor polls cond until it holds or a few seconds passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; !cond(); i++ {
+		if i == 500 {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeaseExpires(t *testing.T) {
+	registry, conn := startRegistry(t)
+	client := pb.NewRegistryClient(conn)
+	ctx := context.Background()
+
+	lease, err := client.Register(ctx, &pb.RegisterRequest{
+		Service:  "echo",
+		Endpoint: &pb.Endpoint{Addr: "localhost:50051", Zone: "zone-a"},
+		Ttl:      durationpb.New(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if got := lease.Ttl.AsDuration(); got != MinTTL {
+		t.Errorf("lease TTL = %v, want it raised to %v", got, MinTTL)
+	}
+	if got := registry.Endpoints("echo"); len(got) != 1 || got[0].Zone != "zone-a" {
+		t.Errorf("Endpoints() = %+v, want the registered endpoint", got)
+	}
+
+	// Heartbeats keep the endpoint past its TTL.
+	start := time.Now()
+	for time.Since(start) < 3*MinTTL/2 {
+		if _, err := client.Heartbeat(ctx, &pb.HeartbeatRequest{LeaseId: lease.Id}); err != nil {
+			t.Fatalf("Heartbeat() failed: %v", err)
+		}
+		time.Sleep(MinTTL / 4)
+	}
+	if got := registry.Endpoints("echo"); len(got) != 1 {
+		t.Fatalf("Endpoints() = %+v after heartbeats, want the registered endpoint", got)
+	}
+
+	waitFor(t, "the lease to expire", func() bool { return len(registry.Endpoints("echo")) == 0 })
+	if _, err := client.Heartbeat(ctx, &pb.HeartbeatRequest{LeaseId: lease.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("Heartbeat() of an expired lease = %v, want NotFound", err)
+	}
+
+	if _, err := client.Register(ctx, &pb.RegisterRequest{Service: "echo", Endpoint: &pb.Endpoint{Addr: "localhost"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Register() without a port = %v, want InvalidArgument", err)
+	}
+}
+
+func TestAnnounce(t *testing.T) {
+	registry, conn := startRegistry(t)
+	a := Announce(conn, "echo", Endpoint{Addr: "localhost:50051", Weight: 2}, MinTTL)
+	waitFor(t, "the registration", func() bool { return len(registry.Endpoints("echo")) == 1 })
+
+	// The registry forgets the lease, e.g. after a restart, and the next
+	// heartbeat registers the endpoint again.
+	a.mu.Lock()
+	id := a.leaseID
+	a.mu.Unlock()
+	if _, err := pb.NewRegistryClient(conn).Deregister(context.Background(), &pb.DeregisterRequest{LeaseId: id}); err != nil {
+		t.Fatalf("Deregister() failed: %v", err)
+	}
+	waitFor(t, "the registration after the lease was lost", func() bool {
+		got := registry.Endpoints("echo")
+		return len(got) == 1 && got[0].Weight == 2
+	})
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() failed: %v", err)
+	}
+	if got := registry.Endpoints("echo"); len(got) != 0 {
+		t.Errorf("Endpoints() = %+v after Stop, want none", got)
+	}
+}
+
+func TestRemoteSource(t *testing.T) {
+	registry, conn := startRegistry(t)
+	source := NewRemoteSource(conn)
+	_, cc := build(t, source, "echo")
+
+	// The first update is the empty list sent by the registry.
+	if got := next(t, cc); len(got) != 0 {
+		t.Errorf("first state = %+v, want none", got)
+	}
+	registry.Register("echo", Endpoint{Addr: "localhost:50051", Zone: "zone-a", Weight: 3})
+	want := []Endpoint{{Addr: "localhost:50051", Zone: "zone-a", Weight: 3}}
+	if got := next(t, cc); !reflect.DeepEqual(got, want) {
+		t.Errorf("state after Register = %+v, want %+v", got, want)
+	}
+
+	// A second watcher of the service gets the endpoints known so far.
+	_, cc2 := build(t, source, "echo")
+	if got := next(t, cc2); !reflect.DeepEqual(got, want) {
+		t.Errorf("first state of a second watcher = %+v, want %+v", got, want)
+	}
+
+	// A service config set through the registry service reaches both.
+	const js = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+	client := pb.NewRegistryClient(conn)
+	if _, err := client.SetServiceConfig(context.Background(), &pb.SetServiceConfigRequest{Service: "echo", ServiceConfig: js}); err != nil {
+		t.Fatalf("SetServiceConfig() failed: %v", err)
+	}
+	for _, cc := range []*fakeClientConn{cc, cc2} {
+		select {
+		case s := <-cc.states:
+			if configOf(s) != js || len(s.Addresses) != 1 {
+				t.Errorf("state after SetServiceConfig = %+v with config %s, want the endpoint with %s", s.Addresses, configOf(s), js)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no state pushed after SetServiceConfig")
+		}
+	}
+	if _, err := client.SetServiceConfig(context.Background(), &pb.SetServiceConfigRequest{Service: "echo", ServiceConfig: "["}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetServiceConfig() of invalid JSON = %v, want InvalidArgument", err)
+	}
+}
+
+func TestRegisterSelf(t *testing.T) {
+	registry, conn := startRegistry(t)
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, &ecServer{addr: "self"})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	self, err := RegisterSelf(config.Registry{Addr: conn.Target(), Zone: "zone-b"}, lis.Addr(), "echo")
+	if err != nil {
+		t.Fatalf("RegisterSelf() failed: %v", err)
+	}
+	waitFor(t, "the registration", func() bool { return len(registry.Endpoints("echo")) == 1 })
+	if got := registry.Endpoints("echo")[0]; got.Zone != "zone-b" || got.Addr != AdvertiseAddr(lis.Addr()) {
+		t.Errorf("registered %+v", got)
+	}
+
+	// Clients find the server through the registry.
+	withRegistry, closeRegistry, err := WithRegistry(config.Registry{Addr: conn.Target()})
+	if err != nil {
+		t.Fatalf("WithRegistry() failed: %v", err)
+	}
+	defer closeRegistry()
+	client, err := grpc.Dial(RegistryScheme+":///echo", grpc.WithInsecure(), withRegistry)
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer client.Close()
+	if got := backends(t, ecpb.NewEchoClient(client), 2); got["self"] != 2 {
+		t.Errorf("calls went to %v, want the registered server", got)
+	}
+
+	if err := self.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() failed: %v", err)
+	}
+	if got := registry.Endpoints("echo"); len(got) != 0 {
+		t.Errorf("Endpoints() = %+v after Stop, want none", got)
+	}
+	if self, err := RegisterSelf(config.Registry{}, lis.Addr(), "echo"); self != nil || err != nil {
+		t.Errorf("RegisterSelf() without a registry = %v, %v, want nil", self, err)
+	}
+}
+
+func TestAdvertiseAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{Port: 50051}, "localhost:50051"},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 50051}, "localhost:50051"},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 50051}, "localhost:50051"},
+		{&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50051}, "10.0.0.1:50051"},
+	} {
+		if got := AdvertiseAddr(tc.addr); got != tc.want {
+			t.Errorf("AdvertiseAddr(%v) = %s, want %s", tc.addr, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package healthcheck drives the per-service status of the standard
+// grpc.health.v1.Health service from dependency checks.
+//
+// Every service registered with the Checker has its own checks, e.g. whether
+// the order store is reachable. The checks run periodically and the service
+// is SERVING while all of them pass and NOT_SERVING otherwise. The overall
+// status, reported for the empty service name, is SERVING only while every
+// service is. Clients can poll with Check or stream status changes with
+// Watch.
+package healthcheck
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = time.Second
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// NamedCheck is a check with a name used in logs.
+type NamedCheck struct {
+	Name  string
+	Check Check
+}
+
+// Option configures a Checker.
+type Option func(*Checker)
+
+// WithInterval sets how often the checks run.
+func WithInterval(d time.Duration) Option {
+	return func(c *Checker) { c.interval = d }
+}
+
+// WithTimeout sets how long a single check may take.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Checker) { c.timeout = d }
+}
+
+// Checker runs the checks of the services and updates the health server.
+type Checker struct {
+	health   *health.Server
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	services map[string][]NamedCheck
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// New creates a Checker updating hs.
+func New(hs *health.Server, opts ...Option) *Checker {
+	c := &Checker{
+		health:   hs,
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		services: make(map[string][]NamedCheck),
+		statuses: make(map[string]healthpb.HealthCheckResponse_ServingStatus),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register creates a health server with a Checker and registers the health
+// service on s.
+func Register(s *grpc.Server, opts ...Option) (*health.Server, *Checker) {
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	return hs, New(hs, opts...)
+}
+
+// AddService adds a service, e.g. "ecommerce.OrderManagement", with its
+// checks. A service without checks is always SERVING.
+func (c *Checker) AddService(service string, checks ...NamedCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services[service] = append(c.services[service], checks...)
+	c.statuses[service] = healthpb.HealthCheckResponse_NOT_SERVING
+	c.health.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	c.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Start runs the checks once and then every interval until Stop is called.
+func (c *Checker) Start() {
+	c.startOnce.Do(c.start)
+}
+
+func (c *Checker) start() {
+	c.RunChecks(context.Background())
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.RunChecks(context.Background())
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops running the checks. It can be used as a lifecycle shutdown hook.
+func (c *Checker) Stop(ctx context.Context) error {
+	// A Checker that was never started has nothing to wait for.
+	c.startOnce.Do(func() { close(c.done) })
+	c.stopOnce.Do(func() { close(c.stop) })
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunChecks runs the checks of every service and updates the health server.
+func (c *Checker) RunChecks(ctx context.Context) {
+	c.mu.Lock()
+	services := make([]string, 0, len(c.services))
+	checks := make(map[string][]NamedCheck, len(c.services))
+	for service, cs := range c.services {
+		services = append(services, service)
+		checks[service] = cs
+	}
+	c.mu.Unlock()
+	sort.Strings(services)
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, service := range services {
+		st := c.runServiceChecks(ctx, service, checks[service])
+		if st != healthpb.HealthCheckResponse_SERVING {
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		c.setStatus(service, st)
+	}
+	c.setStatus("", overall)
+}
+
+func (c *Checker) runServiceChecks(ctx context.Context, service string, checks []NamedCheck) healthpb.HealthCheckResponse_ServingStatus {
+	for _, nc := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err := nc.Check(checkCtx)
+		cancel()
+		if err != nil {
+			log.Printf("healthcheck: %s check %s failed: %v", service, nc.Name, err)
+			return healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+func (c *Checker) setStatus(service string, st healthpb.HealthCheckResponse_ServingStatus) {
+	c.mu.Lock()
+	prev, known := c.statuses[service]
+	c.statuses[service] = st
+	c.mu.Unlock()
+	if known && prev == st {
+		return
+	}
+	if known {
+		log.Printf("healthcheck: %q %v -> %v", service, prev, st)
+	}
+	// The health server ignores updates after Shutdown, so a server being
+	// drained stays NOT_SERVING.
+	c.health.SetServingStatus(service, st)
+}
+
+// Statuses returns the last status of every service, including the overall
+// status under the empty service name.
+func (c *Checker) Statuses() map[string]healthpb.HealthCheckResponse_ServingStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statuses := make(map[string]healthpb.HealthCheckResponse_ServingStatus, len(c.statuses))
+	for service, st := range c.statuses {
+		statuses[service] = st
+	}
+	return statuses
+}
@@ -0,0 +1,67 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, hs *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) failed: %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestStatusFollowsChecks(t *testing.T) {
+	var storeDown atomic.Value
+	storeDown.Store(false)
+	store := func(ctx context.Context) error {
+		if storeDown.Load().(bool) {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	hs := health.NewServer()
+	c := New(hs)
+	c.AddService("ecommerce.OrderManagement", NamedCheck{Name: "order-store", Check: store})
+	c.AddService("ecommerce.ProductInfo")
+
+	if got := servingStatus(t, hs, "ecommerce.OrderManagement"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status before the first check = %v, want NOT_SERVING", got)
+	}
+
+	c.RunChecks(context.Background())
+	for _, service := range []string{"", "ecommerce.OrderManagement", "ecommerce.ProductInfo"} {
+		if got := servingStatus(t, hs, service); got != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("status of %q = %v, want SERVING", service, got)
+		}
+	}
+
+	storeDown.Store(true)
+	c.RunChecks(context.Background())
+	want := map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                          healthpb.HealthCheckResponse_NOT_SERVING,
+		"ecommerce.OrderManagement": healthpb.HealthCheckResponse_NOT_SERVING,
+		"ecommerce.ProductInfo":     healthpb.HealthCheckResponse_SERVING,
+	}
+	for service, st := range want {
+		if got := servingStatus(t, hs, service); got != st {
+			t.Fatalf("status of %q = %v, want %v", service, got, st)
+		}
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	c := New(health.NewServer())
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v, want nil", err)
+	}
+}
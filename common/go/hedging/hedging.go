@@ -0,0 +1,220 @@
+// Package hedging sends hedged copies of slow unary calls.
+//
+// When a call for a hedged method has not completed within a delay derived
+// from the latencies observed for that method, another copy of the same call
+// is started on the same ClientConn. With a balancer such as round_robin the
+// copy is picked onto a different backend. The first response wins and the
+// remaining copies are cancelled.
+//
+// Only idempotent read methods, such as GetOrder or GetProduct, should be
+// hedged because several backends may process the same request.
+package hedging
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// previousAttemptsKey is the metadata key gRPC uses to tell a server how
+// many copies of a call were sent before this one.
+const previousAttemptsKey = "grpc-previous-rpc-attempts"
+
+// Policy configures hedging for a single method.
+type Policy struct {
+	// MaxAttempts is the total number of copies of a call, including the
+	// first one. Values below 2 disable hedging.
+	MaxAttempts int
+	// Percentile of the observed latencies after which the next copy is
+	// sent, e.g. 0.95 for p95.
+	Percentile float64
+	// InitialDelay is used until MinSamples latencies have been observed.
+	InitialDelay time.Duration
+	// MinDelay and MaxDelay bound the delay derived from the percentile.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// MinSamples is the number of observed latencies required before the
+	// percentile is trusted.
+	MinSamples int
+	// WindowSize is the number of most recent latencies kept per method.
+	WindowSize int
+	// NonFatalCodes are the status codes that do not end a hedged call.
+	// When an attempt fails with one of them the next copy is sent right
+	// away instead of waiting for the delay.
+	NonFatalCodes []codes.Code
+}
+
+// DefaultPolicy returns a policy sending up to two copies after the p95
+// latency of the method.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:   2,
+		Percentile:    0.95,
+		InitialDelay:  100 * time.Millisecond,
+		MinDelay:      5 * time.Millisecond,
+		MaxDelay:      time.Second,
+		MinSamples:    20,
+		WindowSize:    200,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}
+}
+
+func (p Policy) isNonFatal(code codes.Code) bool {
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Hedger holds the hedging policies and the observed latencies per method.
+type Hedger struct {
+	policies map[string]Policy
+
+	mu      sync.Mutex
+	windows map[string]*latencyWindow
+}
+
+// New creates a Hedger for the given policies keyed by full method name,
+// e.g. "/ecommerce.OrderManagement/getOrder". Methods without a policy are
+// never hedged.
+func New(policies map[string]Policy) *Hedger {
+	h := &Hedger{
+		policies: make(map[string]Policy, len(policies)),
+		windows:  make(map[string]*latencyWindow, len(policies)),
+	}
+	for method, p := range policies {
+		if p.WindowSize <= 0 {
+			p.WindowSize = DefaultPolicy().WindowSize
+		}
+		h.policies[method] = p
+		h.windows[method] = newLatencyWindow(p.WindowSize)
+	}
+	return h
+}
+
+// UnaryClientInterceptor is a shortcut for New(policies).UnaryClientInterceptor().
+func UnaryClientInterceptor(policies map[string]Policy) grpc.UnaryClientInterceptor {
+	return New(policies).UnaryClientInterceptor()
+}
+
+// Delay returns the current hedging delay of a method.
+func (h *Hedger) Delay(method string) time.Duration {
+	p := h.policies[method]
+	w := h.window(method)
+	if w == nil || w.count() < p.MinSamples {
+		return p.InitialDelay
+	}
+	d, _ := w.percentile(p.Percentile)
+	if d < p.MinDelay {
+		d = p.MinDelay
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func (h *Hedger) window(method string) *latencyWindow {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.windows[method]
+}
+
+type attemptResult struct {
+	reply proto.Message
+	err   error
+}
+
+// UnaryClientInterceptor returns a client interceptor hedging the methods
+// that have a policy.
+func (h *Hedger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p, ok := h.policies[method]
+		out, isProto := reply.(proto.Message)
+		if !ok || p.MaxAttempts < 2 || !isProto {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		// Cancelling the shared context stops the copies that lost the race.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Every attempt records its own latency from its send, so the delay
+		// follows the latency of single calls and not of hedged ones. An
+		// attempt cancelled because another one won took at least as long as
+		// it ran, which is recorded too; leaving it out would skew the
+		// window towards the fast backends.
+		var won atomic.Bool
+		results := make(chan attemptResult, p.MaxAttempts)
+		send := func(attempt int) {
+			attemptReply := proto.Clone(out)
+			attemptCtx := ctx
+			if attempt > 0 {
+				attemptCtx = metadata.AppendToOutgoingContext(ctx, previousAttemptsKey, strconv.Itoa(attempt))
+			}
+			go func() {
+				start := time.Now()
+				err := invoker(attemptCtx, method, req, attemptReply, cc, opts...)
+				if err == nil || won.Load() && status.Code(err) == codes.Canceled {
+					h.window(method).observe(time.Since(start))
+				}
+				results <- attemptResult{reply: attemptReply, err: err}
+			}()
+		}
+
+		delay := h.Delay(method)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		send(0)
+		sent, pending := 1, 1
+		var lastErr error
+		for {
+			select {
+			case <-timer.C:
+				if sent < p.MaxAttempts {
+					send(sent)
+					sent++
+					pending++
+					timer.Reset(delay)
+				}
+			case r := <-results:
+				pending--
+				if r.err == nil {
+					won.Store(true)
+					out.Reset()
+					proto.Merge(out, r.reply)
+					return nil
+				}
+				if !p.isNonFatal(status.Code(r.err)) {
+					return r.err
+				}
+				lastErr = r.err
+				if sent < p.MaxAttempts {
+					send(sent)
+					sent++
+					pending++
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					timer.Reset(delay)
+				} else if pending == 0 {
+					return lastErr
+				}
+			}
+		}
+	}
+}
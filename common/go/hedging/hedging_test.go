@@ -0,0 +1,151 @@
+package hedging
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+const unaryEcho = "/grpc.examples.echo.Echo/UnaryEcho"
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	addr  string
+	delay int64 // time.Duration, accessed atomically
+	calls int32
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	select {
+	case <-time.After(time.Duration(atomic.LoadInt64(&s.delay))):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &ecpb.EchoResponse{Message: fmt.Sprintf("%s (from %s)", req.Message, s.addr)}, nil
+}
+
+func startServer(t *testing.T, delay time.Duration) *ecServer {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	es := &ecServer{addr: lis.Addr().String(), delay: int64(delay)}
+	ecpb.RegisterEchoServer(s, es)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return es
+}
+
+func TestHedgedCallIsAnsweredByFastBackend(t *testing.T) {
+	slow := startServer(t, 0)
+	fast := startServer(t, 0)
+
+	r := manual.NewBuilderWithScheme("hedging")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: slow.addr}, {Addr: fast.addr}}})
+
+	policy := DefaultPolicy()
+	policy.InitialDelay = 50 * time.Millisecond
+	conn, err := grpc.Dial(r.Scheme()+":///echo",
+		grpc.WithResolvers(r),
+		grpc.WithInsecure(),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(map[string]Policy{unaryEcho: policy})),
+	)
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := ecpb.NewEchoClient(conn)
+
+	// Until round_robin is connected to both backends, both copies of a
+	// call would go to the one that is ready.
+	for atomic.LoadInt32(&slow.calls) == 0 || atomic.LoadInt32(&fast.calls) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "warm up"}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("UnaryEcho() failed: %v", err)
+		}
+	}
+	atomic.StoreInt32(&slow.calls, 0)
+	atomic.StoreInt64(&slow.delay, int64(2*time.Second))
+
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		res, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hedge"}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("UnaryEcho() failed: %v", err)
+		}
+		if want := "hedge (from " + fast.addr + ")"; res.Message != want {
+			t.Fatalf("UnaryEcho() = %q, want %q", res.Message, want)
+		}
+	}
+	if atomic.LoadInt32(&slow.calls) == 0 {
+		t.Fatalf("slow backend was never called, hedging was not exercised")
+	}
+}
+
+func TestDelayFollowsPercentile(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.MinSamples = 10
+	h := New(map[string]Policy{unaryEcho: policy})
+
+	if got := h.Delay(unaryEcho); got != policy.InitialDelay {
+		t.Fatalf("Delay() before samples = %v, want %v", got, policy.InitialDelay)
+	}
+	for i := 1; i <= 100; i++ {
+		h.window(unaryEcho).observe(time.Duration(i) * time.Millisecond)
+	}
+	if got, want := h.Delay(unaryEcho), 95*time.Millisecond; got != want {
+		t.Fatalf("Delay() = %v, want %v", got, want)
+	}
+}
+
+// TestAttemptLatencies checks that every attempt records its own latency,
+// including the attempt that lost the race.
+func TestAttemptLatencies(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.InitialDelay = 50 * time.Millisecond
+	h := New(map[string]Policy{unaryEcho: policy})
+	// The first attempt hangs until it is cancelled, the hedged copy answers
+	// at once.
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(previousAttemptsKey)) == 0 {
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return nil
+	}
+	err := h.UnaryClientInterceptor()(context.Background(), unaryEcho, &ecpb.EchoRequest{}, &ecpb.EchoResponse{}, nil, invoker)
+	if err != nil {
+		t.Fatalf("hedged call failed: %v", err)
+	}
+
+	w := h.window(unaryEcho)
+	deadline := time.Now().Add(5 * time.Second)
+	for w.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := w.count(); n != 2 {
+		t.Fatalf("%d latencies recorded, want 2", n)
+	}
+	if fastest, _ := w.percentile(0.5); fastest >= policy.InitialDelay {
+		t.Errorf("latency of the hedged copy = %v, want its own latency below the %v delay", fastest, policy.InitialDelay)
+	}
+	if slowest, _ := w.percentile(1); slowest < policy.InitialDelay {
+		t.Errorf("latency of the cancelled attempt = %v, want at least the %v delay", slowest, policy.InitialDelay)
+	}
+}
@@ -0,0 +1,171 @@
+// Package otlpjson posts telemetry to an OTLP/HTTP collector in the JSON
+// encoding of OTLP. It is shared by the trace and metric exporters, which
+// need neither the OTLP protobuf module nor a newer gRPC than the samples
+// use.
+package otlpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Client posts requests to one OTLP/HTTP endpoint.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient returns a client for endpoint, a host:port address or a URL.
+// The path defaults to defaultPath, e.g. /v1/traces.
+func NewClient(endpoint, defaultPath string) (*Client, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("the otlp exporter needs an endpoint")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("otlp endpoint: %v", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultPath
+	}
+	return &Client{url: u.String(), client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Post sends req as JSON.
+func (c *Client) Post(ctx context.Context, req interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting to %s: %s", c.url, resp.Status)
+	}
+	return nil
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() {
+	c.client.CloseIdleConnections()
+}
+
+// The types below are the JSON mapping of the common OTLP messages. IDs are
+// hex encoded and 64 bit integers are strings.
+
+// Resource is the resource of the telemetry.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// Scope is the instrumentation scope of the telemetry.
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// KeyValue is an attribute.
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value Value  `json:"value"`
+}
+
+// Value is an attribute value; exactly one field is set.
+type Value struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	ArrayValue  *Values  `json:"arrayValue,omitempty"`
+}
+
+// Values is an array value.
+type Values struct {
+	Values []Value `json:"values"`
+}
+
+// ResourceOf encodes res.
+func ResourceOf(res *resource.Resource) Resource {
+	return Resource{Attributes: Attributes(res.Attributes())}
+}
+
+// ScopeOf encodes scope.
+func ScopeOf(scope instrumentation.Scope) Scope {
+	return Scope{Name: scope.Name, Version: scope.Version}
+}
+
+// UnixNano encodes t.
+func UnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Attributes encodes attrs.
+func Attributes(attrs []attribute.KeyValue) []KeyValue {
+	kvs := make([]KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, KeyValue{Key: string(kv.Key), Value: valueOf(kv.Value)})
+	}
+	return kvs
+}
+
+func valueOf(v attribute.Value) Value {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return Value{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return Value{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return Value{DoubleValue: &f}
+	case attribute.BOOLSLICE, attribute.INT64SLICE, attribute.FLOAT64SLICE, attribute.STRINGSLICE:
+		var values []Value
+		switch v.Type() {
+		case attribute.BOOLSLICE:
+			for _, b := range v.AsBoolSlice() {
+				values = append(values, valueOf(attribute.BoolValue(b)))
+			}
+		case attribute.INT64SLICE:
+			for _, i := range v.AsInt64Slice() {
+				values = append(values, valueOf(attribute.Int64Value(i)))
+			}
+		case attribute.FLOAT64SLICE:
+			for _, f := range v.AsFloat64Slice() {
+				values = append(values, valueOf(attribute.Float64Value(f)))
+			}
+		default:
+			for _, s := range v.AsStringSlice() {
+				values = append(values, valueOf(attribute.StringValue(s)))
+			}
+		}
+		return Value{ArrayValue: &Values{Values: values}}
+	default:
+		s := v.Emit()
+		return Value{StringValue: &s}
+	}
+}
@@ -0,0 +1,192 @@
+// Package lifecycle runs a gRPC server until it is asked to stop and then
+// shuts it down gracefully.
+//
+// On SIGTERM or SIGINT the server
+//
+//  1. closes the ShuttingDown channel, so long running streaming handlers can
+//     wrap up their current piece of work,
+//  2. marks every service NOT_SERVING on the health server, if any, and
+//     keeps serving for the shutdown delay so load balancers and probes see
+//     the change before connections are closed,
+//  3. calls GracefulStop and waits up to the drain timeout for in-flight
+//     calls to finish,
+//  4. calls Stop to cancel the calls still running after the drain timeout,
+//  5. runs the shutdown hooks, e.g. to flush tracing and metrics exporters.
+package lifecycle
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+const (
+	defaultDrainTimeout = 30 * time.Second
+	defaultHookTimeout  = 5 * time.Second
+)
+
+// Hook is run after the server has stopped, e.g. to flush an exporter.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithHealth sets the health server whose services are marked NOT_SERVING
+// when the shutdown starts.
+func WithHealth(h *health.Server) Option {
+	return func(s *Server) { s.health = h }
+}
+
+// WithShutdownDelay sets how long the server keeps serving after it was
+// marked NOT_SERVING and before GracefulStop closes the listeners.
+func WithShutdownDelay(d time.Duration) Option {
+	return func(s *Server) { s.shutdownDelay = d }
+}
+
+// WithDrainTimeout sets how long in-flight calls may take to finish before
+// they are cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(s *Server) { s.drainTimeout = d }
+}
+
+// WithHookTimeout sets how long the shutdown hooks may take altogether.
+func WithHookTimeout(d time.Duration) Option {
+	return func(s *Server) { s.hookTimeout = d }
+}
+
+// WithSignals replaces the signals starting the shutdown.
+func WithSignals(sig ...os.Signal) Option {
+	return func(s *Server) { s.signals = sig }
+}
+
+// WithShutdownHook adds a hook run once the server has stopped. Hooks run
+// in the order they were added.
+func WithShutdownHook(name string, fn Hook) Option {
+	return func(s *Server) { s.hooks = append(s.hooks, namedHook{name: name, fn: fn}) }
+}
+
+// Server wraps a grpc.Server with signal handling and graceful shutdown.
+type Server struct {
+	grpcServer    *grpc.Server
+	health        *health.Server
+	shutdownDelay time.Duration
+	drainTimeout  time.Duration
+	hookTimeout   time.Duration
+	signals       []os.Signal
+	hooks         []namedHook
+
+	shutdownOnce sync.Once
+	shuttingDown chan struct{}
+	stopped      chan struct{}
+}
+
+// New wraps s.
+func New(s *grpc.Server, opts ...Option) *Server {
+	srv := &Server{
+		grpcServer:   s,
+		drainTimeout: defaultDrainTimeout,
+		hookTimeout:  defaultHookTimeout,
+		signals:      []os.Signal{syscall.SIGTERM, os.Interrupt},
+		shuttingDown: make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
+}
+
+// ShuttingDown is closed when the shutdown starts. Streaming handlers select
+// on it to finish their current batch and end the stream.
+func (s *Server) ShuttingDown() <-chan struct{} {
+	return s.shuttingDown
+}
+
+// Serve accepts connections on lis until a shutdown signal is received or
+// Shutdown is called, and returns once the server has stopped and the hooks
+// have run. It returns nil after a shutdown and the error of
+// grpc.Server.Serve if serving failed. If the grpc.Server is stopped directly,
+// Serve runs the shutdown as well so the hooks still run.
+func (s *Server) Serve(lis net.Listener) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, s.signals...)
+	defer signal.Stop(sigCh)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.grpcServer.Serve(lis) }()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			return err
+		}
+		// The grpc.Server was stopped without Shutdown, which would
+		// otherwise never close stopped.
+		s.Shutdown()
+	case sig := <-sigCh:
+		log.Printf("lifecycle: received %v, shutting down", sig)
+		s.Shutdown()
+	case <-s.shuttingDown:
+		// Shutdown was called directly.
+	}
+	<-s.stopped
+	return nil
+}
+
+// Shutdown starts the graceful shutdown and blocks until the server has
+// stopped and the hooks have run. It is safe to call more than once.
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() {
+		close(s.shuttingDown)
+		go s.shutdown()
+	})
+	<-s.stopped
+}
+
+func (s *Server) shutdown() {
+	defer close(s.stopped)
+
+	if s.health != nil {
+		// Let load balancers and probes take the server out of rotation.
+		s.health.Shutdown()
+		if s.shutdownDelay > 0 {
+			log.Printf("lifecycle: serving NOT_SERVING for %v before draining", s.shutdownDelay)
+			time.Sleep(s.shutdownDelay)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		log.Printf("lifecycle: all calls drained")
+	case <-time.After(s.drainTimeout):
+		log.Printf("lifecycle: drain timeout of %v expired, cancelling remaining calls", s.drainTimeout)
+		s.grpcServer.Stop()
+		<-drained
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.hookTimeout)
+	defer cancel()
+	for _, h := range s.hooks {
+		if err := h.fn(ctx); err != nil {
+			log.Printf("lifecycle: shutdown hook %s failed: %v", h.name, err)
+		}
+	}
+}
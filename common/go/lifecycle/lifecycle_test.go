@@ -0,0 +1,210 @@
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// ecServer answers UnaryEcho after the call is started and, for the message
+// "block", only once its context is done.
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	started chan struct{}
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	s.started <- struct{}{}
+	if req.Message == "block" {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return &ecpb.EchoResponse{Message: req.Message}, nil
+}
+
+type testServer struct {
+	srv    *Server
+	hs     *health.Server
+	echo   *ecServer
+	conn   *grpc.ClientConn
+	served chan error
+}
+
+// start serves a Server with opts on a local port and dials it.
+func start(t *testing.T, opts ...Option) *testServer {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ts := &testServer{
+		hs:     health.NewServer(),
+		echo:   &ecServer{started: make(chan struct{}, 1)},
+		served: make(chan error, 1),
+	}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, ts.echo)
+	healthpb.RegisterHealthServer(s, ts.hs)
+	ts.srv = New(s, append([]Option{WithHealth(ts.hs), WithSignals(syscall.SIGUSR1)}, opts...)...)
+	go func() { ts.served <- ts.srv.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	ts.conn, err = grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { ts.conn.Close() })
+	return ts
+}
+
+func (ts *testServer) echoCall(ctx context.Context, msg string) error {
+	_, err := ecpb.NewEchoClient(ts.conn).UnaryEcho(ctx, &ecpb.EchoRequest{Message: msg})
+	return err
+}
+
+func (ts *testServer) waitServed(t *testing.T) error {
+	t.Helper()
+	select {
+	case err := <-ts.served:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return")
+		return nil
+	}
+}
+
+func TestShutdownRunsHooksInOrder(t *testing.T) {
+	var ran []string
+	hook := func(name string) Option {
+		return WithShutdownHook(name, func(context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+	ts := start(t, hook("tracing"), hook("metrics"))
+	if err := ts.echoCall(context.Background(), "hi"); err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	<-ts.echo.started
+
+	ts.srv.Shutdown()
+	if err := ts.waitServed(t); err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+	select {
+	case <-ts.srv.ShuttingDown():
+	default:
+		t.Error("ShuttingDown() is not closed after Shutdown")
+	}
+	if want := []string{"tracing", "metrics"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("hooks ran %v, want %v", ran, want)
+	}
+	// A second Shutdown returns at once.
+	ts.srv.Shutdown()
+}
+
+func TestShutdownDelayServesNotServing(t *testing.T) {
+	ts := start(t, WithShutdownDelay(time.Second))
+	go ts.srv.Shutdown()
+	<-ts.srv.ShuttingDown()
+
+	// During the delay the server still answers and reports NOT_SERVING.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hc := healthpb.NewHealthClient(ts.conn)
+	for {
+		res, err := hc.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Check() during the shutdown delay failed: %v", err)
+		}
+		if res.Status == healthpb.HealthCheckResponse_NOT_SERVING {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := ts.echoCall(ctx, "hi"); err != nil {
+		t.Errorf("UnaryEcho() during the shutdown delay failed: %v", err)
+	}
+	if err := ts.waitServed(t); err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+}
+
+func TestDrainTimeoutCancelsCalls(t *testing.T) {
+	ts := start(t, WithDrainTimeout(100*time.Millisecond))
+	callErr := make(chan error, 1)
+	go func() { callErr <- ts.echoCall(context.Background(), "block") }()
+	<-ts.echo.started
+
+	ts.srv.Shutdown()
+	select {
+	case err := <-callErr:
+		if status.Code(err) == codes.OK {
+			t.Error("UnaryEcho() succeeded after the drain timeout, want an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the blocked call was not cancelled after the drain timeout")
+	}
+	if err := ts.waitServed(t); err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+}
+
+func TestSignalStartsShutdown(t *testing.T) {
+	ts := start(t)
+	// Wait until Serve is running and has installed its signal handler.
+	if err := ts.echoCall(context.Background(), "hi"); err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	<-ts.echo.started
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.waitServed(t); err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+}
+
+func TestServeReturnsWhenStoppedDirectly(t *testing.T) {
+	hookRan := make(chan struct{})
+	ts := start(t, WithShutdownHook("flush", func(context.Context) error {
+		close(hookRan)
+		return nil
+	}))
+	if err := ts.echoCall(context.Background(), "hi"); err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	<-ts.echo.started
+
+	ts.srv.grpcServer.Stop()
+	if err := ts.waitServed(t); err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+	select {
+	case <-hookRan:
+	default:
+		t.Error("shutdown hook did not run after the server was stopped directly")
+	}
+}
+
+func TestServeReturnsServeError(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	lis.Close()
+	srv := New(grpc.NewServer(), WithSignals(syscall.SIGUSR1))
+	if err := srv.Serve(lis); err == nil {
+		t.Error("Serve() on a closed listener = nil, want an error")
+	}
+}
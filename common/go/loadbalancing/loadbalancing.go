@@ -0,0 +1,122 @@
+// Package loadbalancing registers client-side load balancing policies with
+// gRPC. Import it for its side effect and select a policy in the service
+// config, e.g.
+//
+//	grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"weighted_locality": {"zone": "zone-a"}}]}`)
+//
+// The least_request and peak_ewma policies pick the less loaded of two
+// random backends, by calls in flight or by their latency. The ring_hash
+// policy sends the calls with the same hash key to the same backend. The
+// outlier_detection policy ejects the failing or slow backends of a child
+// policy for a while.
+//
+// The policies read the zone and weight of each address from the
+// attributes set by the discovery package. With a healthCheckConfig in the
+// service config, backends that are not SERVING are left out.
+package loadbalancing
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	// Registers the client side health checks used with a healthCheckConfig.
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// builder builds a policy on top of the base balancer, which creates a
+// SubConn per address and rebuilds the picker whenever the set of ready
+// SubConns changes.
+type builder struct {
+	name string
+	// parseConfig parses the config of the policy in the service config.
+	parseConfig func(json.RawMessage) (serviceconfig.LoadBalancingConfig, error)
+	// newPicker builds the picker for the ready SubConns.
+	newPicker func(b *lbBalancer, info base.PickerBuildInfo) balancer.Picker
+}
+
+func (bb *builder) Name() string { return bb.name }
+
+func (bb *builder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	return bb.parseConfig(js)
+}
+
+func (bb *builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	b := &lbBalancer{builder: bb, endpoints: resolver.NewAddressMap(), loads: make(map[balancer.SubConn]*load)}
+	pb := pickerBuilderFunc(func(info base.PickerBuildInfo) balancer.Picker { return bb.newPicker(b, info) })
+	// Health checking applies when the service config has a healthCheckConfig.
+	b.Balancer = base.NewBalancerBuilder(bb.name, pb, base.Config{HealthCheck: true}).Build(cc, opts)
+	return b
+}
+
+type pickerBuilderFunc func(info base.PickerBuildInfo) balancer.Picker
+
+func (f pickerBuilderFunc) Build(info base.PickerBuildInfo) balancer.Picker { return f(info) }
+
+// lbBalancer keeps the latest config and endpoints for the picker builder.
+// The base balancer keeps the address a SubConn was created with, so without
+// this a changed weight or zone would not reach the picker. It also keeps
+// the load of each SubConn, which outlives the pickers.
+type lbBalancer struct {
+	balancer.Balancer
+	builder *builder
+
+	mu        sync.Mutex
+	config    serviceconfig.LoadBalancingConfig
+	endpoints *resolver.AddressMap // of discovery.Endpoint
+	loads     map[balancer.SubConn]*load
+}
+
+func (b *lbBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	endpoints := resolver.NewAddressMap()
+	for _, a := range s.ResolverState.Addresses {
+		endpoints.Set(a, discovery.EndpointOf(a))
+	}
+	b.mu.Lock()
+	b.config, b.endpoints = s.BalancerConfig, endpoints
+	b.mu.Unlock()
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+func (b *lbBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.SubConnState) {
+	if state.ConnectivityState == connectivity.Shutdown {
+		b.mu.Lock()
+		delete(b.loads, sc)
+		b.mu.Unlock()
+	}
+	b.Balancer.UpdateSubConnState(sc, state)
+}
+
+// endpoint returns the latest endpoint of addr.
+func (b *lbBalancer) endpoint(addr resolver.Address) discovery.Endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e, ok := b.endpoints.Get(addr); ok {
+		return e.(discovery.Endpoint)
+	}
+	return discovery.EndpointOf(addr)
+}
+
+// lbConfig returns the latest config of the policy, nil if there is none.
+func (b *lbBalancer) lbConfig() serviceconfig.LoadBalancingConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.config
+}
+
+// load returns the load of sc, kept while sc exists.
+func (b *lbBalancer) load(sc balancer.SubConn) *load {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.loads[sc]
+	if !ok {
+		l = &load{}
+		b.loads[sc] = l
+	}
+	return l
+}
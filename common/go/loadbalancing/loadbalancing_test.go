@@ -0,0 +1,174 @@
+package loadbalancing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	addr    string
+	delay   time.Duration
+	failing *int32
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	time.Sleep(s.delay)
+	if atomic.LoadInt32(s.failing) != 0 {
+		return nil, status.Errorf(codes.Internal, "%s is failing", s.addr)
+	}
+	return &ecpb.EchoResponse{Message: s.addr}, nil
+}
+
+type backend struct {
+	addr    string
+	health  *health.Server
+	stop    func()
+	failing int32
+}
+
+// fail makes the calls to b fail with INTERNAL, or succeed again.
+func (b *backend) fail(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&b.failing, v)
+}
+
+// startBackend starts an Echo server with a health service.
+func startBackend(t *testing.T) *backend {
+	t.Helper()
+	return startSlowBackend(t, 0)
+}
+
+// startSlowBackend starts an Echo server answering after delay.
+func startSlowBackend(t *testing.T, delay time.Duration) *backend {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	b := &backend{addr: lis.Addr().String(), health: health.NewServer(), stop: s.Stop}
+	ecpb.RegisterEchoServer(s, &ecServer{addr: b.addr, delay: delay, failing: &b.failing})
+	healthpb.RegisterHealthServer(s, b.health)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return b
+}
+
+// dial connects to the echo service of registry with the service config.
+func dial(t *testing.T, registry *discovery.Registry, serviceConfig string) ecpb.EchoClient {
+	t.Helper()
+	conn, err := grpc.Dial("test:///echo", grpc.WithInsecure(),
+		grpc.WithResolvers(discovery.NewBuilder("test", registry)),
+		grpc.WithDefaultServiceConfig(serviceConfig))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn)
+}
+
+// calls makes n calls and counts them by backend. Failed calls are counted
+// as "error".
+func calls(t *testing.T, client ecpb.EchoClient, n int) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			seen["error"]++
+			continue
+		}
+		seen[resp.Message]++
+	}
+	return seen
+}
+
+// eventually makes calls until cond holds for their counts.
+func eventually(t *testing.T, what string, client ecpb.EchoClient, n int, cond func(map[string]int) bool) {
+	t.Helper()
+	for i := 0; ; i++ {
+		got := calls(t, client, n)
+		if cond(got) {
+			return
+		}
+		if i == 200 {
+			t.Fatalf("calls went to %v, want %s", got, what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func weightedConfig(zone string) string {
+	return fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {"zone": %q}}], "healthCheckConfig": {"serviceName": ""}}`,
+		WeightedLocalityName, zone)
+}
+
+func TestWeights(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr, Weight: 3}, {Addr: b2.addr}})
+	client := dial(t, registry, weightedConfig(""))
+
+	eventually(t, "both backends", client, 8, func(got map[string]int) bool { return got[b1.addr] > 0 && got[b2.addr] > 0 })
+	// Smooth weighted round robin repeats every 4 calls.
+	if got := calls(t, client, 40); got[b1.addr] != 30 || got[b2.addr] != 10 {
+		t.Errorf("calls went to %v, want 30 to %s and 10 to %s", got, b1.addr, b2.addr)
+	}
+
+	// A new weight applies without a new connection.
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr}, {Addr: b2.addr}})
+	eventually(t, "an even split", client, 40, func(got map[string]int) bool {
+		return got[b1.addr] == 20 && got[b2.addr] == 20
+	})
+}
+
+func TestLocality(t *testing.T) {
+	local1, local2, remote := startBackend(t), startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{
+		{Addr: local1.addr, Zone: "zone-a"},
+		{Addr: local2.addr, Zone: "zone-a"},
+		{Addr: remote.addr, Zone: "zone-b", Weight: 10},
+	})
+	client := dial(t, registry, weightedConfig("zone-a"))
+
+	eventually(t, "only the zone-a backends", client, 20, func(got map[string]int) bool {
+		return got[local1.addr] == 10 && got[local2.addr] == 10
+	})
+
+	// An unhealthy backend leaves the zone, the other one takes its calls.
+	local1.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	eventually(t, "the healthy zone-a backend", client, 10, func(got map[string]int) bool {
+		return got[local2.addr] == 10
+	})
+
+	// Without a healthy backend in the zone, calls go to the other zones.
+	local2.stop()
+	eventually(t, "the zone-b backend", client, 10, func(got map[string]int) bool {
+		return got[remote.addr] == 10
+	})
+
+	// They come back once the zone is healthy again.
+	local1.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	eventually(t, "the zone-a backend again", client, 10, func(got map[string]int) bool {
+		return got[local1.addr] == 10
+	})
+}
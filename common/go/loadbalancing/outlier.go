@@ -0,0 +1,530 @@
+package loadbalancing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// OutlierDetectionName is the name of the outlier detection policy.
+const OutlierDetectionName = "outlier_detection"
+
+// Defaults of the outlier_detection policy.
+const (
+	DefaultOutlierInterval       = 10 * time.Second
+	DefaultBaseEjectionTime      = 30 * time.Second
+	DefaultMaxEjectionTime       = 300 * time.Second
+	DefaultMaxEjectionPercent    = 10
+	DefaultFailureThreshold      = 50
+	DefaultLatencyThreshold      = 3.0
+	DefaultEjectionRequestVolume = 20
+)
+
+// errEjected is the connection error of an ejected backend.
+var errEjected = errors.New("ejected by outlier detection")
+
+// OutlierDetectionConfig is the config of the outlier_detection policy, which
+// ejects the backends whose calls fail or are slow more often than the others
+// from the backends of its child policy,
+//
+//	{"loadBalancingConfig": [{"outlier_detection": {
+//	  "interval": "10s", "baseEjectionTime": "30s",
+//	  "failurePercentageEjection": {"threshold": 50, "requestVolume": 20},
+//	  "latencyEjection": {"threshold": 3, "requestVolume": 20},
+//	  "childPolicy": [{"round_robin": {}}]}}]}
+//
+// Every interval it looks at the calls of the interval. An ejected backend
+// is TRANSIENT_FAILURE to the child policy for baseEjectionTime times the
+// number of times it was ejected in a row, up to maxEjectionTime; the count
+// goes down again for every interval the backend is not ejected. At most
+// maxEjectionPercent of the backends are ejected at a time, but always one.
+//
+// The SubConns also run client-side health checks with the Watch method of
+// grpc.health.v1.Health when the service config has a healthCheckConfig,
+// whatever the child policy.
+type OutlierDetectionConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// Interval is the time between ejection analyses, "10s" by default.
+	Interval string `json:"interval,omitempty"`
+	// BaseEjectionTime is the ejection time of a backend ejected once, "30s"
+	// by default.
+	BaseEjectionTime string `json:"baseEjectionTime,omitempty"`
+	// MaxEjectionTime caps the ejection time, "300s" by default.
+	MaxEjectionTime string `json:"maxEjectionTime,omitempty"`
+	// MaxEjectionPercent is the share of backends that may be ejected at a
+	// time, 10 by default.
+	MaxEjectionPercent int `json:"maxEjectionPercent,omitempty"`
+	// FailurePercentageEjection ejects backends by their failed calls.
+	FailurePercentageEjection *FailurePercentageEjection `json:"failurePercentageEjection,omitempty"`
+	// LatencyEjection ejects backends by their latency.
+	LatencyEjection *LatencyEjection `json:"latencyEjection,omitempty"`
+	// ChildPolicy is the policy picking among the backends that are not
+	// ejected, the first registered one of the list.
+	ChildPolicy []map[string]json.RawMessage `json:"childPolicy"`
+
+	interval, baseEjectionTime, maxEjectionTime time.Duration
+	child                                       balancer.Builder
+	childConfig                                 serviceconfig.LoadBalancingConfig
+}
+
+// FailurePercentageEjection ejects the backends whose share of failed calls
+// is above a threshold. Calls failing with UNKNOWN, DEADLINE_EXCEEDED,
+// INTERNAL, UNAVAILABLE or DATA_LOSS count as failed; the other codes are up
+// to the caller.
+type FailurePercentageEjection struct {
+	// Threshold is the percentage of failed calls above which a backend is
+	// ejected, 50 by default.
+	Threshold int `json:"threshold,omitempty"`
+	// RequestVolume is the number of calls a backend needs in an interval to
+	// be considered, 20 by default.
+	RequestVolume int `json:"requestVolume,omitempty"`
+}
+
+// LatencyEjection ejects the backends whose mean latency is above a multiple
+// of the median of the mean latencies of the backends. It needs at least two
+// backends with enough calls, and suits unary calls best, as the latency of a
+// stream is its lifetime.
+type LatencyEjection struct {
+	// Threshold is the ratio of the mean latency of a backend to the median
+	// above which it is ejected, 3 by default.
+	Threshold float64 `json:"threshold,omitempty"`
+	// RequestVolume is the number of calls a backend needs in an interval to
+	// be considered, 20 by default.
+	RequestVolume int `json:"requestVolume,omitempty"`
+}
+
+func init() {
+	balancer.Register(outlierBuilder{})
+}
+
+type outlierBuilder struct{}
+
+func (outlierBuilder) Name() string { return OutlierDetectionName }
+
+func (outlierBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &OutlierDetectionConfig{}
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", OutlierDetectionName, js, err)
+	}
+	durations := []struct {
+		name string
+		s    string
+		d    *time.Duration
+		def  time.Duration
+	}{
+		{"interval", cfg.Interval, &cfg.interval, DefaultOutlierInterval},
+		{"baseEjectionTime", cfg.BaseEjectionTime, &cfg.baseEjectionTime, DefaultBaseEjectionTime},
+		{"maxEjectionTime", cfg.MaxEjectionTime, &cfg.maxEjectionTime, DefaultMaxEjectionTime},
+	}
+	for _, d := range durations {
+		*d.d = d.def
+		if d.s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.s)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("%s: invalid %s %q", OutlierDetectionName, d.name, d.s)
+		}
+		*d.d = v
+	}
+	if cfg.MaxEjectionPercent == 0 {
+		cfg.MaxEjectionPercent = DefaultMaxEjectionPercent
+	}
+	if cfg.MaxEjectionPercent < 0 || cfg.MaxEjectionPercent > 100 {
+		return nil, fmt.Errorf("%s: maxEjectionPercent %d is not between 0 and 100", OutlierDetectionName, cfg.MaxEjectionPercent)
+	}
+	if f := cfg.FailurePercentageEjection; f != nil {
+		if f.Threshold == 0 {
+			f.Threshold = DefaultFailureThreshold
+		}
+		if f.RequestVolume == 0 {
+			f.RequestVolume = DefaultEjectionRequestVolume
+		}
+		if f.Threshold < 0 || f.Threshold > 100 || f.RequestVolume < 0 {
+			return nil, fmt.Errorf("%s: invalid failurePercentageEjection %+v", OutlierDetectionName, *f)
+		}
+	}
+	if l := cfg.LatencyEjection; l != nil {
+		if l.Threshold == 0 {
+			l.Threshold = DefaultLatencyThreshold
+		}
+		if l.RequestVolume == 0 {
+			l.RequestVolume = DefaultEjectionRequestVolume
+		}
+		if l.Threshold <= 1 || l.RequestVolume < 0 {
+			return nil, fmt.Errorf("%s: invalid latencyEjection %+v", OutlierDetectionName, *l)
+		}
+	}
+	for _, policy := range cfg.ChildPolicy {
+		for name, raw := range policy {
+			bb := balancer.Get(name)
+			if bb == nil {
+				continue
+			}
+			cfg.child = bb
+			if parser, ok := bb.(balancer.ConfigParser); ok {
+				childConfig, err := parser.ParseConfig(raw)
+				if err != nil {
+					return nil, fmt.Errorf("%s: child policy: %v", OutlierDetectionName, err)
+				}
+				cfg.childConfig = childConfig
+			}
+			return cfg, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no registered policy in childPolicy %s", OutlierDetectionName, js)
+}
+
+func (outlierBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	b := &outlierBalancer{
+		cc:       cc,
+		opts:     opts,
+		subConns: make(map[balancer.SubConn]*outlierSubConn),
+		addrs:    make(map[string]*addrStats),
+	}
+	return b
+}
+
+// outlierBalancer wraps the child policy. The SubConns of the child are
+// wrapped too, so that ejected ones look TRANSIENT_FAILURE to the child, and
+// so are its pickers, to count the calls of every address.
+type outlierBalancer struct {
+	cc   balancer.ClientConn
+	opts balancer.BuildOptions
+
+	// mu serializes the calls into the child from gRPC and from the timer.
+	mu        sync.Mutex
+	child     balancer.Balancer
+	childName string
+	cfg       *OutlierDetectionConfig
+	timer     *time.Timer
+	closed    bool
+
+	// scMu guards the maps, also used by the child's calls into cc.
+	scMu     sync.Mutex
+	subConns map[balancer.SubConn]*outlierSubConn // by the SubConn of gRPC
+	addrs    map[string]*addrStats                // of the resolved addresses
+}
+
+// addrStats are the calls of an address in the current interval and its
+// ejection state.
+type addrStats struct {
+	calls, failures, latency int64 // latency is the sum in nanoseconds
+
+	ejected    bool
+	ejectedAt  time.Time
+	multiplier int
+}
+
+func (s *addrStats) record(err error, latency time.Duration) {
+	atomic.AddInt64(&s.calls, 1)
+	atomic.AddInt64(&s.latency, int64(latency))
+	switch status.Code(err) {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss:
+		atomic.AddInt64(&s.failures, 1)
+	}
+}
+
+type outlierSubConn struct {
+	balancer.SubConn
+	addr  string
+	stats *addrStats
+	// state is the latest state from gRPC, passed on once the SubConn is
+	// no longer ejected.
+	state   balancer.SubConnState
+	ejected bool
+}
+
+func (b *outlierBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	cfg, ok := s.BalancerConfig.(*OutlierDetectionConfig)
+	if !ok {
+		return fmt.Errorf("%s: unexpected config %T", OutlierDetectionName, s.BalancerConfig)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child == nil || b.childName != cfg.child.Name() {
+		if b.child != nil {
+			b.child.Close()
+		}
+		b.child, b.childName = cfg.child.Build(&outlierClientConn{ClientConn: b.cc, b: b}, b.opts), cfg.child.Name()
+	}
+	// A new interval applies from the next analysis on.
+	b.cfg = cfg
+	if b.timer == nil {
+		b.timer = time.AfterFunc(cfg.interval, b.analyze)
+	}
+
+	b.scMu.Lock()
+	resolved := make(map[string]bool)
+	for _, a := range s.ResolverState.Addresses {
+		resolved[a.Addr] = true
+		if _, ok := b.addrs[a.Addr]; !ok {
+			b.addrs[a.Addr] = &addrStats{}
+		}
+	}
+	for addr := range b.addrs {
+		if !resolved[addr] {
+			delete(b.addrs, addr)
+		}
+	}
+	b.scMu.Unlock()
+
+	return b.child.UpdateClientConnState(balancer.ClientConnState{ResolverState: s.ResolverState, BalancerConfig: cfg.childConfig})
+}
+
+func (b *outlierBalancer) ResolverError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.child != nil {
+		b.child.ResolverError(err)
+	}
+}
+
+func (b *outlierBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.SubConnState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scMu.Lock()
+	osc, ok := b.subConns[sc]
+	if !ok {
+		b.scMu.Unlock()
+		return
+	}
+	osc.state = state
+	if state.ConnectivityState == connectivity.Shutdown {
+		delete(b.subConns, sc)
+	}
+	ejected := osc.ejected && state.ConnectivityState != connectivity.Shutdown
+	b.scMu.Unlock()
+	if ejected {
+		return
+	}
+	if b.child != nil {
+		b.child.UpdateSubConnState(osc, state)
+	}
+}
+
+func (b *outlierBalancer) ExitIdle() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ei, ok := b.child.(balancer.ExitIdler); ok {
+		ei.ExitIdle()
+	}
+}
+
+func (b *outlierBalancer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if b.child != nil {
+		b.child.Close()
+	}
+}
+
+// analyze ejects and returns backends by the calls of the last interval.
+func (b *outlierBalancer) analyze() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	defer func() { b.timer = time.AfterFunc(b.cfg.interval, b.analyze) }()
+
+	now := time.Now()
+	b.scMu.Lock()
+	type interval struct {
+		addr                     string
+		s                        *addrStats
+		calls, failures, latency int64
+	}
+	var all []interval
+	ejected := 0
+	for addr, s := range b.addrs {
+		all = append(all, interval{
+			addr:     addr,
+			s:        s,
+			calls:    atomic.SwapInt64(&s.calls, 0),
+			failures: atomic.SwapInt64(&s.failures, 0),
+			latency:  atomic.SwapInt64(&s.latency, 0),
+		})
+		if s.ejected {
+			ejected++
+		}
+	}
+	b.scMu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].addr < all[j].addr })
+
+	eject := func(iv interval, reason string) {
+		if iv.s.ejected || ejected*100 >= b.cfg.MaxEjectionPercent*len(all) && ejected > 0 {
+			return
+		}
+		ejected++
+		iv.s.ejected, iv.s.ejectedAt = true, now
+		iv.s.multiplier++
+		log.Printf("loadbalancing: ejecting %s for %v: %s", iv.addr, b.ejectionTime(iv.s), reason)
+		b.setEjected(iv.addr, true)
+	}
+	if f := b.cfg.FailurePercentageEjection; f != nil {
+		for _, iv := range all {
+			if iv.calls >= int64(f.RequestVolume) && iv.calls > 0 && iv.failures*100 > int64(f.Threshold)*iv.calls {
+				eject(iv, fmt.Sprintf("%d of %d calls failed", iv.failures, iv.calls))
+			}
+		}
+	}
+	if l := b.cfg.LatencyEjection; l != nil {
+		var means []float64
+		for _, iv := range all {
+			if iv.calls >= int64(l.RequestVolume) && iv.calls > 0 {
+				means = append(means, float64(iv.latency)/float64(iv.calls))
+			}
+		}
+		if len(means) >= 2 {
+			sort.Float64s(means)
+			median := means[len(means)/2]
+			if len(means)%2 == 0 {
+				median = (means[len(means)/2-1] + means[len(means)/2]) / 2
+			}
+			for _, iv := range all {
+				if iv.calls < int64(l.RequestVolume) || iv.calls == 0 {
+					continue
+				}
+				if mean := float64(iv.latency) / float64(iv.calls); mean > l.Threshold*median {
+					eject(iv, fmt.Sprintf("mean latency %v is above %.1f times the median %v",
+						time.Duration(mean), l.Threshold, time.Duration(median)))
+				}
+			}
+		}
+	}
+
+	for _, iv := range all {
+		switch {
+		case !iv.s.ejected && iv.s.multiplier > 0:
+			iv.s.multiplier--
+		case iv.s.ejected && iv.s.ejectedAt != now && !now.Before(iv.s.ejectedAt.Add(b.ejectionTime(iv.s))):
+			iv.s.ejected = false
+			log.Printf("loadbalancing: returning %s after its ejection", iv.addr)
+			b.setEjected(iv.addr, false)
+		}
+	}
+}
+
+// ejectionTime returns how long s is ejected.
+func (b *outlierBalancer) ejectionTime(s *addrStats) time.Duration {
+	max := b.cfg.maxEjectionTime
+	if max < b.cfg.baseEjectionTime {
+		max = b.cfg.baseEjectionTime
+	}
+	if d := b.cfg.baseEjectionTime * time.Duration(s.multiplier); d < max {
+		return d
+	}
+	return max
+}
+
+// setEjected ejects or returns the SubConns of addr. It is called with mu
+// held.
+func (b *outlierBalancer) setEjected(addr string, ejected bool) {
+	var updates []*outlierSubConn
+	b.scMu.Lock()
+	for _, osc := range b.subConns {
+		if osc.addr == addr && osc.ejected != ejected {
+			osc.ejected = ejected
+			updates = append(updates, osc)
+		}
+	}
+	b.scMu.Unlock()
+	for _, osc := range updates {
+		if ejected {
+			b.child.UpdateSubConnState(osc, balancer.SubConnState{ConnectivityState: connectivity.TransientFailure, ConnectionError: errEjected})
+		} else {
+			b.scMu.Lock()
+			state := osc.state
+			b.scMu.Unlock()
+			b.child.UpdateSubConnState(osc, state)
+		}
+	}
+}
+
+// outlierClientConn is the ClientConn of the child policy.
+type outlierClientConn struct {
+	balancer.ClientConn
+	b *outlierBalancer
+}
+
+func (cc *outlierClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	// Health checks run when the service config has a healthCheckConfig.
+	opts.HealthCheckEnabled = true
+	sc, err := cc.ClientConn.NewSubConn(addrs, opts)
+	if err != nil {
+		return nil, err
+	}
+	osc := &outlierSubConn{SubConn: sc, addr: addrs[0].Addr}
+	b := cc.b
+	b.scMu.Lock()
+	defer b.scMu.Unlock()
+	osc.stats = b.addrs[osc.addr]
+	if osc.stats == nil {
+		// Not a resolved address; its calls are not analyzed.
+		osc.stats = &addrStats{}
+	}
+	osc.ejected = osc.stats.ejected
+	b.subConns[sc] = osc
+	return osc, nil
+}
+
+func (cc *outlierClientConn) RemoveSubConn(sc balancer.SubConn) {
+	if osc, ok := sc.(*outlierSubConn); ok {
+		cc.ClientConn.RemoveSubConn(osc.SubConn)
+	}
+}
+
+func (cc *outlierClientConn) UpdateAddresses(sc balancer.SubConn, addrs []resolver.Address) {
+	if osc, ok := sc.(*outlierSubConn); ok {
+		cc.ClientConn.UpdateAddresses(osc.SubConn, addrs)
+	}
+}
+
+func (cc *outlierClientConn) UpdateState(s balancer.State) {
+	if s.Picker != nil {
+		s.Picker = &outlierPicker{child: s.Picker}
+	}
+	cc.ClientConn.UpdateState(s)
+}
+
+// outlierPicker counts the calls of the picks of the child.
+type outlierPicker struct {
+	child balancer.Picker
+}
+
+func (p *outlierPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	res, err := p.child.Pick(info)
+	if err != nil {
+		return res, err
+	}
+	osc, ok := res.SubConn.(*outlierSubConn)
+	if !ok {
+		return res, nil
+	}
+	res.SubConn = osc.SubConn
+	start, done := time.Now(), res.Done
+	res.Done = func(di balancer.DoneInfo) {
+		osc.stats.record(di.Err, time.Since(start))
+		if done != nil {
+			done(di)
+		}
+	}
+	return res, nil
+}
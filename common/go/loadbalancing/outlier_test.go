@@ -0,0 +1,96 @@
+package loadbalancing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func outlierConfig(ejection, child string) string {
+	return fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {"interval": "100ms", "baseEjectionTime": "500ms", "maxEjectionPercent": 50, %s, "childPolicy": [{"%s": {}}]}}], "healthCheckConfig": {"serviceName": ""}}`,
+		OutlierDetectionName, ejection, child)
+}
+
+func TestOutlierFailures(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr}, {Addr: b2.addr}})
+	client := dial(t, registry, outlierConfig(`"failurePercentageEjection": {"threshold": 50, "requestVolume": 5}`, "round_robin"))
+	eventually(t, "both backends", client, 10, func(got map[string]int) bool { return got[b1.addr] > 0 && got[b2.addr] > 0 })
+
+	// The failing backend is ejected, and the child policy sends every call
+	// to the other one.
+	b1.fail(true)
+	eventually(t, "only the healthy backend", client, 10, func(got map[string]int) bool { return got[b2.addr] == 10 })
+
+	// It comes back after the ejection time.
+	b1.fail(false)
+	eventually(t, "both backends again", client, 10, func(got map[string]int) bool { return got[b1.addr] > 0 && got[b2.addr] > 0 })
+}
+
+func TestOutlierLatency(t *testing.T) {
+	fast1, fast2, slow := startBackend(t), startBackend(t), startSlowBackend(t, 30*time.Millisecond)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: fast1.addr}, {Addr: fast2.addr}, {Addr: slow.addr}})
+	client := dial(t, registry, outlierConfig(`"latencyEjection": {"threshold": 3, "requestVolume": 3}`, "round_robin"))
+
+	eventually(t, "only the fast backends", client, 12, func(got map[string]int) bool {
+		return got[fast1.addr] > 0 && got[fast2.addr] > 0 && got[slow.addr] == 0
+	})
+}
+
+func TestOutlierHealth(t *testing.T) {
+	b := startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: b.addr}})
+	// pick_first has no health checks of its own.
+	client := dial(t, registry, outlierConfig(`"failurePercentageEjection": {}`, "pick_first"))
+	eventually(t, "the backend", client, 1, func(got map[string]int) bool { return got[b.addr] == 1 })
+
+	call := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{})
+		return err
+	}
+	b.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for i := 0; call() == nil; i++ {
+		if i == 200 {
+			t.Fatalf("calls to a NOT_SERVING backend succeed, want them to fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	eventually(t, "the backend again", client, 1, func(got map[string]int) bool { return got[b.addr] == 1 })
+}
+
+func TestOutlierConfig(t *testing.T) {
+	bb := balancerBuilder(t, OutlierDetectionName)
+	cfg, err := bb.ParseConfig([]byte(`{"failurePercentageEjection": {}, "childPolicy": [{"unknown": {}}, {"least_request": {"choiceCount": 3}}]}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	got := cfg.(*OutlierDetectionConfig)
+	if got.interval != DefaultOutlierInterval || got.MaxEjectionPercent != DefaultMaxEjectionPercent ||
+		got.FailurePercentageEjection.Threshold != DefaultFailureThreshold || got.LatencyEjection != nil {
+		t.Errorf("ParseConfig = %+v, want the defaults", got)
+	}
+	if got.child.Name() != LeastRequestName || got.childConfig.(*LeastRequestConfig).ChoiceCount != 3 {
+		t.Errorf("ParseConfig child = %s %+v, want least_request with choiceCount 3", got.child.Name(), got.childConfig)
+	}
+	for _, js := range []string{
+		`{"childPolicy": [{"unknown": {}}]}`,
+		`{"interval": "-1s", "childPolicy": [{"round_robin": {}}]}`,
+		`{"latencyEjection": {"threshold": 0.5}, "childPolicy": [{"round_robin": {}}]}`,
+		`{"childPolicy": [{"least_request": {"choiceCount": 1}}]}`,
+	} {
+		if _, err := bb.ParseConfig([]byte(js)); err == nil {
+			t.Errorf("ParseConfig(%s) succeeded, want an error", js)
+		}
+	}
+}
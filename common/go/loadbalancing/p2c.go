@@ -0,0 +1,223 @@
+package loadbalancing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// Names of the power of two choices policies.
+const (
+	LeastRequestName = "least_request"
+	PeakEWMAName     = "peak_ewma"
+)
+
+// Defaults of the power of two choices policies.
+const (
+	DefaultChoiceCount = 2
+	DefaultDecayTime   = 10 * time.Second
+)
+
+// LeastRequestConfig is the config of the least_request policy, which picks
+// the backend with the fewest calls in flight among choiceCount random ones,
+//
+//	{"loadBalancingConfig": [{"least_request": {"choiceCount": 2}}]}
+type LeastRequestConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// ChoiceCount is the number of backends compared per call, 2 by default.
+	ChoiceCount int `json:"choiceCount,omitempty"`
+}
+
+// PeakEWMAConfig is the config of the peak_ewma policy, which picks the
+// backend with the lowest latency times calls in flight among choiceCount
+// random ones,
+//
+//	{"loadBalancingConfig": [{"peak_ewma": {"choiceCount": 2, "decayTime": "10s"}}]}
+//
+// The latency of a backend is a moving average that jumps to a slower call
+// at once and decays to faster calls, and to zero while the backend gets no
+// calls, over decayTime.
+type PeakEWMAConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// ChoiceCount is the number of backends compared per call, 2 by default.
+	ChoiceCount int `json:"choiceCount,omitempty"`
+	// DecayTime is the decay time of the latency average, e.g. "10s"; 10
+	// seconds by default.
+	DecayTime string `json:"decayTime,omitempty"`
+
+	decay time.Duration
+}
+
+func init() {
+	balancer.Register(&builder{
+		name: LeastRequestName,
+		parseConfig: func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+			cfg := &LeastRequestConfig{}
+			if err := json.Unmarshal(js, cfg); err != nil {
+				return nil, fmt.Errorf("%s: invalid config %s: %v", LeastRequestName, js, err)
+			}
+			if cfg.ChoiceCount == 0 {
+				cfg.ChoiceCount = DefaultChoiceCount
+			}
+			if cfg.ChoiceCount < 2 {
+				return nil, fmt.Errorf("%s: choiceCount %d is less than 2", LeastRequestName, cfg.ChoiceCount)
+			}
+			return cfg, nil
+		},
+		newPicker: newLeastRequestPicker,
+	})
+	balancer.Register(&builder{
+		name: PeakEWMAName,
+		parseConfig: func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+			cfg := &PeakEWMAConfig{}
+			if err := json.Unmarshal(js, cfg); err != nil {
+				return nil, fmt.Errorf("%s: invalid config %s: %v", PeakEWMAName, js, err)
+			}
+			if cfg.ChoiceCount == 0 {
+				cfg.ChoiceCount = DefaultChoiceCount
+			}
+			if cfg.ChoiceCount < 2 {
+				return nil, fmt.Errorf("%s: choiceCount %d is less than 2", PeakEWMAName, cfg.ChoiceCount)
+			}
+			cfg.decay = DefaultDecayTime
+			if cfg.DecayTime != "" {
+				d, err := time.ParseDuration(cfg.DecayTime)
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("%s: invalid decayTime %q", PeakEWMAName, cfg.DecayTime)
+				}
+				cfg.decay = d
+			}
+			return cfg, nil
+		},
+		newPicker: newPeakEWMAPicker,
+	})
+}
+
+func newLeastRequestPicker(b *lbBalancer, info base.PickerBuildInfo) balancer.Picker {
+	cfg, ok := b.lbConfig().(*LeastRequestConfig)
+	if !ok {
+		cfg = &LeastRequestConfig{ChoiceCount: DefaultChoiceCount}
+	}
+	return newP2CPicker(b, info, cfg.ChoiceCount, 0)
+}
+
+func newPeakEWMAPicker(b *lbBalancer, info base.PickerBuildInfo) balancer.Picker {
+	cfg, ok := b.lbConfig().(*PeakEWMAConfig)
+	if !ok {
+		cfg = &PeakEWMAConfig{ChoiceCount: DefaultChoiceCount, decay: DefaultDecayTime}
+	}
+	return newP2CPicker(b, info, cfg.ChoiceCount, cfg.decay)
+}
+
+// newP2CPicker returns a picker comparing the load of choices random ready
+// SubConns. Without a decay the load is the number of calls in flight, with
+// one it is the peak EWMA latency times the calls in flight.
+func newP2CPicker(b *lbBalancer, info base.PickerBuildInfo, choices int, decay time.Duration) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &p2cPicker{choices: choices, decay: decay}
+	for sc := range info.ReadySCs {
+		p.subConns = append(p.subConns, sc)
+		p.loads = append(p.loads, b.load(sc))
+	}
+	return p
+}
+
+type p2cPicker struct {
+	subConns []balancer.SubConn
+	loads    []*load
+	choices  int
+	decay    time.Duration
+}
+
+func (p *p2cPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	now := time.Now()
+	best := -1
+	var bestCost float64
+	consider := func(i int) {
+		if c := p.cost(p.loads[i], now); best == -1 || c < bestCost {
+			best, bestCost = i, c
+		}
+	}
+	if p.choices >= len(p.subConns) {
+		// Start at a random SubConn so that ties do not favour the first one.
+		start := rand.Intn(len(p.subConns))
+		for i := range p.subConns {
+			consider((start + i) % len(p.subConns))
+		}
+	} else {
+		for i := 0; i < p.choices; i++ {
+			consider(rand.Intn(len(p.subConns)))
+		}
+	}
+
+	l := p.loads[best]
+	atomic.AddInt64(&l.inflight, 1)
+	return balancer.PickResult{
+		SubConn: p.subConns[best],
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(&l.inflight, -1)
+			if p.decay > 0 {
+				l.observe(time.Since(now), time.Now(), p.decay)
+			}
+		},
+	}, nil
+}
+
+// cost returns the load of l at now.
+func (p *p2cPicker) cost(l *load, now time.Time) float64 {
+	inflight := atomic.LoadInt64(&l.inflight)
+	if p.decay == 0 {
+		return float64(inflight)
+	}
+	latency := l.latency(now, p.decay)
+	if latency == 0 && inflight > 0 {
+		// The latency of a new backend is unknown until its first call
+		// returns; do not send it every call meanwhile.
+		return math.MaxInt32 + float64(inflight)
+	}
+	return latency * float64(inflight+1)
+}
+
+// load is the load of a SubConn: its calls in flight and the peak EWMA of its
+// latency in nanoseconds.
+type load struct {
+	inflight int64
+
+	mu    sync.Mutex
+	ewma  float64
+	stamp time.Time
+}
+
+// observe adds a call that took rtt and returned at now to the average.
+func (l *load) observe(rtt time.Duration, now time.Time, decay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r := float64(rtt); r > l.ewma {
+		l.ewma = r
+	} else {
+		w := math.Exp(-float64(now.Sub(l.stamp)) / float64(decay))
+		l.ewma = l.ewma*w + r*(1-w)
+	}
+	l.stamp = now
+}
+
+// latency returns the average at now, decayed towards zero for the time
+// without calls since the last one.
+func (l *load) latency(now time.Time, decay time.Duration) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stamp.IsZero() || !now.After(l.stamp) {
+		return l.ewma
+	}
+	return l.ewma * math.Exp(-float64(now.Sub(l.stamp))/float64(decay))
+}
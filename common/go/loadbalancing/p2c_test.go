@@ -0,0 +1,91 @@
+package loadbalancing
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"google.golang.org/grpc/balancer"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+)
+
+// balancerBuilder returns the registered builder of the policy name.
+func balancerBuilder(t *testing.T, name string) balancer.ConfigParser {
+	t.Helper()
+	bb, ok := balancer.Get(name).(balancer.ConfigParser)
+	if !ok {
+		t.Fatalf("policy %s is not registered", name)
+	}
+	return bb
+}
+
+// concurrentCalls makes n calls from each of workers goroutines and counts
+// them by backend.
+func concurrentCalls(t *testing.T, client ecpb.EchoClient, workers, n int) map[string]int {
+	t.Helper()
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := calls(t, client, n)
+			mu.Lock()
+			defer mu.Unlock()
+			for addr, c := range got {
+				seen[addr] += c
+			}
+		}()
+	}
+	wg.Wait()
+	return seen
+}
+
+func TestLeastRequest(t *testing.T) {
+	fast, slow := startBackend(t), startSlowBackend(t, 20*time.Millisecond)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: fast.addr}, {Addr: slow.addr}})
+	client := dial(t, registry, fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {}}]}`, LeastRequestName))
+
+	eventually(t, "both backends", client, 20, func(got map[string]int) bool { return got[fast.addr] > 0 && got[slow.addr] > 0 })
+	// The calls to the slow backend stay in flight longer, so it gets fewer.
+	got := concurrentCalls(t, client, 8, 25)
+	if got["error"] != 0 || got[fast.addr] < 3*got[slow.addr] {
+		t.Errorf("calls went to %v, want most to the fast backend %s", got, fast.addr)
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+	fast, slow := startBackend(t), startSlowBackend(t, 20*time.Millisecond)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: fast.addr}, {Addr: slow.addr}})
+	client := dial(t, registry, fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {"decayTime": "1m"}}]}`, PeakEWMAName))
+
+	eventually(t, "both backends", client, 20, func(got map[string]int) bool { return got[fast.addr] > 0 && got[slow.addr] > 0 })
+	// Even one call at a time, the latency keeps the calls off the slow backend.
+	if got := calls(t, client, 50); got[fast.addr] < 45 {
+		t.Errorf("calls went to %v, want most to the fast backend %s", got, fast.addr)
+	}
+}
+
+func TestP2CConfig(t *testing.T) {
+	bb := balancerBuilder(t, PeakEWMAName)
+	cfg, err := bb.ParseConfig([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("ParseConfig({}) failed: %v", err)
+	}
+	if got := cfg.(*PeakEWMAConfig); got.ChoiceCount != DefaultChoiceCount || got.decay != DefaultDecayTime {
+		t.Errorf("ParseConfig({}) = %+v, want the defaults", got)
+	}
+	for _, js := range []string{`{"choiceCount": 1}`, `{"decayTime": "soon"}`, `{"decayTime": "-1s"}`} {
+		if _, err := bb.ParseConfig([]byte(js)); err == nil {
+			t.Errorf("ParseConfig(%s) succeeded, want an error", js)
+		}
+	}
+	if _, err := balancerBuilder(t, LeastRequestName).ParseConfig([]byte(`{"choiceCount": 1}`)); err == nil {
+		t.Errorf("least_request ParseConfig with choiceCount 1 succeeded, want an error")
+	}
+}
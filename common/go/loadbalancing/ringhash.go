@@ -0,0 +1,165 @@
+package loadbalancing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// RingHashName is the name of the consistent hashing policy.
+const RingHashName = "ring_hash"
+
+// Defaults of the ring_hash policy.
+const (
+	// DefaultHashHeader is the metadata key of the hash key.
+	DefaultHashHeader = "x-hash-key"
+	// DefaultReplicas is the number of points of a backend of weight 1 on
+	// the ring.
+	DefaultReplicas = 100
+)
+
+// RingHashConfig is the config of the ring_hash policy, which sends all calls
+// with the same hash key to the same backend,
+//
+//	{"loadBalancingConfig": [{"ring_hash": {"hashHeader": "x-order-id"}}]}
+//
+// Each backend has replicas points per unit of weight on a ring of hashes,
+// and a call goes to the backend of the first point at or after the hash of
+// its key. Adding or removing a backend only moves the keys of its points.
+// Calls without a key go to a random backend.
+type RingHashConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// HashHeader is the metadata key of the hash key, "x-hash-key" by
+	// default. A key set with WithHashKey takes precedence.
+	HashHeader string `json:"hashHeader,omitempty"`
+	// Replicas is the number of points of a backend of weight 1, 100 by
+	// default.
+	Replicas int `json:"replicas,omitempty"`
+}
+
+func init() {
+	balancer.Register(&builder{
+		name: RingHashName,
+		parseConfig: func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+			cfg := &RingHashConfig{}
+			if err := json.Unmarshal(js, cfg); err != nil {
+				return nil, fmt.Errorf("%s: invalid config %s: %v", RingHashName, js, err)
+			}
+			if cfg.HashHeader == "" {
+				cfg.HashHeader = DefaultHashHeader
+			}
+			// Metadata keys are lower case.
+			cfg.HashHeader = strings.ToLower(cfg.HashHeader)
+			if cfg.Replicas == 0 {
+				cfg.Replicas = DefaultReplicas
+			}
+			if cfg.Replicas < 1 || cfg.Replicas > 10000 {
+				return nil, fmt.Errorf("%s: replicas %d is not between 1 and 10000", RingHashName, cfg.Replicas)
+			}
+			return cfg, nil
+		},
+		newPicker: newRingHashPicker,
+	})
+}
+
+type hashKey struct{}
+
+// WithHashKey returns a context whose calls go to the backend of key with the
+// ring_hash policy, e.g. the ID of an order.
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+// HashKeyFromContext returns the hash key set with WithHashKey.
+func HashKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKey{}).(string)
+	return key, ok && key != ""
+}
+
+func newRingHashPicker(b *lbBalancer, info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	cfg, ok := b.lbConfig().(*RingHashConfig)
+	if !ok {
+		cfg = &RingHashConfig{HashHeader: DefaultHashHeader, Replicas: DefaultReplicas}
+	}
+	p := &ringHashPicker{header: cfg.HashHeader}
+	for sc, sci := range info.ReadySCs {
+		e := b.endpoint(sci.Address)
+		p.subConns = append(p.subConns, sc)
+		// The points depend on the address only, so a backend keeps them
+		// across resolver updates and new SubConns.
+		for i := 0; i < cfg.Replicas*int(e.Weight); i++ {
+			p.ring = append(p.ring, ringPoint{hash: hash(e.Addr + "#" + strconv.Itoa(i)), sc: sc, addr: e.Addr})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool {
+		if p.ring[i].hash != p.ring[j].hash {
+			return p.ring[i].hash < p.ring[j].hash
+		}
+		// Colliding points are ordered by address to stay the same in
+		// every picker.
+		return p.ring[i].addr < p.ring[j].addr
+	})
+	return p
+}
+
+type ringPoint struct {
+	hash uint64
+	sc   balancer.SubConn
+	addr string
+}
+
+type ringHashPicker struct {
+	header   string
+	ring     []ringPoint
+	subConns []balancer.SubConn
+}
+
+func (p *ringHashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	key, ok := HashKeyFromContext(info.Ctx)
+	if !ok {
+		md, _ := metadata.FromOutgoingContext(info.Ctx)
+		if v := md.Get(p.header); len(v) > 0 && v[0] != "" {
+			key, ok = v[0], true
+		}
+	}
+	if !ok {
+		return balancer.PickResult{SubConn: p.subConns[rand.Intn(len(p.subConns))]}, nil
+	}
+	return balancer.PickResult{SubConn: p.lookup(hash(key))}, nil
+}
+
+// lookup returns the SubConn of the first point at or after h.
+func (p *ringHashPicker) lookup(h uint64) balancer.SubConn {
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	if i == len(p.ring) {
+		i = 0
+	}
+	return p.ring[i].sc
+}
+
+// hash returns the 64-bit FNV-1a hash of s, mixed so that similar strings
+// spread over the whole ring.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
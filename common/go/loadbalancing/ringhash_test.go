@@ -0,0 +1,168 @@
+package loadbalancing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"google.golang.org/grpc"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+)
+
+// keyed returns the backend of each of n keys, sent in the x-order-id
+// metadata.
+func keyed(t *testing.T, client ecpb.EchoClient, n int) map[string]string {
+	t.Helper()
+	backends := make(map[string]string)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("order-%d", i)
+		ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), "x-order-id", key), 2*time.Second)
+		resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("UnaryEcho(%s) failed: %v", key, err)
+		}
+		backends[key] = resp.Message
+	}
+	return backends
+}
+
+// moved returns the keys on a different backend in after than in before.
+func moved(before, after map[string]string) []string {
+	var keys []string
+	for key, addr := range before {
+		if after[key] != addr {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// waitKeyed returns the backends of the keys once cond holds for them. The
+// picker may change during the calls that meet cond, so it calls again.
+func waitKeyed(t *testing.T, what string, client ecpb.EchoClient, n int, cond func(map[string]string) bool) map[string]string {
+	t.Helper()
+	for i := 0; ; i++ {
+		got := keyed(t, client, n)
+		if cond(got) {
+			return keyed(t, client, n)
+		}
+		if i == 200 {
+			t.Fatalf("keys went to %v, want %s", got, what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRingHashSticky(t *testing.T) {
+	const n = 200
+	b1, b2, b3, b4 := startBackend(t), startBackend(t), startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr}, {Addr: b2.addr}, {Addr: b3.addr}})
+	client := dial(t, registry, fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {"hashHeader": "X-Order-ID"}}]}`, RingHashName))
+
+	initial := waitKeyed(t, "all three backends", client, n, func(got map[string]string) bool {
+		seen := map[string]bool{}
+		for _, addr := range got {
+			seen[addr] = true
+		}
+		return len(seen) == 3
+	})
+	if again := keyed(t, client, n); len(moved(initial, again)) != 0 {
+		t.Fatalf("keys %v moved without a change of backends", moved(initial, again))
+	}
+
+	// A new backend takes about a quarter of the keys, from all the others,
+	// and no other key moves.
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr}, {Addr: b2.addr}, {Addr: b3.addr}, {Addr: b4.addr}})
+	added := waitKeyed(t, "keys on the new backend", client, n, func(got map[string]string) bool {
+		for _, addr := range got {
+			if addr == b4.addr {
+				return true
+			}
+		}
+		return false
+	})
+	keys := moved(initial, added)
+	for _, key := range keys {
+		if added[key] != b4.addr {
+			t.Errorf("key %s moved from %s to %s, want only moves to the new backend %s", key, initial[key], added[key], b4.addr)
+		}
+	}
+	if len(keys) < n/8 || len(keys) > n/2 {
+		t.Errorf("%d of %d keys moved to the new backend, want about a quarter", len(keys), n)
+	}
+
+	// Removing a backend only moves its keys.
+	registry.Set("echo", []discovery.Endpoint{{Addr: b2.addr}, {Addr: b3.addr}, {Addr: b4.addr}})
+	removed := waitKeyed(t, "no keys on the removed backend", client, n, func(got map[string]string) bool {
+		for _, addr := range got {
+			if addr == b1.addr {
+				return false
+			}
+		}
+		return true
+	})
+	for _, key := range moved(added, removed) {
+		if added[key] != b1.addr {
+			t.Errorf("key %s moved from %s to %s, want only the keys of the removed backend %s to move", key, added[key], removed[key], b1.addr)
+		}
+	}
+
+	// A resolver update with the same backends in another order, e.g. from
+	// a registry, moves no key.
+	registry.Set("echo", []discovery.Endpoint{{Addr: b4.addr}, {Addr: b3.addr}, {Addr: b2.addr}})
+	time.Sleep(50 * time.Millisecond)
+	if again := keyed(t, client, n); len(moved(removed, again)) != 0 {
+		t.Errorf("keys %v moved with the same backends", moved(removed, again))
+	}
+}
+
+func TestRingHashKey(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo", []discovery.Endpoint{{Addr: b1.addr}, {Addr: b2.addr}})
+	client := dial(t, registry, fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {}}]}`, RingHashName))
+	eventually(t, "both backends", client, 20, func(got map[string]int) bool { return got[b1.addr] > 0 && got[b2.addr] > 0 })
+
+	call := func(ctx context.Context) string {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+		if err != nil {
+			t.Fatalf("UnaryEcho failed: %v", err)
+		}
+		return resp.Message
+	}
+	// The key of the context and the one of the default header go to the
+	// same backend, and the context takes precedence over the header.
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("customer-%d", i)
+		want := call(WithHashKey(context.Background(), key))
+		if got := call(metadata.AppendToOutgoingContext(context.Background(), DefaultHashHeader, key)); got != want {
+			t.Errorf("key %s in the metadata went to %s, want %s as with WithHashKey", key, got, want)
+		}
+		md := metadata.AppendToOutgoingContext(context.Background(), DefaultHashHeader, "other")
+		if got := call(WithHashKey(md, key)); got != want {
+			t.Errorf("key %s with another key in the metadata went to %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestRingHashConfig(t *testing.T) {
+	bb := balancerBuilder(t, RingHashName)
+	cfg, err := bb.ParseConfig([]byte(`{"hashHeader": "X-Customer-ID"}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if got := cfg.(*RingHashConfig); got.HashHeader != "x-customer-id" || got.Replicas != DefaultReplicas {
+		t.Errorf("ParseConfig = %+v, want x-customer-id with the default replicas", got)
+	}
+	if _, err := bb.ParseConfig([]byte(`{"replicas": -1}`)); err == nil {
+		t.Errorf("ParseConfig with replicas -1 succeeded, want an error")
+	}
+}
@@ -0,0 +1,95 @@
+package loadbalancing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// WeightedLocalityName is the name of the weighted, locality-aware policy.
+const WeightedLocalityName = "weighted_locality"
+
+// WeightedLocalityConfig is the config of the weighted_locality policy,
+//
+//	{"loadBalancingConfig": [{"weighted_locality": {"zone": "zone-a"}}]}
+type WeightedLocalityConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	// Zone is the zone of the client. Calls go to the ready backends of this
+	// zone, and to the ready backends of all zones only while there are
+	// none. Without a zone all backends are used.
+	Zone string `json:"zone,omitempty"`
+}
+
+func init() {
+	balancer.Register(&builder{
+		name: WeightedLocalityName,
+		parseConfig: func(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+			cfg := &WeightedLocalityConfig{}
+			if err := json.Unmarshal(js, cfg); err != nil {
+				return nil, fmt.Errorf("%s: invalid config %s: %v", WeightedLocalityName, js, err)
+			}
+			return cfg, nil
+		},
+		newPicker: newWeightedLocalityPicker,
+	})
+}
+
+func newWeightedLocalityPicker(b *lbBalancer, info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	zone := ""
+	if cfg, ok := b.lbConfig().(*WeightedLocalityConfig); ok {
+		zone = cfg.Zone
+	}
+	var local, all []*weightedSubConn
+	for sc, sci := range info.ReadySCs {
+		e := b.endpoint(sci.Address)
+		wsc := &weightedSubConn{sc: sc, weight: int64(e.Weight)}
+		all = append(all, wsc)
+		if zone != "" && e.Zone == zone {
+			local = append(local, wsc)
+		}
+	}
+	if len(local) > 0 {
+		return &weightedPicker{subConns: local}
+	}
+	// No backend of the zone is ready, or there is no zone.
+	return &weightedPicker{subConns: all}
+}
+
+type weightedSubConn struct {
+	sc      balancer.SubConn
+	weight  int64
+	current int64
+}
+
+// weightedPicker picks with smooth weighted round robin: every pick adds the
+// weights to the current values of the SubConns and takes the SubConn with
+// the highest value, which then drops by the total weight. A SubConn of
+// weight 3 next to one of weight 1 gets 3 of every 4 calls, spread out
+// rather than in a row.
+type weightedPicker struct {
+	mu       sync.Mutex
+	subConns []*weightedSubConn
+}
+
+func (p *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *weightedSubConn
+	var total int64
+	for _, wsc := range p.subConns {
+		wsc.current += wsc.weight
+		total += wsc.weight
+		if best == nil || wsc.current > best.current {
+			best = wsc
+		}
+	}
+	best.current -= total
+	return balancer.PickResult{SubConn: best.sc}, nil
+}
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"github.com/grpc-up-and-running/samples/common/go/requestid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// RequestIDKey is the metadata key carrying the request ID.
+const RequestIDKey = requestid.Key
+
+type fieldsKey struct{}
+
+// fields are the request-scoped fields of a call.
+type fields struct {
+	requestID string
+	method    string
+	peer      string
+	traceID   string
+	// sampler decides which per-message logs of a stream are written.
+	sampler *sampler
+}
+
+func fieldsFrom(ctx context.Context) *fields {
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	return f
+}
+
+func (f *fields) attrs(ctx context.Context) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 5)
+	if f.requestID != "" {
+		attrs = append(attrs, slog.String("request_id", f.requestID))
+	}
+	attrs = append(attrs, slog.String("method", f.method))
+	if f.peer != "" {
+		attrs = append(attrs, slog.String("peer", f.peer))
+	}
+	// The principal is read when logging, so the auth interceptor may run
+	// before or after this one.
+	if principal, ok := auth.Principal(ctx); ok {
+		attrs = append(attrs, slog.String("principal", principal))
+	}
+	if f.traceID != "" {
+		attrs = append(attrs, slog.String("trace_id", f.traceID))
+	}
+	return attrs
+}
+
+func (l *Logging) newFields(ctx context.Context, method string) *fields {
+	f := &fields{method: method}
+	if p, ok := peer.FromContext(ctx); ok {
+		f.peer = p.Addr.String()
+	}
+	// The ID of the requestid interceptors wins over the one the client sent,
+	// as they may have replaced it.
+	f.requestID, _ = requestid.FromContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDKey); len(v) > 0 && f.requestID == "" {
+			f.requestID = v[0]
+		}
+		if v := md.Get("traceparent"); len(v) > 0 {
+			f.traceID = traceIDFromTraceparent(v[0])
+		}
+	}
+	// The span of the tracing interceptors also has a trace ID when the
+	// client sent none.
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		f.traceID = sc.TraceID().String()
+	}
+	return f
+}
+
+// traceIDFromTraceparent returns the trace ID of a W3C traceparent header,
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func traceIDFromTraceparent(tp string) string {
+	parts := strings.Split(tp, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
+
+// UnaryServerInterceptor adds the request-scoped fields to the context of
+// unary calls.
+func (l *Logging) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = context.WithValue(ctx, fieldsKey{}, l.newFields(ctx, info.FullMethod))
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor adds the request-scoped fields to the context of
+// streams and logs every sampled message at the debug level of the grpc
+// package.
+func (l *Logging) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	logger := l.Logger("grpc")
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		f := l.newFields(ss.Context(), info.FullMethod)
+		f.sampler = &sampler{logging: l}
+		ctx := context.WithValue(ss.Context(), fieldsKey{}, f)
+		return handler(srv, &loggedStream{ServerStream: ss, ctx: ctx, logger: logger})
+	}
+}
+
+// loggedStream carries the context with the fields and logs the messages.
+type loggedStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	logger   *slog.Logger
+	sent     int64
+	received int64
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		if Sample(s.ctx, s.logger, slog.LevelDebug) {
+			s.logger.DebugContext(s.ctx, "stream message received", "received", s.received)
+		}
+	}
+	return err
+}
+
+func (s *loggedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		if Sample(s.ctx, s.logger, slog.LevelDebug) {
+			s.logger.DebugContext(s.ctx, "stream message sent", "sent", s.sent)
+		}
+	}
+	return err
+}
+
+// sampler counts the per-message logs of a stream.
+type sampler struct {
+	logging *Logging
+	n       atomic.Int64
+}
+
+func (s *sampler) allow() bool {
+	n := s.n.Add(1)
+	first, every := s.logging.sampleFirst.Load(), s.logging.sampleEvery.Load()
+	if n <= first {
+		return true
+	}
+	return every > 0 && (n-first)%every == 0
+}
+
+// Sample reports whether logger should write a per-message log line at level
+// for the stream of ctx. The level is checked first, so messages logged below
+// the level take no sampling slot and the first messages logged once the
+// level is lowered are written. Outside of streams only the level is checked.
+func Sample(ctx context.Context, logger *slog.Logger, level slog.Level) bool {
+	if !logger.Enabled(ctx, level) {
+		return false
+	}
+	f := fieldsFrom(ctx)
+	if f == nil || f.sampler == nil {
+		return true
+	}
+	return f.sampler.allow()
+}
@@ -0,0 +1,167 @@
+// Package logging sets up structured, leveled logging with log/slog.
+//
+// Every package of a sample gets its own logger from Logging.Logger. Its
+// level defaults to the global level and can be overridden per package, e.g.
+// "orders=debug". Levels and sampling can change while the server runs.
+//
+// Records logged with a context, e.g. logger.InfoContext(ctx, ...), carry the
+// request-scoped fields added by the server interceptors: request ID, method,
+// peer, principal and trace ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Options configures Logging.
+type Options struct {
+	// Level is the default level: debug, info, warn or error. Info if empty.
+	Level string
+	// Format is text or json. Text if empty.
+	Format string
+	// Packages overrides the level of single packages with name=level
+	// entries.
+	Packages []string
+	// SampleFirst and SampleEvery sample the per-message logs of streams:
+	// the first SampleFirst messages of every stream are logged, then every
+	// SampleEvery-th. Zero SampleEvery logs no message after the first ones.
+	SampleFirst int
+	SampleEvery int
+	// Output defaults to os.Stderr.
+	Output io.Writer
+}
+
+// Logging creates the loggers of the packages of a sample and holds their
+// levels.
+type Logging struct {
+	handler slog.Handler
+
+	mu           sync.RWMutex
+	defaultLevel slog.Level
+	packages     map[string]slog.Level
+
+	sampleFirst atomic.Int64
+	sampleEvery atomic.Int64
+}
+
+// New creates Logging writing to opts.Output.
+func New(opts Options) (*Logging, error) {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	// The handler passes every level, the levels are checked per package.
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var h slog.Handler
+	switch opts.Format {
+	case "", "text":
+		h = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		h = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return nil, fmt.Errorf("log format %q is neither text nor json", opts.Format)
+	}
+	l := &Logging{handler: h}
+	if err := l.SetLevels(opts.Level, opts.Packages); err != nil {
+		return nil, err
+	}
+	l.SetSampling(opts.SampleFirst, opts.SampleEvery)
+	return l, nil
+}
+
+// ParseLevel parses debug, info, warn or error. An empty level is info.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log level %q is not one of debug, info, warn or error", s)
+}
+
+// SetLevels replaces the default level and the package levels. Nothing
+// changes when one of them is invalid.
+func (l *Logging) SetLevels(level string, packages []string) error {
+	defaultLevel, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	levels := make(map[string]slog.Level, len(packages))
+	for _, entry := range packages {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("%q is not a package=level pair", entry)
+		}
+		lvl, err := ParseLevel(parts[1])
+		if err != nil {
+			return fmt.Errorf("package %s: %v", parts[0], err)
+		}
+		levels[parts[0]] = lvl
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaultLevel = defaultLevel
+	l.packages = levels
+	return nil
+}
+
+// SetSampling changes the sampling of per-message stream logs. Streams
+// already open keep counting their messages.
+func (l *Logging) SetSampling(first, every int) {
+	l.sampleFirst.Store(int64(first))
+	l.sampleEvery.Store(int64(every))
+}
+
+func (l *Logging) level(pkg string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if lvl, ok := l.packages[pkg]; ok {
+		return lvl
+	}
+	return l.defaultLevel
+}
+
+// Logger returns the logger of the package pkg. Its records carry a package
+// attribute.
+func (l *Logging) Logger(pkg string) *slog.Logger {
+	return slog.New(&packageHandler{logging: l, pkg: pkg, next: l.handler.WithAttrs([]slog.Attr{slog.String("package", pkg)})})
+}
+
+// packageHandler checks the level of its package and adds the request-scoped
+// fields of the context.
+type packageHandler struct {
+	logging *Logging
+	pkg     string
+	next    slog.Handler
+}
+
+func (h *packageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.logging.level(h.pkg)
+}
+
+func (h *packageHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f := fieldsFrom(ctx); f != nil {
+		r.AddAttrs(f.attrs(ctx)...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &packageHandler{logging: h.logging, pkg: h.pkg, next: h.next.WithAttrs(attrs)}
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	return &packageHandler{logging: h.logging, pkg: h.pkg, next: h.next.WithGroup(name)}
+}
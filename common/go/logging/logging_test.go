@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestPackageLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(Options{Level: "warn", Format: "json", Packages: []string{"orders=debug"}, Output: &buf})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	l.Logger("orders").Debug("order received")
+	l.Logger("grpc").Info("dropped")
+	l.Logger("grpc").Warn("kept")
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("logged %d records, want 2: %s", len(records), buf.String())
+	}
+	if records[0]["package"] != "orders" || records[0]["msg"] != "order received" {
+		t.Errorf("first record = %v", records[0])
+	}
+
+	if err := l.SetLevels("info", []string{"orders=loud"}); err == nil {
+		t.Fatalf("SetLevels() accepted an invalid level")
+	}
+	buf.Reset()
+	l.Logger("orders").Debug("still debug")
+	if len(decodeLines(t, &buf)) != 1 {
+		t.Fatalf("an invalid SetLevels() changed the levels")
+	}
+}
+
+func TestRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(Options{Format: "json", Output: &buf})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	logger := l.Logger("orders")
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-request-id", "req-1",
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/ecommerce.OrderManagement/AddOrder"}
+	_, err = l.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		logger.InfoContext(ctx, "order added", "order_id", "101")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor failed: %v", err)
+	}
+
+	records := decodeLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("logged %d records, want 1", len(records))
+	}
+	want := map[string]string{
+		"request_id": "req-1",
+		"method":     "/ecommerce.OrderManagement/AddOrder",
+		"peer":       "127.0.0.1:4242",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"order_id":   "101",
+	}
+	for k, v := range want {
+		if records[0][k] != v {
+			t.Errorf("%s = %v, want %q", k, records[0][k], v)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	l, err := New(Options{Level: "debug", SampleFirst: 2, SampleEvery: 3, Output: io.Discard})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	logger := l.Logger("orders")
+	ctx := context.WithValue(context.Background(), fieldsKey{}, &fields{sampler: &sampler{logging: l}})
+	var got []int
+	for i := 1; i <= 10; i++ {
+		if Sample(ctx, logger, slog.LevelDebug) {
+			got = append(got, i)
+		}
+	}
+	if want := []int{1, 2, 5, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sampled messages %v, want %v", got, want)
+	}
+	if !Sample(context.Background(), logger, slog.LevelDebug) {
+		t.Fatalf("Sample() outside of a stream = false, want true")
+	}
+}
+
+func TestSamplingChecksLevel(t *testing.T) {
+	l, err := New(Options{Level: "info", SampleFirst: 2, SampleEvery: 3, Output: io.Discard})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	logger := l.Logger("orders")
+	ctx := context.WithValue(context.Background(), fieldsKey{}, &fields{sampler: &sampler{logging: l}})
+	for i := 1; i <= 5; i++ {
+		if Sample(ctx, logger, slog.LevelDebug) {
+			t.Fatalf("Sample() of message %d below the level = true, want false", i)
+		}
+	}
+	if Sample(context.Background(), logger, slog.LevelDebug) {
+		t.Errorf("Sample() below the level outside of a stream = true, want false")
+	}
+
+	// The messages below the level took no slot: the first ones after the
+	// level is lowered are written.
+	if err := l.SetLevels("debug", nil); err != nil {
+		t.Fatalf("SetLevels() failed: %v", err)
+	}
+	var got []int
+	for i := 1; i <= 5; i++ {
+		if Sample(ctx, logger, slog.LevelDebug) {
+			got = append(got, i)
+		}
+	}
+	if want := []int{1, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sampled messages after lowering the level %v, want %v", got, want)
+	}
+}
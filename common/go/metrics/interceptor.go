@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Bucket boundaries of the histograms.
+var (
+	durationBounds = []float64{0, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+	sizeBounds     = []float64{0, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+)
+
+// rpcInstruments are the instruments of one side of the calls, named
+// rpc.server.* or rpc.client.* after the OpenTelemetry RPC conventions.
+type rpcInstruments struct {
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+	messages     metric.Int64Counter
+	active       metric.Int64UpDownCounter
+}
+
+func newRPCInstruments(meter metric.Meter, side string) (*rpcInstruments, error) {
+	prefix := "rpc." + side + "."
+	var ins rpcInstruments
+	var err error
+	if ins.duration, err = meter.Float64Histogram(prefix+"duration",
+		metric.WithDescription("Duration of the calls."),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(durationBounds...)); err != nil {
+		return nil, err
+	}
+	if ins.requestSize, err = meter.Int64Histogram(prefix+"request.size",
+		metric.WithDescription("Uncompressed size of the request messages of a call."),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(sizeBounds...)); err != nil {
+		return nil, err
+	}
+	if ins.responseSize, err = meter.Int64Histogram(prefix+"response.size",
+		metric.WithDescription("Uncompressed size of the response messages of a call."),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(sizeBounds...)); err != nil {
+		return nil, err
+	}
+	if ins.messages, err = meter.Int64Counter(prefix+"stream.messages",
+		metric.WithDescription("Messages sent and received on streams."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if ins.active, err = meter.Int64UpDownCounter(prefix+"active_requests",
+		metric.WithDescription("Calls in flight."),
+		metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	return &ins, nil
+}
+
+// methodAttrs returns the attributes of a method, e.g. rpc.service
+// ecommerce.OrderManagement and rpc.method AddOrder for
+// /ecommerce.OrderManagement/AddOrder.
+func methodAttrs(fullMethod string) []attribute.KeyValue {
+	name := strings.TrimPrefix(fullMethod, "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, semconv.RPCService(name[:i]), semconv.RPCMethod(name[i+1:]))
+	}
+	return attrs
+}
+
+func size(m interface{}) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
+
+// call counts the messages and bytes of one call. The request of the server
+// is what it receives, the request of the client what it sends.
+type call struct {
+	ins   *rpcInstruments
+	attrs []attribute.KeyValue
+	start time.Time
+	// stream is set for streaming calls, whose messages are counted by
+	// stream.messages too.
+	stream bool
+
+	mu                     sync.Mutex
+	received, sent         int64
+	receivedSize, sentSize int64
+}
+
+func (ins *rpcInstruments) begin(ctx context.Context, fullMethod string, stream bool) *call {
+	c := &call{ins: ins, attrs: methodAttrs(fullMethod), start: time.Now(), stream: stream}
+	ins.active.Add(ctx, 1, metric.WithAttributes(c.attrs...))
+	return c
+}
+
+func (c *call) recv(m interface{}) {
+	c.mu.Lock()
+	c.received++
+	c.receivedSize += size(m)
+	c.mu.Unlock()
+}
+
+func (c *call) send(m interface{}) {
+	c.mu.Lock()
+	c.sent++
+	c.sentSize += size(m)
+	c.mu.Unlock()
+}
+
+// end records the call with its status code.
+func (c *call) end(ctx context.Context, server bool, err error) {
+	// The recording must not fail because the call's context is done.
+	ctx = context.WithoutCancel(ctx)
+	c.ins.active.Add(ctx, -1, metric.WithAttributes(c.attrs...))
+
+	c.mu.Lock()
+	received, sent := c.received, c.sent
+	requestSize, responseSize := c.receivedSize, c.sentSize
+	c.mu.Unlock()
+	if !server {
+		requestSize, responseSize = responseSize, requestSize
+	}
+
+	code := semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err)))
+	opt := metric.WithAttributes(with(c.attrs, code)...)
+	c.ins.duration.Record(ctx, float64(time.Since(c.start))/float64(time.Millisecond), opt)
+	c.ins.requestSize.Record(ctx, requestSize, opt)
+	c.ins.responseSize.Record(ctx, responseSize, opt)
+	if c.stream {
+		c.ins.messages.Add(ctx, received, metric.WithAttributes(with(c.attrs, code, semconv.MessageTypeReceived)...))
+		c.ins.messages.Add(ctx, sent, metric.WithAttributes(with(c.attrs, code, semconv.MessageTypeSent)...))
+	}
+}
+
+// with returns attrs followed by more, without modifying attrs.
+func with(attrs []attribute.KeyValue, more ...attribute.KeyValue) []attribute.KeyValue {
+	return append(append(make([]attribute.KeyValue, 0, len(attrs)+len(more)), attrs...), more...)
+}
+
+// UnaryServerInterceptor records the metrics of unary calls. Chain it first,
+// so calls rejected by other interceptors are recorded too.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := m.server.begin(ctx, info.FullMethod, false)
+		c.recv(req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			c.send(resp)
+		}
+		c.end(ctx, true, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the metrics of streams.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		c := m.server.begin(ctx, info.FullMethod, true)
+		err := handler(srv, &serverStream{ServerStream: ss, call: c})
+		c.end(ctx, true, err)
+		return err
+	}
+}
+
+// serverStream counts the messages of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	call *call
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.recv(m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.send(m)
+	}
+	return err
+}
+
+// UnaryClientInterceptor records the metrics of unary calls.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := m.client.begin(ctx, method, false)
+		c.send(req)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			c.recv(reply)
+		}
+		c.end(ctx, false, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records the metrics of streams. A stream is
+// recorded when it returns its status or its context is done.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := m.client.begin(ctx, method, true)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.end(ctx, false, err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, desc: desc, call: c, ctx: ctx, done: make(chan struct{})}
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.finish(ctx.Err())
+			case <-s.done:
+			}
+		}()
+		return s, nil
+	}
+}
+
+// clientStream counts the messages of a stream and records it once it
+// ended.
+type clientStream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	call *call
+	ctx  context.Context
+
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.call.end(s.ctx, false, err)
+		close(s.done)
+	})
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	default:
+		s.call.recv(m)
+		// Without server streaming the only response ends the call.
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.send(m)
+	} else if err != io.EOF {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish(err)
+	}
+	return md, err
+}
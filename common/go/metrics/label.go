@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Other replaces the label values above the limit of Capped.
+const Other = "other"
+
+// Capped bounds the values of a label taken from requests, e.g. product names
+// or destinations. Values are normalized, and once max distinct values were
+// seen every new value is reported as Other, so a metric never has more than
+// max+1 series per label.
+type Capped struct {
+	max int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// NewCapped returns a Capped keeping the first max values.
+func NewCapped(max int) *Capped {
+	return &Capped{max: max, seen: make(map[string]bool)}
+}
+
+// Value returns the label value of v.
+func (c *Capped) Value(v string) string {
+	v = Normalize(v)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[v] {
+		return v
+	}
+	if len(c.seen) >= c.max {
+		return Other
+	}
+	c.seen[v] = true
+	return v
+}
+
+// Normalize lowercases v and joins its words with underscores, so spellings
+// like "San Jose, CA" and "san jose ca" become one value, san_jose_ca.
+func Normalize(v string) string {
+	words := strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "unknown"
+	}
+	return strings.Join(words, "_")
+}
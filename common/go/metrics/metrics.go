@@ -0,0 +1,113 @@
+// Package metrics sets up OpenTelemetry metrics for the sample servers and
+// clients and exports them to Prometheus and over OTLP.
+//
+// The interceptors record the RED metrics of every call per method and
+// status code: the call rate and errors through the count of the duration
+// histogram, the duration, the request and response sizes and the number of
+// stream messages, plus the calls in flight. With the Prometheus exporter the
+// server metrics read e.g.
+//
+//	rpc_server_duration_milliseconds_bucket{rpc_method="AddOrder",rpc_grpc_status_code="0",...,le="5"} 12
+//	rpc_server_active_requests{rpc_method="ProcessOrders",...} 2
+//
+// Business metrics are created with Meter. Their attributes must take a
+// bounded set of values; Capped bounds values coming from requests.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// instrumentationName names the meter of the interceptors.
+const instrumentationName = "github.com/grpc-up-and-running/samples/common/go/metrics"
+
+// Options configures Metrics.
+type Options struct {
+	// ServiceName is the service.name of the metrics, e.g. order-service.
+	ServiceName string
+	// Endpoint is the OTLP/HTTP collector, e.g. localhost:4318 or
+	// http://collector:4318/v1/metrics. Without it metrics are only
+	// served to Prometheus.
+	Endpoint string
+	// Interval is the interval of the OTLP exports, 30s by default.
+	Interval time.Duration
+}
+
+// Metrics holds the meter provider of a sample and creates its
+// interceptors.
+type Metrics struct {
+	provider *sdkmetric.MeterProvider
+	registry *prometheus.Registry
+	server   *rpcInstruments
+	client   *rpcInstruments
+}
+
+// Setup creates the meter provider of opts and installs it as the global
+// one. Call Shutdown before the process exits to export the last values.
+func Setup(opts Options) (*Metrics, error) {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metrics{registry: prometheus.NewRegistry()}
+	m.registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	promExporter, err := otelprom.New(otelprom.WithRegisterer(m.registry), otelprom.WithoutScopeInfo())
+	if err != nil {
+		return nil, err
+	}
+	providerOpts := []sdkmetric.Option{sdkmetric.WithResource(res), sdkmetric.WithReader(promExporter)}
+	if opts.Endpoint != "" {
+		exp, err := newOTLPExporter(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		interval := opts.Interval
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		providerOpts = append(providerOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(interval))))
+	}
+
+	m.provider = sdkmetric.NewMeterProvider(providerOpts...)
+	meter := m.provider.Meter(instrumentationName)
+	if m.server, err = newRPCInstruments(meter, "server"); err != nil {
+		return nil, err
+	}
+	if m.client, err = newRPCInstruments(meter, "client"); err != nil {
+		return nil, err
+	}
+	otel.SetMeterProvider(m.provider)
+	return m, nil
+}
+
+// Meter returns a meter for the sample's own metrics, e.g. business
+// metrics.
+func (m *Metrics) Meter(name string) metric.Meter {
+	return m.provider.Meter(name)
+}
+
+// Handler serves the metrics in the Prometheus text format, usually at
+// /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Shutdown exports the last values and stops the exporters. It fits
+// lifecycle.WithShutdownHook.
+func (m *Metrics) Shutdown(ctx context.Context) error {
+	return m.provider.Shutdown(ctx)
+}
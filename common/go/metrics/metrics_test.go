@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+)
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	// inStream is signalled while a stream is open.
+	inStream chan struct{}
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	if req.Message == "fail" {
+		return nil, status.Error(codes.NotFound, "no such order")
+	}
+	return &ecpb.EchoResponse{Message: req.Message}, nil
+}
+
+func (s *ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Message == "wait" {
+			s.inStream <- struct{}{}
+			<-s.inStream
+		}
+		if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// scrape returns the Prometheus text of m.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+// value returns the value of the series of name with all the labels, e.g.
+// rpc_method="UnaryEcho", or -1 without such a series.
+func value(t *testing.T, text, name string, labels ...string) float64 {
+	t.Helper()
+lines:
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, name+"{") {
+			continue
+		}
+		for _, l := range labels {
+			if !strings.Contains(line, l) {
+				continue lines
+			}
+		}
+		v, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+		if err != nil {
+			t.Fatalf("invalid sample %q: %v", line, err)
+		}
+		return v
+	}
+	return -1
+}
+
+func TestRPCMetrics(t *testing.T) {
+	m, err := Setup(Options{ServiceName: "echo"})
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	defer m.Shutdown(context.Background())
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := &ecServer{inStream: make(chan struct{})}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(m.StreamServerInterceptor()))
+	ecpb.RegisterEchoServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(m.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(m.StreamClientInterceptor()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := ecpb.NewEchoClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req := &ecpb.EchoRequest{Message: "hello"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.UnaryEcho(ctx, req); err != nil {
+			t.Fatalf("UnaryEcho() failed: %v", err)
+		}
+	}
+	if _, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "fail"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("UnaryEcho() = %v, want NotFound", err)
+	}
+
+	stream, err := c.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+	}
+	// The stream is in flight while the server waits.
+	if err := stream.Send(&ecpb.EchoRequest{Message: "wait"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	<-srv.inStream
+	text := scrape(t, m)
+	for _, name := range []string{"rpc_server_active_requests", "rpc_client_active_requests"} {
+		if got := value(t, text, name, `rpc_method="BidirectionalStreamingEcho"`); got != 1 {
+			t.Errorf("%s = %v while the stream is open, want 1", name, got)
+		}
+	}
+	srv.inStream <- struct{}{}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() failed: %v", err)
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv() = %v, want EOF", err)
+	}
+	// The server records a stream after the client got its status.
+	s.GracefulStop()
+
+	text = scrape(t, m)
+	unary := `rpc_method="UnaryEcho"`
+	bidi := `rpc_method="BidirectionalStreamingEcho"`
+	ok, notFound := `rpc_grpc_status_code="0"`, `rpc_grpc_status_code="5"`
+	for _, side := range []string{"server", "client"} {
+		prefix := "rpc_" + side + "_"
+		for _, tc := range []struct {
+			name   string
+			labels []string
+			want   float64
+		}{
+			{"duration_milliseconds_count", []string{unary, ok}, 2},
+			{"duration_milliseconds_count", []string{unary, notFound}, 1},
+			{"duration_milliseconds_count", []string{bidi, ok}, 1},
+			{"request_size_bytes_sum", []string{unary, ok}, 14},
+			{"response_size_bytes_sum", []string{unary, ok}, 14},
+			{"response_size_bytes_sum", []string{unary, notFound}, 0},
+			{"stream_messages_total", []string{bidi, ok, `message_type="RECEIVED"`}, 4},
+			{"stream_messages_total", []string{bidi, ok, `message_type="SENT"`}, 4},
+			{"active_requests", []string{bidi}, 0},
+			{"active_requests", []string{unary}, 0},
+		} {
+			if got := value(t, text, prefix+tc.name, tc.labels...); got != tc.want {
+				t.Errorf("%s%s%v = %v, want %v", prefix, tc.name, tc.labels, got, tc.want)
+			}
+		}
+	}
+	if got := value(t, text, "rpc_server_stream_messages_total", unary); got != -1 {
+		t.Errorf("unary calls have stream message counters")
+	}
+}
+
+func TestCapped(t *testing.T) {
+	c := NewCapped(2)
+	for _, tc := range []struct{ in, want string }{
+		{"Mountain View, CA", "mountain_view_ca"},
+		{"San Jose, CA", "san_jose_ca"},
+		{"mountain view ca", "mountain_view_ca"},
+		{"Seattle, WA", Other},
+		{"  SAN JOSE,  CA ", "san_jose_ca"},
+		{"", Other},
+	} {
+		if got := c.Value(tc.in); got != tc.want {
+			t.Errorf("Value(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("request to %s, want /v1/metrics", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	m, err := Setup(Options{ServiceName: "order-service", Endpoint: collector.Listener.Addr().String(), Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	orders, err := m.Meter("orders").Int64Counter("orders.added")
+	if err != nil {
+		t.Fatalf("Int64Counter() failed: %v", err)
+	}
+	orders.Add(context.Background(), 3)
+	// Shutdown exports the last values.
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+
+	var sum map[string]interface{}
+	for _, sm := range got["resourceMetrics"].([]interface{})[0].(map[string]interface{})["scopeMetrics"].([]interface{}) {
+		for _, metric := range sm.(map[string]interface{})["metrics"].([]interface{}) {
+			if metric := metric.(map[string]interface{}); metric["name"] == "orders.added" {
+				sum = metric["sum"].(map[string]interface{})
+			}
+		}
+	}
+	if sum == nil {
+		t.Fatalf("orders.added was not exported: %v", got)
+	}
+	point := sum["dataPoints"].([]interface{})[0].(map[string]interface{})
+	if point["asInt"] != "3" || sum["isMonotonic"] != true || sum["aggregationTemporality"] != 2.0 {
+		t.Errorf("exported sum = %v, want a cumulative monotonic sum of 3", sum)
+	}
+}
@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grpc-up-and-running/samples/common/go/internal/otlpjson"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// otlpExporter posts metrics to an OTLP/HTTP collector in the JSON encoding
+// of OTLP.
+type otlpExporter struct {
+	client *otlpjson.Client
+}
+
+// newOTLPExporter returns an exporter for endpoint, a host:port address or
+// a URL. The path defaults to /v1/metrics.
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	client, err := otlpjson.NewClient(endpoint, "/v1/metrics")
+	if err != nil {
+		return nil, err
+	}
+	return &otlpExporter{client: client}, nil
+}
+
+func (e *otlpExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *otlpExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *otlpExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.client.Post(ctx, otlpRequest(rm))
+}
+
+func (e *otlpExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.Close()
+	return nil
+}
+
+// The types below are the JSON mapping of the OTLP metrics request.
+
+type jsonRequest struct {
+	ResourceMetrics []jsonResourceMetrics `json:"resourceMetrics"`
+}
+
+type jsonResourceMetrics struct {
+	Resource     otlpjson.Resource  `json:"resource"`
+	ScopeMetrics []jsonScopeMetrics `json:"scopeMetrics"`
+}
+
+type jsonScopeMetrics struct {
+	Scope   otlpjson.Scope `json:"scope"`
+	Metrics []jsonMetric   `json:"metrics"`
+}
+
+// jsonMetric has one of Sum, Gauge and Histogram set.
+type jsonMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Sum         *jsonSum       `json:"sum,omitempty"`
+	Gauge       *jsonGauge     `json:"gauge,omitempty"`
+	Histogram   *jsonHistogram `json:"histogram,omitempty"`
+}
+
+type jsonSum struct {
+	DataPoints             []jsonNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type jsonGauge struct {
+	DataPoints []jsonNumberDataPoint `json:"dataPoints"`
+}
+
+type jsonHistogram struct {
+	DataPoints             []jsonHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type jsonNumberDataPoint struct {
+	Attributes        []otlpjson.KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string              `json:"timeUnixNano"`
+	AsInt             *string             `json:"asInt,omitempty"`
+	AsDouble          *float64            `json:"asDouble,omitempty"`
+}
+
+type jsonHistogramDataPoint struct {
+	Attributes        []otlpjson.KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	TimeUnixNano      string              `json:"timeUnixNano"`
+	Count             string              `json:"count"`
+	Sum               float64             `json:"sum"`
+	BucketCounts      []string            `json:"bucketCounts"`
+	ExplicitBounds    []float64           `json:"explicitBounds"`
+	Min               *float64            `json:"min,omitempty"`
+	Max               *float64            `json:"max,omitempty"`
+}
+
+func otlpRequest(rm *metricdata.ResourceMetrics) jsonRequest {
+	rs := jsonResourceMetrics{Resource: otlpjson.ResourceOf(rm.Resource)}
+	for _, sm := range rm.ScopeMetrics {
+		scope := jsonScopeMetrics{Scope: otlpjson.ScopeOf(sm.Scope)}
+		for _, m := range sm.Metrics {
+			if jm, ok := jsonMetricOf(m); ok {
+				scope.Metrics = append(scope.Metrics, jm)
+			}
+		}
+		rs.ScopeMetrics = append(rs.ScopeMetrics, scope)
+	}
+	return jsonRequest{ResourceMetrics: []jsonResourceMetrics{rs}}
+}
+
+// jsonMetricOf encodes the aggregations the default selector creates:
+// sums, gauges and explicit bucket histograms.
+func jsonMetricOf(m metricdata.Metrics) (jsonMetric, bool) {
+	jm := jsonMetric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		jm.Sum = &jsonSum{DataPoints: numberPoints(data.DataPoints), AggregationTemporality: temporality(data.Temporality), IsMonotonic: data.IsMonotonic}
+	case metricdata.Sum[float64]:
+		jm.Sum = &jsonSum{DataPoints: numberPoints(data.DataPoints), AggregationTemporality: temporality(data.Temporality), IsMonotonic: data.IsMonotonic}
+	case metricdata.Gauge[int64]:
+		jm.Gauge = &jsonGauge{DataPoints: numberPoints(data.DataPoints)}
+	case metricdata.Gauge[float64]:
+		jm.Gauge = &jsonGauge{DataPoints: numberPoints(data.DataPoints)}
+	case metricdata.Histogram[int64]:
+		jm.Histogram = &jsonHistogram{DataPoints: histogramPoints(data.DataPoints), AggregationTemporality: temporality(data.Temporality)}
+	case metricdata.Histogram[float64]:
+		jm.Histogram = &jsonHistogram{DataPoints: histogramPoints(data.DataPoints), AggregationTemporality: temporality(data.Temporality)}
+	default:
+		return jm, false
+	}
+	return jm, true
+}
+
+// temporality returns the OTLP number of t: DELTA is 1, CUMULATIVE 2.
+func temporality(t metricdata.Temporality) int {
+	if t == metricdata.DeltaTemporality {
+		return 1
+	}
+	return 2
+}
+
+func attributes(set attribute.Set) []otlpjson.KeyValue {
+	return otlpjson.Attributes(set.ToSlice())
+}
+
+func numberPoints[N int64 | float64](points []metricdata.DataPoint[N]) []jsonNumberDataPoint {
+	jps := make([]jsonNumberDataPoint, 0, len(points))
+	for _, p := range points {
+		jp := jsonNumberDataPoint{Attributes: attributes(p.Attributes), TimeUnixNano: otlpjson.UnixNano(p.Time)}
+		if !p.StartTime.IsZero() {
+			jp.StartTimeUnixNano = otlpjson.UnixNano(p.StartTime)
+		}
+		switch v := any(p.Value).(type) {
+		case int64:
+			i := strconv.FormatInt(v, 10)
+			jp.AsInt = &i
+		case float64:
+			jp.AsDouble = &v
+		}
+		jps = append(jps, jp)
+	}
+	return jps
+}
+
+func histogramPoints[N int64 | float64](points []metricdata.HistogramDataPoint[N]) []jsonHistogramDataPoint {
+	jps := make([]jsonHistogramDataPoint, 0, len(points))
+	for _, p := range points {
+		jp := jsonHistogramDataPoint{
+			Attributes:        attributes(p.Attributes),
+			StartTimeUnixNano: otlpjson.UnixNano(p.StartTime),
+			TimeUnixNano:      otlpjson.UnixNano(p.Time),
+			Count:             strconv.FormatUint(p.Count, 10),
+			Sum:               float64(p.Sum),
+			ExplicitBounds:    p.Bounds,
+		}
+		for _, c := range p.BucketCounts {
+			jp.BucketCounts = append(jp.BucketCounts, strconv.FormatUint(c, 10))
+		}
+		if v, ok := p.Min.Value(); ok {
+			min := float64(v)
+			jp.Min = &min
+		}
+		if v, ok := p.Max.Value(); ok {
+			max := float64(v)
+			jp.Max = &max
+		}
+		jps = append(jps, jp)
+	}
+	return jps
+}
@@ -0,0 +1,339 @@
+// Package pool keeps several connections to one target and spreads the calls
+// over them.
+//
+// A grpc.ClientConn multiplexes all its calls over one HTTP/2 connection to a
+// backend, so a client with many concurrent streams runs into the
+// MaxConcurrentStreams limit of the server, after which new streams wait for
+// others to end. A Pool dials Size ClientConns and sends each call to the
+// ready connection with the fewest calls in flight. A connection that stays
+// in TRANSIENT_FAILURE while other connections to the target are ready is
+// replaced by a new one; when none is ready the target itself is down and
+// the connections keep reconnecting on their own.
+//
+// A Pool is a grpc.ClientConnInterface, so generated clients take it in place
+// of a ClientConn:
+//
+//	p, err := pool.New("localhost:50051", pool.Options{Size: 4, DialOptions: []grpc.DialOption{grpc.WithInsecure()}})
+//	client := pb.NewOrderManagementClient(p)
+//
+// Code generated before ClientConnInterface takes a *grpc.ClientConn; Conn
+// returns the least loaded one for a client made per call.
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Defaults of the Options.
+const (
+	DefaultSize         = 4
+	DefaultReplaceAfter = 5 * time.Second
+)
+
+// Options configure a Pool.
+type Options struct {
+	// Size is the number of connections, DefaultSize when 0.
+	Size int
+	// DialOptions are passed to grpc.Dial for every connection.
+	DialOptions []grpc.DialOption
+	// ReplaceAfter is how long a connection may stay in TRANSIENT_FAILURE
+	// while other connections are ready before it is replaced,
+	// DefaultReplaceAfter when 0.
+	ReplaceAfter time.Duration
+}
+
+// Pool is a fixed number of connections to one target. It is safe for
+// concurrent use.
+type Pool struct {
+	target string
+	opts   Options
+	conns  []*conn
+	// next is where the search for the least loaded connection starts, so
+	// connections with the same load take turns.
+	next     uint32
+	replaced uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ grpc.ClientConnInterface = (*Pool)(nil)
+
+// conn is a slot of the pool; its ClientConn changes when it is replaced.
+type conn struct {
+	mu sync.Mutex
+	cc *grpc.ClientConn
+
+	inFlight int64
+	calls    uint64
+}
+
+func (c *conn) clientConn() *grpc.ClientConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cc
+}
+
+// New dials opts.Size connections to target. Like grpc.Dial it does not wait
+// for them to be established.
+func New(target string, opts Options) (*Pool, error) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.ReplaceAfter <= 0 {
+		opts.ReplaceAfter = DefaultReplaceAfter
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{target: target, opts: opts, ctx: ctx, cancel: cancel}
+	for i := 0; i < opts.Size; i++ {
+		c := &conn{}
+		cc, err := p.dial(c)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("pool: dialing %s: %v", target, err)
+		}
+		c.cc = cc
+		p.conns = append(p.conns, c)
+	}
+	for _, c := range p.conns {
+		p.wg.Add(1)
+		go p.watch(c)
+	}
+	return p, nil
+}
+
+// dial dials a connection of c. Its interceptors count the calls of c,
+// whether they come through the Pool or through Conn.
+func (p *Pool) dial(c *conn) (*grpc.ClientConn, error) {
+	opts := append([]grpc.DialOption{}, p.opts.DialOptions...)
+	opts = append(opts,
+		grpc.WithChainUnaryInterceptor(c.unaryInterceptor),
+		grpc.WithChainStreamInterceptor(c.streamInterceptor))
+	return grpc.Dial(p.target, opts...)
+}
+
+// Target returns the target of the connections.
+func (p *Pool) Target() string {
+	return p.target
+}
+
+// pick returns the ready connection with the fewest calls in flight, or the
+// least loaded one if none is ready; its call then waits for it to connect
+// or fails like a call on a single ClientConn would.
+func (p *Pool) pick() *grpc.ClientConn {
+	start := atomic.AddUint32(&p.next, 1)
+	var (
+		best      *grpc.ClientConn
+		bestReady bool
+		bestLoad  int64
+	)
+	for i := range p.conns {
+		c := p.conns[(int(start)+i)%len(p.conns)]
+		cc := c.clientConn()
+		ready := cc.GetState() == connectivity.Ready
+		load := atomic.LoadInt64(&c.inFlight)
+		if best == nil || ready && !bestReady || ready == bestReady && load < bestLoad {
+			best, bestReady, bestLoad = cc, ready, load
+		}
+	}
+	return best
+}
+
+// Conn returns the ready connection with the fewest calls in flight, for
+// generated clients that take a *grpc.ClientConn. Make a client per call
+// or batch of calls, so the calls are spread over the pool.
+func (p *Pool) Conn() *grpc.ClientConn {
+	return p.pick()
+}
+
+// Invoke makes a unary call on the least loaded connection.
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return p.Conn().Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream starts a stream on the least loaded connection.
+func (p *Pool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.Conn().NewStream(ctx, desc, method, opts...)
+}
+
+func (c *conn) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	atomic.AddUint64(&c.calls, 1)
+	atomic.AddInt64(&c.inFlight, 1)
+	defer atomic.AddInt64(&c.inFlight, -1)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// streamInterceptor counts a stream as in flight until it ends: RecvMsg
+// returned an error, its single response was received, or ctx is done.
+func (c *conn) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	atomic.AddUint64(&c.calls, 1)
+	atomic.AddInt64(&c.inFlight, 1)
+	var once sync.Once
+	release := func() { once.Do(func() { atomic.AddInt64(&c.inFlight, -1) }) }
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, release)
+	return &stream{ClientStream: cs, desc: desc, done: func() { stop(); release() }}, nil
+}
+
+// stream calls done once the stream has ended.
+type stream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	done func()
+}
+
+func (s *stream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.desc.ServerStreams {
+		s.done()
+	}
+	return err
+}
+
+// watch connects connections that went idle, e.g. after the server sent a
+// GOAWAY, and replaces connections that stay in TRANSIENT_FAILURE for
+// ReplaceAfter while others are ready, until the pool is closed.
+func (p *Pool) watch(c *conn) {
+	defer p.wg.Done()
+	for {
+		cc := c.clientConn()
+		state := cc.GetState()
+		switch state {
+		case connectivity.Idle:
+			cc.Connect()
+		case connectivity.TransientFailure:
+			if !p.recovers(cc) {
+				if p.ctx.Err() != nil {
+					return
+				}
+				if p.othersReady(c) {
+					p.replace(c, cc)
+					continue
+				}
+			}
+		}
+		if !cc.WaitForStateChange(p.ctx, state) {
+			return
+		}
+	}
+}
+
+// recovers reports whether cc becomes ready within ReplaceAfter.
+func (p *Pool) recovers(cc *grpc.ClientConn) bool {
+	ctx, cancel := context.WithTimeout(p.ctx, p.opts.ReplaceAfter)
+	defer cancel()
+	for state := cc.GetState(); state != connectivity.Ready; state = cc.GetState() {
+		if state == connectivity.Idle {
+			cc.Connect()
+		}
+		if !cc.WaitForStateChange(ctx, state) {
+			return false
+		}
+	}
+	return true
+}
+
+// othersReady reports whether another connection of the pool is ready. Only
+// then is the target reachable and a new connection likely to do better than
+// c; replacing the connections of a target that is down would only churn
+// them.
+func (p *Pool) othersReady(c *conn) bool {
+	for _, o := range p.conns {
+		if o != c && o.clientConn().GetState() == connectivity.Ready {
+			return true
+		}
+	}
+	return false
+}
+
+// replace dials a new connection in place of cc and closes cc. Calls on cc
+// have failed or are failing anyway.
+func (p *Pool) replace(c *conn, cc *grpc.ClientConn) {
+	fresh, err := p.dial(c)
+	if err != nil {
+		// The options dialed the first connections, so this is unlikely;
+		// the old connection keeps retrying on its own.
+		log.Printf("pool: replacing a connection to %s: %v", p.target, err)
+		return
+	}
+	c.mu.Lock()
+	c.cc = fresh
+	c.mu.Unlock()
+	atomic.AddUint64(&p.replaced, 1)
+	log.Printf("pool: replaced a connection to %s that stayed in %v for %v", p.target, connectivity.TransientFailure, p.opts.ReplaceAfter)
+	cc.Close()
+}
+
+// Close closes the connections. Calls in flight fail with CANCELED.
+func (p *Pool) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	for _, c := range p.conns {
+		c.clientConn().Close()
+	}
+	return nil
+}
+
+// ConnStats are the statistics of a connection of a Pool.
+type ConnStats struct {
+	State connectivity.State
+	// InFlight are the calls and streams in flight.
+	InFlight int64
+	// Calls are the calls and streams started, including those on the
+	// connections it replaced.
+	Calls uint64
+}
+
+// Stats are the statistics of a Pool.
+type Stats struct {
+	Target string
+	Conns  []ConnStats
+	// Replaced counts the connections replaced since New.
+	Replaced uint64
+}
+
+// Ready returns the number of ready connections.
+func (s Stats) Ready() int {
+	n := 0
+	for _, c := range s.Conns {
+		if c.State == connectivity.Ready {
+			n++
+		}
+	}
+	return n
+}
+
+// InFlight returns the calls in flight on all connections.
+func (s Stats) InFlight() int64 {
+	var n int64
+	for _, c := range s.Conns {
+		n += c.InFlight
+	}
+	return n
+}
+
+// Stats returns the current statistics of the pool.
+func (p *Pool) Stats() Stats {
+	s := Stats{Target: p.target, Replaced: atomic.LoadUint64(&p.replaced)}
+	for _, c := range p.conns {
+		s.Conns = append(s.Conns, ConnStats{
+			State:    c.clientConn().GetState(),
+			InFlight: atomic.LoadInt64(&c.inFlight),
+			Calls:    atomic.LoadUint64(&c.calls),
+		})
+	}
+	return s
+}
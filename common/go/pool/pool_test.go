@@ -0,0 +1,287 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ecServer echoes the messages of bidirectional streams and answers unary
+// calls with the address of the client connection.
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+}
+
+func (ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	p, _ := peer.FromContext(ctx)
+	return &ecpb.EchoResponse{Message: p.Addr.String()}, nil
+}
+
+func (ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// startServer serves Echo on addr, allowing maxStreams concurrent streams
+// per connection, and returns its address.
+func startServer(t *testing.T, addr string, maxStreams uint32) (string, func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(grpc.MaxConcurrentStreams(maxStreams))
+	ecpb.RegisterEchoServer(s, ecServer{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), s.Stop
+}
+
+func newPool(t *testing.T, addr string, opts Options) *Pool {
+	t.Helper()
+	opts.DialOptions = append(opts.DialOptions, grpc.WithInsecure())
+	p, err := New(addr, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestStreamsAboveTheLimit opens more streams than one connection may carry
+// and checks that all of them are served at once.
+func TestStreamsAboveTheLimit(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 2)
+	p := newPool(t, addr, Options{Size: 3})
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 3 })
+	client := ecpb.NewEchoClient(p)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var streams []ecpb.Echo_BidirectionalStreamingEchoClient
+	for i := 0; i < 6; i++ {
+		stream, err := client.BidirectionalStreamingEcho(ctx)
+		if err != nil {
+			t.Fatalf("BidirectionalStreamingEcho: %v", err)
+		}
+		// The reply shows the stream is served and not waiting for a
+		// stream of its connection to end.
+		if err := stream.Send(&ecpb.EchoRequest{Message: "hi"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("stream %d: Recv: %v", i, err)
+		}
+		streams = append(streams, stream)
+	}
+	for i, c := range p.Stats().Conns {
+		if c.InFlight != 2 {
+			t.Errorf("connection %d has %d streams in flight, want 2", i, c.InFlight)
+		}
+	}
+
+	for _, stream := range streams {
+		stream.CloseSend()
+		if _, err := stream.Recv(); err != io.EOF {
+			t.Fatalf("Recv after CloseSend = %v, want EOF", err)
+		}
+	}
+	if n := p.Stats().InFlight(); n != 0 {
+		t.Errorf("%d calls in flight after the streams ended, want 0", n)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 0)
+	p := newPool(t, addr, Options{Size: 4})
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 4 })
+	client := ecpb.NewEchoClient(p)
+
+	peers := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		resp, err := client.UnaryEcho(context.Background(), &ecpb.EchoRequest{})
+		if err != nil {
+			t.Fatalf("UnaryEcho: %v", err)
+		}
+		peers[resp.Message]++
+	}
+	if len(peers) != 4 {
+		t.Errorf("calls came from %d connections, want 4: %v", len(peers), peers)
+	}
+	for i, c := range p.Stats().Conns {
+		if c.Calls != 10 {
+			t.Errorf("connection %d made %d calls, want 10", i, c.Calls)
+		}
+	}
+}
+
+// TestConn checks that calls on the connections returned by Conn are
+// counted and spread like calls on the Pool.
+func TestConn(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 0)
+	p := newPool(t, addr, Options{Size: 4})
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 4 })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 4; i++ {
+		if _, err := ecpb.NewEchoClient(p.Conn()).BidirectionalStreamingEcho(ctx); err != nil {
+			t.Fatalf("BidirectionalStreamingEcho: %v", err)
+		}
+	}
+	for i, c := range p.Stats().Conns {
+		if c.InFlight != 1 {
+			t.Errorf("connection %d has %d streams in flight, want 1", i, c.InFlight)
+		}
+	}
+}
+
+func TestCancelledStream(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 0)
+	p := newPool(t, addr, Options{Size: 1})
+	client := ecpb.NewEchoClient(p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := client.BidirectionalStreamingEcho(ctx); err != nil {
+		t.Fatalf("BidirectionalStreamingEcho: %v", err)
+	}
+	if n := p.Stats().InFlight(); n != 1 {
+		t.Errorf("%d calls in flight, want 1", n)
+	}
+	// The stream is abandoned without reading its end.
+	cancel()
+	waitFor(t, "the cancelled stream to end", func() bool { return p.Stats().InFlight() == 0 })
+}
+
+// TestKeepWhileTargetDown checks that connections are not replaced while
+// the whole target is down, and reconnect once it is back.
+func TestKeepWhileTargetDown(t *testing.T) {
+	addr, stop := startServer(t, "localhost:0", 0)
+	p := newPool(t, addr, Options{Size: 2, ReplaceAfter: 100 * time.Millisecond})
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 2 })
+
+	stop()
+	waitFor(t, "failed connections", func() bool { return p.Stats().Ready() == 0 })
+	time.Sleep(500 * time.Millisecond)
+	if n := p.Stats().Replaced; n != 0 {
+		t.Errorf("%d connections replaced while the target was down, want 0", n)
+	}
+	startServer(t, addr, 0)
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := ecpb.NewEchoClient(p).UnaryEcho(ctx, &ecpb.EchoRequest{}); err != nil {
+		t.Errorf("UnaryEcho after the server came back: %v", err)
+	}
+}
+
+// failingDialer dials addr until fail is called, after which it refuses new
+// connections and has closed the first one it made.
+type failingDialer struct {
+	addr string
+
+	mu      sync.Mutex
+	failing bool
+	conns   []net.Conn
+}
+
+func (d *failingDialer) dial(ctx context.Context, _ string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failing {
+		return nil, errors.New("connection refused")
+	}
+	c, err := (&net.Dialer{}).DialContext(ctx, "tcp", d.addr)
+	if err == nil {
+		d.conns = append(d.conns, c)
+	}
+	return c, err
+}
+
+func (d *failingDialer) fail() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failing = true
+	d.conns[0].Close()
+}
+
+func (d *failingDialer) recover() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failing = false
+}
+
+// TestReplace breaks one connection while the other stays ready and checks
+// that the broken one is replaced.
+func TestReplace(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 0)
+	d := &failingDialer{addr: addr}
+	p := newPool(t, addr, Options{Size: 2, ReplaceAfter: 100 * time.Millisecond,
+		DialOptions: []grpc.DialOption{grpc.WithContextDialer(d.dial)}})
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 2 })
+
+	d.fail()
+	waitFor(t, "a replaced connection", func() bool { return p.Stats().Replaced >= 1 })
+	if n := p.Stats().Ready(); n != 1 {
+		t.Errorf("%d ready connections while one is broken, want 1", n)
+	}
+	d.recover()
+	waitFor(t, "ready connections", func() bool { return p.Stats().Ready() == 2 })
+}
+
+func TestClose(t *testing.T) {
+	addr, _ := startServer(t, "localhost:0", 0)
+	p := newPool(t, addr, Options{})
+	if n := len(p.Stats().Conns); n != DefaultSize {
+		t.Errorf("pool has %d connections, want %d", n, DefaultSize)
+	}
+	var wg sync.WaitGroup
+	stream, err := ecpb.NewEchoClient(p).BidirectionalStreamingEcho(context.Background())
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+			t.Errorf("Recv after Close = %v, want CANCELED", err)
+		}
+	}()
+	p.Close()
+	wg.Wait()
+	for i, c := range p.Stats().Conns {
+		if c.State != connectivity.Shutdown {
+			t.Errorf("connection %d is %v after Close, want SHUTDOWN", i, c.State)
+		}
+	}
+}
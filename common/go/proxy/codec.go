@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"google.golang.org/grpc/encoding"
+	// Registers the proto codec the Codec falls back to.
+	_ "google.golang.org/grpc/encoding/proto"
+)
+
+// Frame is the encoded payload of a message passed through the proxy
+// unchanged.
+type Frame struct {
+	payload []byte
+}
+
+// Size returns the size of the payload, e.g. for the access log.
+func (f *Frame) Size() int {
+	return len(f.payload)
+}
+
+// Codec passes Frames through without decoding them, so the proxy does not
+// need the descriptors of the services behind it. Other messages, e.g. those
+// of a health service registered on the proxy itself, are encoded with the
+// proto codec. Its name is "proto", so the backends see the content type
+// clients sent.
+type Codec struct{}
+
+// Marshal returns the payload of a Frame, or the proto encoding of v.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*Frame); ok {
+		return f.payload, nil
+	}
+	return encoding.GetCodec("proto").Marshal(v)
+}
+
+// Unmarshal keeps data as the payload of a Frame, or decodes it into v.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	if f, ok := v.(*Frame); ok {
+		f.payload = data
+		return nil
+	}
+	return encoding.GetCodec("proto").Unmarshal(data, v)
+}
+
+// Name returns "proto".
+func (Codec) Name() string {
+	return "proto"
+}
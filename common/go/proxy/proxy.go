@@ -0,0 +1,158 @@
+// Package proxy is a transparent gRPC-to-gRPC reverse proxy. It forwards
+// calls to methods it does not serve itself to backends chosen by a
+// Director, passing the messages through without decoding them, so it works
+// with any service and all four kinds of calls: unary, server streaming,
+// client streaming and bidirectional streaming.
+//
+// The metadata of a call is forwarded to the backend, and the headers,
+// messages, trailers and status of the backend are returned to the client.
+// The deadline of the call becomes the deadline of the backend call, and a
+// client cancelling the call cancels the backend call.
+//
+// The proxy is a grpc.Server with the options of ServerOptions, so the
+// server interceptors, e.g. those of the auth, ratelimit and accesslog
+// packages, apply to every proxied call in one place:
+//
+//	router := proxy.NewRouter()
+//	router.Route("/ecommerce.OrderManagement/", ordersConn)
+//	router.Route("/ecommerce.ProductInfo/", productsConn)
+//	s := grpc.NewServer(append(proxy.ServerOptions(router.Director),
+//		grpc.ChainStreamInterceptor(tokens.StreamServerInterceptor(), limiter.StreamServerInterceptor()))...)
+package proxy
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Director returns the backend connection of a call to fullMethod, e.g.
+// /ecommerce.OrderManagement/getOrder, and the context of the backend call.
+// ctx already carries the metadata of the call as outgoing metadata; the
+// Director may add to it. A status error is returned to the client as is.
+type Director func(ctx context.Context, fullMethod string) (context.Context, *grpc.ClientConn, error)
+
+// ServerOptions returns the options of a server proxying the calls of
+// unknown services with director.
+func ServerOptions(director Director) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ForceServerCodec(Codec{}),
+		grpc.UnknownServiceHandler(TransparentHandler(director)),
+	}
+}
+
+// clientStreams describes the backend calls: a proxied call may be of any
+// kind, so it is always forwarded as a bidirectional stream.
+var clientStreams = &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+
+// TransparentHandler returns a handler forwarding calls to the backends of
+// director. The server needs the Codec, see ServerOptions.
+func TransparentHandler(director Director) grpc.StreamHandler {
+	return func(_ interface{}, ss grpc.ServerStream) error {
+		fullMethod, ok := grpc.MethodFromServerStream(ss)
+		if !ok {
+			return status.Error(codes.Internal, "proxy: no method in the stream context")
+		}
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		ctx, conn, err := director(metadata.NewOutgoingContext(ss.Context(), forwarded(md)), fullMethod)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cs, err := conn.NewStream(ctx, clientStreams, fullMethod, grpc.ForceCodec(Codec{}))
+		if err != nil {
+			return err
+		}
+
+		toBackend := forwardToBackend(ss, cs)
+		toClient := forwardToClient(cs, ss)
+		for {
+			select {
+			case err := <-toBackend:
+				if err == io.EOF {
+					// The client sent all its messages, or the backend ended
+					// the call; the backend's status follows on toClient.
+					cs.CloseSend()
+					toBackend = nil
+					continue
+				}
+				// The client went away; cancel cancels the backend call.
+				return err
+			case err := <-toClient:
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+// forwardToBackend sends the messages of the client to the backend until
+// the client is done sending, which returns io.EOF.
+func forwardToBackend(ss grpc.ServerStream, cs grpc.ClientStream) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		f := &Frame{}
+		for {
+			if err := ss.RecvMsg(f); err != nil {
+				done <- err
+				return
+			}
+			if err := cs.SendMsg(f); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// forwardToClient sends the headers and messages of the backend to the
+// client and sets the trailers of the backend. It returns io.EOF when the
+// backend ended the call with OK, and the backend's status error otherwise.
+func forwardToClient(cs grpc.ClientStream, ss grpc.ServerStream) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		// Header waits for the backend's headers; they are sent before the
+		// first message, or with the status of a call without messages.
+		if md, err := cs.Header(); err == nil && len(md) > 0 {
+			if err := ss.SendHeader(md); err != nil {
+				done <- err
+				return
+			}
+		}
+		f := &Frame{}
+		for {
+			if err := cs.RecvMsg(f); err != nil {
+				ss.SetTrailer(cs.Trailer())
+				done <- err
+				return
+			}
+			if err := ss.SendMsg(f); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// forwarded returns the metadata of a call without the pseudo headers and
+// the headers gRPC sets itself on the backend call.
+func forwarded(md metadata.MD) metadata.MD {
+	out := make(metadata.MD, len(md))
+	for k, v := range md {
+		if strings.HasPrefix(k, ":") || k == "content-type" || k == "user-agent" || k == "te" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
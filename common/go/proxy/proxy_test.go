@@ -0,0 +1,366 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/accesslog"
+	"github.com/grpc-up-and-running/samples/common/go/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ecServer answers with its name and the message, and returns the x-test
+// metadata of the call and whether it has a deadline in its headers.
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	name string
+	// cancelled receives the error of streams ended by the client.
+	cancelled chan error
+}
+
+func (s *ecServer) reply(msg string) *ecpb.EchoResponse {
+	return &ecpb.EchoResponse{Message: s.name + ": " + msg}
+}
+
+func (s *ecServer) headers(ctx context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	deadline := "no"
+	if _, ok := ctx.Deadline(); ok {
+		deadline = "yes"
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("backend", s.name, "x-test", strings.Join(md.Get("x-test"), ","), "deadline", deadline))
+	grpc.SetTrailer(ctx, metadata.Pairs("backend-trailer", s.name))
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	s.headers(ctx)
+	if req.Message == "fail" {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: invalid message", s.name)
+	}
+	return s.reply(req.Message), nil
+}
+
+func (s *ecServer) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	s.headers(stream.Context())
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(s.reply(req.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ecServer) ClientStreamingEcho(stream ecpb.Echo_ClientStreamingEchoServer) error {
+	s.headers(stream.Context())
+	var msgs []string
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(s.reply(strings.Join(msgs, ",")))
+		}
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, req.Message)
+	}
+}
+
+func (s *ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	s.headers(stream.Context())
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			s.cancelled <- err
+			return err
+		}
+		if err := stream.Send(s.reply(req.Message)); err != nil {
+			return err
+		}
+	}
+}
+
+// startBackend starts an Echo server and returns a connection to it.
+func startBackend(t *testing.T, name string) (*ecServer, *grpc.ClientConn) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	srv := &ecServer{name: name, cancelled: make(chan error, 1)}
+	ecpb.RegisterEchoServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+// startProxy starts a proxy with the routes and options and returns a
+// connection to it.
+func startProxy(t *testing.T, router *Router, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(append(ServerOptions(router.Director), opts...)...)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "x-test", "forwarded")
+}
+
+func checkHeader(t *testing.T, method string, header, trailer metadata.MD, backend string) {
+	t.Helper()
+	for k, want := range map[string]string{"backend": backend, "x-test": "forwarded", "deadline": "yes"} {
+		if got := header.Get(k); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: header %s = %v, want %s", method, k, got, want)
+		}
+	}
+	if got := trailer.Get("backend-trailer"); len(got) != 1 || got[0] != backend {
+		t.Errorf("%s: trailer backend-trailer = %v, want %s", method, got, backend)
+	}
+}
+
+func TestStreamingTypes(t *testing.T) {
+	_, conn := startBackend(t, "a")
+	router := NewRouter()
+	router.Route("/grpc.examples.echo.Echo/", conn)
+	client := ecpb.NewEchoClient(startProxy(t, router))
+	ctx := testContext(t)
+
+	var header, trailer metadata.MD
+	resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "unary"}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil || resp.Message != "a: unary" {
+		t.Fatalf("UnaryEcho = %v, %v, want a: unary", resp, err)
+	}
+	checkHeader(t, "UnaryEcho", header, trailer, "a")
+
+	ss, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: "server"})
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho: %v", err)
+	}
+	n := 0
+	for {
+		resp, err := ss.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || resp.Message != "a: server" {
+			t.Fatalf("ServerStreamingEcho.Recv = %v, %v, want a: server", resp, err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("ServerStreamingEcho got %d messages, want 3", n)
+	}
+	header, _ = ss.Header()
+	checkHeader(t, "ServerStreamingEcho", header, ss.Trailer(), "a")
+
+	cs, err := client.ClientStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("ClientStreamingEcho: %v", err)
+	}
+	for _, msg := range []string{"1", "2", "3"} {
+		if err := cs.Send(&ecpb.EchoRequest{Message: msg}); err != nil {
+			t.Fatalf("ClientStreamingEcho.Send: %v", err)
+		}
+	}
+	if resp, err := cs.CloseAndRecv(); err != nil || resp.Message != "a: 1,2,3" {
+		t.Fatalf("ClientStreamingEcho.CloseAndRecv = %v, %v, want a: 1,2,3", resp, err)
+	}
+	header, _ = cs.Header()
+	checkHeader(t, "ClientStreamingEcho", header, cs.Trailer(), "a")
+
+	bidi, err := client.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho: %v", err)
+	}
+	for _, msg := range []string{"1", "2", "3"} {
+		if err := bidi.Send(&ecpb.EchoRequest{Message: msg}); err != nil {
+			t.Fatalf("BidirectionalStreamingEcho.Send: %v", err)
+		}
+		// Each reply comes back before the next message is sent.
+		if resp, err := bidi.Recv(); err != nil || resp.Message != "a: "+msg {
+			t.Fatalf("BidirectionalStreamingEcho.Recv = %v, %v, want a: %s", resp, err, msg)
+		}
+	}
+	bidi.CloseSend()
+	if _, err := bidi.Recv(); err != io.EOF {
+		t.Fatalf("BidirectionalStreamingEcho.Recv after CloseSend = %v, want EOF", err)
+	}
+	header, _ = bidi.Header()
+	checkHeader(t, "BidirectionalStreamingEcho", header, bidi.Trailer(), "a")
+}
+
+func TestRouting(t *testing.T) {
+	_, connA := startBackend(t, "a")
+	_, connB := startBackend(t, "b")
+	router := NewRouter()
+	router.Route("/grpc.examples.echo.Echo/", connA)
+	router.Route("/grpc.examples.echo.Echo/UnaryEcho", connB)
+	client := ecpb.NewEchoClient(startProxy(t, router))
+	ctx := testContext(t)
+
+	// The longest prefix wins.
+	if resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hi"}); err != nil || resp.Message != "b: hi" {
+		t.Errorf("UnaryEcho = %v, %v, want b: hi", resp, err)
+	}
+	ss, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho: %v", err)
+	}
+	if resp, err := ss.Recv(); err != nil || resp.Message != "a: hi" {
+		t.Errorf("ServerStreamingEcho.Recv = %v, %v, want a: hi", resp, err)
+	}
+
+	// The status of the backend reaches the client unchanged.
+	_, err = client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "fail"})
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || st.Message() != "b: invalid message" {
+		t.Errorf("UnaryEcho(fail) = %v, want INVALID_ARGUMENT from b", err)
+	}
+
+	router.Route("/grpc.examples.echo.Echo/", nil)
+	ss, err = client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{Message: "hi"})
+	if err == nil {
+		_, err = ss.Recv()
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("ServerStreamingEcho without a route = %v, want UNIMPLEMENTED", err)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	_, conn := startBackend(t, "a")
+	router := NewRouter()
+	router.Route("/grpc.examples.echo.Echo/", conn)
+	tokens, err := auth.NewTokens([]string{"alice=secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessLog, err := accesslog.New(accesslog.Options{Format: "json", Sinks: []string{"ring"}, RingSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accessLog.Close()
+	client := ecpb.NewEchoClient(startProxy(t, router,
+		grpc.ChainStreamInterceptor(accessLog.StreamServerInterceptor(), tokens.StreamServerInterceptor())))
+	ctx := testContext(t)
+
+	if _, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hi"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("UnaryEcho without a token = %v, want UNAUTHENTICATED", err)
+	}
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	if resp, err := client.UnaryEcho(authCtx, &ecpb.EchoRequest{Message: "hi"}); err != nil || resp.Message != "a: hi" {
+		t.Errorf("UnaryEcho with a token = %v, %v, want a: hi", resp, err)
+	}
+	bidi, err := client.BidirectionalStreamingEcho(authCtx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho: %v", err)
+	}
+	if err := bidi.Send(&ecpb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("BidirectionalStreamingEcho.Send: %v", err)
+	}
+	if resp, err := bidi.Recv(); err != nil || resp.Message != "a: hi" {
+		t.Errorf("BidirectionalStreamingEcho.Recv = %v, %v, want a: hi", resp, err)
+	}
+	bidi.CloseSend()
+	if _, err := bidi.Recv(); err != io.EOF {
+		t.Fatalf("BidirectionalStreamingEcho.Recv after CloseSend = %v, want EOF", err)
+	}
+
+	// The access log counts the bytes of the frames passed through.
+	type entry struct {
+		Method        string `json:"method"`
+		Code          string `json:"code"`
+		RequestBytes  int    `json:"request_bytes"`
+		ResponseBytes int    `json:"response_bytes"`
+		Principal     string `json:"principal"`
+	}
+	var entries []entry
+	for _, line := range accessLog.Ring().Lines() {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("access log line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d access log lines, want 3", len(entries))
+	}
+	if e := entries[0]; e.Code != "Unauthenticated" || e.Principal != "" {
+		t.Errorf("access log of the call without a token = %+v", e)
+	}
+	for _, e := range entries[1:] {
+		if e.Code != "OK" || e.Principal != "alice" || e.RequestBytes == 0 || e.ResponseBytes == 0 {
+			t.Errorf("access log of %s = %+v, want OK calls of alice with their sizes", e.Method, e)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	backend, conn := startBackend(t, "a")
+	router := NewRouter()
+	router.Route("/grpc.examples.echo.Echo/", conn)
+	client := ecpb.NewEchoClient(startProxy(t, router))
+
+	ctx, cancel := context.WithCancel(testContext(t))
+	bidi, err := client.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho: %v", err)
+	}
+	if err := bidi.Send(&ecpb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("BidirectionalStreamingEcho.Send: %v", err)
+	}
+	if _, err := bidi.Recv(); err != nil {
+		t.Fatalf("BidirectionalStreamingEcho.Recv: %v", err)
+	}
+	cancel()
+	select {
+	case err := <-backend.cancelled:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("backend stream ended with %v, want CANCELLED", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the call did not cancel the backend call")
+	}
+}
+
+// TestProxyHealth checks that the services of the proxy itself still get
+// decoded messages.
+func TestProxyHealth(t *testing.T) {
+	conn := startProxy(t, NewRouter())
+	resp, err := healthpb.NewHealthClient(conn).Check(testContext(t), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check = %v, %v, want SERVING", resp, err)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Router routes calls to backend connections by the prefix of their full
+// method name, e.g. /ecommerce.OrderManagement/ for all methods of a service
+// or /ecommerce.OrderManagement/processOrders for one method. The longest
+// matching prefix wins. Routes can be changed while the proxy runs.
+type Router struct {
+	mu sync.RWMutex
+	// routes are sorted by descending prefix length.
+	routes []route
+}
+
+type route struct {
+	prefix string
+	conn   *grpc.ClientConn
+}
+
+// NewRouter returns a Router without routes.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Route sends the calls of methods starting with prefix to conn, replacing
+// the route of the same prefix. The caller keeps owning conn; usually it
+// balances the calls over a pool of backends. A nil conn removes the route.
+func (r *Router) Route(prefix string, conn *grpc.ClientConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := make([]route, 0, len(r.routes)+1)
+	for _, rt := range r.routes {
+		if rt.prefix != prefix {
+			routes = append(routes, rt)
+		}
+	}
+	if conn != nil {
+		routes = append(routes, route{prefix: prefix, conn: conn})
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].prefix) > len(routes[j].prefix) })
+	r.routes = routes
+}
+
+// Director is the Director of the routes. Calls without a route fail with
+// UNIMPLEMENTED, as calls of an unknown service do on a server.
+func (r *Router) Director(ctx context.Context, fullMethod string) (context.Context, *grpc.ClientConn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rt := range r.routes {
+		if strings.HasPrefix(fullMethod, rt.prefix) {
+			return ctx, rt.conn, nil
+		}
+	}
+	return nil, nil, status.Errorf(codes.Unimplemented, "proxy: no route for %s", fullMethod)
+}
@@ -0,0 +1,75 @@
+// Package ratelimit limits the calls a server accepts with a token bucket.
+// The limit can be changed while the server runs, e.g. after a config
+// reload.
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Limiter rejects calls above the rate with RESOURCE_EXHAUSTED. Health
+// checks are never limited.
+type Limiter struct {
+	limiter atomic.Pointer[rate.Limiter]
+}
+
+// New returns a Limiter accepting rps calls per second with bursts of up to
+// burst calls. A zero rps disables the limit.
+func New(rps float64, burst int) *Limiter {
+	l := &Limiter{}
+	l.SetLimit(rps, burst)
+	return l
+}
+
+// SetLimit changes the rate and the burst. A limit set on a Limiter without
+// one starts with a full burst; otherwise the calls accepted so far still
+// count.
+func (l *Limiter) SetLimit(rps float64, burst int) {
+	limit := rate.Limit(rps)
+	if rps <= 0 {
+		limit = rate.Inf
+	}
+	current := l.limiter.Load()
+	if current == nil || current.Limit() == rate.Inf {
+		// An unlimited bucket does not fill up, so start from a new one.
+		l.limiter.Store(rate.NewLimiter(limit, burst))
+		return
+	}
+	current.SetLimit(limit)
+	current.SetBurst(burst)
+}
+
+func (l *Limiter) allow(fullMethod string) error {
+	if strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") || l.limiter.Load().Allow() {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "%s rejected by the rate limit, retry later", fullMethod)
+}
+
+// UnaryServerInterceptor limits the unary calls.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.allow(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the streams. The messages of an accepted
+// stream are not limited.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.allow(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ordersMethod = "/ecommerce.OrderManagement/getOrder"
+	healthMethod = "/grpc.health.v1.Health/Check"
+)
+
+func unary(l *Limiter, method string) error {
+	_, err := l.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	return err
+}
+
+func stream(l *Limiter, method string) error {
+	return l.StreamServerInterceptor()(nil, nil, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv interface{}, ss grpc.ServerStream) error { return nil })
+}
+
+func TestLimiter(t *testing.T) {
+	// A rate this low refills no token while the test runs.
+	l := New(0.001, 2)
+	for i := 0; i < 2; i++ {
+		if err := unary(l, ordersMethod); err != nil {
+			t.Fatalf("call %d within the burst = %v, want nil", i+1, err)
+		}
+	}
+	if err := unary(l, ordersMethod); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("unary call above the limit = %v, want RESOURCE_EXHAUSTED", err)
+	}
+	if err := stream(l, "/ecommerce.OrderManagement/processOrders"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("stream above the limit = %v, want RESOURCE_EXHAUSTED", err)
+	}
+	// Health checks are never limited.
+	for i := 0; i < 5; i++ {
+		if err := unary(l, healthMethod); err != nil {
+			t.Errorf("health check above the limit = %v, want nil", err)
+		}
+		if err := stream(l, "/grpc.health.v1.Health/Watch"); err != nil {
+			t.Errorf("health watch above the limit = %v, want nil", err)
+		}
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	l := New(0.001, 1)
+	if err := unary(l, ordersMethod); err != nil {
+		t.Fatalf("first call = %v, want nil", err)
+	}
+	if err := unary(l, ordersMethod); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second call = %v, want RESOURCE_EXHAUSTED", err)
+	}
+
+	// A zero rate disables the limit.
+	l.SetLimit(0, 0)
+	for i := 0; i < 10; i++ {
+		if err := unary(l, ordersMethod); err != nil {
+			t.Fatalf("call %d without a limit = %v, want nil", i+1, err)
+		}
+	}
+
+	// Limiting again starts with a full burst.
+	l.SetLimit(0.001, 1)
+	if err := unary(l, ordersMethod); err != nil {
+		t.Errorf("first call after limiting again = %v, want nil", err)
+	}
+	if err := unary(l, ordersMethod); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call after limiting again = %v, want RESOURCE_EXHAUSTED", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package requestid ties a client call to the server logs of the call with a
+// request ID sent in the x-request-id metadata.
+//
+// The client interceptors send the ID of the call context, or a new one. The
+// server interceptors take the ID of the call, or create one, put it in the
+// context of the handler and return it in the response headers, the trailers
+// and the details of errors:
+//
+//	x-request-id: 4bf92f3577b34da6a3ce929d0e0e4736
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Key is the metadata key carrying the request ID.
+const Key = "x-request-id"
+
+// maxLen is the longest request ID taken from a client. Longer or non
+// printable IDs are replaced.
+const maxLen = 128
+
+type idKey struct{}
+
+// NewContext returns a context carrying the request ID id. Calls made with
+// it send id instead of a new ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the request ID of the context.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey{}).(string)
+	return id, ok && id != ""
+}
+
+// New returns a random request ID of 32 hex digits.
+func New() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("requestid: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// FromMD returns the request ID of headers or trailers.
+func FromMD(md metadata.MD) (string, bool) {
+	if v := md.Get(Key); len(v) > 0 && v[0] != "" {
+		return v[0], true
+	}
+	return "", false
+}
+
+// FromError returns the request ID in the details of a status error.
+func FromError(err error) (string, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return "", false
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.RequestInfo); ok && info.RequestId != "" {
+			return info.RequestId, true
+		}
+	}
+	return "", false
+}
+
+// outgoing returns ctx with the request ID in its outgoing metadata. An ID
+// already in the metadata is kept.
+func outgoing(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if _, ok := FromMD(md); ok {
+		return ctx
+	}
+	id, ok := FromContext(ctx)
+	if !ok {
+		id = New()
+	}
+	return metadata.AppendToOutgoingContext(ctx, Key, id)
+}
+
+// UnaryClientInterceptor sends a request ID with every unary call.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor sends a request ID with every stream.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoing(ctx), desc, cc, method, opts...)
+	}
+}
+
+// incoming returns the request ID of the call, or a new one if the client
+// sent none or an unusable one.
+func incoming(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if id, ok := FromMD(md); ok && valid(id) {
+		return id
+	}
+	return New()
+}
+
+func valid(id string) bool {
+	if len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// withID adds the request ID to the details of err, unless they carry one.
+func withID(err error, id string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := FromError(err); ok {
+		return err
+	}
+	st, dErr := status.Convert(err).WithDetails(&errdetails.RequestInfo{RequestId: id})
+	if dErr != nil {
+		return err
+	}
+	return st.Err()
+}
+
+// UnaryServerInterceptor puts the request ID in the context of unary calls
+// and returns it to the client. Chain it before the logging interceptors, so
+// their logs carry the ID.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := incoming(ctx)
+		ctx = NewContext(ctx, id)
+		// SetHeader keeps the header open, so the handler can still add to it.
+		grpc.SetHeader(ctx, metadata.Pairs(Key, id))
+		grpc.SetTrailer(ctx, metadata.Pairs(Key, id))
+		resp, err := handler(ctx, req)
+		return resp, withID(err, id)
+	}
+}
+
+// StreamServerInterceptor puts the request ID in the context of streams and
+// returns it to the client.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := incoming(ss.Context())
+		ss.SetHeader(metadata.Pairs(Key, id))
+		ss.SetTrailer(metadata.Pairs(Key, id))
+		err := handler(srv, &idStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+		return withID(err, id)
+	}
+}
+
+// idStream carries the context with the request ID.
+type idStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *idStream) Context() context.Context {
+	return s.ctx
+}
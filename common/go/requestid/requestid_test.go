@@ -0,0 +1,135 @@
+package requestid
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ecServer echoes the request ID of the handler context, and fails calls
+// asking for it.
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	id, _ := FromContext(ctx)
+	if req.Message == "fail" {
+		return nil, status.Errorf(codes.NotFound, "no such order")
+	}
+	return &ecpb.EchoResponse{Message: id}, nil
+}
+
+func (s *ecServer) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	id, _ := FromContext(stream.Context())
+	return stream.Send(&ecpb.EchoResponse{Message: id})
+}
+
+func dial(t *testing.T) ecpb.EchoClient {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor()),
+		grpc.StreamInterceptor(StreamServerInterceptor()))
+	ecpb.RegisterEchoServer(s, &ecServer{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn)
+}
+
+func TestUnaryPropagation(t *testing.T) {
+	c := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var header, trailer metadata.MD
+	res, err := c.UnaryEcho(NewContext(ctx, "req-1"), &ecpb.EchoRequest{Message: "hi"},
+		grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	if res.Message != "req-1" {
+		t.Errorf("server saw request ID %q, want req-1", res.Message)
+	}
+	if id, _ := FromMD(header); id != "req-1" {
+		t.Errorf("header request ID = %q, want req-1", id)
+	}
+	if id, _ := FromMD(trailer); id != "req-1" {
+		t.Errorf("trailer request ID = %q, want req-1", id)
+	}
+
+	// Without an ID in the context, the client interceptor creates one.
+	header = nil
+	res, err = c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hi"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	if id, _ := FromMD(header); len(res.Message) != 32 || id != res.Message {
+		t.Errorf("server saw request ID %q and returned %q, want the same new ID", res.Message, id)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	c := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := c.UnaryEcho(NewContext(ctx, "req-2"), &ecpb.EchoRequest{Message: "fail"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("UnaryEcho() = %v, want NotFound", err)
+	}
+	if id, ok := FromError(err); !ok || id != "req-2" {
+		t.Errorf("FromError() = %q, %v, want req-2", id, ok)
+	}
+}
+
+func TestStreamPropagation(t *testing.T) {
+	c := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// An ID already in the outgoing metadata is sent as is.
+	ctx = metadata.AppendToOutgoingContext(ctx, Key, "req-3")
+	stream, err := c.ServerStreamingEcho(ctx, &ecpb.EchoRequest{})
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho() failed: %v", err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() failed: %v", err)
+	}
+	if res.Message != "req-3" {
+		t.Errorf("server saw request ID %q, want req-3", res.Message)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv() = %v, want EOF", err)
+	}
+	if id, _ := FromMD(stream.Trailer()); id != "req-3" {
+		t.Errorf("trailer request ID = %q, want req-3", id)
+	}
+}
+
+func TestInvalidIDIsReplaced(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(Key, "bad id"))
+	if id := incoming(ctx); id == "bad id" || len(id) != 32 {
+		t.Errorf("incoming() = %q, want a new ID", id)
+	}
+}
@@ -0,0 +1,70 @@
+package streamutil
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store is a map from string keys to values, e.g. the orders of a server by
+// ID, safe for concurrent use by the handlers of a server. The zero value is
+// an empty Store ready to use.
+type Store[V any] struct {
+	mu sync.RWMutex
+	m  map[string]V
+}
+
+// Put stores v under key, replacing the value stored before.
+func (s *Store[V]) Put(key string, v V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]V)
+	}
+	s.m[key] = v
+}
+
+// Get returns the value stored under key and whether there is one.
+func (s *Store[V]) Get(key string) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
+// Len returns the number of values.
+func (s *Store[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// Range calls fn for every value in key order until fn returns false or an
+// error, or until ctx is done. It returns the error of fn or the status of
+// ctx, so a streaming handler iterating the store stops as soon as its
+// client is gone. Values put while Range runs may or may not be visited; fn
+// may use the Store.
+func (s *Store[V]) Range(ctx context.Context, fn func(v V) (bool, error)) error {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.m))
+	for key := range s.m {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ContextErr(ctx); err != nil {
+			return err
+		}
+		v, _ := s.Get(key)
+		more, err := fn(v)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
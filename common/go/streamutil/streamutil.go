@@ -0,0 +1,59 @@
+// Package streamutil provides helpers for streaming handlers that stop
+// promptly once the other side of the stream has gone away.
+package streamutil
+
+import (
+	"context"
+
+	"google.golang.org/grpc/status"
+)
+
+// Sender is implemented by grpc.ServerStream and grpc.ClientStream.
+type Sender interface {
+	SendMsg(m interface{}) error
+}
+
+// Receiver is implemented by grpc.ServerStream and grpc.ClientStream.
+type Receiver interface {
+	RecvMsg(m interface{}) error
+}
+
+// ContextErr returns nil while ctx is alive and the status error matching
+// the reason ctx is done otherwise, i.e. CANCELLED or DEADLINE_EXCEEDED.
+func ContextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// SendContext sends m on s unless ctx is already done. When the send fails
+// because ctx ended meanwhile, the context status is returned instead of the
+// transport error.
+func SendContext(ctx context.Context, s Sender, m interface{}) error {
+	if err := ContextErr(ctx); err != nil {
+		return err
+	}
+	if err := s.SendMsg(m); err != nil {
+		if ctxErr := ContextErr(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RecvContext receives into m from r unless ctx is already done. Like
+// SendContext it prefers the context status over the transport error.
+func RecvContext(ctx context.Context, r Receiver, m interface{}) error {
+	if err := ContextErr(ctx); err != nil {
+		return err
+	}
+	if err := r.RecvMsg(m); err != nil {
+		if ctxErr := ContextErr(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
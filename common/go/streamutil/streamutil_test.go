@@ -0,0 +1,243 @@
+package streamutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream records the messages sent and returns err from SendMsg and
+// RecvMsg, after running before if set.
+type fakeStream struct {
+	sent   []interface{}
+	recvd  int
+	err    error
+	before func()
+}
+
+func (f *fakeStream) SendMsg(m interface{}) error {
+	if f.before != nil {
+		f.before()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func (f *fakeStream) RecvMsg(m interface{}) error {
+	if f.before != nil {
+		f.before()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.recvd++
+	return nil
+}
+
+func TestContextErr(t *testing.T) {
+	if err := ContextErr(context.Background()); err != nil {
+		t.Errorf("ContextErr(alive) = %v, want nil", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ContextErr(ctx); status.Code(err) != codes.Canceled {
+		t.Errorf("ContextErr(cancelled) = %v, want CANCELED", err)
+	}
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := ContextErr(ctx); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("ContextErr(expired) = %v, want DEADLINE_EXCEEDED", err)
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	s := &fakeStream{}
+	if err := SendContext(context.Background(), s, "m"); err != nil || len(s.sent) != 1 {
+		t.Errorf("SendContext() = %v after %d sends, want nil after 1", err, len(s.sent))
+	}
+
+	// A done context sends nothing.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s = &fakeStream{}
+	if err := SendContext(ctx, s, "m"); status.Code(err) != codes.Canceled || len(s.sent) != 0 {
+		t.Errorf("SendContext(cancelled) = %v after %d sends, want CANCELED after 0", err, len(s.sent))
+	}
+
+	// A send failing because the context ended meanwhile reports the context.
+	ctx, cancel = context.WithCancel(context.Background())
+	s = &fakeStream{err: io.EOF, before: cancel}
+	if err := SendContext(ctx, s, "m"); status.Code(err) != codes.Canceled {
+		t.Errorf("SendContext(cancelled while sending) = %v, want CANCELED", err)
+	}
+
+	// Other send errors are returned as they are.
+	s = &fakeStream{err: io.EOF}
+	if err := SendContext(context.Background(), s, "m"); err != io.EOF {
+		t.Errorf("SendContext() = %v, want EOF", err)
+	}
+}
+
+func TestRecvContext(t *testing.T) {
+	s := &fakeStream{}
+	if err := RecvContext(context.Background(), s, nil); err != nil || s.recvd != 1 {
+		t.Errorf("RecvContext() = %v after %d receives, want nil after 1", err, s.recvd)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	s = &fakeStream{}
+	if err := RecvContext(ctx, s, nil); status.Code(err) != codes.DeadlineExceeded || s.recvd != 0 {
+		t.Errorf("RecvContext(expired) = %v after %d receives, want DEADLINE_EXCEEDED after 0", err, s.recvd)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	s = &fakeStream{err: status.Error(codes.Canceled, "context canceled"), before: cancel}
+	if err := RecvContext(ctx, s, nil); status.Code(err) != codes.Canceled {
+		t.Errorf("RecvContext(cancelled while receiving) = %v, want CANCELED", err)
+	}
+
+	// The end of the stream is not an error of the context.
+	s = &fakeStream{err: io.EOF}
+	if err := RecvContext(context.Background(), s, nil); err != io.EOF {
+		t.Errorf("RecvContext() = %v, want EOF", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	var s Store[int]
+	if _, ok := s.Get("a"); ok || s.Len() != 0 {
+		t.Fatalf("zero Store is not empty")
+	}
+	for i, key := range []string{"c", "a", "b"} {
+		s.Put(key, i)
+	}
+	s.Put("c", 10)
+	if v, ok := s.Get("c"); !ok || v != 10 {
+		t.Errorf("Get(c) = %d, %v, want 10, true", v, ok)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+
+	var visited []int
+	err := s.Range(context.Background(), func(v int) (bool, error) {
+		visited = append(visited, v)
+		return true, nil
+	})
+	if err != nil || fmt.Sprint(visited) != "[1 2 10]" {
+		t.Errorf("Range() = %v visiting %v, want nil visiting [1 2 10] in key order", err, visited)
+	}
+
+	visited = nil
+	err = s.Range(context.Background(), func(v int) (bool, error) {
+		visited = append(visited, v)
+		return len(visited) < 2, nil
+	})
+	if err != nil || len(visited) != 2 {
+		t.Errorf("Range() = %v after %d values, want nil after 2", err, len(visited))
+	}
+
+	errStop := errors.New("stop")
+	if err := s.Range(context.Background(), func(int) (bool, error) { return true, errStop }); err != errStop {
+		t.Errorf("Range() = %v, want the error of fn", err)
+	}
+}
+
+func TestStoreRangeStopsWhenContextIsDone(t *testing.T) {
+	var s Store[int]
+	for i := 0; i < 5; i++ {
+		s.Put(fmt.Sprint(i), i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	err := s.Range(ctx, func(int) (bool, error) {
+		visited++
+		if visited == 2 {
+			cancel()
+		}
+		return true, nil
+	})
+	if status.Code(err) != codes.Canceled || visited != 2 {
+		t.Errorf("Range() = %v after %d values, want CANCELED after 2", err, visited)
+	}
+}
+
+// ecServer streams the values of a Store the way the order servers stream
+// their orders, and reports how its handler ended. Its work on the first
+// value lasts until the client is gone.
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	store *Store[string]
+	ended chan error
+}
+
+func (s *ecServer) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	ctx := stream.Context()
+	sent := 0
+	err := s.store.Range(ctx, func(v string) (bool, error) {
+		if err := SendContext(ctx, stream, &ecpb.EchoResponse{Message: v}); err != nil {
+			return false, err
+		}
+		if sent++; sent == 1 {
+			<-ctx.Done()
+		}
+		return true, nil
+	})
+	s.ended <- err
+	return err
+}
+
+// TestCancelledStream cancels a server stream while the handler ranges over
+// a Store and checks that the handler stops with CANCELED instead of sending
+// the other values.
+func TestCancelledStream(t *testing.T) {
+	srv := &ecServer{store: &Store[string]{}, ended: make(chan error, 1)}
+	for i := 0; i < 10; i++ {
+		srv.store.Put(fmt.Sprintf("%02d", i), fmt.Sprintf("order %d", i))
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := ecpb.NewEchoClient(conn).ServerStreamingEcho(ctx, &ecpb.EchoRequest{})
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho() failed: %v", err)
+	}
+	if res, err := stream.Recv(); err != nil || res.Message != "order 0" {
+		t.Fatalf("Recv() = %v, %v, want order 0", res, err)
+	}
+	cancel()
+
+	select {
+	case err := <-srv.ended:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("handler ended with %v, want CANCELED", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not stop after the client cancelled")
+	}
+}
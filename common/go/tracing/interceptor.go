@@ -0,0 +1,246 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadataCarrier lets the propagators read and write gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// spanName returns the span name and the attributes of a method, e.g.
+// ecommerce.OrderManagement/addOrder for /ecommerce.OrderManagement/addOrder.
+func spanName(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, semconv.RPCService(name[:i]), semconv.RPCMethod(name[i+1:]))
+	}
+	return name, attrs
+}
+
+// messageEvent records a message sent or received as a span event.
+func messageEvent(span trace.Span, typ attribute.KeyValue, id int, m interface{}) {
+	attrs := []attribute.KeyValue{typ, semconv.MessageIDKey.Int(id)}
+	if pm, ok := m.(proto.Message); ok {
+		attrs = append(attrs, semconv.MessageUncompressedSizeKey.Int(proto.Size(pm)))
+	}
+	span.AddEvent("message", trace.WithAttributes(attrs...))
+}
+
+// end sets the status of the call and ends the span.
+func end(span trace.Span, err error) {
+	s := status.Convert(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+	if s.Code() != codes.OK {
+		span.SetStatus(otelcodes.Error, s.Message())
+	}
+	span.End()
+}
+
+// startServerSpan starts the span of an incoming call as a child of the
+// caller's span.
+func (t *Tracing) startServerSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = t.propagator.Extract(ctx, metadataCarrier(md))
+	name, attrs := spanName(fullMethod)
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, semconv.NetworkPeerAddress(p.Addr.String()))
+	}
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// startClientSpan starts the span of an outgoing call and sends its context
+// in the metadata.
+func (t *Tracing) startClientSpan(ctx context.Context, method string, cc *grpc.ClientConn) (context.Context, trace.Span) {
+	name, attrs := spanName(method)
+	if cc != nil {
+		attrs = append(attrs, semconv.ServerAddress(cc.Target()))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	t.propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// UnaryServerInterceptor traces unary calls. Chain it before the logging
+// interceptors, so their records carry the trace ID of the call.
+func (t *Tracing) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := t.startServerSpan(ctx, info.FullMethod)
+		messageEvent(span, semconv.MessageTypeReceived, 1, req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			messageEvent(span, semconv.MessageTypeSent, 1, resp)
+		}
+		end(span, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor traces streams with an event for every message.
+func (t *Tracing) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := t.startServerSpan(ss.Context(), info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx, span: span})
+		end(span, err)
+		return err
+	}
+}
+
+// serverStream carries the span context and records the messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx            context.Context
+	span           trace.Span
+	received, sent int
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		messageEvent(s.span, semconv.MessageTypeReceived, s.received, m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		messageEvent(s.span, semconv.MessageTypeSent, s.sent, m)
+	}
+	return err
+}
+
+// UnaryClientInterceptor traces unary calls and propagates the trace
+// context to the server.
+func (t *Tracing) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := t.startClientSpan(ctx, method, cc)
+		messageEvent(span, semconv.MessageTypeSent, 1, req)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			messageEvent(span, semconv.MessageTypeReceived, 1, reply)
+		}
+		end(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor traces streams with an event for every message.
+// The span ends when the stream returns its status or the context of the
+// stream is done.
+func (t *Tracing) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := t.startClientSpan(ctx, method, cc)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			end(span, err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, desc: desc, span: span, done: make(chan struct{})}
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.finish(ctx.Err())
+			case <-s.done:
+			}
+		}()
+		return s, nil
+	}
+}
+
+// clientStream records the messages and ends the span with the status of
+// the stream.
+type clientStream struct {
+	grpc.ClientStream
+	desc           *grpc.StreamDesc
+	span           trace.Span
+	received, sent int
+
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		end(s.span, err)
+		close(s.done)
+	})
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	default:
+		s.received++
+		messageEvent(s.span, semconv.MessageTypeReceived, s.received, m)
+		// Without server streaming the only response ends the call.
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		messageEvent(s.span, semconv.MessageTypeSent, s.sent, m)
+	} else if err != io.EOF {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish(err)
+	}
+	return md, err
+}
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/grpc-up-and-running/samples/common/go/internal/otlpjson"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpExporter posts spans to an OTLP/HTTP collector in the JSON encoding of
+// OTLP.
+type otlpExporter struct {
+	client *otlpjson.Client
+}
+
+// newOTLPExporter returns an exporter for endpoint, a host:port address or
+// a URL. The path defaults to /v1/traces.
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	client, err := otlpjson.NewClient(endpoint, "/v1/traces")
+	if err != nil {
+		return nil, err
+	}
+	return &otlpExporter{client: client}, nil
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.client.Post(ctx, otlpRequest(spans))
+}
+
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.Close()
+	return nil
+}
+
+// The types below are the JSON mapping of the OTLP trace request.
+
+type jsonRequest struct {
+	ResourceSpans []jsonResourceSpans `json:"resourceSpans"`
+}
+
+type jsonResourceSpans struct {
+	Resource   otlpjson.Resource `json:"resource"`
+	ScopeSpans []jsonScopeSpans  `json:"scopeSpans"`
+}
+
+type jsonScopeSpans struct {
+	Scope otlpjson.Scope `json:"scope"`
+	Spans []jsonSpan     `json:"spans"`
+}
+
+type jsonSpan struct {
+	TraceID           string              `json:"traceId"`
+	SpanID            string              `json:"spanId"`
+	ParentSpanID      string              `json:"parentSpanId,omitempty"`
+	Name              string              `json:"name"`
+	Kind              int                 `json:"kind"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string              `json:"endTimeUnixNano"`
+	Attributes        []otlpjson.KeyValue `json:"attributes,omitempty"`
+	Events            []jsonEvent         `json:"events,omitempty"`
+	Status            jsonStatus          `json:"status"`
+}
+
+type jsonEvent struct {
+	TimeUnixNano string              `json:"timeUnixNano"`
+	Name         string              `json:"name"`
+	Attributes   []otlpjson.KeyValue `json:"attributes,omitempty"`
+}
+
+type jsonStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpRequest groups the spans by resource and instrumentation scope.
+func otlpRequest(spans []sdktrace.ReadOnlySpan) jsonRequest {
+	var req jsonRequest
+	resources := make(map[attribute.Distinct]int)
+	for _, s := range spans {
+		rkey := s.Resource().Equivalent()
+		ri, ok := resources[rkey]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resources[rkey] = ri
+			req.ResourceSpans = append(req.ResourceSpans, jsonResourceSpans{Resource: otlpjson.ResourceOf(s.Resource())})
+		}
+		rs := &req.ResourceSpans[ri]
+		scope := otlpjson.ScopeOf(s.InstrumentationScope())
+		si := -1
+		for i, ss := range rs.ScopeSpans {
+			if ss.Scope == scope {
+				si = i
+			}
+		}
+		if si < 0 {
+			si = len(rs.ScopeSpans)
+			rs.ScopeSpans = append(rs.ScopeSpans, jsonScopeSpans{Scope: scope})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, jsonSpanOf(s))
+	}
+	return req
+}
+
+func jsonSpanOf(s sdktrace.ReadOnlySpan) jsonSpan {
+	sc := s.SpanContext()
+	span := jsonSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: otlpjson.UnixNano(s.StartTime()),
+		EndTimeUnixNano:   otlpjson.UnixNano(s.EndTime()),
+		Attributes:        otlpjson.Attributes(s.Attributes()),
+	}
+	if parent := s.Parent(); parent.IsValid() {
+		span.ParentSpanID = parent.SpanID().String()
+	}
+	for _, ev := range s.Events() {
+		span.Events = append(span.Events, jsonEvent{
+			TimeUnixNano: otlpjson.UnixNano(ev.Time),
+			Name:         ev.Name,
+			Attributes:   otlpjson.Attributes(ev.Attributes),
+		})
+	}
+	// OTLP numbers the codes UNSET, OK, ERROR; the SDK Unset, Error, Ok.
+	switch s.Status().Code {
+	case otelcodes.Ok:
+		span.Status = jsonStatus{Code: 1}
+	case otelcodes.Error:
+		span.Status = jsonStatus{Code: 2, Message: s.Status().Description}
+	}
+	return span
+}
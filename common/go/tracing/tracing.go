@@ -0,0 +1,131 @@
+// Package tracing sets up OpenTelemetry tracing for the sample servers and
+// clients.
+//
+// Calls carry the W3C traceparent header in their metadata, so the spans of a
+// client call and of the server handling it belong to one trace:
+//
+//	traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//
+// Every message a call sends or receives is recorded as a span event. Spans
+// are exported over OTLP/HTTP, written to stdout or kept in memory for tests.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the interceptors.
+const instrumentationName = "github.com/grpc-up-and-running/samples/common/go/tracing"
+
+// Options configures Tracing.
+type Options struct {
+	// ServiceName is the service.name of the spans, e.g. order-service.
+	ServiceName string
+	// Exporter is otlp, stdout, memory or none. It defaults to otlp when an
+	// endpoint is set and to none otherwise. Without an exporter spans are
+	// still created and propagated, e.g. for the trace IDs in logs, but not
+	// exported.
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector, e.g. localhost:4318 or
+	// http://collector:4318/v1/traces.
+	Endpoint string
+	// SampleRate is the fraction of new traces sampled. Calls of a sampled
+	// trace are always sampled.
+	SampleRate float64
+	// Output replaces os.Stdout for the stdout exporter.
+	Output io.Writer
+}
+
+// Tracing holds the tracer provider of a sample and creates its
+// interceptors.
+type Tracing struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	memory     *tracetest.InMemoryExporter
+}
+
+// Setup creates the tracer provider of opts and installs it, with the W3C
+// trace context and baggage propagators, as the global one. Call Shutdown
+// before the process exits to flush the spans.
+func Setup(opts Options) (*Tracing, error) {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRate))),
+	}
+
+	t := &Tracing{}
+	exporter := opts.Exporter
+	if exporter == "" && opts.Endpoint != "" {
+		exporter = "otlp"
+	}
+	switch exporter {
+	case "", "none":
+	case "otlp":
+		exp, err := newOTLPExporter(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exp))
+	case "stdout":
+		out := opts.Output
+		if out == nil {
+			out = os.Stdout
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, err
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exp))
+	case "memory":
+		// Spans are exported when they end, so tests see them right away.
+		t.memory = tracetest.NewInMemoryExporter()
+		providerOpts = append(providerOpts, sdktrace.WithSyncer(t.memory))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, want otlp, stdout, memory or none", exporter)
+	}
+
+	t.provider = sdktrace.NewTracerProvider(providerOpts...)
+	t.tracer = t.provider.Tracer(instrumentationName)
+	t.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	otel.SetTracerProvider(t.provider)
+	otel.SetTextMapPropagator(t.propagator)
+	return t, nil
+}
+
+// Tracer returns a tracer for spans of the sample's own code, e.g. a client
+// span around several calls.
+func (t *Tracing) Tracer(name string) trace.Tracer {
+	return t.provider.Tracer(name)
+}
+
+// Spans returns the ended spans kept by the memory exporter.
+func (t *Tracing) Spans() tracetest.SpanStubs {
+	if t.memory == nil {
+		return nil
+	}
+	return t.memory.GetSpans()
+}
+
+// Shutdown exports the remaining spans and stops the exporter. It fits
+// lifecycle.WithShutdownHook.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
@@ -0,0 +1,263 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/status"
+)
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	if req.Message == "fail" {
+		return nil, status.Error(codes.NotFound, "no such order")
+	}
+	return &ecpb.EchoResponse{Message: req.Message}, nil
+}
+
+func (s *ecServer) BidirectionalStreamingEcho(stream ecpb.Echo_BidirectionalStreamingEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&ecpb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// setup starts a traced echo server and returns a traced client, both
+// exporting to the memory exporter of t.
+func setup(t *testing.T) (*Tracing, ecpb.EchoClient) {
+	tr, err := Setup(Options{ServiceName: "echo", Exporter: "memory", SampleRate: 1})
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	t.Cleanup(func() { tr.Shutdown(context.Background()) })
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(tr.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(tr.StreamServerInterceptor()))
+	ecpb.RegisterEchoServer(s, &ecServer{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(tr.StreamClientInterceptor()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return tr, ecpb.NewEchoClient(conn)
+}
+
+// find returns the span with the name and kind.
+func find(t *testing.T, spans tracetest.SpanStubs, name string, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name && s.SpanKind == kind {
+			return s
+		}
+	}
+	t.Fatalf("no %v span %q in %d spans", kind, name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func attr(s tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// waitForSpans waits until n spans ended; server spans end after the client
+// got the response.
+func waitForSpans(t *testing.T, tr *Tracing, n int) tracetest.SpanStubs {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		spans := tr.Spans()
+		if len(spans) >= n || time.Now().After(deadline) {
+			return spans
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSpanTree(t *testing.T) {
+	tr, c := setup(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctx, parent := tr.Tracer("test").Start(ctx, "place order")
+	if _, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "hello"}); err != nil {
+		t.Fatalf("UnaryEcho() failed: %v", err)
+	}
+	if _, err := c.UnaryEcho(ctx, &ecpb.EchoRequest{Message: "fail"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("UnaryEcho() = %v, want NotFound", err)
+	}
+	parent.End()
+
+	// place order, and a client and a server span for each call.
+	spans := waitForSpans(t, tr, 5)
+	if len(spans) != 5 {
+		t.Fatalf("got %d spans, want 5", len(spans))
+	}
+	root := find(t, spans, "place order", trace.SpanKindInternal)
+	var clients, servers []tracetest.SpanStub
+	for _, s := range spans {
+		switch s.SpanKind {
+		case trace.SpanKindClient:
+			clients = append(clients, s)
+		case trace.SpanKindServer:
+			servers = append(servers, s)
+		}
+	}
+	for _, client := range clients {
+		if client.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("client span %v is not a child of the root span", client.SpanContext.SpanID())
+		}
+		// Each client span has exactly one server span as its child.
+		var children int
+		for _, server := range servers {
+			if server.Parent.SpanID() == client.SpanContext.SpanID() {
+				children++
+				if server.SpanContext.TraceID() != root.SpanContext.TraceID() {
+					t.Errorf("server span is not part of the trace of the client")
+				}
+				if !server.Parent.IsRemote() {
+					t.Errorf("parent of the server span is not remote")
+				}
+			}
+		}
+		if children != 1 {
+			t.Errorf("client span has %d server children, want 1", children)
+		}
+	}
+
+	for _, s := range servers {
+		if got := attr(s, "rpc.method").AsString(); got != "UnaryEcho" {
+			t.Errorf("rpc.method = %q, want UnaryEcho", got)
+		}
+		if got := attr(s, "rpc.service").AsString(); got != "grpc.examples.echo.Echo" {
+			t.Errorf("rpc.service = %q", got)
+		}
+		code := attr(s, "rpc.grpc.status_code").AsInt64()
+		switch codes.Code(code) {
+		case codes.OK:
+			if s.Status.Code == otelcodes.Error || len(s.Events) != 2 {
+				t.Errorf("successful call has status %v and %d events, want 2", s.Status, len(s.Events))
+			}
+		case codes.NotFound:
+			if s.Status.Code != otelcodes.Error || s.Status.Description != "no such order" {
+				t.Errorf("failed call has status %v", s.Status)
+			}
+		default:
+			t.Errorf("unexpected status code %d", code)
+		}
+	}
+}
+
+func TestStreamMessageEvents(t *testing.T) {
+	tr, c := setup(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.BidirectionalStreamingEcho(ctx)
+	if err != nil {
+		t.Fatalf("BidirectionalStreamingEcho() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&ecpb.EchoRequest{Message: "order"}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv() = %v, want EOF", err)
+	}
+
+	spans := waitForSpans(t, tr, 2)
+	const name = "grpc.examples.echo.Echo/BidirectionalStreamingEcho"
+	for _, kind := range []trace.SpanKind{trace.SpanKindClient, trace.SpanKindServer} {
+		s := find(t, spans, name, kind)
+		var sent, received int
+		for _, ev := range s.Events {
+			if ev.Name != "message" {
+				continue
+			}
+			for _, kv := range ev.Attributes {
+				if kv.Key == "message.type" && kv.Value.AsString() == "SENT" {
+					sent++
+				}
+				if kv.Key == "message.type" && kv.Value.AsString() == "RECEIVED" {
+					received++
+				}
+			}
+		}
+		if sent != 3 || received != 3 {
+			t.Errorf("%v span has %d sent and %d received message events, want 3 each", kind, sent, received)
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request to %s with content type %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	tr, err := Setup(Options{ServiceName: "order-service", Endpoint: collector.Listener.Addr().String(), SampleRate: 1})
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	_, span := tr.Tracer("test").Start(context.Background(), "process order")
+	span.SetStatus(otelcodes.Error, "out of stock")
+	span.End()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+
+	rs := got["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	exported := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	if exported["name"] != "process order" || exported["traceId"] != span.SpanContext().TraceID().String() {
+		t.Errorf("exported span = %v", exported)
+	}
+	if st := exported["status"].(map[string]interface{}); st["code"] != 2.0 || st["message"] != "out of stock" {
+		t.Errorf("exported status = %v, want code 2 (ERROR)", st)
+	}
+}
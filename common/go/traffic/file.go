@@ -0,0 +1,341 @@
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the resources of a control plane, usually read from a file,
+//
+//	listeners:
+//	  - name: lb.example.grpc.io
+//	    route_config: echo-routes
+//	route_configs:
+//	  - name: echo-routes
+//	    routes:
+//	      - match: {headers: [{name: x-canary, exact_match: "true"}]}
+//	        cluster: echo-canary
+//	      - match: {prefix: /grpc.examples.echo.Echo/}
+//	        weighted_clusters:
+//	          - {name: echo-stable, weight: 90}
+//	          - {name: echo-canary, weight: 10}
+//	clusters:
+//	  - name: echo-stable
+//	    lb_policy: [{round_robin: {}}]
+//	  - name: echo-canary
+//	    service: lb.example.grpc.io-canary
+//	endpoints:
+//	  - cluster_name: echo-stable
+//	    endpoints:
+//	      - addr: localhost:50051
+//
+// A listener is what a client asks for with xds:///name. Files ending in
+// .json are read as JSON with the same keys.
+type Config struct {
+	Listeners    []Listener              `json:"listeners" yaml:"listeners"`
+	RouteConfigs []RouteConfiguration    `json:"route_configs" yaml:"route_configs"`
+	Clusters     []Cluster               `json:"clusters" yaml:"clusters"`
+	Endpoints    []ClusterLoadAssignment `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+}
+
+// Listener names the route configuration of a target.
+type Listener struct {
+	Name        string `json:"name" yaml:"name"`
+	RouteConfig string `json:"route_config" yaml:"route_config"`
+}
+
+// RouteConfiguration is a list of routes; a call takes the first one that
+// matches.
+type RouteConfiguration struct {
+	Name   string  `json:"name" yaml:"name"`
+	Routes []Route `json:"routes" yaml:"routes"`
+}
+
+// Route sends the calls it matches to Cluster, or splits them among
+// WeightedClusters by weight.
+type Route struct {
+	Match            RouteMatch        `json:"match" yaml:"match"`
+	Cluster          string            `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	WeightedClusters []WeightedCluster `json:"weighted_clusters,omitempty" yaml:"weighted_clusters,omitempty"`
+}
+
+// RouteMatch matches the full method name of a call, e.g.
+// /ecommerce.ProductInfo/getProduct, by Prefix or Path, and its metadata.
+// An empty match matches all calls.
+type RouteMatch struct {
+	Prefix  string          `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Path    string          `json:"path,omitempty" yaml:"path,omitempty"`
+	Headers []HeaderMatcher `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// HeaderMatcher matches the metadata values of Name.
+type HeaderMatcher struct {
+	Name       string `json:"name" yaml:"name"`
+	ExactMatch string `json:"exact_match" yaml:"exact_match"`
+}
+
+// WeightedCluster is a cluster with its share of the calls of a route.
+type WeightedCluster struct {
+	Name   string `json:"name" yaml:"name"`
+	Weight uint32 `json:"weight" yaml:"weight"`
+}
+
+// Cluster is a group of backends with a load balancing policy.
+type Cluster struct {
+	Name string `json:"name" yaml:"name"`
+	// LBPolicy is a gRPC loadBalancingConfig list, round_robin when empty.
+	LBPolicy discovery.ServiceConfigJSON `json:"lb_policy,omitempty" yaml:"lb_policy,omitempty"`
+	// Service is the service in the discovery source of the Server with the
+	// endpoints of a cluster without endpoints in the Config.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+}
+
+// ClusterLoadAssignment holds the endpoints of a cluster.
+type ClusterLoadAssignment struct {
+	ClusterName string               `json:"cluster_name" yaml:"cluster_name"`
+	Endpoints   []discovery.Endpoint `json:"endpoints" yaml:"endpoints"`
+}
+
+// ParseFile parses and validates a control plane file. name is only used to
+// choose between JSON and YAML.
+func ParseFile(name string, data []byte) (*Config, error) {
+	cfg := &Config{}
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("traffic: parsing %s: %v", name, err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("traffic: parsing %s: %v", name, err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the names are unique and that the resources refer to
+// ones that exist.
+func (c *Config) Validate() error {
+	routeConfigs := make(map[string]bool)
+	for _, rc := range c.RouteConfigs {
+		if rc.Name == "" || routeConfigs[rc.Name] {
+			return fmt.Errorf("traffic: route config name %q is empty or not unique", rc.Name)
+		}
+		routeConfigs[rc.Name] = true
+	}
+	listeners := make(map[string]bool)
+	for _, l := range c.Listeners {
+		if l.Name == "" || listeners[l.Name] {
+			return fmt.Errorf("traffic: listener name %q is empty or not unique", l.Name)
+		}
+		listeners[l.Name] = true
+		if !routeConfigs[l.RouteConfig] {
+			return fmt.Errorf("traffic: listener %s: unknown route config %q", l.Name, l.RouteConfig)
+		}
+	}
+	clusters := make(map[string]bool)
+	for _, cl := range c.Clusters {
+		if cl.Name == "" || clusters[cl.Name] {
+			return fmt.Errorf("traffic: cluster name %q is empty or not unique", cl.Name)
+		}
+		clusters[cl.Name] = true
+		if cl.LBPolicy != "" {
+			var policies []map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(cl.LBPolicy), &policies); err != nil || len(policies) == 0 {
+				return fmt.Errorf("traffic: cluster %s: lb_policy %s is not a loadBalancingConfig list", cl.Name, cl.LBPolicy)
+			}
+		}
+	}
+	for _, rc := range c.RouteConfigs {
+		for i, r := range rc.Routes {
+			if err := r.validate(clusters); err != nil {
+				return fmt.Errorf("traffic: route config %s: route %d: %v", rc.Name, i, err)
+			}
+		}
+	}
+	assigned := make(map[string]bool)
+	for _, cla := range c.Endpoints {
+		if !clusters[cla.ClusterName] || assigned[cla.ClusterName] {
+			return fmt.Errorf("traffic: endpoints of unknown or repeated cluster %q", cla.ClusterName)
+		}
+		assigned[cla.ClusterName] = true
+		for _, e := range cla.Endpoints {
+			if _, _, err := net.SplitHostPort(e.Addr); err != nil {
+				return fmt.Errorf("traffic: cluster %s: %q is not a host:port address", cla.ClusterName, e.Addr)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Route) validate(clusters map[string]bool) error {
+	m := r.Match
+	if m.Prefix != "" && m.Path != "" {
+		return errors.New("match has both a prefix and a path")
+	}
+	if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+		return fmt.Errorf("path %q does not start with /", m.Path)
+	}
+	for _, h := range m.Headers {
+		if h.Name == "" || h.Name != strings.ToLower(h.Name) {
+			return fmt.Errorf("header name %q is empty or not in lower case", h.Name)
+		}
+	}
+	if (r.Cluster == "") == (len(r.WeightedClusters) == 0) {
+		return errors.New("route needs either a cluster or weighted clusters")
+	}
+	if r.Cluster != "" && !clusters[r.Cluster] {
+		return fmt.Errorf("unknown cluster %q", r.Cluster)
+	}
+	for _, wc := range r.WeightedClusters {
+		if !clusters[wc.Name] {
+			return fmt.Errorf("unknown cluster %q", wc.Name)
+		}
+		if wc.Weight == 0 {
+			return fmt.Errorf("cluster %s has weight 0", wc.Name)
+		}
+	}
+	return nil
+}
+
+// Store keeps the Config served by a Server. Streams pick up a new Config
+// right away.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	cfg     *Config
+	version uint64
+	// changed is closed and replaced whenever the Config changes.
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// NewStore returns a Store holding cfg. It fails if cfg is not valid.
+func NewStore(cfg *Config) (*Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Store{cfg: cfg, version: 1, changed: make(chan struct{}), done: make(chan struct{})}, nil
+}
+
+// NewFileStore reads the control plane file at path. It fails if the file
+// cannot be read or parsed.
+func NewFileStore(path string) (*Store, error) {
+	cfg, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.path = path
+	return s, nil
+}
+
+func readFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(path, data)
+}
+
+// Set replaces the Config. It fails if cfg is not valid.
+func (s *Store) Set(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.DeepEqual(s.cfg, cfg) {
+		return nil
+	}
+	s.cfg = cfg
+	s.version++
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return nil
+}
+
+// snapshot returns the Config, its version and a channel closed once it
+// changes.
+func (s *Store) snapshot() (*Config, uint64, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg, s.version, s.changed
+}
+
+// WatchFile polls the file of a Store from NewFileStore every interval and
+// reads it again when it was modified, until Close is called. A file that
+// cannot be read or parsed is logged and the Config read before stays in
+// place.
+func (s *Store) WatchFile(interval time.Duration) {
+	modTime := func() time.Time {
+		fi, err := os.Stat(s.path)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	last := modTime()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if mt := modTime(); !mt.Equal(last) {
+					last = mt
+					s.reload()
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Store) reload() {
+	cfg, err := readFile(s.path)
+	if err == nil {
+		err = s.Set(cfg)
+	}
+	if err != nil {
+		log.Printf("traffic: reading %s failed, keeping the resources: %v", s.path, err)
+		return
+	}
+	log.Printf("traffic: read the resources of %s", s.path)
+}
+
+// Close stops watching the file.
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
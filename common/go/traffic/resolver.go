@@ -0,0 +1,549 @@
+// Package traffic is a minimal xDS control plane serving listeners, routes,
+// clusters and endpoints from a file, and the client side of it: a resolver
+// for xds:///listener targets and the traffic_routing load balancing policy.
+//
+// The control plane (Server) speaks the state of the world variant of xDS's
+// aggregated discovery service (ADS). A client asks over one stream for the
+// listener named by its target (LDS), the route configuration of the
+// listener (RDS), the clusters of its routes (CDS) and their endpoints
+// (EDS). The routes pick a cluster per call by the method and metadata of
+// the call and split the calls among weighted clusters, e.g. 90% to a stable
+// cluster and 10% to a canary; each cluster has its own load balancing
+// policy. Changes to the file reach the clients right away.
+//
+// Only a subset of the xDS resources is supported, the one the Server
+// produces: an api_listener whose HTTP connection manager takes its route
+// configuration from RDS or inline, routes matching by path prefix, path and
+// exact metadata values, EDS clusters served over ADS with a
+// load_balancing_policy, and endpoints with socket addresses. The client
+// NACKs resources outside of it. The messages of xdspb keep the names and
+// field numbers of Envoy's, so they are valid xDS resources, but they are
+// registered under the same protobuf names as the ones of
+// google.golang.org/grpc/xds and go-control-plane: a binary cannot link
+// both.
+//
+// The package registers no resolver; pass NewBuilder to grpc.WithResolvers.
+// A Builder without a bootstrap reads the bootstrap file named by
+// GRPC_XDS_BOOTSTRAP, or its content in GRPC_XDS_BOOTSTRAP_CONFIG, in the
+// format of gRPC's own xDS support,
+//
+//	{
+//	  "xds_servers": [{"server_uri": "localhost:18000", "channel_creds": [{"type": "insecure"}], "server_features": ["xds_v3"]}],
+//	  "node": {"id": "echo-client"}
+//	}
+package traffic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/traffic/xdspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Scheme is the scheme of targets resolved by the control plane, e.g.
+// xds:///ecommerce.ProductInfo.
+const Scheme = "xds"
+
+// Environment variables of the bootstrap file: the path of the file, or its
+// content.
+const (
+	BootstrapFileEnv   = "GRPC_XDS_BOOTSTRAP"
+	BootstrapConfigEnv = "GRPC_XDS_BOOTSTRAP_CONFIG"
+)
+
+// Delays between attempts to open the ADS stream again after the control
+// plane could not be reached.
+const (
+	watchRetryMin = 500 * time.Millisecond
+	watchRetryMax = 30 * time.Second
+)
+
+// Bootstrap tells a client where the control plane is.
+type Bootstrap struct {
+	// ServerURI is the address of the control plane.
+	ServerURI string
+	// NodeID identifies the client to the control plane.
+	NodeID string
+}
+
+// bootstrapFile is the part of gRPC's bootstrap file the package reads.
+type bootstrapFile struct {
+	XDSServers []struct {
+		ServerURI    string `json:"server_uri"`
+		ChannelCreds []struct {
+			Type string `json:"type"`
+		} `json:"channel_creds"`
+	} `json:"xds_servers"`
+	Node struct {
+		ID string `json:"id"`
+	} `json:"node"`
+}
+
+// ParseBootstrap parses a bootstrap file. The first of the xds_servers is
+// used; it must allow insecure channel credentials.
+func ParseBootstrap(data []byte) (Bootstrap, error) {
+	var f bootstrapFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Bootstrap{}, fmt.Errorf("traffic: invalid bootstrap file: %v", err)
+	}
+	if len(f.XDSServers) == 0 || f.XDSServers[0].ServerURI == "" {
+		return Bootstrap{}, errors.New("traffic: bootstrap file has no xds_servers with a server_uri")
+	}
+	server := f.XDSServers[0]
+	insecure := len(server.ChannelCreds) == 0
+	for _, creds := range server.ChannelCreds {
+		insecure = insecure || creds.Type == "insecure"
+	}
+	if !insecure {
+		return Bootstrap{}, fmt.Errorf("traffic: xds server %s: only insecure channel_creds are supported", server.ServerURI)
+	}
+	return Bootstrap{ServerURI: server.ServerURI, NodeID: f.Node.ID}, nil
+}
+
+// bootstrapFromEnv reads the bootstrap file of the environment.
+func bootstrapFromEnv() (Bootstrap, error) {
+	if path := os.Getenv(BootstrapFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Bootstrap{}, fmt.Errorf("traffic: reading the bootstrap file: %v", err)
+		}
+		return ParseBootstrap(data)
+	}
+	if js := os.Getenv(BootstrapConfigEnv); js != "" {
+		return ParseBootstrap([]byte(js))
+	}
+	return Bootstrap{}, fmt.Errorf("traffic: neither %s nor %s is set", BootstrapFileEnv, BootstrapConfigEnv)
+}
+
+// Builder builds resolvers for xds:///listener targets.
+type Builder struct {
+	// bootstrap is read from the environment on Build when it is empty.
+	bootstrap Bootstrap
+}
+
+// NewBuilder returns a Builder for the control plane of bootstrap, or of the
+// bootstrap file of the environment if bootstrap is empty. Pass it to
+// grpc.WithResolvers.
+func NewBuilder(bootstrap Bootstrap) *Builder {
+	return &Builder{bootstrap: bootstrap}
+}
+
+// Scheme returns "xds".
+func (b *Builder) Scheme() string { return Scheme }
+
+// Build starts watching the listener named by the target.
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	listener := strings.TrimPrefix(target.URL.Path, "/")
+	if listener == "" {
+		listener = target.URL.Opaque
+	}
+	if listener == "" {
+		return nil, fmt.Errorf("traffic: target %q names no listener", target.URL.String())
+	}
+	bootstrap := b.bootstrap
+	if bootstrap.ServerURI == "" {
+		var err error
+		if bootstrap, err = bootstrapFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := grpc.Dial(bootstrap.ServerURI, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("traffic: control plane %s: %v", bootstrap.ServerURI, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &xdsResolver{
+		target:       listener,
+		node:         &xdspb.Node{Id: bootstrap.NodeID},
+		cc:           cc,
+		conn:         conn,
+		cancel:       cancel,
+		retry:        make(chan struct{}, 1),
+		subs:         make(map[string]*clientSubscription),
+		routeConfigs: make(map[string]*xdspb.RouteConfiguration),
+		clusters:     make(map[string]*xdspb.Cluster),
+		endpoints:    make(map[string]*xdspb.ClusterLoadAssignment),
+	}
+	for _, typ := range resourceTypes {
+		r.subs[typ] = &clientSubscription{}
+	}
+	r.subs[listenerType].names = []string{listener}
+	go r.watch(ctx)
+	return r, nil
+}
+
+// clientSubscription is what a client asks for of one resource type.
+type clientSubscription struct {
+	names []string
+	// version is the one of the last accepted response, nonce the one of the
+	// last response of the stream.
+	version string
+	nonce   string
+	// received is set once a response was accepted.
+	received bool
+}
+
+// xdsResolver keeps an ADS stream open. Everything but the fields set by
+// Build is only used by the watch goroutine.
+type xdsResolver struct {
+	target string
+	node   *xdspb.Node
+	cc     resolver.ClientConn
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+	retry  chan struct{}
+
+	subs map[string]*clientSubscription
+	// listener is the listener of the target, nil if the control plane has
+	// none.
+	listener     *xdspb.Listener
+	routeConfigs map[string]*xdspb.RouteConfiguration
+	clusters     map[string]*xdspb.Cluster
+	endpoints    map[string]*xdspb.ClusterLoadAssignment
+	// known is set once the ClientConn got the resources.
+	known bool
+}
+
+// watch keeps an ADS stream open until ctx is done. While the control plane
+// cannot be reached the ClientConn keeps the resources received last.
+func (r *xdsResolver) watch(ctx context.Context) {
+	client := xdspb.NewAggregatedDiscoveryServiceClient(r.conn)
+	delay := watchRetryMin
+	for {
+		err := r.stream(ctx, client, func() { delay = watchRetryMin })
+		if ctx.Err() != nil {
+			return
+		}
+		if !r.known {
+			r.cc.ReportError(fmt.Errorf("traffic: watching listener %s: %v", r.target, err))
+		}
+		log.Printf("traffic: watching listener %s: %v, retrying in %v", r.target, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-r.retry:
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > watchRetryMax {
+			delay = watchRetryMax
+		}
+	}
+}
+
+// stream asks for the resources over a new stream, with the versions the
+// client already has, and handles the responses until the stream breaks.
+func (r *xdsResolver) stream(ctx context.Context, client xdspb.AggregatedDiscoveryServiceClient, received func()) error {
+	stream, err := client.StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+	first := true
+	for _, typ := range resourceTypes {
+		sub := r.subs[typ]
+		sub.nonce = ""
+		if len(sub.names) == 0 {
+			continue
+		}
+		req := &xdspb.DiscoveryRequest{VersionInfo: sub.version, ResourceNames: sub.names, TypeUrl: typ}
+		if first {
+			req.Node, first = r.node, false
+		}
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		received()
+		if err := r.handle(stream, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// handle ACKs or NACKs a response, asks for the resources the new ones refer
+// to and updates the ClientConn.
+func (r *xdsResolver) handle(stream xdspb.AggregatedDiscoveryService_StreamAggregatedResourcesClient, resp *xdspb.DiscoveryResponse) error {
+	sub, ok := r.subs[resp.TypeUrl]
+	if !ok {
+		log.Printf("traffic: listener %s: ignoring resources of unknown type %s", r.target, resp.TypeUrl)
+		return nil
+	}
+	sub.nonce = resp.Nonce
+	if err := r.apply(resp); err != nil {
+		log.Printf("traffic: listener %s: rejecting %s version %s: %v", r.target, resp.TypeUrl, resp.VersionInfo, err)
+		return stream.Send(&xdspb.DiscoveryRequest{
+			VersionInfo:   sub.version,
+			ResourceNames: sub.names,
+			TypeUrl:       resp.TypeUrl,
+			ResponseNonce: resp.Nonce,
+			ErrorDetail:   &xdspb.Status{Code: int32(codes.InvalidArgument), Message: err.Error()},
+		})
+	}
+	sub.version, sub.received = resp.VersionInfo, true
+	changed := r.subscribe()
+	for _, typ := range resourceTypes {
+		s := r.subs[typ]
+		if typ != resp.TypeUrl && !changed[typ] {
+			continue
+		}
+		// Listeners and clusters asked for without names are all of them.
+		if len(s.names) == 0 && (typ == listenerType || typ == clusterType) {
+			continue
+		}
+		if err := stream.Send(&xdspb.DiscoveryRequest{
+			VersionInfo:   s.version,
+			ResourceNames: s.names,
+			TypeUrl:       typ,
+			ResponseNonce: s.nonce,
+		}); err != nil {
+			return err
+		}
+	}
+	r.update()
+	return nil
+}
+
+// unmarshalResource unmarshals a resource of type typ into m.
+func unmarshalResource(res *anypb.Any, typ string, m proto.Message) error {
+	if res.GetTypeUrl() != typ {
+		return fmt.Errorf("resource of type %s in a response of %s", res.GetTypeUrl(), typ)
+	}
+	return proto.Unmarshal(res.Value, m)
+}
+
+// apply validates the resources of a response and keeps them. A response
+// holds all the listeners or clusters the client asked for, but only the
+// route configurations or cluster load assignments that changed.
+func (r *xdsResolver) apply(resp *xdspb.DiscoveryResponse) error {
+	switch resp.TypeUrl {
+	case listenerType:
+		var listener *xdspb.Listener
+		for _, res := range resp.Resources {
+			l := &xdspb.Listener{}
+			if err := unmarshalResource(res, listenerType, l); err != nil {
+				return err
+			}
+			if l.Name != r.target {
+				continue
+			}
+			if _, _, err := routeConfigNameOf(l); err != nil {
+				return err
+			}
+			listener = l
+		}
+		r.listener = listener
+	case routeConfigType:
+		received := make(map[string]*xdspb.RouteConfiguration)
+		for _, res := range resp.Resources {
+			rc := &xdspb.RouteConfiguration{}
+			if err := unmarshalResource(res, routeConfigType, rc); err != nil {
+				return err
+			}
+			if err := r.validateRouteConfig(rc); err != nil {
+				return err
+			}
+			received[rc.Name] = rc
+		}
+		for name, rc := range received {
+			r.routeConfigs[name] = rc
+		}
+	case clusterType:
+		received := make(map[string]*xdspb.Cluster)
+		for _, res := range resp.Resources {
+			cl := &xdspb.Cluster{}
+			if err := unmarshalResource(res, clusterType, cl); err != nil {
+				return err
+			}
+			if _, _, err := clusterOfResource(cl); err != nil {
+				return err
+			}
+			received[cl.Name] = cl
+		}
+		r.clusters = received
+	case endpointsType:
+		received := make(map[string]*xdspb.ClusterLoadAssignment)
+		for _, res := range resp.Resources {
+			cla := &xdspb.ClusterLoadAssignment{}
+			if err := unmarshalResource(res, endpointsType, cla); err != nil {
+				return err
+			}
+			if _, err := endpointsOf(cla); err != nil {
+				return fmt.Errorf("cluster load assignment %s: %v", cla.ClusterName, err)
+			}
+			received[cla.ClusterName] = cla
+		}
+		for name, cla := range received {
+			r.endpoints[name] = cla
+		}
+	}
+	return nil
+}
+
+// validateRouteConfig checks that a route configuration has a virtual host
+// for the target with routes the client supports.
+func (r *xdsResolver) validateRouteConfig(rc *xdspb.RouteConfiguration) error {
+	vh := virtualHostOf(rc, r.target)
+	if vh == nil {
+		return fmt.Errorf("route config %s has no virtual host for %s", rc.Name, r.target)
+	}
+	_, err := routesOf(vh)
+	return err
+}
+
+// routeConfig returns the route configuration of the listener, nil if the
+// client does not have it yet.
+func (r *xdsResolver) routeConfig() *xdspb.RouteConfiguration {
+	if r.listener == nil {
+		return nil
+	}
+	name, inline, _ := routeConfigNameOf(r.listener)
+	if inline != nil {
+		if r.validateRouteConfig(inline) != nil {
+			return nil
+		}
+		return inline
+	}
+	return r.routeConfigs[name]
+}
+
+// subscribe works out the names to ask for from the resources the client
+// has, drops the resources it no longer needs and returns the types whose
+// names changed.
+func (r *xdsResolver) subscribe() map[string]bool {
+	routeConfigs, clusters, services := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	if r.listener != nil {
+		if name, _, _ := routeConfigNameOf(r.listener); name != "" {
+			routeConfigs[name] = true
+		}
+	}
+	if rc := r.routeConfig(); rc != nil {
+		routes, _ := routesOf(virtualHostOf(rc, r.target))
+		for _, route := range routes {
+			for _, wc := range route.Clusters {
+				clusters[wc.Name] = true
+			}
+		}
+	}
+	for name := range clusters {
+		if cl, ok := r.clusters[name]; ok {
+			service, _, _ := clusterOfResource(cl)
+			services[service] = true
+		}
+	}
+	for name := range r.routeConfigs {
+		if !routeConfigs[name] {
+			delete(r.routeConfigs, name)
+		}
+	}
+	for name := range r.endpoints {
+		if !services[name] {
+			delete(r.endpoints, name)
+		}
+	}
+
+	changed := make(map[string]bool)
+	for typ, set := range map[string]map[string]bool{routeConfigType: routeConfigs, clusterType: clusters, endpointsType: services} {
+		names := sortedNames(set)
+		sub := r.subs[typ]
+		if strings.Join(names, "\x00") != strings.Join(sub.names, "\x00") {
+			sub.names = names
+			changed[typ] = true
+		}
+	}
+	return changed
+}
+
+// update pushes the endpoints of the clusters and a traffic_routing config
+// with the routes and clusters to the ClientConn once the client has all of
+// them.
+func (r *xdsResolver) update() {
+	if r.listener == nil {
+		if !r.known && r.subs[listenerType].received {
+			r.cc.ReportError(fmt.Errorf("traffic: the control plane has no listener %s", r.target))
+		}
+		return
+	}
+	rc := r.routeConfig()
+	if rc == nil {
+		return
+	}
+	routes, _ := routesOf(virtualHostOf(rc, r.target))
+	cfg := &routingConfig{Routes: routes, Clusters: make(map[string]*clusterConfig)}
+	var addrs []resolver.Address
+	for _, name := range r.subs[clusterType].names {
+		cl, ok := r.clusters[name]
+		if !ok {
+			return
+		}
+		service, policy, _ := clusterOfResource(cl)
+		cla, ok := r.endpoints[service]
+		if !ok {
+			return
+		}
+		endpoints, _ := endpointsOf(cla)
+		for _, e := range endpoints {
+			addrs = append(addrs, withCluster(e.Address(), name))
+		}
+		cfg.Clusters[name] = &clusterConfig{ChildPolicy: policy}
+	}
+
+	js, err := json.Marshal(cfg)
+	if err != nil {
+		log.Printf("traffic: listener %s: %v", r.target, err)
+		return
+	}
+	config := r.cc.ParseServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: %s}]}`, RoutingName, js))
+	if config.Err != nil {
+		// An unknown policy of a cluster, e.g. one of a package the client
+		// does not import, makes the whole config invalid.
+		log.Printf("traffic: invalid resources of listener %s, keeping the last ones: %v", r.target, config.Err)
+		return
+	}
+	r.known = true
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs, ServiceConfig: config}); err != nil && len(addrs) > 0 {
+		log.Printf("traffic: updating listener %s: %v", r.target, err)
+	}
+}
+
+// ResolveNow retries at once if the stream is broken; otherwise the stream
+// keeps the resources up to date.
+func (r *xdsResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.retry <- struct{}{}:
+	default:
+	}
+}
+
+func (r *xdsResolver) Close() {
+	r.cancel()
+	r.conn.Close()
+}
+
+// clusterKey is the balancer attribute key of the cluster of an address.
+type clusterKey struct{}
+
+func withCluster(addr resolver.Address, cluster string) resolver.Address {
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(clusterKey{}, cluster)
+	return addr
+}
+
+// clusterOf returns the cluster of an address from the resolver.
+func clusterOf(addr resolver.Address) string {
+	cluster, _ := addr.BalancerAttributes.Value(clusterKey{}).(string)
+	return cluster
+}
@@ -0,0 +1,382 @@
+package traffic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/traffic/xdspb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Type URLs of the resources served over ADS, in the order a client needs
+// them.
+const (
+	listenerType    = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	routeConfigType = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	clusterType     = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	endpointsType   = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+)
+
+var resourceTypes = []string{listenerType, routeConfigType, clusterType, endpointsType}
+
+// Type URLs of the messages inside resources.
+const (
+	httpConnectionManagerType = "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager"
+	routerType                = "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"
+	roundRobinType            = "type.googleapis.com/envoy.extensions.load_balancing_policies.round_robin.v3.RoundRobin"
+	pickFirstType             = "type.googleapis.com/envoy.extensions.load_balancing_policies.pick_first.v3.PickFirst"
+	typedStructType           = "type.googleapis.com/xds.type.v3.TypedStruct"
+)
+
+// routerFilter is the name of the router, the last HTTP filter of a listener.
+const routerFilter = "envoy.filters.http.router"
+
+// marshalAny wraps m in an Any. The bytes are deterministic, so equal
+// resources hash to the same version.
+func marshalAny(typeURL string, m proto.Message) *anypb.Any {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		// Only messages with invalid UTF-8 strings fail, and the strings
+		// come from a parsed file.
+		panic(fmt.Sprintf("traffic: marshaling %s: %v", typeURL, err))
+	}
+	return &anypb.Any{TypeUrl: typeURL, Value: b}
+}
+
+// versionOf returns the version of the resources sent for names.
+func versionOf(names []string, resources []*anypb.Any) string {
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%q,", name)
+	}
+	for _, r := range resources {
+		h.Write(r.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// adsSource is the config source of resources served over the same ADS
+// stream.
+func adsSource() *xdspb.ConfigSource {
+	return &xdspb.ConfigSource{
+		ConfigSourceSpecifier: &xdspb.ConfigSource_Ads{Ads: &xdspb.AggregatedConfigSource{}},
+		ResourceApiVersion:    xdspb.ApiVersion_V3,
+	}
+}
+
+func listenerResource(l Listener) *xdspb.Listener {
+	hcm := &xdspb.HttpConnectionManager{
+		RouteSpecifier: &xdspb.HttpConnectionManager_Rds{Rds: &xdspb.Rds{
+			ConfigSource:    adsSource(),
+			RouteConfigName: l.RouteConfig,
+		}},
+		HttpFilters: []*xdspb.HttpFilter{{
+			Name:       routerFilter,
+			ConfigType: &xdspb.HttpFilter_TypedConfig{TypedConfig: marshalAny(routerType, &xdspb.Router{})},
+		}},
+	}
+	return &xdspb.Listener{
+		Name:        l.Name,
+		ApiListener: &xdspb.ApiListener{ApiListener: marshalAny(httpConnectionManagerType, hcm)},
+	}
+}
+
+// routeConfigResource returns a route configuration with one virtual host
+// for all targets.
+func routeConfigResource(rc RouteConfiguration) *xdspb.RouteConfiguration {
+	vh := &xdspb.VirtualHost{Name: rc.Name, Domains: []string{"*"}}
+	for _, r := range rc.Routes {
+		match := &xdspb.RouteMatch{PathSpecifier: &xdspb.RouteMatch_Prefix{Prefix: r.Match.Prefix}}
+		if r.Match.Path != "" {
+			match.PathSpecifier = &xdspb.RouteMatch_Path{Path: r.Match.Path}
+		}
+		for _, h := range r.Match.Headers {
+			match.Headers = append(match.Headers, &xdspb.HeaderMatcher{
+				Name:                 h.Name,
+				HeaderMatchSpecifier: &xdspb.HeaderMatcher_ExactMatch{ExactMatch: h.ExactMatch},
+			})
+		}
+		action := &xdspb.RouteAction{}
+		if r.Cluster != "" {
+			action.ClusterSpecifier = &xdspb.RouteAction_Cluster{Cluster: r.Cluster}
+		} else {
+			wcs := &xdspb.WeightedCluster{}
+			for _, wc := range r.WeightedClusters {
+				wcs.Clusters = append(wcs.Clusters, &xdspb.WeightedCluster_ClusterWeight{Name: wc.Name, Weight: wrapperspb.UInt32(wc.Weight)})
+			}
+			action.ClusterSpecifier = &xdspb.RouteAction_WeightedClusters{WeightedClusters: wcs}
+		}
+		vh.Routes = append(vh.Routes, &xdspb.Route{Match: match, Action: &xdspb.Route_Route{Route: action}})
+	}
+	return &xdspb.RouteConfiguration{Name: rc.Name, VirtualHosts: []*xdspb.VirtualHost{vh}}
+}
+
+// clusterResource returns an EDS cluster whose cluster load assignment has
+// the name of the cluster.
+func clusterResource(cl Cluster) (*xdspb.Cluster, error) {
+	policy, err := lbPolicyResource(cl.LBPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %v", cl.Name, err)
+	}
+	return &xdspb.Cluster{
+		Name:                 cl.Name,
+		ClusterDiscoveryType: &xdspb.Cluster_Type{Type: xdspb.Cluster_EDS},
+		EdsClusterConfig:     &xdspb.Cluster_EdsClusterConfig{EdsConfig: adsSource(), ServiceName: cl.Name},
+		LoadBalancingPolicy:  policy,
+	}, nil
+}
+
+// lbPolicyResource converts a loadBalancingConfig list. round_robin and
+// pick_first become Envoy's policies, the others a TypedStruct with the
+// JSON config of the policy, as gRPC expects custom policies.
+func lbPolicyResource(js discovery.ServiceConfigJSON) (*xdspb.LoadBalancingPolicy, error) {
+	if js == "" {
+		return nil, nil
+	}
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(js), &list); err != nil {
+		return nil, err
+	}
+	lb := &xdspb.LoadBalancingPolicy{}
+	for _, entry := range list {
+		for name, config := range entry {
+			var typed *anypb.Any
+			switch name {
+			case "round_robin":
+				typed = &anypb.Any{TypeUrl: roundRobinType}
+			case "pick_first":
+				typed = &anypb.Any{TypeUrl: pickFirstType}
+			default:
+				s := &structpb.Struct{}
+				if err := protojson.Unmarshal(config, s); err != nil {
+					return nil, fmt.Errorf("config of %s: %v", name, err)
+				}
+				typed = marshalAny(typedStructType, &xdspb.TypedStruct{TypeUrl: "type.googleapis.com/" + name, Value: s})
+			}
+			lb.Policies = append(lb.Policies, &xdspb.LoadBalancingPolicy_Policy{
+				TypedExtensionConfig: &xdspb.TypedExtensionConfig{Name: name, TypedConfig: typed},
+			})
+		}
+	}
+	return lb, nil
+}
+
+// endpointsResource returns the endpoints of a cluster grouped by zone.
+// Endpoints whose address has no numeric port are left out.
+func endpointsResource(cluster string, endpoints []discovery.Endpoint) *xdspb.ClusterLoadAssignment {
+	cla := &xdspb.ClusterLoadAssignment{ClusterName: cluster}
+	zones := make(map[string]*xdspb.LocalityLbEndpoints)
+	for _, e := range endpoints {
+		host, portStr, err := net.SplitHostPort(e.Addr)
+		port, perr := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || perr != nil {
+			log.Printf("traffic: cluster %s: skipping endpoint %q without a host and numeric port", cluster, e.Addr)
+			continue
+		}
+		locality, ok := zones[e.Zone]
+		if !ok {
+			locality = &xdspb.LocalityLbEndpoints{Locality: &xdspb.Locality{Zone: e.Zone}, LoadBalancingWeight: wrapperspb.UInt32(0)}
+			zones[e.Zone] = locality
+			cla.Endpoints = append(cla.Endpoints, locality)
+		}
+		weight := e.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		// gRPC ignores localities of weight 0.
+		locality.LoadBalancingWeight.Value += weight
+		locality.LbEndpoints = append(locality.LbEndpoints, &xdspb.LbEndpoint{
+			HostIdentifier: &xdspb.LbEndpoint_Endpoint{Endpoint: &xdspb.Endpoint{Address: &xdspb.Address{
+				Address: &xdspb.Address_SocketAddress{SocketAddress: &xdspb.SocketAddress{
+					Address:       host,
+					PortSpecifier: &xdspb.SocketAddress_PortValue{PortValue: uint32(port)},
+				}},
+			}}},
+			HealthStatus:        xdspb.HealthStatus_HEALTHY,
+			LoadBalancingWeight: wrapperspb.UInt32(weight),
+		})
+	}
+	return cla
+}
+
+// routeConfigNameOf returns the name of the route configuration of a
+// listener, or the route configuration inside it.
+func routeConfigNameOf(l *xdspb.Listener) (string, *xdspb.RouteConfiguration, error) {
+	api := l.GetApiListener().GetApiListener()
+	if api.GetTypeUrl() != httpConnectionManagerType {
+		return "", nil, fmt.Errorf("listener %s has no HttpConnectionManager api_listener", l.Name)
+	}
+	hcm := &xdspb.HttpConnectionManager{}
+	if err := api.UnmarshalTo(hcm); err != nil {
+		return "", nil, fmt.Errorf("listener %s: %v", l.Name, err)
+	}
+	filters := hcm.GetHttpFilters()
+	if len(filters) == 0 || filters[len(filters)-1].GetTypedConfig().GetTypeUrl() != routerType {
+		return "", nil, fmt.Errorf("listener %s: the last HTTP filter is not the router", l.Name)
+	}
+	switch rs := hcm.RouteSpecifier.(type) {
+	case *xdspb.HttpConnectionManager_Rds:
+		if rs.Rds.GetConfigSource().GetAds() == nil || rs.Rds.RouteConfigName == "" {
+			return "", nil, fmt.Errorf("listener %s: route config is not named or not served over ADS", l.Name)
+		}
+		return rs.Rds.RouteConfigName, nil, nil
+	case *xdspb.HttpConnectionManager_RouteConfig:
+		return "", rs.RouteConfig, nil
+	}
+	return "", nil, fmt.Errorf("listener %s has no route config", l.Name)
+}
+
+// virtualHostOf returns the virtual host of rc for target: the one of an
+// exact domain, else of the longest wildcard domain.
+func virtualHostOf(rc *xdspb.RouteConfiguration, target string) *xdspb.VirtualHost {
+	var (
+		best  *xdspb.VirtualHost
+		score = -1
+	)
+	target = strings.ToLower(target)
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.Domains {
+			d = strings.ToLower(d)
+			s := -1
+			switch {
+			case d == target:
+				s = 1 << 20
+			case d == "*":
+				s = 0
+			case strings.HasPrefix(d, "*") && strings.HasSuffix(target, d[1:]),
+				strings.HasSuffix(d, "*") && strings.HasPrefix(target, d[:len(d)-1]):
+				s = len(d)
+			}
+			if s > score {
+				best, score = vh, s
+			}
+		}
+	}
+	return best
+}
+
+// routesOf converts the routes of a virtual host to traffic_routing routes.
+func routesOf(vh *xdspb.VirtualHost) ([]routeConfig, error) {
+	var routes []routeConfig
+	for i, r := range vh.GetRoutes() {
+		var route routeConfig
+		switch ps := r.GetMatch().GetPathSpecifier().(type) {
+		case *xdspb.RouteMatch_Prefix:
+			route.Prefix = ps.Prefix
+		case *xdspb.RouteMatch_Path:
+			route.Path = ps.Path
+		default:
+			return nil, fmt.Errorf("virtual host %s: route %d matches neither a prefix nor a path", vh.Name, i)
+		}
+		for _, h := range r.Match.Headers {
+			exact, ok := h.HeaderMatchSpecifier.(*xdspb.HeaderMatcher_ExactMatch)
+			if !ok {
+				return nil, fmt.Errorf("virtual host %s: route %d: header %s needs an exact_match", vh.Name, i, h.Name)
+			}
+			route.Headers = append(route.Headers, headerConfig{Name: h.Name, Exact: exact.ExactMatch})
+		}
+		switch cs := r.GetRoute().GetClusterSpecifier().(type) {
+		case *xdspb.RouteAction_Cluster:
+			route.Clusters = []weightedClusterConfig{{Name: cs.Cluster, Weight: 1}}
+		case *xdspb.RouteAction_WeightedClusters:
+			for _, wc := range cs.WeightedClusters.GetClusters() {
+				route.Clusters = append(route.Clusters, weightedClusterConfig{Name: wc.Name, Weight: wc.GetWeight().GetValue()})
+			}
+		}
+		if len(route.Clusters) == 0 {
+			return nil, fmt.Errorf("virtual host %s: route %d has no cluster", vh.Name, i)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// clusterOfResource returns the name of the cluster load assignment of an
+// EDS cluster and its loadBalancingConfig list.
+func clusterOfResource(cl *xdspb.Cluster) (string, json.RawMessage, error) {
+	if cl.GetType() != xdspb.Cluster_EDS || cl.GetEdsClusterConfig().GetEdsConfig().GetAds() == nil {
+		return "", nil, fmt.Errorf("cluster %s is not an EDS cluster served over ADS", cl.Name)
+	}
+	service := cl.EdsClusterConfig.ServiceName
+	if service == "" {
+		service = cl.Name
+	}
+	policies := cl.GetLoadBalancingPolicy().GetPolicies()
+	if len(policies) == 0 {
+		return service, json.RawMessage(`[{"round_robin": {}}]`), nil
+	}
+	var list []map[string]json.RawMessage
+	for _, p := range policies {
+		typed := p.GetTypedExtensionConfig().GetTypedConfig()
+		switch typed.GetTypeUrl() {
+		case roundRobinType:
+			list = append(list, map[string]json.RawMessage{"round_robin": json.RawMessage("{}")})
+		case pickFirstType:
+			list = append(list, map[string]json.RawMessage{"pick_first": json.RawMessage("{}")})
+		case typedStructType:
+			ts := &xdspb.TypedStruct{}
+			if err := typed.UnmarshalTo(ts); err != nil {
+				return "", nil, fmt.Errorf("cluster %s: %v", cl.Name, err)
+			}
+			config, err := protojson.Marshal(ts.GetValue())
+			if err != nil {
+				return "", nil, fmt.Errorf("cluster %s: %v", cl.Name, err)
+			}
+			name := ts.TypeUrl[strings.LastIndex(ts.TypeUrl, "/")+1:]
+			list = append(list, map[string]json.RawMessage{name: config})
+		}
+	}
+	if len(list) == 0 {
+		return "", nil, fmt.Errorf("cluster %s has no supported load balancing policy", cl.Name)
+	}
+	js, err := json.Marshal(list)
+	return service, js, err
+}
+
+// endpointsOf returns the endpoints of a cluster load assignment that can
+// take calls.
+func endpointsOf(cla *xdspb.ClusterLoadAssignment) ([]discovery.Endpoint, error) {
+	var endpoints []discovery.Endpoint
+	for _, locality := range cla.GetEndpoints() {
+		for _, lbe := range locality.LbEndpoints {
+			switch lbe.HealthStatus {
+			case xdspb.HealthStatus_UNKNOWN, xdspb.HealthStatus_HEALTHY, xdspb.HealthStatus_DEGRADED:
+			default:
+				continue
+			}
+			sa := lbe.GetEndpoint().GetAddress().GetSocketAddress()
+			if sa == nil || sa.Address == "" {
+				return nil, errors.New("endpoint has no socket address")
+			}
+			endpoints = append(endpoints, discovery.Endpoint{
+				Addr:   net.JoinHostPort(sa.Address, strconv.FormatUint(uint64(sa.GetPortValue()), 10)),
+				Zone:   locality.GetLocality().GetZone(),
+				Weight: lbe.GetLoadBalancingWeight().GetValue(),
+			})
+		}
+	}
+	return endpoints, nil
+}
+
+// sortedNames returns the sorted distinct names of a set.
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,339 @@
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// RoutingName is the name of the policy the resolver selects for xds:///
+// targets. It routes each call to a cluster and balances the calls of a
+// cluster with the policy of the cluster.
+const RoutingName = "traffic_routing"
+
+// routingConfig is the config of the traffic_routing policy, built by the
+// resolver from the resources of a listener.
+type routingConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+	Routes                            []routeConfig             `json:"routes"`
+	Clusters                          map[string]*clusterConfig `json:"clusters"`
+}
+
+type routeConfig struct {
+	Prefix   string                  `json:"prefix,omitempty"`
+	Path     string                  `json:"path,omitempty"`
+	Headers  []headerConfig          `json:"headers,omitempty"`
+	Clusters []weightedClusterConfig `json:"clusters"`
+}
+
+type headerConfig struct {
+	Name  string `json:"name"`
+	Exact string `json:"exact"`
+}
+
+type weightedClusterConfig struct {
+	Name   string `json:"name"`
+	Weight uint32 `json:"weight"`
+}
+
+type clusterConfig struct {
+	// ChildPolicy is the loadBalancingConfig list of the cluster; the first
+	// registered policy is used.
+	ChildPolicy json.RawMessage `json:"childPolicy"`
+
+	child       balancer.Builder
+	childConfig serviceconfig.LoadBalancingConfig
+}
+
+func init() {
+	balancer.Register(routingBuilder{})
+}
+
+type routingBuilder struct{}
+
+func (routingBuilder) Name() string { return RoutingName }
+
+func (routingBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &routingConfig{}
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid config %s: %v", RoutingName, js, err)
+	}
+	for name, cl := range cfg.Clusters {
+		if err := cl.parse(); err != nil {
+			return nil, fmt.Errorf("%s: cluster %s: %v", RoutingName, name, err)
+		}
+	}
+	for i, r := range cfg.Routes {
+		for _, wc := range r.Clusters {
+			if _, ok := cfg.Clusters[wc.Name]; !ok || wc.Weight == 0 {
+				return nil, fmt.Errorf("%s: route %d: unknown cluster %q or weight 0", RoutingName, i, wc.Name)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// parse parses the child policy of the cluster.
+func (c *clusterConfig) parse() error {
+	var policies []map[string]json.RawMessage
+	if err := json.Unmarshal(c.ChildPolicy, &policies); err != nil {
+		return fmt.Errorf("invalid childPolicy %s: %v", c.ChildPolicy, err)
+	}
+	for _, policy := range policies {
+		for name, raw := range policy {
+			bb := balancer.Get(name)
+			if bb == nil {
+				continue
+			}
+			c.child = bb
+			if parser, ok := bb.(balancer.ConfigParser); ok {
+				cfg, err := parser.ParseConfig(raw)
+				if err != nil {
+					return err
+				}
+				c.childConfig = cfg
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no registered policy in childPolicy %s", c.ChildPolicy)
+}
+
+func (routingBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return &routingBalancer{
+		cc:       cc,
+		opts:     opts,
+		children: make(map[string]*cluster),
+		subConns: make(map[balancer.SubConn]*cluster),
+	}
+}
+
+// routingBalancer keeps a child balancer per cluster. gRPC calls it from one
+// goroutine; the children may update their state from others, e.g. the
+// timer of outlier_detection.
+type routingBalancer struct {
+	cc   balancer.ClientConn
+	opts balancer.BuildOptions
+
+	// mu guards the children, their SubConns and states and the routes, and
+	// orders the pickers sent to gRPC. The children only change on calls
+	// from gRPC, which read them without mu.
+	mu       sync.Mutex
+	children map[string]*cluster
+	subConns map[balancer.SubConn]*cluster
+	routes   []routeConfig
+}
+
+// cluster is the child balancer of a cluster.
+type cluster struct {
+	name   string
+	policy string
+	b      balancer.Balancer
+	// state is the latest state of the child, guarded by routingBalancer.mu.
+	state balancer.State
+	// reported is set once the child sent a state.
+	reported bool
+}
+
+func (b *routingBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	cfg, ok := s.BalancerConfig.(*routingConfig)
+	if !ok {
+		return fmt.Errorf("%s: unexpected config %T", RoutingName, s.BalancerConfig)
+	}
+	addrs := make(map[string][]resolver.Address)
+	for _, a := range s.ResolverState.Addresses {
+		addrs[clusterOf(a)] = append(addrs[clusterOf(a)], a)
+	}
+
+	b.mu.Lock()
+	b.routes = cfg.Routes
+	b.mu.Unlock()
+	for name, c := range b.children {
+		if cl, ok := cfg.Clusters[name]; !ok || cl.child.Name() != c.policy {
+			b.remove(c)
+		}
+	}
+	for name, cl := range cfg.Clusters {
+		c, ok := b.children[name]
+		if !ok {
+			c = &cluster{name: name, policy: cl.child.Name()}
+			c.b = cl.child.Build(&clusterClientConn{ClientConn: b.cc, b: b, c: c}, b.opts)
+			b.mu.Lock()
+			b.children[name] = c
+			b.mu.Unlock()
+		}
+		// A cluster without endpoints fails its calls; its error is not
+		// an error of the other clusters.
+		c.b.UpdateClientConnState(balancer.ClientConnState{
+			ResolverState:  resolver.State{Addresses: addrs[name], Attributes: s.ResolverState.Attributes},
+			BalancerConfig: cl.childConfig,
+		})
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updateState()
+	return nil
+}
+
+// remove closes the child of a cluster.
+func (b *routingBalancer) remove(c *cluster) {
+	b.mu.Lock()
+	delete(b.children, c.name)
+	b.mu.Unlock()
+	c.b.Close()
+}
+
+func (b *routingBalancer) ResolverError(err error) {
+	if len(b.children) == 0 {
+		b.cc.UpdateState(balancer.State{ConnectivityState: connectivity.TransientFailure, Picker: base.NewErrPicker(err)})
+		return
+	}
+	for _, c := range b.children {
+		c.b.ResolverError(err)
+	}
+}
+
+func (b *routingBalancer) UpdateSubConnState(sc balancer.SubConn, state balancer.SubConnState) {
+	b.mu.Lock()
+	c, ok := b.subConns[sc]
+	if ok && state.ConnectivityState == connectivity.Shutdown {
+		delete(b.subConns, sc)
+	}
+	b.mu.Unlock()
+	if ok {
+		c.b.UpdateSubConnState(sc, state)
+	}
+}
+
+func (b *routingBalancer) ExitIdle() {
+	for _, c := range b.children {
+		if ei, ok := c.b.(balancer.ExitIdler); ok {
+			ei.ExitIdle()
+		}
+	}
+}
+
+func (b *routingBalancer) Close() {
+	for _, c := range b.children {
+		b.remove(c)
+	}
+}
+
+// updateState sends the aggregated state of the children and a picker
+// routing the calls to them. It is called with mu held.
+func (b *routingBalancer) updateState() {
+	p := &routingPicker{routes: b.routes, pickers: make(map[string]balancer.Picker)}
+	counts := make(map[connectivity.State]int)
+	for name, c := range b.children {
+		if !c.reported {
+			counts[connectivity.Connecting]++
+			continue
+		}
+		p.pickers[name] = c.state.Picker
+		counts[c.state.ConnectivityState]++
+	}
+	state := connectivity.TransientFailure
+	switch {
+	case counts[connectivity.Ready] > 0:
+		state = connectivity.Ready
+	case counts[connectivity.Connecting] > 0:
+		state = connectivity.Connecting
+	case counts[connectivity.Idle] > 0:
+		state = connectivity.Idle
+	}
+	b.cc.UpdateState(balancer.State{ConnectivityState: state, Picker: p})
+}
+
+// clusterClientConn is the ClientConn of the child of a cluster.
+type clusterClientConn struct {
+	balancer.ClientConn
+	b *routingBalancer
+	c *cluster
+}
+
+func (cc *clusterClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	sc, err := cc.ClientConn.NewSubConn(addrs, opts)
+	if err != nil {
+		return nil, err
+	}
+	cc.b.mu.Lock()
+	defer cc.b.mu.Unlock()
+	cc.b.subConns[sc] = cc.c
+	return sc, nil
+}
+
+func (cc *clusterClientConn) UpdateState(s balancer.State) {
+	cc.b.mu.Lock()
+	defer cc.b.mu.Unlock()
+	if cc.b.children[cc.c.name] != cc.c {
+		// The child of a removed cluster.
+		return
+	}
+	cc.c.state, cc.c.reported = s, true
+	cc.b.updateState()
+}
+
+// routingPicker picks the cluster of a call by the routes and the SubConn
+// with the picker of the cluster.
+type routingPicker struct {
+	routes  []routeConfig
+	pickers map[string]balancer.Picker
+}
+
+func (p *routingPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	md, _ := metadata.FromOutgoingContext(info.Ctx)
+	for _, r := range p.routes {
+		if !r.matches(info.FullMethodName, md) {
+			continue
+		}
+		picker, ok := p.pickers[r.pick()]
+		if !ok {
+			// The child has not sent a picker yet.
+			return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+		}
+		return picker.Pick(info)
+	}
+	return balancer.PickResult{}, status.Errorf(codes.Unavailable, "%s: no route for %s", RoutingName, info.FullMethodName)
+}
+
+func (r *routeConfig) matches(method string, md metadata.MD) bool {
+	if r.Path != "" && method != r.Path || !strings.HasPrefix(method, r.Prefix) {
+		return false
+	}
+	for _, h := range r.Headers {
+		if v := md.Get(h.Name); len(v) == 0 || v[0] != h.Exact {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns a cluster of the route at random by weight.
+func (r *routeConfig) pick() string {
+	if len(r.Clusters) == 1 {
+		return r.Clusters[0].Name
+	}
+	var total uint32
+	for _, wc := range r.Clusters {
+		total += wc.Weight
+	}
+	n := uint32(rand.Int63n(int64(total)))
+	for _, wc := range r.Clusters {
+		if n < wc.Weight {
+			return wc.Name
+		}
+		n -= wc.Weight
+	}
+	return r.Clusters[len(r.Clusters)-1].Name
+}
@@ -0,0 +1,291 @@
+package traffic
+
+import (
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/traffic/xdspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Server serves the resources of a Store over the state of the world variant
+// of xDS's aggregated discovery service (ADS): listeners, route
+// configurations, EDS clusters and cluster load assignments. The endpoints
+// of clusters with a service instead of endpoints come from a discovery
+// source, e.g. a registry the backends register with.
+type Server struct {
+	xdspb.UnimplementedAggregatedDiscoveryServiceServer
+	store  *Store
+	source discovery.Source
+}
+
+// NewServer returns a Server for store. source may be nil when all clusters
+// have their endpoints in the Config.
+func NewServer(store *Store, source discovery.Source) *Server {
+	return &Server{store: store, source: source}
+}
+
+// RegisterServer registers a Server for store and source with s and returns
+// it.
+func RegisterServer(s grpc.ServiceRegistrar, store *Store, source discovery.Source) *Server {
+	srv := NewServer(store, source)
+	xdspb.RegisterAggregatedDiscoveryServiceServer(s, srv)
+	return srv
+}
+
+// subscription is what a stream asked for of one resource type.
+type subscription struct {
+	names []string
+	// wildcard is set for listeners and clusters asked for without names.
+	wildcard bool
+	// version and nonce are the ones of the last response.
+	version string
+	nonce   string
+}
+
+// adsStream is the state of one StreamAggregatedResources stream.
+type adsStream struct {
+	srv    *Server
+	stream xdspb.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	node   string
+	subs   map[string]*subscription
+	nonce  int
+
+	// Only the latest endpoints matter, so a slow stream skips versions.
+	mu        sync.Mutex
+	endpoints map[string][]discovery.Endpoint
+	changed   chan struct{}
+	watches   map[string]func()
+}
+
+func (s *Server) StreamAggregatedResources(stream xdspb.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	ctx := stream.Context()
+	reqs := make(chan *xdspb.DiscoveryRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	a := &adsStream{
+		srv:       s,
+		stream:    stream,
+		subs:      make(map[string]*subscription),
+		endpoints: make(map[string][]discovery.Endpoint),
+		changed:   make(chan struct{}, 1),
+		watches:   make(map[string]func()),
+	}
+	defer func() {
+		for _, stop := range a.watches {
+			stop()
+		}
+	}()
+	for {
+		cfg, _, storeChanged := s.store.snapshot()
+		if err := a.send(cfg); err != nil {
+			return err
+		}
+		select {
+		case req := <-reqs:
+			a.request(req)
+		case err := <-recvErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-storeChanged:
+		case <-a.changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// request records what a request subscribes to. The response follows when
+// the resources differ from the ones sent last.
+func (a *adsStream) request(req *xdspb.DiscoveryRequest) {
+	if a.node == "" {
+		a.node = req.GetNode().GetId()
+	}
+	typ := req.TypeUrl
+	if !knownType(typ) {
+		log.Printf("traffic: node %q asked for unknown resource type %s", a.node, typ)
+		return
+	}
+	sub, ok := a.subs[typ]
+	if ok && req.ResponseNonce != sub.nonce {
+		// The request answers an older response; the one of the current
+		// nonce follows.
+		return
+	}
+	if ok && req.ErrorDetail != nil {
+		log.Printf("traffic: node %q rejected %s version %s: %s", a.node, typ, sub.version, req.ErrorDetail.Message)
+	}
+	names := append([]string(nil), req.ResourceNames...)
+	sort.Strings(names)
+	if !ok {
+		sub = &subscription{}
+		a.subs[typ] = sub
+		if typ == listenerType {
+			log.Printf("traffic: node %q watching listeners %v", a.node, names)
+		}
+	}
+	sub.names = names
+	sub.wildcard = len(names) == 0 && (typ == listenerType || typ == clusterType)
+}
+
+func knownType(typ string) bool {
+	for _, t := range resourceTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// send sends the resources of every subscribed type that changed since they
+// were sent last.
+func (a *adsStream) send(cfg *Config) error {
+	for _, typ := range resourceTypes {
+		sub, ok := a.subs[typ]
+		if !ok {
+			continue
+		}
+		resources, err := a.resources(cfg, typ, sub)
+		if err != nil {
+			// The Store only holds validated configs.
+			return status.Errorf(codes.Internal, "traffic: %v", err)
+		}
+		version := versionOf(sub.names, resources)
+		if version == sub.version {
+			continue
+		}
+		a.nonce++
+		nonce := strconv.Itoa(a.nonce)
+		if err := a.stream.Send(&xdspb.DiscoveryResponse{
+			VersionInfo: version,
+			Resources:   resources,
+			TypeUrl:     typ,
+			Nonce:       nonce,
+		}); err != nil {
+			return err
+		}
+		sub.version, sub.nonce = version, nonce
+	}
+	return nil
+}
+
+// resources returns the resources of a type a subscription asks for.
+func (a *adsStream) resources(cfg *Config, typ string, sub *subscription) ([]*anypb.Any, error) {
+	wanted := make(map[string]bool)
+	for _, name := range sub.names {
+		wanted[name] = true
+	}
+	var resources []*anypb.Any
+	switch typ {
+	case listenerType:
+		for _, l := range cfg.Listeners {
+			if sub.wildcard || wanted[l.Name] {
+				resources = append(resources, marshalAny(typ, listenerResource(l)))
+			}
+		}
+	case routeConfigType:
+		for _, rc := range cfg.RouteConfigs {
+			if wanted[rc.Name] {
+				resources = append(resources, marshalAny(typ, routeConfigResource(rc)))
+			}
+		}
+	case clusterType:
+		for _, cl := range cfg.Clusters {
+			if sub.wildcard || wanted[cl.Name] {
+				msg, err := clusterResource(cl)
+				if err != nil {
+					return nil, err
+				}
+				resources = append(resources, marshalAny(typ, msg))
+			}
+		}
+	case endpointsType:
+		endpoints := a.clusterEndpoints(cfg, wanted)
+		for _, cl := range cfg.Clusters {
+			if wanted[cl.Name] {
+				resources = append(resources, marshalAny(typ, endpointsResource(cl.Name, endpoints[cl.Name])))
+			}
+		}
+	}
+	return resources, nil
+}
+
+// clusterEndpoints returns the endpoints of the wanted clusters, from the
+// Config or from the discovery source, and watches the services of the
+// source the stream needs.
+func (a *adsStream) clusterEndpoints(cfg *Config, wanted map[string]bool) map[string][]discovery.Endpoint {
+	endpoints := make(map[string][]discovery.Endpoint)
+	static := make(map[string]bool)
+	for _, cla := range cfg.Endpoints {
+		endpoints[cla.ClusterName] = cla.Endpoints
+		static[cla.ClusterName] = true
+	}
+	services := make(map[string]string)
+	for _, cl := range cfg.Clusters {
+		if wanted[cl.Name] && !static[cl.Name] && cl.Service != "" {
+			services[cl.Name] = cl.Service
+		}
+	}
+	if a.srv.source != nil {
+		a.watch(services)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for cluster, service := range services {
+		endpoints[cluster] = a.endpoints[service]
+	}
+	return endpoints
+}
+
+// watch watches the services of the clusters in the source and stops
+// watching the services no cluster uses anymore.
+func (a *adsStream) watch(services map[string]string) {
+	used := make(map[string]bool)
+	for _, service := range services {
+		used[service] = true
+		if _, ok := a.watches[service]; !ok {
+			service := service
+			a.watches[service] = a.srv.source.Watch(service, func(e []discovery.Endpoint) {
+				a.mu.Lock()
+				a.endpoints[service] = e
+				a.mu.Unlock()
+				select {
+				case a.changed <- struct{}{}:
+				default:
+				}
+			})
+		}
+	}
+	for service, stop := range a.watches {
+		if !used[service] {
+			stop()
+			delete(a.watches, service)
+			a.mu.Lock()
+			delete(a.endpoints, service)
+			a.mu.Unlock()
+		}
+	}
+}
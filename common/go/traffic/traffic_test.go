@@ -0,0 +1,405 @@
+package traffic
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grpc-up-and-running/samples/common/go/discovery"
+	"github.com/grpc-up-and-running/samples/common/go/traffic/xdspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	ecpb "google.golang.org/grpc/examples/features/proto/echo"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type ecServer struct {
+	ecpb.UnimplementedEchoServer
+	addr string
+}
+
+func (s *ecServer) UnaryEcho(ctx context.Context, req *ecpb.EchoRequest) (*ecpb.EchoResponse, error) {
+	return &ecpb.EchoResponse{Message: s.addr}, nil
+}
+
+func (s *ecServer) ServerStreamingEcho(req *ecpb.EchoRequest, stream ecpb.Echo_ServerStreamingEchoServer) error {
+	return stream.Send(&ecpb.EchoResponse{Message: s.addr})
+}
+
+// startBackend starts an Echo server answering with its address.
+func startBackend(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	ecpb.RegisterEchoServer(s, &ecServer{addr: lis.Addr().String()})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// startControlPlane serves store and source and returns the bootstrap of the
+// control plane.
+func startControlPlane(t *testing.T, store *Store, source discovery.Source) Bootstrap {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	RegisterServer(s, store, source)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return Bootstrap{ServerURI: lis.Addr().String(), NodeID: "test"}
+}
+
+// dial connects to the listener echo of the control plane.
+func dial(t *testing.T, bootstrap Bootstrap) ecpb.EchoClient {
+	t.Helper()
+	conn, err := grpc.Dial("xds:///echo", grpc.WithInsecure(), grpc.WithResolvers(NewBuilder(bootstrap)))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ecpb.NewEchoClient(conn)
+}
+
+// calls makes n calls with the metadata kv and counts them by backend.
+func calls(t *testing.T, client ecpb.EchoClient, n int, kv ...string) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), kv...), 2*time.Second)
+		resp, err := client.UnaryEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("UnaryEcho failed: %v", err)
+		}
+		seen[resp.Message]++
+	}
+	return seen
+}
+
+// eventually makes calls until cond holds for their counts.
+func eventually(t *testing.T, what string, client ecpb.EchoClient, n int, cond func(map[string]int) bool) {
+	t.Helper()
+	for i := 0; ; i++ {
+		got := calls(t, client, n)
+		if cond(got) {
+			return
+		}
+		if i == 200 {
+			t.Fatalf("calls went to %v, want %s", got, what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// canaryConfig splits the calls of echo between a stable and a canary
+// cluster, and sends the calls with x-canary: true to the canary.
+func canaryConfig(stable, canary string, canaryWeight uint32) *Config {
+	return &Config{
+		Listeners: []Listener{{Name: "echo", RouteConfig: "echo-routes"}},
+		RouteConfigs: []RouteConfiguration{{Name: "echo-routes", Routes: []Route{
+			{Match: RouteMatch{Headers: []HeaderMatcher{{Name: "x-canary", ExactMatch: "true"}}}, Cluster: "canary"},
+			{Match: RouteMatch{Prefix: "/grpc.examples.echo.Echo/"}, WeightedClusters: []WeightedCluster{
+				{Name: "stable", Weight: 100 - canaryWeight},
+				{Name: "canary", Weight: canaryWeight},
+			}},
+		}}},
+		Clusters: []Cluster{{Name: "stable"}, {Name: "canary", LBPolicy: `[{"pick_first": {}}]`}},
+		Endpoints: []ClusterLoadAssignment{
+			{ClusterName: "stable", Endpoints: []discovery.Endpoint{{Addr: stable}}},
+			{ClusterName: "canary", Endpoints: []discovery.Endpoint{{Addr: canary}}},
+		},
+	}
+}
+
+func TestTrafficSplit(t *testing.T) {
+	stable, canary := startBackend(t), startBackend(t)
+	store, err := NewStore(canaryConfig(stable, canary, 20))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	client := dial(t, startControlPlane(t, store, nil))
+
+	got := calls(t, client, 500)
+	if got[stable]+got[canary] != 500 || got[canary] < 50 || got[canary] > 150 {
+		t.Errorf("calls went to %v, want about 20%% to the canary %s", got, canary)
+	}
+	if got := calls(t, client, 20, "x-canary", "true"); got[canary] != 20 {
+		t.Errorf("calls with x-canary went to %v, want all to the canary %s", got, canary)
+	}
+
+	// Promoting the canary needs no new connection.
+	if err := store.Set(canaryConfig(stable, canary, 100)); err == nil {
+		t.Fatalf("Set with a stable weight of 0 succeeded, want an error")
+	}
+	cfg := canaryConfig(stable, canary, 0)
+	cfg.RouteConfigs[0].Routes[1] = Route{Match: RouteMatch{Prefix: "/"}, Cluster: "canary"}
+	if err := store.Set(cfg); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	eventually(t, "only the canary", client, 20, func(got map[string]int) bool { return got[canary] == 20 })
+}
+
+func TestRouteByMethod(t *testing.T) {
+	unary, streaming := startBackend(t), startBackend(t)
+	cfg := &Config{
+		Listeners: []Listener{{Name: "echo", RouteConfig: "echo-routes"}},
+		RouteConfigs: []RouteConfiguration{{Name: "echo-routes", Routes: []Route{
+			{Match: RouteMatch{Path: "/grpc.examples.echo.Echo/UnaryEcho"}, Cluster: "unary"},
+			{Match: RouteMatch{Path: "/grpc.examples.echo.Echo/ServerStreamingEcho"}, Cluster: "streaming"},
+		}}},
+		Clusters: []Cluster{{Name: "unary"}, {Name: "streaming"}},
+		Endpoints: []ClusterLoadAssignment{
+			{ClusterName: "unary", Endpoints: []discovery.Endpoint{{Addr: unary}}},
+			{ClusterName: "streaming", Endpoints: []discovery.Endpoint{{Addr: streaming}}},
+		},
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	client := dial(t, startControlPlane(t, store, nil))
+
+	if got := calls(t, client, 10); got[unary] != 10 {
+		t.Errorf("UnaryEcho went to %v, want all to %s", got, unary)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stream, err := client.ServerStreamingEcho(ctx, &ecpb.EchoRequest{}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatalf("ServerStreamingEcho failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.Message != streaming {
+		t.Errorf("ServerStreamingEcho = %v, %v, want %s", resp, err, streaming)
+	}
+	// No route matches the other methods.
+	_, err = client.BidirectionalStreamingEcho(ctx)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("BidirectionalStreamingEcho = %v, want UNAVAILABLE", err)
+	}
+}
+
+func TestServiceCluster(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	registry := discovery.NewRegistry()
+	registry.Set("echo-v1", []discovery.Endpoint{{Addr: b1}})
+	store, err := NewStore(&Config{
+		Listeners:    []Listener{{Name: "echo", RouteConfig: "echo-routes"}},
+		RouteConfigs: []RouteConfiguration{{Name: "echo-routes", Routes: []Route{{Cluster: "v1"}}}},
+		Clusters:     []Cluster{{Name: "v1", Service: "echo-v1"}},
+	})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	client := dial(t, startControlPlane(t, store, registry))
+	if got := calls(t, client, 10); got[b1] != 10 {
+		t.Errorf("calls went to %v, want all to %s", got, b1)
+	}
+
+	// The endpoints follow the registry.
+	registry.Set("echo-v1", []discovery.Endpoint{{Addr: b2}})
+	eventually(t, "the new endpoint", client, 10, func(got map[string]int) bool { return got[b2] == 10 })
+}
+
+func TestFileStore(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	path := filepath.Join(t.TempDir(), "traffic.yaml")
+	write := func(addr string) {
+		t.Helper()
+		data := []byte(`listeners:
+  - name: echo
+    route_config: echo-routes
+route_configs:
+  - name: echo-routes
+    routes:
+      - match: {prefix: /}
+        cluster: echo
+clusters:
+  - name: echo
+    lb_policy: [{round_robin: {}}]
+endpoints:
+  - cluster_name: echo
+    endpoints:
+      - addr: ` + addr + "\n")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(b1)
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	store.WatchFile(10 * time.Millisecond)
+	defer store.Close()
+	client := dial(t, startControlPlane(t, store, nil))
+	if got := calls(t, client, 10); got[b1] != 10 {
+		t.Errorf("calls went to %v, want all to %s", got, b1)
+	}
+
+	// An invalid file keeps the resources in place.
+	if err := os.WriteFile(path, []byte("listeners: [{name: echo, route_config: unknown}]\n"), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := calls(t, client, 10); got[b1] != 10 {
+		t.Errorf("calls went to %v after an invalid file, want all to %s", got, b1)
+	}
+
+	write(b2)
+	eventually(t, "the backend of the new file", client, 10, func(got map[string]int) bool { return got[b2] == 10 })
+}
+
+func TestUnknownListener(t *testing.T) {
+	store, err := NewStore(&Config{})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	client := dial(t, startControlPlane(t, store, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = client.UnaryEcho(ctx, &ecpb.EchoRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("UnaryEcho = %v, want UNAVAILABLE", err)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	for _, data := range []string{
+		"listeners: [{name: echo, route_config: unknown}]",
+		"route_configs: [{name: r, routes: [{cluster: unknown}]}]",
+		"clusters: [{name: c}]\nroute_configs: [{name: r, routes: [{cluster: c, weighted_clusters: [{name: c, weight: 1}]}]}]",
+		"clusters: [{name: c}]\nroute_configs: [{name: r, routes: [{weighted_clusters: [{name: c, weight: 0}]}]}]",
+		"clusters: [{name: c}]\nroute_configs: [{name: r, routes: [{match: {headers: [{name: X-Canary}]}, cluster: c}]}]",
+		"clusters: [{name: c, lb_policy: {round_robin: {}}}]",
+		"clusters: [{name: c}]\nendpoints: [{cluster_name: c, endpoints: [{addr: localhost}]}]",
+		"clusters: [{name: c}, {name: c}]",
+	} {
+		if _, err := ParseFile("traffic.yaml", []byte(data)); err == nil {
+			t.Errorf("ParseFile(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestParseBootstrap(t *testing.T) {
+	b, err := ParseBootstrap([]byte(`{
+  "xds_servers": [{"server_uri": "localhost:18000", "channel_creds": [{"type": "insecure"}], "server_features": ["xds_v3"]}],
+  "node": {"id": "client-1"}
+}`))
+	if err != nil || b != (Bootstrap{ServerURI: "localhost:18000", NodeID: "client-1"}) {
+		t.Errorf("ParseBootstrap = %+v, %v, want localhost:18000 and client-1", b, err)
+	}
+	for _, data := range []string{
+		`{"node": {"id": "client-1"}}`,
+		`{"xds_servers": [{"server_uri": "localhost:18000", "channel_creds": [{"type": "google_default"}]}]}`,
+	} {
+		if _, err := ParseBootstrap([]byte(data)); err == nil {
+			t.Errorf("ParseBootstrap(%s) succeeded, want an error", data)
+		}
+	}
+}
+
+// TestADS checks the protocol as an xDS client sees it: a response per
+// subscribed type, none for an ACK, and a new version when the resources
+// change.
+func TestADS(t *testing.T) {
+	backend := startBackend(t)
+	store, err := NewStore(canaryConfig(backend, backend, 10))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	bootstrap := startControlPlane(t, store, nil)
+	conn, err := grpc.Dial(bootstrap.ServerURI, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := xdspb.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		t.Fatalf("StreamAggregatedResources failed: %v", err)
+	}
+	send := func(req *xdspb.DiscoveryRequest) {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	recv := func(typ string) *xdspb.DiscoveryResponse {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if resp.TypeUrl != typ {
+			t.Fatalf("got a response of %s, want %s", resp.TypeUrl, typ)
+		}
+		return resp
+	}
+
+	send(&xdspb.DiscoveryRequest{Node: &xdspb.Node{Id: "test"}, TypeUrl: listenerType, ResourceNames: []string{"echo"}})
+	lds := recv(listenerType)
+	l := &xdspb.Listener{}
+	if len(lds.Resources) != 1 || lds.Resources[0].UnmarshalTo(l) != nil {
+		t.Fatalf("LDS response = %v, want the listener echo", lds)
+	}
+	if name, _, err := routeConfigNameOf(l); err != nil || name != "echo-routes" {
+		t.Errorf("route config of the listener = %q, %v, want echo-routes", name, err)
+	}
+	send(&xdspb.DiscoveryRequest{TypeUrl: listenerType, ResourceNames: []string{"echo"}, VersionInfo: lds.VersionInfo, ResponseNonce: lds.Nonce})
+
+	// Clusters asked for without names are all of them.
+	send(&xdspb.DiscoveryRequest{TypeUrl: clusterType})
+	cds := recv(clusterType)
+	if len(cds.Resources) != 2 {
+		t.Errorf("CDS response has %d clusters, want 2", len(cds.Resources))
+	}
+	// A NACK gets no new response until the resources change.
+	send(&xdspb.DiscoveryRequest{TypeUrl: clusterType, ResponseNonce: cds.Nonce, ErrorDetail: &xdspb.Status{Code: int32(codes.InvalidArgument), Message: "test"}})
+
+	send(&xdspb.DiscoveryRequest{TypeUrl: endpointsType, ResourceNames: []string{"canary"}})
+	eds := recv(endpointsType)
+	cla := &xdspb.ClusterLoadAssignment{}
+	if len(eds.Resources) != 1 || eds.Resources[0].UnmarshalTo(cla) != nil {
+		t.Fatalf("EDS response = %v, want the endpoints of canary", eds)
+	}
+	if got, err := endpointsOf(cla); err != nil || len(got) != 1 || got[0].Addr != backend {
+		t.Errorf("endpoints of canary = %v, %v, want %s", got, err, backend)
+	}
+	send(&xdspb.DiscoveryRequest{TypeUrl: endpointsType, ResourceNames: []string{"canary"}, VersionInfo: eds.VersionInfo, ResponseNonce: eds.Nonce})
+
+	// Only the clusters changed.
+	cfg := canaryConfig(backend, backend, 10)
+	cfg.Clusters[0].LBPolicy = `[{"pick_first": {}}]`
+	if err := store.Set(cfg); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if resp := recv(clusterType); resp.VersionInfo == cds.VersionInfo {
+		t.Errorf("CDS version after a change = %s, want a new one", resp.VersionInfo)
+	}
+}
+
+func TestRejectUnsupportedResources(t *testing.T) {
+	r := &xdsResolver{target: "echo", clusters: make(map[string]*xdspb.Cluster)}
+	for _, resp := range []*xdspb.DiscoveryResponse{
+		{TypeUrl: clusterType, Resources: []*anypb.Any{marshalAny(clusterType, &xdspb.Cluster{Name: "dns", ClusterDiscoveryType: &xdspb.Cluster_Type{Type: xdspb.Cluster_LOGICAL_DNS}})}},
+		{TypeUrl: clusterType, Resources: []*anypb.Any{marshalAny(listenerType, &xdspb.Listener{Name: "echo"})}},
+		{TypeUrl: listenerType, Resources: []*anypb.Any{marshalAny(listenerType, &xdspb.Listener{Name: "echo"})}},
+		{TypeUrl: routeConfigType, Resources: []*anypb.Any{marshalAny(routeConfigType, &xdspb.RouteConfiguration{Name: "r", VirtualHosts: []*xdspb.VirtualHost{{Domains: []string{"other"}}}})}},
+	} {
+		if err := r.apply(resp); err == nil {
+			t.Errorf("apply(%v) succeeded, want an error", resp)
+		}
+	}
+}